@@ -0,0 +1,59 @@
+package actions
+
+import (
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/schema"
+)
+
+// ExitSubscriptionExpired is an exit code of 'fvpn account status' when the subscription of the user is expired,
+// so cron jobs could tell it apart from other errors.
+const ExitSubscriptionExpired = 3
+
+// ExitSessionExpired is an exit code of any command once the back-end rejects the token of the profile, e.g. after the password change,
+// so the scripts could tell the need to log in again from other failures.
+const ExitSessionExpired = 4
+
+// AccountStatus is a structure representing the account of the user printed by 'fvpn account status --json'.
+// Its fields are described by the account-status schema of the schema package.
+type AccountStatus struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Email         string    `json:"email"`
+	Plan          string    `json:"plan"`
+	ExpiryDate    time.Time `json:"expiry_date"`
+	Expired       bool      `json:"expired"`
+	EmailVerified bool      `json:"email_verified"`
+	Devices       int       `json:"devices"`
+}
+
+// GetAccountStatus is a method to collect the subscription, verification state and the number of devices of the user.
+func (w AuthClientWrapper) GetAccountStatus(profile *auth.Profile) (AccountStatus, error) {
+	status := AccountStatus{SchemaVersion: schema.Version, Email: string(profile.Email)}
+
+	b, err := w.GetUnexpiredOrMostRecentBillingFeature(profile.ID)
+	if err != nil {
+		return status, err
+	}
+
+	bid := b.GetBundleId()
+	status.Plan = bid[strings.LastIndex(bid, ".")+1:]
+	status.ExpiryDate = b.GetExpiryDate()
+	status.Expired = time.Now().After(status.ExpiryDate)
+
+	user, err := w.ApiClient.GetUser()
+	if err != nil {
+		return status, err
+	}
+
+	status.EmailVerified = user.GetEmailVerified()
+
+	devices, err := w.ApiClient.ListDevices()
+	if err != nil {
+		return status, err
+	}
+
+	status.Devices = len(devices)
+	return status, nil
+}
@@ -0,0 +1,237 @@
+package actions_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+func TestQuotaAdd(t *testing.T) {
+	june := time.Date(2023, 6, 30, 23, 0, 0, 0, time.UTC)
+	july := time.Date(2023, 7, 1, 1, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		name     string
+		quota    actions.Quota
+		rx, tx   int64
+		now      time.Time
+		expected actions.Quota
+		reached  int
+	}{
+		{
+			name:     "first record",
+			quota:    actions.Quota{Limit: 1000},
+			rx:       100,
+			tx:       50,
+			now:      june,
+			expected: actions.Quota{Limit: 1000, Month: "2023-06", Rx: 100, Tx: 50, LastRx: 100, LastTx: 50},
+		},
+		{
+			name:     "counters grow",
+			quota:    actions.Quota{Limit: 1000, Month: "2023-06", Rx: 100, Tx: 50, LastRx: 100, LastTx: 50},
+			rx:       300,
+			tx:       150,
+			now:      june,
+			expected: actions.Quota{Limit: 1000, Month: "2023-06", Rx: 300, Tx: 150, LastRx: 300, LastTx: 150},
+		},
+		{
+			name:     "counters reset by a new interface",
+			quota:    actions.Quota{Limit: 1000, Month: "2023-06", Rx: 300, Tx: 150, LastRx: 300, LastTx: 150},
+			rx:       20,
+			tx:       10,
+			now:      june,
+			expected: actions.Quota{Limit: 1000, Month: "2023-06", Rx: 320, Tx: 160, LastRx: 20, LastTx: 10},
+		},
+		{
+			name:     "month rollover",
+			quota:    actions.Quota{Limit: 1000, Month: "2023-06", Rx: 700, Tx: 200, Warned: 90, LastRx: 700, LastTx: 200},
+			rx:       750,
+			tx:       210,
+			now:      july,
+			expected: actions.Quota{Limit: 1000, Month: "2023-07", Rx: 50, Tx: 10, LastRx: 750, LastTx: 210},
+		},
+		{
+			name:     "first threshold",
+			quota:    actions.Quota{Limit: 1000, Month: "2023-06", Rx: 700, LastRx: 700},
+			rx:       810,
+			now:      june,
+			expected: actions.Quota{Limit: 1000, Month: "2023-06", Rx: 810, Warned: 80, LastRx: 810},
+			reached:  80,
+		},
+		{
+			name:     "threshold already warned",
+			quota:    actions.Quota{Limit: 1000, Month: "2023-06", Rx: 810, Warned: 80, LastRx: 810},
+			rx:       850,
+			now:      june,
+			expected: actions.Quota{Limit: 1000, Month: "2023-06", Rx: 850, Warned: 80, LastRx: 850},
+		},
+		{
+			name:     "thresholds skipped at once",
+			quota:    actions.Quota{Limit: 1000, Month: "2023-06", Rx: 500, LastRx: 500},
+			rx:       1200,
+			now:      june,
+			expected: actions.Quota{Limit: 1000, Month: "2023-06", Rx: 1200, Warned: 100, LastRx: 1200},
+			reached:  100,
+		},
+		{
+			name:     "usage only tracked",
+			quota:    actions.Quota{Month: "2023-06"},
+			rx:       5000,
+			now:      june,
+			expected: actions.Quota{Month: "2023-06", Rx: 5000, LastRx: 5000},
+		},
+	} {
+		quota := c.quota
+		if reached := quota.Add(c.rx, c.tx, c.now); reached != c.reached {
+			t.Errorf("%s: expected %d reached, got %d", c.name, c.reached, reached)
+		}
+
+		if quota != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, quota)
+		}
+	}
+}
+
+func TestQualityMonitorObserve(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	loss := config.SwitchPolicy{Metric: "loss", Threshold: 5, For: 2 * time.Minute}
+	latency := config.SwitchPolicy{Metric: "latency", Threshold: 200, For: 2 * time.Minute}
+
+	// values are in the unit of the metric of the policy, at every minute from start; -1 is every probe lost
+	for _, c := range []struct {
+		name     string
+		policy   config.SwitchPolicy
+		switched bool
+		values   []float64
+		expected []bool
+	}{
+		{"persistent loss", loss, false, []float64{10, 10, 10}, []bool{false, false, true}},
+		{"healthy", loss, false, []float64{1, 0, 2, 1}, []bool{false, false, false, false}},
+		{"recovered in between", loss, false, []float64{10, 2, 10, 10, 10}, []bool{false, false, false, false, true}},
+		{"hovering below the threshold", loss, false, []float64{10, 4.5, 10}, []bool{false, false, true}},
+		{"persistent latency", latency, false, []float64{250, 300, 201}, []bool{false, false, true}},
+		{"latency at the threshold", latency, false, []float64{200, 200, 200}, []bool{false, false, false}},
+		{"every probe lost", latency, false, []float64{-1, -1, -1}, []bool{false, false, true}},
+		{"hold-down after a switch", loss, true, []float64{10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, []bool{false, false, false, false, false, false, false, false, false, false, false, false, true}},
+	} {
+		monitor := actions.QualityMonitor{Policy: c.policy}
+		if c.switched {
+			monitor.Switched(start)
+		}
+
+		for i, value := range c.values {
+			sample := actions.Sample{At: start.Add(time.Duration(i) * time.Minute)}
+			switch {
+			case value < 0:
+				sample.Loss = 1
+			case c.policy.Metric == "loss":
+				sample.Loss = value / 100
+			default:
+				sample.Latency = value
+			}
+
+			if actual := monitor.Observe(sample); actual != c.expected[i] {
+				t.Errorf("%s: expected %t at minute %d, got %t", c.name, c.expected[i], i, actual)
+			}
+		}
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+
+	assets := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := assets[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	releasesURL, publicKey := actions.ReleasesURL, actions.ReleasePublicKey
+	actions.ReleasesURL, actions.ReleasePublicKey = server.URL+"/", base64.StdEncoding.EncodeToString(public)
+	defer func() { actions.ReleasesURL, actions.ReleasePublicKey = releasesURL, publicKey }()
+
+	binary := filepath.Join(t.TempDir(), "fvpn")
+	if err := os.WriteFile(binary, []byte("released binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		archive  string
+		served   string
+		key      ed25519.PrivateKey
+		expected string
+	}{
+		{name: "released", archive: "released binary", served: "released binary", key: private},
+		{name: "modified archive", archive: "released binary", served: "tampered binary", key: private, expected: "checksum"},
+		{name: "wrong key", archive: "tampered binary", served: "tampered binary", key: other, expected: "signature"},
+		{name: "modified binary", archive: "other binary", served: "other binary", key: private, expected: "doesn't match"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			archive := targz(t, "fvpn", c.archive)
+			manifest := utils.NewReleaseManifest("v1.2.3", map[string]string{"fvpn_linux_amd64.tar.gz": utils.SHA256(archive)}, actions.ReleasesURL)
+			if err := manifest.Sign(c.key); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assets["/v1.2.3/"+actions.ReleaseManifestName] = data
+			assets["/v1.2.3/fvpn_linux_amd64.tar.gz"] = targz(t, "fvpn", c.served)
+
+			err = actions.VerifyFile(binary, "v1.2.3", "linux", "amd64")
+			switch {
+			case len(c.expected) == 0 && err != nil:
+				t.Errorf("expected the binary verified, got %v", err)
+			case len(c.expected) > 0 && (err == nil || !strings.Contains(err.Error(), c.expected)):
+				t.Errorf("expected the error about %s, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+// targz is a function that packs the file named name with content into a tar.gz archive like the ones published by goreleaser.
+func targz(t *testing.T, name string, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
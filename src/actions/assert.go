@@ -0,0 +1,45 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// ExitAssertionFailed is an exit code of 'fvpn assert' when the tunnel is down or the egress country doesn't match.
+const ExitAssertionFailed = 1
+
+// AssertCountry is a method that waits up to timeout for the Wireguard connection to be up and the egress country to be country.
+// It's polled every few seconds, so the assertion could follow 'fvpn state up' right away.
+func (s *State) AssertCountry(country string, timeout time.Duration) error {
+	country = strings.ToUpper(country)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := s.checkCountry(country)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (s *State) checkCountry(country string) error {
+	if !s.GetStatus() {
+		return fmt.Errorf("%s is down", s.WiregaurdInterface)
+	}
+
+	egress, err := utils.EgressCountry()
+	if err != nil {
+		return err
+	}
+
+	if egress != country {
+		return fmt.Errorf("egress country is %s, not %s", egress, country)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/forestvpn/cli/config"
+)
+
+// SwitchHoldDown is a time after switching the location during which the quality is not judged, so the connection doesn't flap between locations.
+const SwitchHoldDown = 10 * time.Minute
+
+// SwitchRecovery is a share of the threshold of the policy the metric has to drop below to count as recovered.
+// Samples between it and the threshold keep the degradation going, so a metric hovering around the threshold doesn't reset it.
+const SwitchRecovery = 0.8
+
+// QualityMonitor is a structure that tracks the samples of the connection to detect the quality degraded persistently according to Policy.
+type QualityMonitor struct {
+	Policy config.SwitchPolicy
+	// degradedSince is the time of the first degraded sample not followed by a recovered one.
+	degradedSince time.Time
+	switchedAt    time.Time
+}
+
+// Observe is a method to account the sample and check whether the connection should be switched to another location.
+func (m *QualityMonitor) Observe(sample Sample) bool {
+	if sample.At.Sub(m.switchedAt) < SwitchHoldDown {
+		return false
+	}
+
+	value := m.value(sample)
+	switch {
+	case value > m.Policy.Threshold:
+		if m.degradedSince.IsZero() {
+			m.degradedSince = sample.At
+		}
+	case value < m.Policy.Threshold*SwitchRecovery:
+		m.degradedSince = time.Time{}
+	}
+
+	return !m.degradedSince.IsZero() && sample.At.Sub(m.degradedSince) >= m.Policy.For
+}
+
+// Switched is a method to start the hold-down once the connection is switched at the time given.
+func (m *QualityMonitor) Switched(at time.Time) {
+	m.switchedAt = at
+	m.degradedSince = time.Time{}
+}
+
+// Reset is a method to forget the degradation, e.g. once the connection is set down.
+func (m *QualityMonitor) Reset() {
+	m.degradedSince = time.Time{}
+}
+
+func (m *QualityMonitor) value(sample Sample) float64 {
+	switch m.Policy.Metric {
+	case "loss":
+		return sample.Loss * 100
+	case "jitter":
+		return sample.Jitter
+	}
+
+	// every probe lost tells nothing about the latency, so it's taken as degraded
+	if sample.Loss >= 1 {
+		return m.Policy.Threshold + 1
+	}
+	return sample.Latency
+}
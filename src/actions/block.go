@@ -0,0 +1,114 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// LoadBlocklist is a function that reads the domains blocked with 'fvpn block add' from auth.BlocklistFile.
+// Returns an empty list if nothing has been blocked yet.
+func LoadBlocklist() ([]string, error) {
+	var domains []string
+	data, err := os.ReadFile(auth.AppDir + auth.BlocklistFile)
+	if os.IsNotExist(err) {
+		return domains, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &domains)
+	return domains, err
+}
+
+// NormalizeDomains is a function that lowercases, sorts and deduplicates domains the way they are stored in the blocklist.
+func NormalizeDomains(domains []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if !seen[domain] {
+			seen[domain] = true
+			unique = append(unique, domain)
+		}
+	}
+
+	sort.Strings(unique)
+	return unique
+}
+
+// SaveBlocklist is a function that stores the normalized domains in auth.BlocklistFile.
+func SaveBlocklist(domains []string) ([]string, error) {
+	unique := NormalizeDomains(domains)
+	data, err := json.MarshalIndent(unique, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return unique, os.WriteFile(auth.AppDir+auth.BlocklistFile, data, 0644)
+}
+
+// BlockDomains is a function that adds domains to the blocklist and returns the number of domains added.
+func BlockDomains(domains []string) (int, error) {
+	for _, domain := range domains {
+		if !utils.IsDomain(domain) {
+			return 0, fmt.Errorf("invalid domain: %s", domain)
+		}
+	}
+
+	blocked, err := LoadBlocklist()
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := SaveBlocklist(append(blocked, domains...))
+	return len(updated) - len(blocked), err
+}
+
+// UnblockDomains is a function that removes domains from the blocklist.
+func UnblockDomains(domains []string) error {
+	blocked, err := LoadBlocklist()
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[string]bool)
+	for _, domain := range domains {
+		remove[strings.ToLower(domain)] = true
+	}
+
+	var kept []string
+	for _, domain := range blocked {
+		if remove[domain] {
+			delete(remove, domain)
+		} else {
+			kept = append(kept, domain)
+		}
+	}
+
+	for domain := range remove {
+		return fmt.Errorf("%s is not blocked", domain)
+	}
+
+	_, err = SaveBlocklist(kept)
+	return err
+}
+
+// ApplyBlocklist is a function that enforces the blocklist in the hosts file while connected and lifts it otherwise.
+func ApplyBlocklist(connected bool) error {
+	var domains []string
+	if connected {
+		var err error
+		domains, err = LoadBlocklist()
+		if err != nil {
+			return err
+		}
+	}
+
+	return utils.WriteHostsBlocklist(domains)
+}
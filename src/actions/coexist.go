@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"os"
+	"strings"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// ownInterface is the name of the Wireguard interface wg-quick derives from the configuration file, i.e. fvpn0.
+var ownInterface = strings.TrimSuffix(strings.TrimPrefix(auth.WireguardConfig, "/"), ".conf")
+
+// CoexistingTunnels is a function to find the other VPN tunnels of the host, e.g. Tailscale or a corporate WireGuard interface,
+// the tunnel of the Wireguard interface has to coexist with. Only Linux and macOS are checked.
+func CoexistingTunnels(wiregaurdInterface string) []utils.Tunnel {
+	if utils.Os != "linux" && utils.Os != "darwin" {
+		return nil
+	}
+
+	tunnels, err := utils.Tunnels(wiregaurdInterface)
+	if err != nil {
+		if utils.Verbose {
+			utils.InfoLogger.Println(err)
+		}
+		return nil
+	}
+
+	return tunnels
+}
+
+// coexistNetworks is a function that lists the networks of the tunnels to keep out of the own one, e.g. the tailnet.
+// The default routes are left, as they're handled with the routing table of the tunnel, see LoadRouting.
+func coexistNetworks(tunnels []utils.Tunnel) []string {
+	var networks []string
+	for _, tunnel := range tunnels {
+		for _, network := range tunnel.Networks {
+			if network != "0.0.0.0/0" && network != "::/0" {
+				networks = append(networks, network)
+			}
+		}
+	}
+	return networks
+}
+
+// coexistDefault is a function to check whether any of the tunnels takes all the traffic, so the own one needs its routing table.
+func coexistDefault(tunnels []utils.Tunnel) bool {
+	for _, tunnel := range tunnels {
+		if tunnel.Default() {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveCoexistence is a method to check the Wireguard configuration of the user with id value of given user id
+// against the other tunnels of the host right before setting the connection up, as they could have started since it was written.
+// The configuration is written anew to keep their networks out and route around them. Returns the coexisting tunnels.
+func (w AuthClientWrapper) ResolveCoexistence(device *forestvpn_api.Device, userID auth.ProfileID, wiregaurdInterface string) ([]utils.Tunnel, error) {
+	tunnels := CoexistingTunnels(wiregaurdInterface)
+	if len(tunnels) == 0 {
+		// the table kept for the tunnel that took all the traffic is dropped once it's gone
+		c, err := config.Load()
+		if err != nil || c.Get(config.RoutingTable) != "off" {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.WireguardConfig)
+		if err != nil || len(configTable(string(data))) == 0 {
+			return nil, err
+		}
+	}
+
+	return tunnels, w.SetLocation(device, userID)
+}
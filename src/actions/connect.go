@@ -0,0 +1,211 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// HandshakeTimeout is a time to wait for the first handshake with the peer after the connection is set up.
+const HandshakeTimeout = 10 * time.Second
+
+// InternetCheckTimeout is a time 'fvpn state up --require-internet-check' waits for an HTTPS request to get through the connection.
+const InternetCheckTimeout = 15 * time.Second
+
+// AwaitHandshake is a method that waits until the Wireguard interface handshakes with its peers, but no longer than timeout.
+// A keepalive is sent to the peers to trigger the handshake without waiting for the traffic.
+func (s *State) AwaitHandshake(device *forestvpn_api.Device, timeout time.Duration) bool {
+	return s.AwaitHandshakeSince(device, time.Time{}, timeout)
+}
+
+// AwaitHandshakeSince is a method that waits until the Wireguard interface handshakes with its peers after since, but no longer than timeout.
+func (s *State) AwaitHandshakeSince(device *forestvpn_api.Device, since time.Time, timeout time.Duration) bool {
+	// the fake connection has no interface to handshake
+	if utils.Fake {
+		return true
+	}
+
+	for _, peer := range device.Wireguard.GetPeers() {
+		_ = utils.Run("wg", "set", s.WiregaurdInterface, "peer", peer.GetPubKey(), "persistent-keepalive", "1")
+	}
+
+	keepalive := Keepalive()
+	defer func() {
+		for _, peer := range device.Wireguard.GetPeers() {
+			_ = utils.Run("wg", "set", s.WiregaurdInterface, "peer", peer.GetPubKey(), "persistent-keepalive", keepalive)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if handshake, err := utils.WireguardLatestHandshake(s.WiregaurdInterface); err == nil && !handshake.IsZero() && handshake.After(since) {
+			return true
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return false
+}
+
+// RetryEndpoints is a method that points the peers of the Wireguard interface to the alternative endpoints of the location one by one
+// until the handshake succeeds. Returns false if none of the endpoints responded.
+func (s *State) RetryEndpoints(device *forestvpn_api.Device, timeout time.Duration) bool {
+	for _, endpoint := range AlternativeEndpoints(device) {
+		fmt.Fprintf(s.out(), "No handshake, trying %s\n", endpoint)
+
+		for _, peer := range device.Wireguard.GetPeers() {
+			if err := utils.Run("wg", "set", s.WiregaurdInterface, "peer", peer.GetPubKey(), "endpoint", endpoint); err != nil {
+				if utils.Verbose {
+					utils.InfoLogger.Println(err)
+				}
+			}
+		}
+
+		if s.AwaitHandshake(device, timeout) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AlternativeEndpoints is a function that lists the Wireguard endpoints of the servers of the device location,
+// except for the ones the peers are configured with.
+func AlternativeEndpoints(device *forestvpn_api.Device) []string {
+	var endpoints []string
+	seen := map[string]bool{}
+	port := "51820"
+	rewrite := endpointRewriter()
+
+	for _, peer := range device.Wireguard.GetPeers() {
+		seen[rewrite(peer.GetEndpoint())] = true
+		if _, p, err := net.SplitHostPort(peer.GetEndpoint()); err == nil {
+			port = p
+		}
+	}
+
+	add := func(endpoint string) {
+		endpoint = rewrite(endpoint)
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	for _, server := range device.GetServers() {
+		for _, service := range server.GetNetworkServices() {
+			switch service.GetProto() {
+			case "wireguard", "wg", "udp":
+			default:
+				continue
+			}
+
+			if u, err := url.Parse(service.GetConnectionUri()); err == nil && len(u.Port()) > 0 {
+				add(u.Host)
+			} else {
+				add(net.JoinHostPort(server.GetHost(), port))
+			}
+		}
+	}
+
+	return endpoints
+}
+
+// SwitchLocation is a method to move the device of the user to the location and apply it to the running connection, if any.
+func (w AuthClientWrapper) SwitchLocation(userID auth.ProfileID, state *State, location forestvpn_api.Location) (*forestvpn_api.Device, error) {
+	primary, err := auth.LoadDevice(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the running connection could be failed over to the standby device
+	previous, err := ConnectedDevice(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := w.ApiClient.UpdateDevice(primary.GetId(), location.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auth.UpdateProfileDevice(device, userID); err != nil {
+		return nil, err
+	}
+
+	if !utils.IsOpenWRT() {
+		if err := w.SetLocation(device, userID); err != nil {
+			return nil, err
+		}
+	}
+	ClearFailoverEvent(userID)
+
+	if utils.NMConnectionExists(state.WiregaurdInterface) {
+		if err := utils.NMImport(state.WiregaurdInterface, auth.ProfilesDir+string(userID)+auth.WireguardConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if state.GetStatus() {
+		if err := state.Reconfigure(userID, previous, device); err != nil {
+			return nil, err
+		}
+	}
+
+	return device, nil
+}
+
+// FailoverOnConnect is a method that switches the connection that failed to handshake to the next-best locations one by one,
+// trying no more than attempts locations. Returns the location the connection is established with.
+func (w AuthClientWrapper) FailoverOnConnect(userID auth.ProfileID, state *State, premium bool, attempts int) (forestvpn_api.Location, error) {
+	device, err := auth.LoadDevice(userID)
+	if err != nil {
+		return forestvpn_api.Location{}, err
+	}
+
+	locations, err := w.GetLocations()
+	if err != nil {
+		return forestvpn_api.Location{}, err
+	}
+
+	current := device.GetLocation()
+	candidates := GetLocationWrappers(locations)
+
+	for i := 0; i < attempts; i++ {
+		next, found := StandbyLocation(candidates, current, "auto", premium)
+		if !found {
+			break
+		}
+
+		fmt.Fprintf(state.out(), "No handshake, failing over to %s\n", next.Location.GetName())
+		device, err = w.FailOver(userID, state, next.Location)
+		if err != nil {
+			return forestvpn_api.Location{}, err
+		}
+
+		if state.AwaitHandshake(device, HandshakeTimeout) || state.RetryEndpoints(device, HandshakeTimeout) {
+			return next.Location, nil
+		}
+
+		candidates = withoutLocation(candidates, current.GetId())
+		current = next.Location
+	}
+
+	return forestvpn_api.Location{}, errors.New("no location responded")
+}
+
+func withoutLocation(locations []LocationWrapper, id string) []LocationWrapper {
+	var result []LocationWrapper
+	for _, loc := range locations {
+		if loc.Location.GetId() != id {
+			result = append(result, loc)
+		}
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+)
+
+// CountriesCacheTTL is a time the cached countries are considered fresh.
+const CountriesCacheTTL = 7 * 24 * time.Hour
+
+// GetCountries is a method to get the countries from the local cache, refreshing the cache from back-end once it's older than CountriesCacheTTL.
+// If back-end is unavailable, the stale cache is used.
+func (w AuthClientWrapper) GetCountries() ([]forestvpn_api.Country, error) {
+	path := filepath.Join(auth.AppDir, auth.CountriesFile)
+
+	fStat, statErr := os.Stat(path)
+	if statErr == nil && time.Since(fStat.ModTime()) < CountriesCacheTTL {
+		if countries, err := loadCountries(path); err == nil {
+			return countries, nil
+		}
+	}
+
+	countries, err := w.ApiClient.GetCountries()
+	if err != nil {
+		if statErr == nil {
+			return loadCountries(path)
+		}
+		return nil, err
+	}
+
+	data, err := json.Marshal(countries)
+	if err != nil {
+		return nil, err
+	}
+
+	return countries, auth.JsonDump(data, path)
+}
+
+func loadCountries(path string) ([]forestvpn_api.Country, error) {
+	var countries []forestvpn_api.Country
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return countries, json.Unmarshal(data, &countries)
+}
+
+// countryFlags is a function that maps country ids to their flag emojis.
+func countryFlags(countries []forestvpn_api.Country) map[string]string {
+	flags := make(map[string]string, len(countries))
+	for _, c := range countries {
+		flags[c.GetId()] = c.GetEmoji()
+	}
+	return flags
+}
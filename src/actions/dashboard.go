@@ -0,0 +1,167 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// DashboardWindow is a time span of the quality graph of 'fvpn dashboard'.
+const DashboardWindow = time.Hour
+
+// Dashboard is a structure holding the state of the connection rendered full-screen by 'fvpn dashboard', e.g. on the display of a gateway.
+type Dashboard struct {
+	State  *State
+	UserID auth.ProfileID
+	// Target is an address probed through the tunnel on every Update to draw the quality graph.
+	Target string
+
+	Connected bool
+	Location  string
+	Handshake time.Time
+	Rx        int64
+	Tx        int64
+	// RxRate and TxRate are in bytes per second since the previous Update.
+	RxRate float64
+	TxRate float64
+	// Samples are the quality samples of the last DashboardWindow, both recorded by 'fvpn monitor start' and taken by the dashboard.
+	Samples []Sample
+	// Traffic are the transfer rates of the last DashboardWindow taken by the dashboard.
+	Traffic []TrafficSample
+	Quota   Quota
+	Updated time.Time
+}
+
+// TrafficSample is a structure of the transfer rates of the connection at a point in time, in bytes per second.
+type TrafficSample struct {
+	At     time.Time `json:"at"`
+	RxRate float64   `json:"rx_rate"`
+	TxRate float64   `json:"tx_rate"`
+}
+
+// Update is a method to refresh the dashboard from the running interface and the local files.
+func (d *Dashboard) Update() error {
+	now := time.Now()
+	if len(d.Samples) == 0 {
+		samples, err := LoadSamples(d.UserID, now.Add(-DashboardWindow))
+		if err != nil {
+			return err
+		}
+		d.Samples = samples
+	}
+
+	d.Connected = d.State.GetStatus()
+	device, err := ConnectedDevice(d.UserID)
+	if err != nil {
+		return err
+	}
+
+	location := device.GetLocation()
+	country := location.GetCountry()
+	d.Location = fmt.Sprintf("%s, %s", location.GetName(), country.GetName())
+
+	if d.Connected {
+		rx, tx, err := utils.WireguardTransfer(d.State.WiregaurdInterface)
+		if err == nil {
+			// the counters start over once the interface is set up anew
+			if !d.Updated.IsZero() && rx >= d.Rx && tx >= d.Tx {
+				elapsed := now.Sub(d.Updated).Seconds()
+				d.RxRate, d.TxRate = float64(rx-d.Rx)/elapsed, float64(tx-d.Tx)/elapsed
+				d.Traffic = append(d.Traffic, TrafficSample{At: now, RxRate: d.RxRate, TxRate: d.TxRate})
+			}
+			d.Rx, d.Tx = rx, tx
+		}
+
+		d.Handshake, _ = utils.WireguardLatestHandshake(d.State.WiregaurdInterface)
+
+		sample, err := d.State.TakeSample(d.UserID, d.Target, 1)
+		if err == nil {
+			d.Samples = append(d.Samples, sample)
+		}
+	} else {
+		d.Rx, d.Tx, d.RxRate, d.TxRate = 0, 0, 0, 0
+	}
+
+	for len(d.Samples) > 0 && d.Samples[0].At.Before(now.Add(-DashboardWindow)) {
+		d.Samples = d.Samples[1:]
+	}
+	for len(d.Traffic) > 0 && d.Traffic[0].At.Before(now.Add(-DashboardWindow)) {
+		d.Traffic = d.Traffic[1:]
+	}
+
+	d.Quota, err = LoadQuota(d.UserID)
+	if err != nil {
+		return err
+	}
+
+	if d.Connected {
+		// the data not recorded by 'fvpn daemon' yet, the quota isn't saved back
+		d.Quota.Add(d.Rx, d.Tx, now)
+	}
+	d.Updated = now
+
+	return nil
+}
+
+// Render is a method to draw the dashboard to fit the screen of columns and rows.
+func (d *Dashboard) Render(columns int, rows int) string {
+	var lines []string
+	lines = append(lines, "ForestVPN", "")
+
+	if d.Connected {
+		lines = append(lines, "Connected to "+d.Location)
+		if !d.Handshake.IsZero() {
+			lines = append(lines, fmt.Sprintf("Last handshake %s ago", utils.HumanizeDuration(time.Since(d.Handshake))))
+		}
+		lines = append(lines,
+			fmt.Sprintf("Received %s (%s/s)", utils.FormatBytes(d.Rx), utils.FormatBytes(int64(d.RxRate))),
+			fmt.Sprintf("Sent     %s (%s/s)", utils.FormatBytes(d.Tx), utils.FormatBytes(int64(d.TxRate))),
+		)
+	} else {
+		lines = append(lines, "Disconnected", "Last location "+d.Location)
+	}
+
+	lines = append(lines, "")
+	latencies := make([]float64, 0, len(d.Samples))
+	for _, sample := range d.Samples {
+		if sample.Loss >= 1 {
+			latencies = append(latencies, -1)
+		} else {
+			latencies = append(latencies, sample.Latency)
+		}
+	}
+
+	if len(d.Samples) > 0 {
+		last := d.Samples[len(d.Samples)-1]
+		lines = append(lines, fmt.Sprintf("Latency of the last %s: %.0f ms now, %.1f%% loss", utils.HumanizeDuration(DashboardWindow), last.Latency, last.Loss*100))
+		lines = append(lines, utils.Sparkline(latencies, columns))
+	} else {
+		lines = append(lines, "No quality samples yet")
+	}
+
+	lines = append(lines, "")
+	if d.Quota.Limit > 0 {
+		lines = append(lines,
+			fmt.Sprintf("Quota %s of %s used this month", utils.FormatBytes(d.Quota.Used()), utils.FormatBytes(d.Quota.Limit)),
+			fmt.Sprintf("%s %d%%", utils.ProgressBar(d.Quota.Percent(), columns-8), d.Quota.Percent()),
+		)
+	} else {
+		lines = append(lines, fmt.Sprintf("%s used this month", utils.FormatBytes(d.Quota.Used())))
+	}
+
+	if len(lines) > rows-1 {
+		lines = lines[:rows-1]
+	}
+
+	lines = append(lines, "Updated "+utils.FormatTime(d.Updated))
+	for i, line := range lines {
+		if runes := []rune(line); len(runes) > columns {
+			lines[i] = string(runes[:columns])
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
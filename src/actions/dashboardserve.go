@@ -0,0 +1,213 @@
+package actions
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// DashboardHistoryKept is the number of the latest transitions of the connection history shown by 'fvpn dashboard serve'.
+const DashboardHistoryKept = 20
+
+// DashboardStatus is a structure of the state of the connection served as JSON at /status.json by 'fvpn dashboard serve'.
+type DashboardStatus struct {
+	Connected       bool       `json:"connected"`
+	Location        string     `json:"location"`
+	LatestHandshake *time.Time `json:"latest_handshake,omitempty"`
+	RxBytes         int64      `json:"rx_bytes"`
+	TxBytes         int64      `json:"tx_bytes"`
+	// RxRate and TxRate are in bytes per second.
+	RxRate float64 `json:"rx_rate"`
+	TxRate float64 `json:"tx_rate"`
+	// QuotaUsed is the data used this month, and QuotaLimit is the quota set with 'fvpn quota set', or 0 if there is none.
+	QuotaUsed  int64           `json:"quota_used"`
+	QuotaLimit int64           `json:"quota_limit"`
+	Samples    []Sample        `json:"samples"`
+	Traffic    []TrafficSample `json:"traffic"`
+	History    []HistoryEntry  `json:"history"`
+	Updated    time.Time       `json:"updated"`
+}
+
+// DashboardServer is a structure serving the dashboard read-only over HTTP, so the connection of a headless machine is checked
+// from a browser on the LAN. The page is served at / and the DashboardStatus at /status.json.
+type DashboardServer struct {
+	Board *Dashboard
+	// Refresh is the time after which the page reloads itself, usually the interval of the updates.
+	Refresh time.Duration
+	mu      sync.Mutex
+}
+
+// Update is a method to refresh the dashboard, safe to call while it's served.
+func (s *DashboardServer) Update() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Board.Update()
+}
+
+// Status is a method to get the state of the connection along with the latest transitions of the connection history.
+func (s *DashboardServer) Status() (DashboardStatus, error) {
+	history, err := LoadHistory(s.Board.UserID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return DashboardStatus{}, err
+	}
+	if len(history) > DashboardHistoryKept {
+		history = history[len(history)-DashboardHistoryKept:]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.Board
+	status := DashboardStatus{
+		Connected:  d.Connected,
+		Location:   d.Location,
+		RxBytes:    d.Rx,
+		TxBytes:    d.Tx,
+		RxRate:     d.RxRate,
+		TxRate:     d.TxRate,
+		QuotaUsed:  d.Quota.Used(),
+		QuotaLimit: d.Quota.Limit,
+		Samples:    append([]Sample{}, d.Samples...),
+		Traffic:    append([]TrafficSample{}, d.Traffic...),
+		History:    append([]HistoryEntry{}, history...),
+		Updated:    d.Updated,
+	}
+	if d.Connected && !d.Handshake.IsZero() {
+		handshake := d.Handshake
+		status.LatestHandshake = &handshake
+	}
+
+	return status, nil
+}
+
+// ServeHTTP is a method that implements http.Handler. Only GET and HEAD requests are served, nothing is ever changed.
+func (s *DashboardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "The dashboard is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path != "/" && r.URL.Path != "/status.json" {
+		http.NotFound(w, r)
+		return
+	}
+
+	status, err := s.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Path == "/status.json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = dashboardPage.Execute(w, dashboardPageData{DashboardStatus: status, Refresh: int(s.Refresh.Seconds())})
+}
+
+// dashboardPageData is a structure of the data rendered by dashboardPage.
+type dashboardPageData struct {
+	DashboardStatus
+	Refresh int
+}
+
+// dashboardGraphWidth and dashboardGraphHeight are the size of the graphs of dashboardPage.
+const (
+	dashboardGraphWidth  = 600
+	dashboardGraphHeight = 80
+)
+
+// dashboardPage is a template of the page of 'fvpn dashboard serve', kept without scripts, so it works in any browser.
+var dashboardPage = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"bytes": utils.FormatBytes,
+	"rate": func(rate float64) string {
+		return utils.FormatBytes(int64(rate)) + "/s"
+	},
+	"time": utils.FormatTime,
+	"ago": func(t *time.Time) string {
+		return utils.HumanizeDuration(time.Since(*t).Round(time.Second))
+	},
+	"percent": func(used int64, limit int64) int64 {
+		return used * 100 / limit
+	},
+	"latencies": func(samples []Sample) string {
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			if sample.Loss >= 1 {
+				values = append(values, -1)
+			} else {
+				values = append(values, sample.Latency)
+			}
+		}
+		return utils.SVGPoints(values, dashboardGraphWidth, dashboardGraphHeight)
+	},
+	"received": func(traffic []TrafficSample) string {
+		values := make([]float64, 0, len(traffic))
+		for _, sample := range traffic {
+			values = append(values, sample.RxRate)
+		}
+		return utils.SVGPoints(values, dashboardGraphWidth, dashboardGraphHeight)
+	},
+	"sent": func(traffic []TrafficSample) string {
+		values := make([]float64, 0, len(traffic))
+		for _, sample := range traffic {
+			values = append(values, sample.TxRate)
+		}
+		return utils.SVGPoints(values, dashboardGraphWidth, dashboardGraphHeight)
+	},
+	"window": func() string {
+		return utils.HumanizeDuration(DashboardWindow)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if gt .Refresh 0}}<meta http-equiv="refresh" content="{{.Refresh}}">{{end}}
+<title>ForestVPN {{if .Connected}}connected{{else}}disconnected{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 640px; margin: 2em auto; padding: 0 1em; color: #222; }
+.up { color: #1a7f37; } .down { color: #cf222e; }
+svg { width: 100%; height: auto; background: #f6f8fa; }
+polyline { fill: none; stroke-width: 1.5; }
+table { border-collapse: collapse; width: 100%; } td { padding: 0.2em 0.5em 0.2em 0; }
+small { color: #666; }
+</style>
+</head>
+<body>
+<h1>ForestVPN</h1>
+{{if .Connected}}
+<p class="up">Connected to {{.Location}}</p>
+{{with .LatestHandshake}}<p>Last handshake {{ago .}} ago</p>{{end}}
+<p>Received {{bytes .RxBytes}} ({{rate .RxRate}}), sent {{bytes .TxBytes}} ({{rate .TxRate}})</p>
+{{else}}
+<p class="down">Disconnected</p>
+<p>Last location {{.Location}}</p>
+{{end}}
+{{if .QuotaLimit}}<p>Quota {{bytes .QuotaUsed}} of {{bytes .QuotaLimit}} used this month, {{percent .QuotaUsed .QuotaLimit}}%</p>
+{{else}}<p>{{bytes .QuotaUsed}} used this month</p>{{end}}
+<h2>Latency of the last {{window}}</h2>
+{{if .Samples}}<svg viewBox="0 0 600 80" role="img"><polyline stroke="#0969da" points="{{latencies .Samples}}"/></svg>
+{{else}}<p>No quality samples yet</p>{{end}}
+<h2>Traffic of the last {{window}}</h2>
+{{if .Traffic}}<svg viewBox="0 0 600 80" role="img"><polyline stroke="#1a7f37" points="{{received .Traffic}}"/><polyline stroke="#bf8700" points="{{sent .Traffic}}"/></svg>
+<p><small>Green is received, yellow is sent</small></p>
+{{else}}<p>No traffic yet</p>{{end}}
+<h2>History</h2>
+{{if .History}}<table>
+{{range .History}}<tr><td>{{time .At}}</td><td>{{.Event}}</td><td>{{.Location}}</td><td>{{.User}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+{{else}}<p>No transitions in the last 24 hours</p>{{end}}
+<p><small>Updated {{time .Updated}}, also served as JSON at <a href="status.json">status.json</a></small></p>
+</body>
+</html>
+`))
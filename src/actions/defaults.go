@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// ApplyAccountDefaults is a method to carry the defaults of the account set in the mobile apps over to this machine on login:
+// the device of the user with id value of given user id is moved to the preferred location, or the best one of the preferred region,
+// if the plan allows it. The kill switch is enabled per connection and the DNS filter has no counterpart in fvpn, so they're only reported.
+// Returns the notes on what was applied or skipped.
+func (w AuthClientWrapper) ApplyAccountDefaults(userID auth.ProfileID) ([]string, error) {
+	var notes []string
+	settings, err := w.ApiClient.GetAccountSettings()
+	if err != nil {
+		return notes, err
+	}
+
+	if settings.KillSwitch {
+		notes = append(notes, "The kill switch of the account isn't turned on by login, connect with 'fvpn state up --killswitch'")
+	}
+	if len(settings.DNSFilter) > 0 {
+		notes = append(notes, fmt.Sprintf("The DNS filter of the account (%s) isn't supported by fvpn, skipped", settings.DNSFilter))
+	}
+
+	if len(settings.Location) == 0 && len(settings.Region) == 0 {
+		return notes, nil
+	}
+
+	locations, err := w.GetLocations()
+	if err != nil {
+		return notes, err
+	}
+
+	entitlements, err := w.GetEntitlements(userID)
+	if err != nil {
+		return notes, err
+	}
+
+	wrappers := entitlements.Apply(GetLocationWrappers(locations))
+	location, found := FindLocation(wrappers, settings.Location)
+	if !found {
+		location, found = bestInRegion(wrappers, settings.Region)
+	}
+
+	switch {
+	case !found:
+		return append(notes, fmt.Sprintf("The preferred location of the account (%s) is not available, skipped", preferred(settings.Location, settings.Region))), nil
+	case !location.Usable:
+		return append(notes, fmt.Sprintf("The preferred location of the account (%s) is not allowed by your plan, skipped", location.Location.GetName())), nil
+	}
+
+	device, err := auth.LoadDevice(userID)
+	if err != nil {
+		return notes, err
+	}
+
+	current := device.GetLocation()
+	if current.GetId() != location.Location.GetId() {
+		if device, err = w.ApiClient.UpdateDevice(device.GetId(), location.Location.GetId()); err != nil {
+			return notes, err
+		}
+
+		if err := auth.UpdateProfileDevice(device, userID); err != nil {
+			return notes, err
+		}
+	}
+
+	country := location.Location.GetCountry()
+	return append(notes, fmt.Sprintf("Location is set to %s, %s as preferred by the account", location.Location.GetName(), country.GetName())), nil
+}
+
+// bestInRegion is a function that picks the usable location of the country with the ISO code or name given with the best quality.
+func bestInRegion(locations []LocationWrapper, region string) (LocationWrapper, bool) {
+	var best LocationWrapper
+	found := false
+	for _, loc := range locations {
+		country := loc.Location.GetCountry()
+		if len(region) == 0 || !loc.Usable || !utils.MatchCountry(region, country.GetId(), country.GetName()) {
+			continue
+		}
+
+		if !found || quality(loc.Location.LatencyRate) > quality(best.Location.LatencyRate) {
+			best, found = loc, true
+		}
+	}
+	return best, found
+}
+
+func quality(latencyRate *float64) float64 {
+	if latencyRate == nil {
+		return DegradedLatencyRate
+	}
+	return *latencyRate
+}
+
+func preferred(location string, region string) string {
+	if len(location) > 0 {
+		return location
+	}
+	return region
+}
@@ -0,0 +1,267 @@
+package actions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ListDevices is a method to print the devices of the user, marking the device of this machine.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/DeviceApi.md#listdevices for more information.
+func (w AuthClientWrapper) ListDevices(userID auth.ProfileID) error {
+	var currentID, standbyID string
+	if device, err := auth.LoadDevice(userID); err == nil {
+		currentID = device.GetId()
+	}
+	if device, err := LoadStandbyDevice(userID); err == nil {
+		standbyID = device.GetId()
+	}
+
+	devices, err := w.ApiClient.ListDevices()
+	if err != nil {
+		return err
+	}
+
+	var data [][]string
+	for _, device := range devices {
+		name := device.GetName()
+		if device.GetId() == currentID {
+			name += " (this device)"
+		} else if device.GetId() == standbyID {
+			name += " (standby of this device)"
+		}
+
+		location := device.GetLocation()
+		lastActive := "never"
+		if t, ok := device.GetLastActiveAtOk(); ok {
+			lastActive = utils.FormatTime(*t)
+		}
+
+		data = append(data, []string{name, device.GetType(), location.GetName(), lastActive, device.GetId()})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Platform", "Location", "Last active", "UUID"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// ListOrgDevices is a method to print the devices of all the members of the organization of the user, e.g. the machines enrolled with 'fvpn enroll',
+// for its admins.
+func (w AuthClientWrapper) ListOrgDevices() error {
+	devices, err := w.ApiClient.ListOrgDevices()
+	if err != nil {
+		return err
+	}
+
+	var data [][]string
+	for _, device := range devices {
+		location := ""
+		if device.Location != nil {
+			location = device.Location.GetName()
+		}
+
+		lastActive := "never"
+		if device.LastActiveAt != nil {
+			lastActive = utils.FormatTime(*device.LastActiveAt)
+		}
+
+		data = append(data, []string{device.Name, device.Owner, device.Type, location, lastActive, device.ID})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Owner", "Platform", "Location", "Last active", "UUID"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// RenameDevice is a method to rename the device of this machine.
+func (w AuthClientWrapper) RenameDevice(userID auth.ProfileID, name string) error {
+	device, err := auth.LoadDevice(userID)
+	if err != nil {
+		return err
+	}
+
+	device, err = w.ApiClient.RenameDevice(device.GetId(), name)
+	if err != nil {
+		return err
+	}
+
+	return auth.UpdateProfileDevice(device, userID)
+}
+
+// ReplaceOldestDevice is a function implementing auth.DeviceLimitHandler that picks the least recently active device to revoke.
+func ReplaceOldestDevice(devices []forestvpn_api.Device) (string, error) {
+	if len(devices) == 0 {
+		return "", errors.New("the account has reached its limit of devices, but has no devices to revoke")
+	}
+
+	oldest := devices[0]
+	for _, device := range devices[1:] {
+		if device.GetLastActiveAt().Before(oldest.GetLastActiveAt()) {
+			oldest = device
+		}
+	}
+
+	fmt.Printf("Revoking the least recently active device %s\n", oldest.GetName())
+	return oldest.GetId(), nil
+}
+
+// PromptDeviceToRevoke is a function implementing auth.DeviceLimitHandler that lets the user pick the device to revoke.
+func PromptDeviceToRevoke(devices []forestvpn_api.Device) (string, error) {
+	if len(devices) == 0 {
+		return "", errors.New("the account has reached its limit of devices, but has no devices to revoke")
+	}
+
+	fmt.Println("The account has reached its limit of devices.")
+
+	var data [][]string
+	for i, device := range devices {
+		lastActive := "never"
+		if t, ok := device.GetLastActiveAtOk(); ok {
+			lastActive = utils.FormatTime(*t)
+		}
+		data = append(data, []string{strconv.Itoa(i + 1), device.GetName(), device.GetType(), lastActive})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"#", "Name", "Platform", "Last active"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	if !utils.IsInteractive() {
+		return "", errors.New("the account has reached its limit of devices, try 'fvpn account login --replace-oldest'")
+	}
+
+	fmt.Printf("Enter the number of the device to revoke (1-%d), or leave empty to cancel: ", len(devices))
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	input = strings.TrimSpace(input)
+	if len(input) == 0 {
+		return "", errors.New("cancelled: the account has reached its limit of devices")
+	}
+
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 1 || n > len(devices) {
+		return "", fmt.Errorf("no such device: %s", input)
+	}
+
+	return devices[n-1].GetId(), nil
+}
+
+// FindDevice is a method to find the device of the user by UUID or name to revoke with RevokeDevice.
+// The device of this machine can't be revoked this way.
+func (w AuthClientWrapper) FindDevice(userID auth.ProfileID, arg string) (forestvpn_api.Device, error) {
+	var found []forestvpn_api.Device
+	devices, err := w.ApiClient.ListDevices()
+	if err != nil {
+		return forestvpn_api.Device{}, err
+	}
+
+	for _, device := range devices {
+		if device.GetId() == arg || strings.EqualFold(device.GetName(), arg) {
+			found = append(found, device)
+		}
+	}
+
+	switch {
+	case len(found) == 0:
+		return forestvpn_api.Device{}, fmt.Errorf("no such device: %s", arg)
+	case len(found) > 1:
+		return forestvpn_api.Device{}, fmt.Errorf("%d devices are named %s, use UUID instead", len(found), arg)
+	}
+
+	if current, err := auth.LoadDevice(userID); err == nil && current.GetId() == found[0].GetId() {
+		return forestvpn_api.Device{}, errors.New("this is the device of this machine, use 'fvpn account logout' instead")
+	}
+
+	return found[0], nil
+}
+
+// RevokeDevice is a method to delete the device found with FindDevice, cutting off its access to the VPN, e.g. after the machine is lost.
+func (w AuthClientWrapper) RevokeDevice(device forestvpn_api.Device) error {
+	return w.ApiClient.DeleteDevice(device.GetId())
+}
+
+// InactiveDevices is a method to find the devices of the user not active for inactiveFor, the ones never active included.
+// The device of this machine and its standby device are always left out.
+func (w AuthClientWrapper) InactiveDevices(userID auth.ProfileID, inactiveFor time.Duration) ([]forestvpn_api.Device, error) {
+	devices, err := w.ApiClient.ListDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var currentID, standbyID string
+	if device, err := auth.LoadDevice(userID); err == nil {
+		currentID = device.GetId()
+	}
+	if device, err := LoadStandbyDevice(userID); err == nil {
+		standbyID = device.GetId()
+	}
+
+	var inactive []forestvpn_api.Device
+	cutoff := time.Now().Add(-inactiveFor)
+	for _, device := range devices {
+		if device.GetId() == currentID || device.GetId() == standbyID {
+			continue
+		}
+
+		if t, ok := device.GetLastActiveAtOk(); !ok || t.Before(cutoff) {
+			inactive = append(inactive, device)
+		}
+	}
+
+	return inactive, nil
+}
+
+// PruneDevices is a method to delete the devices, e.g. found by InactiveDevices. It goes on past the failures
+// and returns the number of the devices deleted along with the first error.
+func (w AuthClientWrapper) PruneDevices(devices []forestvpn_api.Device) (int, error) {
+	var firstErr error
+	deleted := 0
+	for _, device := range devices {
+		if err := w.ApiClient.DeleteDevice(device.GetId()); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to remove %s: %s", device.GetName(), err)
+			}
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, firstErr
+}
+
+// PrintDevices is a function to print the devices with their last activity as a table.
+func PrintDevices(devices []forestvpn_api.Device) {
+	var data [][]string
+	for _, device := range devices {
+		lastActive := "never"
+		if t, ok := device.GetLastActiveAtOk(); ok {
+			lastActive = utils.FormatTime(*t)
+		}
+		data = append(data, []string{device.GetName(), device.GetType(), lastActive, device.GetId()})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Platform", "Last active", "UUID"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
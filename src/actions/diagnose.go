@@ -0,0 +1,103 @@
+package actions
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Leak is a structure describing a connection to the internet that bypasses the Wireguard interface.
+type Leak struct {
+	Process   string
+	Proto     string
+	Remote    string
+	Interface string
+}
+
+// FindLeaks is a method that lists the connected sockets routed to non-LAN destinations through interfaces other than the Wireguard one.
+// The connections to the Wireguard peers are expected to bypass the tunnel and skipped.
+func (s *State) FindLeaks() ([]Leak, error) {
+	var leaks []Leak
+	if !s.GetStatus() {
+		return leaks, fmt.Errorf("%s is down, leaks are only checked while connected", s.WiregaurdInterface)
+	}
+
+	sockets, err := utils.ConnectedSockets()
+	if err != nil {
+		return leaks, err
+	}
+
+	endpoints, err := utils.WireguardEndpoints(s.WiregaurdInterface)
+	if err != nil {
+		return leaks, err
+	}
+
+	var processes map[string]string
+	routes := make(map[string]string)
+	for _, socket := range sockets {
+		if utils.IsLAN(socket.Remote) || containsIP(endpoints, socket.Remote) {
+			continue
+		}
+
+		remote := socket.Remote.String()
+		iface, ok := routes[remote]
+		if !ok {
+			iface, err = utils.RouteInterface(socket.Remote)
+			if err != nil {
+				return leaks, err
+			}
+			routes[remote] = iface
+		}
+
+		if iface == s.WiregaurdInterface {
+			continue
+		}
+
+		if processes == nil {
+			processes = utils.SocketProcesses()
+		}
+
+		process, ok := processes[socket.Inode]
+		if !ok {
+			process = "unknown"
+		}
+
+		leaks = append(leaks, Leak{
+			Process:   process,
+			Proto:     socket.Proto,
+			Remote:    remote + ":" + strconv.Itoa(socket.Port),
+			Interface: iface,
+		})
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].Process < leaks[j].Process
+	})
+
+	return leaks, nil
+}
+
+// PrintLeaks is a function that prints leaks as a table.
+func PrintLeaks(leaks []Leak) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Process", "Proto", "Remote", "Interface"})
+	table.SetBorder(false)
+	for _, leak := range leaks {
+		table.Append([]string{leak.Process, leak.Proto, leak.Remote, leak.Interface})
+	}
+	table.Render()
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+)
+
+// DeviceChange is a structure of a setting changed by moving the device to another location, e.g. its endpoint.
+type DeviceChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// String is a method that formats the change as "Name: Old → New".
+func (c DeviceChange) String() string {
+	return fmt.Sprintf("%s: %s → %s", c.Name, c.Old, c.New)
+}
+
+// DiffDevices is a function to list the changes of the location, endpoint, DNS and AllowedIPs of the WireGuard configuration
+// between oldDevice and device. The location is always listed, the rest only if they differ.
+func DiffDevices(oldDevice *forestvpn_api.Device, device *forestvpn_api.Device) ([]DeviceChange, error) {
+	oldAllowedIPs, err := AllowedIPs(oldDevice)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedIPs, err := AllowedIPs(device)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []DeviceChange{{Name: "Location", Old: locationName(oldDevice.GetLocation()), New: locationName(device.GetLocation())}}
+	for _, c := range []DeviceChange{
+		{Name: "Endpoint", Old: strings.Join(endpoints(oldDevice), ", "), New: strings.Join(endpoints(device), ", ")},
+		{Name: "DNS", Old: strings.Join(oldDevice.GetDns(), ", "), New: strings.Join(device.GetDns(), ", ")},
+		{Name: "AllowedIPs", Old: strings.Join(oldAllowedIPs, ", "), New: strings.Join(allowedIPs, ", ")},
+	} {
+		if c.Old != c.New {
+			changes = append(changes, c)
+		}
+	}
+
+	return changes, nil
+}
+
+func locationName(location forestvpn_api.Location) string {
+	if len(location.GetId()) == 0 {
+		return "-"
+	}
+
+	country := location.GetCountry()
+	return fmt.Sprintf("%s, %s (%s)", location.GetName(), country.GetName(), LocationSlug(location))
+}
+
+func endpoints(device *forestvpn_api.Device) []string {
+	rewrite := endpointRewriter()
+	var result []string
+	for _, peer := range device.Wireguard.GetPeers() {
+		result = append(result, rewrite(peer.GetEndpoint()))
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/dns"
+	"github.com/forestvpn/cli/utils"
+)
+
+// DNSConfigurator is a function to open the backend of the dns-backend setting.
+func DNSConfigurator() (dns.Configurator, error) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return dns.Open(c.Get(config.DNSBackend))
+}
+
+// setDNS is a method to set the DNS servers of the device of the user with id value of given user id once the interface is up,
+// unless they're left to wg-quick. The backend is kept in the profile, so revertDNS uses the same one even if the setting changes meanwhile.
+func (s *State) setDNS(userID auth.ProfileID) error {
+	configurator, err := DNSConfigurator()
+	if err != nil || configurator.Name() == "wg-quick" {
+		return err
+	}
+
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(auth.ProfilesDir+string(userID)+auth.DNSBackendFile, []byte(configurator.Name()), 0644); err != nil {
+		return err
+	}
+
+	if err := configurator.Set(s.WiregaurdInterface, device.GetDns()); err != nil {
+		return fmt.Errorf("failed to set DNS with %s: %s, try another one with 'fvpn config set dns-backend'", configurator.Name(), err)
+	}
+
+	return nil
+}
+
+// revertDNS is a method to restore the DNS of the host with the backend the servers were set with by setDNS, if any.
+func (s *State) revertDNS(userID auth.ProfileID) {
+	path := auth.ProfilesDir + string(userID) + auth.DNSBackendFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	configurator, err := dns.Open(strings.TrimSpace(string(data)))
+	if err == nil {
+		err = configurator.Revert(s.WiregaurdInterface)
+	}
+
+	if err != nil && utils.Verbose {
+		utils.InfoLogger.Println(err)
+	}
+
+	_ = os.Remove(path)
+}
+
+// dnsInConfig is a function to check whether the DNS servers are written to the Wireguard configuration for wg-quick to set.
+func dnsInConfig() bool {
+	configurator, err := DNSConfigurator()
+	return err != nil || configurator.Name() == "wg-quick"
+}
@@ -0,0 +1,81 @@
+package actions
+
+import (
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+)
+
+// LocationConstraint is a namespace of the constraints of the billing features listing the UUIDs of the locations the plan allows, or * for all.
+const LocationConstraint = "location"
+
+// FreeLocations are the UUIDs of the locations of the free plan, used while its billing feature doesn't list them in the constraints.
+var FreeLocations = []string{Helsinki, Falkenstein}
+
+// Entitlements is a structure of the locations the plan of the user allows to connect to.
+type Entitlements struct {
+	// Free is whether the plan is the free one, so the locations it doesn't allow are the premium ones.
+	Free bool
+	// All is whether every location is allowed.
+	All       bool
+	Locations map[string]bool
+}
+
+// NewEntitlements is a factory function that returns the Entitlements of the billing feature b out of its location constraints.
+// Without them, the premium plans allow every location and the free plan allows FreeLocations.
+func NewEntitlements(b forestvpn_api.BillingFeature) Entitlements {
+	e := Entitlements{Free: b.GetBundleId() == "com.forestvpn.freemium", Locations: make(map[string]bool)}
+	constrained := false
+	for _, constraint := range b.GetConstraints() {
+		if constraint.GetNamespace() != LocationConstraint {
+			continue
+		}
+
+		constrained = true
+		for _, subject := range constraint.GetSubject() {
+			if subject == "*" {
+				e.All = true
+			}
+			e.Locations[subject] = true
+		}
+	}
+
+	if constrained {
+		return e
+	}
+
+	if !e.Free {
+		e.All = true
+		return e
+	}
+
+	for _, id := range FreeLocations {
+		e.Locations[id] = true
+	}
+	return e
+}
+
+// Allows is a method to check whether the plan allows to connect to the location with the UUID given.
+func (e Entitlements) Allows(locationID string) bool {
+	return e.All || e.Locations[locationID]
+}
+
+// Apply is a method to mark the locations the plan allows as usable. On the free plan, the rest are marked as premium.
+func (e Entitlements) Apply(locations []LocationWrapper) []LocationWrapper {
+	for i := range locations {
+		locations[i].Usable = e.Allows(locations[i].Location.GetId())
+		if e.Free {
+			locations[i].Premium = !locations[i].Usable
+		}
+	}
+	return locations
+}
+
+// GetEntitlements is a method to get the Entitlements of the plan of the user with id value of given user id.
+func (w AuthClientWrapper) GetEntitlements(userID auth.ProfileID) (Entitlements, error) {
+	b, err := w.GetUnexpiredOrMostRecentBillingFeature(userID)
+	if err != nil {
+		return Entitlements{}, err
+	}
+
+	return NewEntitlements(b), nil
+}
@@ -0,0 +1,71 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// SaveExpiry is a function to store the time the connection of the user with id value of given user id is set down at.
+func SaveExpiry(userID auth.ProfileID, deadline time.Time) error {
+	return os.WriteFile(auth.ProfilesDir+string(userID)+auth.ExpiryFile, []byte(deadline.UTC().Format(time.RFC3339)), 0644)
+}
+
+// LoadExpiry is a function to read the time the connection of the user with id value of given user id is set down at.
+// Returns false unless the connection was set up with 'fvpn state up --for'.
+func LoadExpiry(userID auth.ProfileID) (time.Time, bool) {
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.ExpiryFile)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	deadline, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	return deadline, err == nil
+}
+
+// ClearExpiry is a function to cancel the scheduled disconnect, e.g. once the connection is set up or down by hand.
+// The pending 'fvpn state expire' finds the expiry gone and exits without touching the connection.
+func ClearExpiry(userID auth.ProfileID) {
+	_ = os.Remove(auth.ProfilesDir + string(userID) + auth.ExpiryFile)
+}
+
+// ScheduleExpiry is a function to run 'fvpn state expire' in the background to set the connection down at deadline.
+// It's run as a transient systemd service where available, so it survives the end of the login session,
+// or as a detached process otherwise.
+func ScheduleExpiry(deadline time.Time) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"state", "expire", deadline.UTC().Format(time.RFC3339)}
+	// the fake connections stay in the session, e.g. the CI job, which doesn't outlive them
+	if utils.Os == "linux" && utils.IsAdmin() && !utils.Fake {
+		if _, err := exec.LookPath("systemd-run"); err == nil {
+			unit := fmt.Sprintf("fvpn-expiry-%d", deadline.Unix())
+			// the profiles are looked up in the home directory, which the services don't have by default
+			if err := utils.Run("systemd-run", append([]string{"--unit", unit, "--collect", "--quiet", "--setenv", "HOME=" + os.Getenv("HOME"), executable}, args...)...); err == nil {
+				return nil
+			}
+		}
+	}
+
+	cmd := exec.Command(executable, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// AwaitExpiry is a function that sleeps until deadline and tells whether it still stands,
+// i.e. the connection wasn't set up or down by hand since it was scheduled.
+func AwaitExpiry(userID auth.ProfileID, deadline time.Time) bool {
+	time.Sleep(time.Until(deadline))
+	current, ok := LoadExpiry(userID)
+	return ok && current.Equal(deadline)
+}
@@ -0,0 +1,110 @@
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// FailoverThreshold is an age of the latest handshake after which the peer is considered dead if the sent traffic is not answered.
+// Wireguard repeats the handshake every 2 minutes while there is traffic.
+const FailoverThreshold = 3 * time.Minute
+
+// FailoverEvent is a structure representing a switch of the connection to the standby location.
+type FailoverEvent struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+	// Device is the UUID of the standby device the connection is set up with, see ConnectedDevice.
+	Device string `json:"device,omitempty"`
+}
+
+// HandshakeMonitor is a structure that tracks the Wireguard interface to detect a peer that stopped handshaking.
+type HandshakeMonitor struct {
+	WiregaurdInterface string
+	rx                 int64
+	tx                 int64
+}
+
+// Stalled is a method to check whether the peer stopped handshaking while there is traffic to it.
+// An idle connection never handshakes, so it's only considered stalled when something was sent but nothing was received since the last check.
+func (m *HandshakeMonitor) Stalled() (bool, error) {
+	rx, tx, err := utils.WireguardTransfer(m.WiregaurdInterface)
+	if err != nil {
+		return false, err
+	}
+
+	sending := tx > m.tx && rx == m.rx
+	m.rx, m.tx = rx, tx
+
+	handshake, err := utils.WireguardLatestHandshake(m.WiregaurdInterface)
+	if err != nil {
+		return false, err
+	}
+
+	return sending && time.Since(handshake) > FailoverThreshold, nil
+}
+
+// StandbyLocation is a function to pick the location to switch to from the current one.
+// For auto it picks the location in the same country with the best connection quality, or the best one elsewhere,
+// skipping premium locations unless premium is true.
+func StandbyLocation(locations []LocationWrapper, current forestvpn_api.Location, standby string, premium bool) (LocationWrapper, bool) {
+	if standby != "auto" {
+		return FindLocation(locations, standby)
+	}
+
+	var candidates []LocationWrapper
+	for _, loc := range locations {
+		if loc.Location.GetId() != current.GetId() && (premium || !loc.Premium) {
+			candidates = append(candidates, loc)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return LocationWrapper{}, false
+	}
+
+	country := current.GetCountry()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i].Location.GetCountry(), candidates[j].Location.GetCountry()
+		sameI, sameJ := ci.GetId() == country.GetId(), cj.GetId() == country.GetId()
+		if sameI != sameJ {
+			return sameI
+		}
+		return candidates[i].Location.GetLatencyRate() > candidates[j].Location.GetLatencyRate()
+	})
+
+	return candidates[0], true
+}
+
+// SaveFailoverEvent is a function to store the last failover of the user with id value of given user id.
+func SaveFailoverEvent(userID auth.ProfileID, event FailoverEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return auth.JsonDump(data, auth.ProfilesDir+string(userID)+auth.FailoverFile)
+}
+
+// LoadFailoverEvent is a function to read the last failover of the user with id value of given user id.
+// Returns false if there was no failover since the connection was set up.
+func LoadFailoverEvent(userID auth.ProfileID) (FailoverEvent, bool) {
+	var event FailoverEvent
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.FailoverFile)
+	if err != nil {
+		return event, false
+	}
+
+	return event, json.Unmarshal(data, &event) == nil
+}
+
+// ClearFailoverEvent is a function to forget the last failover once the connection is set up or down.
+func ClearFailoverEvent(userID auth.ProfileID) {
+	_ = os.Remove(auth.ProfilesDir + string(userID) + auth.FailoverFile)
+}
@@ -0,0 +1,121 @@
+package actions
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// HistoryEntry is a structure of a transition of the connection recorded to the connection history for audit.
+type HistoryEntry struct {
+	At time.Time `json:"at"`
+	// Event is up, down or switch for the change of the location of the running connection, or routes for the routes restored by the daemon.
+	Event    string `json:"event"`
+	Location string `json:"location,omitempty"`
+	Country  string `json:"country,omitempty"`
+	// User is the name of the system user who made the transition.
+	User   string `json:"user,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Label is the label of the connection given with 'fvpn state up --label'.
+	Label string `json:"label,omitempty"`
+}
+
+// AppendHistory is a function to append the entry to the connection history of the user with id value of given user id.
+func AppendHistory(userID auth.ProfileID, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(auth.ProfilesDir+string(userID)+auth.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// LoadHistory is a function to read the connection history of the user with id value of given user id recorded since the time given.
+// Returns no entries if nothing has been recorded yet.
+func LoadHistory(userID auth.ProfileID, since time.Time) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	file, err := os.Open(auth.ProfilesDir + string(userID) + auth.HistoryFile)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && !entry.At.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// FilterHistory is a function to get the entries of the connection history of the connections labeled with label.
+func FilterHistory(entries []HistoryEntry, label string) []HistoryEntry {
+	filtered := []HistoryEntry{}
+	for _, entry := range entries {
+		if entry.Label == label {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// PrintHistory is a function to print the entries of the connection history as a table.
+func PrintHistory(entries []HistoryEntry) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Time", "Event", "Location", "Label", "User", "Reason"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, e := range entries {
+		table.Append([]string{utils.FormatTime(e.At), e.Event, strings.Trim(e.Location+", "+e.Country, ", "), e.Label, e.User, e.Reason})
+	}
+
+	table.Render()
+}
+
+// recordHistory is a method to append the transition from the previous state record to the current one to the connection history.
+// Nothing is recorded unless the state or the location has changed.
+func (s *State) recordHistory(userID auth.ProfileID, previous StateRecord) error {
+	record, err := LoadStateRecord()
+	if err != nil {
+		return err
+	}
+
+	if record.State == previous.State && record.Location == previous.Location {
+		return nil
+	}
+
+	entry := HistoryEntry{At: record.Since, Event: record.State, Location: record.Location, Country: record.Country, Reason: s.Reason, Label: record.Label}
+	if record.State == "up" && previous.State == "up" {
+		entry.Event = "switch"
+	} else if record.State == "down" {
+		entry.Location, entry.Country, entry.Label = previous.Location, previous.Country, previous.Label
+	}
+
+	// the connection is usually set up with sudo, so the user behind it is recorded rather than root
+	if entry.User = os.Getenv("SUDO_USER"); len(entry.User) == 0 {
+		if u, err := user.Current(); err == nil {
+			entry.User = u.Username
+		}
+	}
+
+	return AppendHistory(userID, entry)
+}
@@ -0,0 +1,32 @@
+package actions
+
+import "time"
+
+// IdleTraffic is a number of bytes transferred between two observations below which the tunnel is taken as idle,
+// as the keepalives and handshakes never stop.
+const IdleTraffic = 4096
+
+// IdleMonitor is a structure that tracks the transfer counters of the interface to detect no traffic through the tunnel for Timeout.
+type IdleMonitor struct {
+	Timeout  time.Duration
+	lastRx   int64
+	lastTx   int64
+	activeAt time.Time
+}
+
+// Observe is a method to account the transfer counters rx and tx read at the time given
+// and check whether the tunnel has been idle for Timeout.
+func (m *IdleMonitor) Observe(rx int64, tx int64, at time.Time) bool {
+	// the counters start from zero once the interface is set up anew
+	if m.activeAt.IsZero() || rx < m.lastRx || tx < m.lastTx || rx+tx-m.lastRx-m.lastTx >= IdleTraffic {
+		m.activeAt = at
+	}
+
+	m.lastRx, m.lastTx = rx, tx
+	return at.Sub(m.activeAt) >= m.Timeout
+}
+
+// Reset is a method to start tracking anew, e.g. once the connection is set down.
+func (m *IdleMonitor) Reset() {
+	m.activeAt = time.Time{}
+}
@@ -0,0 +1,88 @@
+package actions
+
+import (
+	"net"
+	"os"
+	"regexp"
+
+	"github.com/forestvpn/cli/auth"
+
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// IPv6OnlyNetwork is a function to check whether the endpoints should be rewritten to IPv6 according to the ipv6-only setting.
+func IPv6OnlyNetwork() bool {
+	c, err := config.Load()
+	if err != nil {
+		return utils.IPv6Only()
+	}
+
+	switch c.Get(config.IPv6Only) {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+
+	return utils.IPv6Only()
+}
+
+var endpointPattern = regexp.MustCompile(`(?m)^Endpoint\s*=\s*(\S+)`)
+
+// RefreshEndpoints is a function that rewrites the Wireguard configuration of the user with id value of given user id
+// once its endpoints don't fit the current network anymore, e.g. written on Wi-Fi and connecting on an IPv6-only carrier with NAT64.
+// The configuration is only checked while the interface is down, as the tunnel routes would hide the IPv4 route of the network.
+// Returns true if the configuration was rewritten.
+func RefreshEndpoints(userID auth.ProfileID) (bool, error) {
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.WireguardConfig)
+	if err != nil {
+		return false, err
+	}
+
+	configured := make(map[string]bool)
+	for _, match := range endpointPattern.FindAllStringSubmatch(string(data), -1) {
+		configured[match[1]] = true
+	}
+
+	rewrite := endpointRewriter()
+	for _, peer := range device.Wireguard.GetPeers() {
+		if !configured[rewrite(peer.GetEndpoint())] {
+			return true, AuthClientWrapper{}.SetLocation(device, userID)
+		}
+	}
+
+	return false, nil
+}
+
+// endpointRewriter is a function that returns a function to make the Wireguard endpoints reachable on the current network.
+// The endpoints are returned as they are unless the network is IPv6-only, in which case the NAT64 prefix is discovered once.
+func endpointRewriter() func(endpoint string) string {
+	if !IPv6OnlyNetwork() {
+		return func(endpoint string) string {
+			return endpoint
+		}
+	}
+
+	var prefix net.IP
+	return func(endpoint string) string {
+		if prefix == nil {
+			prefix = utils.NAT64Prefix()
+		}
+
+		rewritten, err := utils.IPv6Endpoint(endpoint, prefix)
+		if err != nil {
+			if utils.Verbose {
+				utils.InfoLogger.Println(err)
+			}
+			return endpoint
+		}
+
+		return rewritten
+	}
+}
@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/actions/killswitch"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// KillSwitchEnabled is a function to check whether the kill switch is enabled for the connection of the user with id value of given user id.
+func KillSwitchEnabled(userID auth.ProfileID) bool {
+	_, err := os.Stat(auth.ProfilesDir + string(userID) + auth.KillSwitchFile)
+	return err == nil
+}
+
+// CheckKillSwitch is a function to check whether the kill switch could be enabled on this host with the tunnel-scope setting,
+// so 'fvpn state up --killswitch' fails before connecting.
+func CheckKillSwitch() error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if c.Get(config.TunnelScope) == "custom" {
+		return errors.New("kill switch requires the tunnel-scope setting all or internet-only, as it would block the networks left out of the tunnel")
+	}
+
+	if utils.Fake {
+		return nil
+	}
+
+	_, err = killswitch.Detect()
+	return err
+}
+
+// EnableKillSwitch is a method to block the traffic of the host outside of the tunnel of the user with id value of given user id,
+// except to the endpoints of the peers and to the networks kept out of the tunnel, e.g. the local ones.
+// Calling it again, e.g. after switching to another location, replaces the rules with the ones of the new endpoints.
+func (s *State) EnableKillSwitch(userID auth.ProfileID) error {
+	if err := CheckKillSwitch(); err != nil {
+		return err
+	}
+
+	path := auth.ProfilesDir + string(userID) + auth.KillSwitchFile
+	if utils.Fake {
+		return os.WriteFile(path, []byte("fake"), 0644)
+	}
+
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return err
+	}
+
+	rules, err := s.killSwitchRules(device)
+	if err != nil {
+		return err
+	}
+
+	// the rules are removed with the same backend they're installed with
+	firewall, err := killSwitchFirewall(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, []byte(firewall.Name()), 0644); err != nil {
+		return err
+	}
+
+	return firewall.Enable(rules)
+}
+
+// DisableKillSwitch is a method to let the traffic of the host outside of the tunnel of the user with id value of given user id through again.
+// It's called by 'fvpn state down' only, so the traffic stays blocked while the connection is down for any other reason.
+func (s *State) DisableKillSwitch(userID auth.ProfileID) error {
+	path := auth.ProfilesDir + string(userID) + auth.KillSwitchFile
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if backend := strings.TrimSpace(string(data)); backend != "fake" {
+		firewall, err := killswitch.Open(backend)
+		if err != nil {
+			return err
+		}
+
+		if err := firewall.Disable(); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(path)
+}
+
+// killSwitchFirewall is a function that opens the backend recorded to the file at path, or detects one, e.g. if it was recorded by the fake backend.
+func killSwitchFirewall(path string) (killswitch.Firewall, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		if firewall, err := killswitch.Open(strings.TrimSpace(string(data))); err == nil {
+			return firewall, nil
+		}
+	}
+
+	return killswitch.Detect()
+}
+
+// killSwitchRules is a method that collects the traffic to let through the kill switch out of the device and the tunnel-scope setting.
+func (s *State) killSwitchRules(device *forestvpn_api.Device) (killswitch.Rules, error) {
+	plan, err := PlanRoutes(device)
+	if err != nil {
+		return killswitch.Rules{}, err
+	}
+
+	rules := killswitch.Rules{Interface: s.WiregaurdInterface, Addresses: device.GetIps()}
+	if name := utils.DarwinInterface(s.WiregaurdInterface); len(name) > 0 {
+		rules.Interface = name
+	}
+
+	for _, exclusion := range plan.Exclusions {
+		rules.Bypass = append(rules.Bypass, exclusion.Network)
+	}
+
+	rewrite := endpointRewriter()
+	for _, peer := range device.Wireguard.GetPeers() {
+		host, port, err := net.SplitHostPort(rewrite(peer.GetEndpoint()))
+		if err != nil {
+			return rules, err
+		}
+
+		addresses := []string{host}
+		if net.ParseIP(host) == nil {
+			if addresses, err = net.LookupHost(host); err != nil {
+				return rules, err
+			}
+		}
+
+		for _, address := range addresses {
+			rules.Endpoints = append(rules.Endpoints, net.JoinHostPort(address, port))
+		}
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,112 @@
+package killswitch
+
+import (
+	"strconv"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// IptablesChain is the chain of the rules of Iptables.
+const IptablesChain = "FVPN-KILLSWITCH"
+
+// Iptables is a structure of the backend filtering the outgoing traffic with a chain of its own jumped to from OUTPUT
+// with iptables and ip6tables on Linux, where nftables isn't installed.
+type Iptables struct{}
+
+// Name is a method that returns iptables.
+func (Iptables) Name() string {
+	return "iptables"
+}
+
+// Enable is a method to fill the chain with the rules and to jump to it from OUTPUT. IPv6 is skipped if ip6tables is missing.
+func (i Iptables) Enable(rules Rules) error {
+	for _, command := range []string{"iptables", "ip6tables"} {
+		if command == "ip6tables" && !installed(command) {
+			continue
+		}
+
+		commands, err := i.Commands(rules, command == "ip6tables")
+		if err != nil {
+			return err
+		}
+
+		// the chain is left from the previous Enable
+		_ = utils.Run(command, "-N", IptablesChain)
+		for _, args := range commands {
+			if err := utils.Run(command, args...); err != nil {
+				return err
+			}
+		}
+
+		if err := utils.Run(command, "-C", "OUTPUT", "-j", IptablesChain); err != nil {
+			if err := utils.Run(command, "-I", "OUTPUT", "1", "-j", IptablesChain); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Disable is a method to remove the jump to the chain and the chain itself.
+func (Iptables) Disable() error {
+	for _, command := range []string{"iptables", "ip6tables"} {
+		if command == "ip6tables" && !installed(command) {
+			continue
+		}
+
+		if err := utils.Run(command, "-C", "OUTPUT", "-j", IptablesChain); err == nil {
+			if err := utils.Run(command, "-D", "OUTPUT", "-j", IptablesChain); err != nil {
+				return err
+			}
+		}
+
+		if err := utils.Run(command, "-F", IptablesChain); err == nil {
+			if err := utils.Run(command, "-X", IptablesChain); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Commands is a method that returns the arguments of the iptables, or ip6tables if ipv6, commands filling the chain anew.
+func (Iptables) Commands(rules Rules, ipv6 bool) ([][]string, error) {
+	commands := [][]string{
+		{"-F", IptablesChain},
+		{"-A", IptablesChain, "-o", "lo", "-j", "ACCEPT"},
+		{"-A", IptablesChain, "-o", rules.Interface, "-j", "ACCEPT"},
+	}
+
+	if ipv6 {
+		commands = append(commands, []string{"-A", IptablesChain, "-p", "udp", "--sport", "546", "--dport", "547", "-j", "ACCEPT"})
+		for _, icmp := range []string{"router-solicitation", "neighbour-solicitation", "neighbour-advertisement"} {
+			commands = append(commands, []string{"-A", IptablesChain, "-p", "ipv6-icmp", "--icmpv6-type", icmp, "-j", "ACCEPT"})
+		}
+	} else {
+		commands = append(commands, []string{"-A", IptablesChain, "-p", "udp", "--sport", "68", "--dport", "67", "-j", "ACCEPT"})
+	}
+
+	for _, e := range rules.Endpoints {
+		ip, port, endpointIPv6, err := endpoint(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if endpointIPv6 == ipv6 {
+			commands = append(commands, []string{"-A", IptablesChain, "-d", ip, "-p", "udp", "--dport", strconv.Itoa(port), "-j", "ACCEPT"})
+		}
+	}
+
+	v4, v6 := families(rules.Bypass)
+	bypass := v4
+	if ipv6 {
+		bypass = v6
+	}
+	for _, network := range bypass {
+		commands = append(commands, []string{"-A", IptablesChain, "-d", network, "-j", "ACCEPT"})
+	}
+
+	return append(commands, []string{"-A", IptablesChain, "-j", "REJECT"}), nil
+}
@@ -0,0 +1,139 @@
+// killswitch is a package containing the backends of the firewall blocking the traffic of the host outside of the tunnel,
+// so nothing leaks if the Wireguard interface goes down unexpectedly, e.g. on a crash of wg-quick or a lost peer.
+// The rules stay until they're removed with Disable on 'fvpn state down'.
+package killswitch
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+var home, _ = os.UserHomeDir()
+
+// StateDir is a directory the backends keep what Enable changed in, e.g. the firewall policy of Windows or the pf reference,
+// so Disable puts it back in another process, i.e. 'fvpn state down'.
+var StateDir = filepath.Join(home, ".forestvpn", "killswitch")
+
+// Backends are the names of the backends accepted by Open besides auto.
+var Backends = []string{"nftables", "iptables", "pf", "netsh"}
+
+// Rules is a structure of the traffic let through the kill switch: everything through Interface, the encrypted packets to Endpoints,
+// the traffic from Addresses of the tunnel where the firewall can't match the interface, and the traffic to Bypass,
+// the networks kept out of the tunnel, e.g. the local ones.
+type Rules struct {
+	Interface string
+	// Endpoints are the endpoints of the peers as IP:port.
+	Endpoints []string
+	Addresses []string
+	Bypass    []string
+}
+
+// Firewall is an interface of the backend installing the rules of the kill switch.
+type Firewall interface {
+	// Name is a method that returns the name of the backend as accepted by Open.
+	Name() string
+	// Enable is a method to block the traffic except the one of rules, replacing the rules installed before, if any.
+	Enable(rules Rules) error
+	// Disable is a method to remove the rules of Enable.
+	Disable() error
+}
+
+// Open is a factory function that returns the Firewall of the backend, or the one detected with Detect for auto.
+func Open(backend string) (Firewall, error) {
+	switch backend {
+	case "auto":
+		return Detect()
+	case "nftables":
+		return Nftables{}, nil
+	case "iptables":
+		return Iptables{}, nil
+	case "pf":
+		return Pf{}, nil
+	case "netsh":
+		return Netsh{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported kill switch backend: %s, must be auto, %s", backend, strings.Join(Backends, ", "))
+}
+
+// Detect is a function that picks the backend of the host: nftables if nft is installed, iptables otherwise on Linux,
+// pf on macOS and the Windows Firewall with netsh on Windows.
+func Detect() (Firewall, error) {
+	switch {
+	case utils.IsOpenWRT() || utils.IsTermux():
+	case utils.Os == "linux" && installed("nft"):
+		return Nftables{}, nil
+	case utils.Os == "linux" && installed("iptables"):
+		return Iptables{}, nil
+	case utils.Os == "darwin":
+		return Pf{}, nil
+	case utils.Os == "windows":
+		return Netsh{}, nil
+	}
+
+	return nil, fmt.Errorf("kill switch is not supported on this system")
+}
+
+func installed(command string) bool {
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+// endpoint is a function that splits the endpoint into the IP address and the port, telling whether the address is IPv6.
+func endpoint(hostport string) (string, int, bool, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", 0, false, fmt.Errorf("invalid endpoint: %s", hostport)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, false, fmt.Errorf("invalid endpoint: %s", hostport)
+	}
+
+	return ip.String(), p, ip.To4() == nil, nil
+}
+
+// families is a function that splits the networks into IPv4 and IPv6 ones.
+func families(networks []string) ([]string, []string) {
+	var v4, v6 []string
+	for _, network := range networks {
+		if strings.Contains(network, ":") {
+			v6 = append(v6, network)
+		} else {
+			v4 = append(v4, network)
+		}
+	}
+	return v4, v6
+}
+
+// loadState is a function to read the state of Enable named name, returning false if there is none.
+func loadState(name string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(StateDir, name))
+	return string(data), err == nil
+}
+
+// saveState is a function to keep the state of Enable named name for Disable.
+func saveState(name string, value string) error {
+	if err := os.MkdirAll(StateDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(StateDir, name), []byte(value), 0600)
+}
+
+// removeState is a function to forget the state of Enable named name once Disable has put it back.
+func removeState(name string) {
+	_ = os.Remove(filepath.Join(StateDir, name))
+}
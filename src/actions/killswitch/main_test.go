@@ -0,0 +1,144 @@
+package killswitch_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/forestvpn/cli/actions/killswitch"
+)
+
+var rules = killswitch.Rules{
+	Interface: "fvpn0",
+	Endpoints: []string{"198.51.100.1:51820", "[2001:db8::1]:51820"},
+	Addresses: []string{"10.42.0.2/32", "fd42::2/128"},
+	Bypass:    []string{"192.168.0.0/16", "fc00::/7"},
+}
+
+func TestOpen(t *testing.T) {
+	for _, backend := range killswitch.Backends {
+		firewall, err := killswitch.Open(backend)
+		if err != nil {
+			t.Errorf("Open(%q): %s", backend, err)
+		} else if firewall.Name() != backend {
+			t.Errorf("Open(%q) returned %s", backend, firewall.Name())
+		}
+	}
+
+	if _, err := killswitch.Open("ufw"); err == nil {
+		t.Error("expected an unsupported backend to fail")
+	}
+}
+
+func TestNftablesScript(t *testing.T) {
+	script, err := killswitch.Nftables{}.Script(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"policy drop;",
+		`oifname "fvpn0" accept`,
+		"ip daddr 198.51.100.1 udp dport 51820 accept",
+		"ip6 daddr 2001:db8::1 udp dport 51820 accept",
+		"ip daddr { 192.168.0.0/16 } accept",
+		"ip6 daddr { fc00::/7 } accept",
+	} {
+		if !strings.Contains(script, line) {
+			t.Errorf("expected %q in the script:\n%s", line, script)
+		}
+	}
+
+	if _, err := (killswitch.Nftables{}).Script(killswitch.Rules{Interface: "fvpn0", Endpoints: []string{"vpn.example.com:51820"}}); err == nil {
+		t.Error("expected an unresolved endpoint to fail")
+	}
+}
+
+func TestIptablesCommands(t *testing.T) {
+	commands, err := killswitch.Iptables{}.Commands(rules, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var joined []string
+	for _, args := range commands {
+		joined = append(joined, strings.Join(args, " "))
+	}
+	all := strings.Join(joined, "\n")
+
+	if !strings.Contains(all, "-d 198.51.100.1 -p udp --dport 51820 -j ACCEPT") || strings.Contains(all, "2001:db8::1") {
+		t.Errorf("expected only the IPv4 endpoint:\n%s", all)
+	}
+	if !strings.Contains(all, "-d 192.168.0.0/16 -j ACCEPT") || strings.Contains(all, "fc00::/7") {
+		t.Errorf("expected only the IPv4 bypass:\n%s", all)
+	}
+	if last := joined[len(joined)-1]; last != "-A FVPN-KILLSWITCH -j REJECT" {
+		t.Errorf("expected the chain to end with REJECT, got %s", last)
+	}
+}
+
+func TestPfScript(t *testing.T) {
+	script, err := killswitch.Pf{}.Script(killswitch.Rules{Interface: "utun4", Endpoints: []string{"198.51.100.1:51820"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(script), "\n")
+	if lines[1] != "pass out quick on utun4 all" || lines[len(lines)-1] != "block drop out quick all" {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+}
+
+func TestNetshCommands(t *testing.T) {
+	commands, err := killswitch.Netsh{}.Commands(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, args := range commands {
+		if args[len(args)-1] == "localip=10.42.0.2,fd42::2" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the traffic from the addresses of the tunnel to be allowed, got %v", commands)
+	}
+}
+
+func TestParsePfToken(t *testing.T) {
+	output := "No ALTQ support in kernel\nALTQ related functions disabled\npf enabled\nToken : 5417734681265486327\n"
+	if token, err := killswitch.ParsePfToken(output); err != nil || token != "5417734681265486327" {
+		t.Errorf("expected the token, got %q, %v", token, err)
+	}
+
+	if _, err := killswitch.ParsePfToken("pfctl: /dev/pf: Permission denied"); err == nil {
+		t.Error("expected an error without the token")
+	}
+}
+
+func TestParseNetshPolicies(t *testing.T) {
+	output := `
+Domain Profile Settings:
+----------------------------------------------------------------------
+Firewall Policy                       BlockInbound,AllowOutbound
+
+Private Profile Settings:
+----------------------------------------------------------------------
+Firewall Policy                       BlockInbound,BlockOutbound
+
+Public Profile Settings:
+----------------------------------------------------------------------
+Firewall Policy                       AllowInbound,AllowOutbound
+Ok.
+`
+	expected := map[string]string{
+		"domainprofile":  "BlockInbound,AllowOutbound",
+		"privateprofile": "BlockInbound,BlockOutbound",
+		"publicprofile":  "AllowInbound,AllowOutbound",
+	}
+
+	if policies := killswitch.ParseNetshPolicies(output); !reflect.DeepEqual(policies, expected) {
+		t.Errorf("expected %v, got %v", expected, policies)
+	}
+}
@@ -0,0 +1,141 @@
+package killswitch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// NetshRule is the name of the rules of Netsh.
+const NetshRule = "fvpn kill switch"
+
+// Netsh is a structure of the backend blocking the outgoing traffic with the Windows Firewall, which filters it with
+// the Windows Filtering Platform. The firewall can't match the tunnel adapter, so the traffic is let through by the addresses of the tunnel.
+type Netsh struct{}
+
+// Name is a method that returns netsh.
+func (Netsh) Name() string {
+	return "netsh"
+}
+
+// netshPolicyState is a name of the state keeping the firewall policies of the profiles before Enable.
+const netshPolicyState = "netsh.policy"
+
+// Enable is a method to add the rules letting the traffic through and to block the rest of the outgoing traffic by default.
+// The firewall policies of the profiles are kept for Disable to put back.
+func (n Netsh) Enable(rules Rules) error {
+	commands, err := n.Commands(rules)
+	if err != nil {
+		return err
+	}
+
+	// the policies of the previous Enable are the blocking ones
+	if _, ok := loadState(netshPolicyState); !ok {
+		output, err := utils.Output("netsh", "advfirewall", "show", "allprofiles", "firewallpolicy")
+		if err != nil {
+			return fmt.Errorf("netsh advfirewall show: %s", strings.TrimSpace(string(output)))
+		}
+
+		var lines []string
+		for profile, policy := range ParseNetshPolicies(string(output)) {
+			lines = append(lines, profile+" "+policy)
+		}
+		sort.Strings(lines)
+		if err := saveState(netshPolicyState, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+
+	// the rules of the previous Enable are replaced
+	_ = utils.Run("netsh", "advfirewall", "firewall", "delete", "rule", "name="+NetshRule)
+	for _, args := range commands {
+		if err := utils.Run("netsh", args...); err != nil {
+			return err
+		}
+	}
+
+	return utils.Run("netsh", "advfirewall", "set", "allprofiles", "firewallpolicy", "blockinbound,blockoutbound")
+}
+
+// Disable is a method to put back the firewall policies of the profiles from before Enable and to delete the rules.
+// The outgoing traffic is allowed by default, as Windows does out of the box, if the policies couldn't be read.
+func (Netsh) Disable() error {
+	saved, _ := loadState(netshPolicyState)
+	policies := make(map[string]string)
+	for _, line := range strings.Split(saved, "\n") {
+		if profile, policy, found := strings.Cut(line, " "); found {
+			policies[profile] = policy
+		}
+	}
+
+	if len(policies) == 0 {
+		policies["allprofiles"] = "blockinbound,allowoutbound"
+	}
+
+	for profile, policy := range policies {
+		if err := utils.Run("netsh", "advfirewall", "set", profile, "firewallpolicy", policy); err != nil {
+			return err
+		}
+	}
+
+	removeState(netshPolicyState)
+	_ = utils.Run("netsh", "advfirewall", "firewall", "delete", "rule", "name="+NetshRule)
+	return nil
+}
+
+// ParseNetshPolicies is a function that reads the firewall policies of the profiles out of the output of
+// 'netsh advfirewall show allprofiles firewallpolicy' into a map of the profiles as accepted by 'netsh advfirewall set', e.g. domainprofile,
+// to the policies, e.g. BlockInbound,AllowOutbound.
+func ParseNetshPolicies(output string) map[string]string {
+	policies := make(map[string]string)
+	profile := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, " Profile Settings:") {
+			profile = strings.ToLower(strings.TrimSuffix(line, " Profile Settings:")) + "profile"
+			continue
+		}
+
+		// the label of the policy is translated, while the policy itself isn't, e.g. BlockInbound,AllowOutbound
+		fields := strings.Fields(line)
+		if len(profile) > 0 && len(fields) > 0 && strings.HasSuffix(strings.ToLower(fields[len(fields)-1]), "outbound") {
+			policies[profile] = fields[len(fields)-1]
+			profile = ""
+		}
+	}
+
+	return policies
+}
+
+// Commands is a method that returns the arguments of the netsh commands adding the rules.
+func (Netsh) Commands(rules Rules) ([][]string, error) {
+	add := []string{"advfirewall", "firewall", "add", "rule", "name=" + NetshRule, "dir=out", "action=allow"}
+	commands := [][]string{
+		append(add[:len(add):len(add)], "protocol=UDP", "localport=68", "remoteport=67"),
+	}
+
+	if len(rules.Addresses) > 0 {
+		var addresses []string
+		for _, address := range rules.Addresses {
+			addresses = append(addresses, strings.Split(address, "/")[0])
+		}
+		commands = append(commands, append(add[:len(add):len(add)], "localip="+strings.Join(addresses, ",")))
+	}
+
+	for _, e := range rules.Endpoints {
+		ip, port, _, err := endpoint(e)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, append(add[:len(add):len(add)], "protocol=UDP", "remoteip="+ip, "remoteport="+strconv.Itoa(port)))
+	}
+
+	if len(rules.Bypass) > 0 {
+		commands = append(commands, append(add[:len(add):len(add)], "remoteip="+strings.Join(rules.Bypass, ",")))
+	}
+
+	return commands, nil
+}
@@ -0,0 +1,81 @@
+package killswitch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// NftablesTable is the table of the rules of Nftables.
+const NftablesTable = "fvpn_killswitch"
+
+// Nftables is a structure of the backend filtering the outgoing traffic with a table of its own in nftables on Linux.
+type Nftables struct{}
+
+// Name is a method that returns nftables.
+func (Nftables) Name() string {
+	return "nftables"
+}
+
+// Enable is a method to replace the table with the rules in a single transaction.
+func (n Nftables) Enable(rules Rules) error {
+	script, err := n.Script(rules)
+	if err != nil {
+		return err
+	}
+
+	return utils.RunInput(script, "nft", "-f", "-")
+}
+
+// Disable is a method to delete the table.
+func (Nftables) Disable() error {
+	if err := utils.Run("nft", "list", "table", "inet", NftablesTable); err != nil {
+		// already disabled
+		return nil
+	}
+
+	return utils.Run("nft", "delete", "table", "inet", NftablesTable)
+}
+
+// Script is a method that renders the rules for 'nft -f'. The table is created before it's deleted, so the script doesn't fail
+// when there is none yet, and the new one replaces it atomically.
+func (Nftables) Script(rules Rules) (string, error) {
+	lines := []string{
+		"table inet " + NftablesTable,
+		"delete table inet " + NftablesTable,
+		"table inet " + NftablesTable + " {",
+		"\tchain output {",
+		"\t\ttype filter hook output priority 0; policy drop;",
+		"\t\toifname \"lo\" accept",
+		fmt.Sprintf("\t\toifname %q accept", rules.Interface),
+		// DHCP and the neighbor discovery keep the link itself up
+		"\t\tudp sport 68 udp dport 67 accept",
+		"\t\tudp sport 546 udp dport 547 accept",
+		"\t\ticmpv6 type { nd-router-solicit, nd-neighbor-solicit, nd-neighbor-advert } accept",
+	}
+
+	for _, e := range rules.Endpoints {
+		ip, port, ipv6, err := endpoint(e)
+		if err != nil {
+			return "", err
+		}
+
+		family := "ip"
+		if ipv6 {
+			family = "ip6"
+		}
+		lines = append(lines, fmt.Sprintf("\t\t%s daddr %s udp dport %d accept", family, ip, port))
+	}
+
+	v4, v6 := families(rules.Bypass)
+	if len(v4) > 0 {
+		lines = append(lines, fmt.Sprintf("\t\tip daddr { %s } accept", strings.Join(v4, ", ")))
+	}
+	if len(v6) > 0 {
+		lines = append(lines, fmt.Sprintf("\t\tip6 daddr { %s } accept", strings.Join(v6, ", ")))
+	}
+
+	lines = append(lines, "\t}", "}", "")
+	return strings.Join(lines, "\n"), nil
+}
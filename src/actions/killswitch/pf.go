@@ -0,0 +1,116 @@
+package killswitch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// PfAnchor is the anchor of the rules of Pf. The default /etc/pf.conf of macOS evaluates the anchors under com.apple,
+// so the rules apply without changing the main ruleset.
+const PfAnchor = "com.apple/fvpn.killswitch"
+
+// Pf is a structure of the backend filtering the outgoing traffic with an anchor of the packet filter of macOS.
+type Pf struct{}
+
+// Name is a method that returns pf.
+func (Pf) Name() string {
+	return "pf"
+}
+
+// pfTokenState is a name of the state keeping the reference to pf taken by Enable.
+const pfTokenState = "pf.token"
+
+var pfTokenPattern = regexp.MustCompile(`(?m)^Token\s*:\s*(\d+)`)
+
+// Enable is a method to load the rules into the anchor and to enable pf with a reference, 'pfctl -E',
+// which Disable releases, so pf is only disabled if no other application enabled it as well.
+func (p Pf) Enable(rules Rules) error {
+	script, err := p.Script(rules)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.RunInput(script, "pfctl", "-a", PfAnchor, "-f", "-"); err != nil {
+		return err
+	}
+
+	output, err := utils.Output("pfctl", "-E")
+	if err != nil {
+		return fmt.Errorf("pfctl -E: %s", strings.TrimSpace(string(output)))
+	}
+
+	token, err := ParsePfToken(string(output))
+	if err != nil {
+		return err
+	}
+
+	// the reference of the previous Enable is released once the new one is taken, it's gone anyway after a reboot
+	if previous, ok := loadState(pfTokenState); ok {
+		_ = utils.Run("pfctl", "-X", previous)
+	}
+
+	return saveState(pfTokenState, token)
+}
+
+// Disable is a method to flush the anchor and to release the reference to pf taken by Enable.
+func (Pf) Disable() error {
+	if err := utils.Run("pfctl", "-a", PfAnchor, "-F", "all"); err != nil {
+		return err
+	}
+
+	token, ok := loadState(pfTokenState)
+	if !ok {
+		return nil
+	}
+
+	if err := utils.Run("pfctl", "-X", token); err != nil {
+		return err
+	}
+
+	removeState(pfTokenState)
+	return nil
+}
+
+// ParsePfToken is a function that reads the reference token out of the output of 'pfctl -E', e.g. "Token : 5417734681265486327".
+func ParsePfToken(output string) (string, error) {
+	match := pfTokenPattern.FindStringSubmatch(output)
+	if match == nil {
+		return "", fmt.Errorf("no token in the output of pfctl -E: %s", strings.TrimSpace(output))
+	}
+	return match[1], nil
+}
+
+// Script is a method that renders the rules for 'pfctl -f'. The rules are quick, so the first match wins.
+func (Pf) Script(rules Rules) (string, error) {
+	lines := []string{
+		"pass out quick on lo0 all",
+		fmt.Sprintf("pass out quick on %s all", rules.Interface),
+		// DHCP and the neighbor discovery keep the link itself up
+		"pass out quick inet proto udp from any port 68 to any port 67",
+		"pass out quick inet6 proto udp from any port 546 to any port 547",
+		"pass out quick inet6 proto icmp6 all icmp6-type { routersol, neighbrsol, neighbradv }",
+	}
+
+	for _, e := range rules.Endpoints {
+		ip, port, ipv6, err := endpoint(e)
+		if err != nil {
+			return "", err
+		}
+
+		family := "inet"
+		if ipv6 {
+			family = "inet6"
+		}
+		lines = append(lines, fmt.Sprintf("pass out quick %s proto udp to %s port %d", family, ip, port))
+	}
+
+	for _, network := range rules.Bypass {
+		lines = append(lines, fmt.Sprintf("pass out quick to %s", network))
+	}
+
+	lines = append(lines, "block drop out quick all", "")
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,33 @@
+package actions
+
+import (
+	"os"
+	"strings"
+
+	"github.com/forestvpn/cli/auth"
+)
+
+// SaveLabel is a function to store the label of the connection of the user with id value of given user id given with 'fvpn state up --label'.
+// It's kept until the next 'fvpn state up', so the connection keeps it over the reconnects and the switches of the location.
+// An empty label clears it.
+func SaveLabel(userID auth.ProfileID, label string) error {
+	path := auth.ProfilesDir + string(userID) + auth.LabelFile
+	if len(label) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return os.WriteFile(path, []byte(label+"\n"), 0644)
+}
+
+// LoadLabel is a function to read the label of the connection of the user with id value of given user id, or an empty string if there is none.
+func LoadLabel(userID auth.ProfileID) string {
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.LabelFile)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
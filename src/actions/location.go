@@ -1,13 +1,23 @@
 package actions
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	forestvpn_api "github.com/forestvpn/api-client-go"
 	"github.com/forestvpn/cli/auth"
 	"github.com/forestvpn/cli/utils"
+	"github.com/google/uuid"
 	"github.com/olekukonko/tablewriter"
 	"gopkg.in/ini.v1"
 )
@@ -15,34 +25,132 @@ import (
 const Falkenstein = "b134d679-8697-4dc6-b629-c4c189392fca"
 const Helsinki = "7fc5b17c-eddf-413f-8b37-9d36eb5e33ec"
 
+// DegradedLatencyRate is a latency rate reported by back-end below which the location is considered degraded or full.
+const DegradedLatencyRate = 0.3
+
+// IsAvailable is a function to check whether the location with latencyRate is not degraded.
+// The locations are considered available if back-end doesn't report the rate.
+func IsAvailable(latencyRate *float64) bool {
+	return latencyRate == nil || *latencyRate >= DegradedLatencyRate
+}
+
+// FormatQuality is a function that formats latencyRate as percents for 'fvpn location ls', e.g. 95%, or - if it's unknown.
+func FormatQuality(latencyRate *float64) string {
+	if latencyRate == nil {
+		return "-"
+	}
+
+	quality := fmt.Sprintf("%.0f%%", *latencyRate*100)
+	if !IsAvailable(latencyRate) {
+		quality += " (degraded)"
+	}
+	return quality
+}
+
+// LocationEntry is a structure of a location printed by 'fvpn location ls' and 'fvpn location status' with --output json.
+type LocationEntry struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Country     string `json:"country"`
+	CountryCode string `json:"countryCode,omitempty"`
+	Slug        string `json:"slug"`
+	Premium     bool   `json:"premium"`
+	Usable      bool   `json:"usable"`
+	// Quality is a connection quality reported by back-end from 0 to 1, if any.
+	Quality *float64 `json:"quality,omitempty"`
+}
+
+// NewLocationEntry is a function that describes the location wrapped for the JSON output.
+func NewLocationEntry(loc LocationWrapper) LocationEntry {
+	country := loc.Location.GetCountry()
+	return LocationEntry{
+		ID:          loc.Location.GetId(),
+		Name:        loc.Location.GetName(),
+		Country:     country.GetName(),
+		CountryCode: country.GetId(),
+		Slug:        loc.Slug,
+		Premium:     loc.Premium,
+		Usable:      loc.Usable,
+		Quality:     loc.Location.LatencyRate,
+	}
+}
+
 // ListLocations is a function to get the list of locations available for user.
+// With asJSON, the locations are printed as a JSON array of LocationEntry instead of the table.
+// The locations are taken from the local snapshot while it's fresh, unless refresh is true.
+// The degraded locations are left out if availableOnly is true, and the ones the plan of the user doesn't allow if usableOnly is true.
 //
 // See https://github.com/forestvpn/api-client-go/blob/main/docs/GeoApi.md#listlocations for more information.
-func (w AuthClientWrapper) ListLocations(country string) error {
+func (w AuthClientWrapper) ListLocations(userID auth.ProfileID, country string, patterns []string, refresh bool, availableOnly bool, usableOnly bool, asJSON bool) error {
 	var data [][]string
+	entries := []LocationEntry{}
+	var countries []forestvpn_api.Country
+	var wg sync.WaitGroup
 
-	locations, err := w.ApiClient.GetLocations()
+	// countries are fetched along with locations, as they are only needed for flags and never fail the listing
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		countries, _ = w.GetCountries()
+	}()
+
+	locations, err := w.GetCachedLocations(refresh)
+	wg.Wait()
 	if err != nil {
 		return err
 	}
 
+	entitlements, err := w.GetEntitlements(userID)
+	if err != nil {
+		return err
+	}
+
+	flags := countryFlags(countries)
+
 	if len(country) > 0 {
 		locations = filterLocationsByCountry(locations, country)
 	}
 
 	sortLocations(locations)
-	wrappedLocations := GetLocationWrappers(locations)
+	wrappedLocations := entitlements.Apply(MatchLocations(GetLocationWrappers(locations), patterns))
 
 	for _, loc := range wrappedLocations {
-		premiumMark := ""
+		if availableOnly && !IsAvailable(loc.Location.LatencyRate) || usableOnly && !loc.Usable {
+			continue
+		}
+
+		if asJSON {
+			entries = append(entries, NewLocationEntry(loc))
+			continue
+		}
+
+		premiumMark, usableMark := "", ""
 		if loc.Premium {
 			premiumMark = "*"
 		}
-		data = append(data, []string{loc.Location.GetName(), loc.Location.Country.GetName(), loc.Location.GetId(), premiumMark})
+		if loc.Usable {
+			usableMark = "yes"
+		}
+		country := loc.Location.GetCountry()
+		flag, ok := flags[country.GetId()]
+		if !ok {
+			flag = country.GetEmoji()
+		}
+		data = append(data, []string{loc.Location.GetName(), strings.TrimSpace(flag + " " + country.GetName()), loc.Slug, loc.Location.GetId(), premiumMark, usableMark, FormatQuality(loc.Location.LatencyRate)})
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(entries, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"City", "Country", "UUID", "Premium"})
+	table.SetHeader([]string{"City", "Country", "Slug", "UUID", "Premium", "Usable", "Quality"})
 	table.SetBorder(false)
 	table.AppendBulk(data)
 	table.Render()
@@ -53,7 +161,7 @@ func (w AuthClientWrapper) ListLocations(country string) error {
 func filterLocationsByCountry(locations []forestvpn_api.Location, country string) []forestvpn_api.Location {
 	var locationsByCountry []forestvpn_api.Location
 	for _, location := range locations {
-		if strings.EqualFold(location.Country.GetName(), country) {
+		if utils.MatchCountry(country, location.Country.GetId(), location.Country.GetName()) {
 			locationsByCountry = append(locationsByCountry, location)
 		}
 	}
@@ -68,6 +176,8 @@ func sortLocations(locations []forestvpn_api.Location) {
 
 // SetLocation is a function that writes the location data into the Wireguard configuration file.
 // It uses gopkg.in/ini.v1 package to form Woreguard compatible configuration file from the location data.
+// On IPv6-only networks the endpoints are rewritten to IPv6 addresses, see IPv6OnlyNetwork.
+// The networks routed through the tunnel are set with the tunnel-scope setting, see AllowedIPs.
 // If the user subscrition on the Forest VPN services is out of date, it calls BuyPremiumDialog.
 //
 // See https://github.com/forestvpn/api-client-go/blob/main/docs/BillingFeature.md for more information.
@@ -86,39 +196,57 @@ func (w AuthClientWrapper) SetLocation(device *forestvpn_api.Device, user_id aut
 	if err != nil {
 		return err
 	}
-	_, err = interfaceSection.NewKey("DNS", strings.Join(device.GetDns()[:], ","))
+	// the other DNS backends set the servers once the interface is up, see State.SetUp
+	if dnsInConfig() {
+		_, err = interfaceSection.NewKey("DNS", strings.Join(device.GetDns()[:], ","))
+		if err != nil {
+			return err
+		}
+	}
+
+	plan, err := PlanRoutes(device)
 	if err != nil {
 		return err
 	}
 
-	for _, peer := range device.Wireguard.GetPeers() {
-		peerSection, err := config.NewSection("Peer")
-		if err != nil {
-			return err
+	allowedIps := plan.AllowedIPs
+	if utils.Verbose {
+		for _, line := range plan.Describe(user_id) {
+			utils.InfoLogger.Println(line)
 		}
+	}
 
-		var allowedIps []string
-		if utils.Os == "darwin" || utils.Os == "windows" {
-			allowedIps = append(allowedIps, "0.0.0.0/0")
-		} else {
-			allowedIps = peer.GetAllowedIps()
-			activeSShClient := utils.GetActiveSshClient()
+	routing, err := LoadRouting(user_id, coexistDefault(CoexistingTunnels(ownInterface)))
+	if err != nil {
+		return err
+	}
+	if routing.Table > 0 {
+		postUp, postDown := routing.Hooks(allowedIps)
+		for _, key := range [][2]string{
+			{"Table", strconv.Itoa(routing.Table)},
+			{"FwMark", strconv.FormatUint(uint64(routing.Mark), 10)},
+			{"PostUp", strings.Join(postUp, "; ")},
+			{"PostDown", strings.Join(postDown, "; ")},
+		} {
+			_, err = interfaceSection.NewKey(key[0], key[1])
 			if err != nil {
 				return err
 			}
-			if len(activeSShClient) > 0 {
-				allowedIps, err = utils.ExcludeDisallowedIps(allowedIps, activeSShClient)
-				if err != nil {
-					return err
-				}
-			}
+		}
+	}
+
+	rewrite := endpointRewriter()
+	for _, peer := range device.Wireguard.GetPeers() {
+		peerSection, err := config.NewSection("Peer")
+		if err != nil {
+			return err
 		}
 
 		_, err = peerSection.NewKey("AllowedIPs", strings.Join(allowedIps, ", "))
 		if err != nil {
 			return err
 		}
-		_, err = peerSection.NewKey("Endpoint", peer.GetEndpoint())
+		_, err = peerSection.NewKey("Endpoint", rewrite(peer.GetEndpoint()))
 		if err != nil {
 			return err
 		}
@@ -126,6 +254,12 @@ func (w AuthClientWrapper) SetLocation(device *forestvpn_api.Device, user_id aut
 		if err != nil {
 			return err
 		}
+		if keepalive := Keepalive(); keepalive != "off" {
+			_, err = peerSection.NewKey("PersistentKeepalive", keepalive)
+			if err != nil {
+				return err
+			}
+		}
 		presharedKey := peer.GetPsKey()
 		if len(presharedKey) > 0 {
 			_, err = peerSection.NewKey("PresharedKey", presharedKey)
@@ -144,25 +278,316 @@ func (w AuthClientWrapper) SetLocation(device *forestvpn_api.Device, user_id aut
 	return nil
 }
 
+// LocationsCacheTTL is a time the location snapshot is used by 'fvpn location ls' without asking back-end.
+const LocationsCacheTTL = time.Hour
+
+// LocationsSnapshot is a structure of the local snapshot of the location catalog.
+// Checksum is the SHA-256 of Locations to detect a corrupted or hand-edited snapshot.
+type LocationsSnapshot struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Checksum  string          `json:"checksum"`
+	Locations json.RawMessage `json:"locations"`
+}
+
+// GetLocations is a method to get all the locations available at back-end and save them to the local snapshot.
+// If back-end is unavailable, the locations are read from the snapshot.
+func (w AuthClientWrapper) GetLocations() ([]forestvpn_api.Location, error) {
+	locations, err := w.ApiClient.GetLocations()
+	if err != nil {
+		snapshot, snapshotErr := loadLocationsSnapshot()
+		if snapshotErr != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "Back-end is unavailable, using the locations as of %s\n", utils.FormatTime(snapshot.FetchedAt))
+		return snapshot.locations()
+	}
+
+	return locations, saveLocationsSnapshot(locations)
+}
+
+// GetCachedLocations is a method to get the locations from the local snapshot while it's fresh,
+// refreshing it from back-end once it's older than LocationsCacheTTL or refresh is true.
+func (w AuthClientWrapper) GetCachedLocations(refresh bool) ([]forestvpn_api.Location, error) {
+	if snapshot, err := loadLocationsSnapshot(); err == nil && !refresh && time.Since(snapshot.FetchedAt) < LocationsCacheTTL {
+		if locations, err := snapshot.locations(); err == nil {
+			return locations, nil
+		}
+	}
+
+	if refresh {
+		locations, err := w.ApiClient.GetLocations()
+		if err != nil {
+			return nil, err
+		}
+		return locations, saveLocationsSnapshot(locations)
+	}
+
+	return w.GetLocations()
+}
+
+// LoadLocations is a function to read the locations from the local snapshot saved by GetLocations.
+func LoadLocations() ([]forestvpn_api.Location, error) {
+	snapshot, err := loadLocationsSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot.locations()
+}
+
+func saveLocationsSnapshot(locations []forestvpn_api.Location) error {
+	data, err := json.Marshal(locations)
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(data)
+	snapshot, err := json.Marshal(LocationsSnapshot{FetchedAt: time.Now(), Checksum: hex.EncodeToString(checksum[:]), Locations: data})
+	if err != nil {
+		return err
+	}
+
+	return auth.JsonDump(snapshot, filepath.Join(auth.AppDir, auth.LocationsFile))
+}
+
+func loadLocationsSnapshot() (LocationsSnapshot, error) {
+	var snapshot LocationsSnapshot
+	data, err := os.ReadFile(filepath.Join(auth.AppDir, auth.LocationsFile))
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, err
+	}
+
+	checksum := sha256.Sum256(snapshot.Locations)
+	if hex.EncodeToString(checksum[:]) != snapshot.Checksum {
+		return snapshot, errors.New("location snapshot is corrupted")
+	}
+
+	return snapshot, nil
+}
+
+func (s LocationsSnapshot) locations() ([]forestvpn_api.Location, error) {
+	var locations []forestvpn_api.Location
+	return locations, json.Unmarshal(s.Locations, &locations)
+}
+
+// LocationKey is a function that returns the country-prefixed key of the location used in patterns, e.g. de-frankfurt.
+func LocationKey(location forestvpn_api.Location) string {
+	country := location.GetCountry()
+	return strings.ToLower(country.GetId() + "-" + strings.ReplaceAll(location.GetName(), " ", "-"))
+}
+
+// MatchLocations is a function to filter the locations matching any of shell patterns, e.g. "de-*" or "Hel*".
+// Patterns are matched against the key, slug, city, country and UUID of the location.
+func MatchLocations(locations []LocationWrapper, patterns []string) []LocationWrapper {
+	var matched []LocationWrapper
+	for _, loc := range locations {
+		country := loc.Location.GetCountry()
+		if utils.GlobMatch(patterns, LocationKey(loc.Location), loc.Slug, loc.Location.GetName(), country.GetName(), loc.Location.GetId()) {
+			matched = append(matched, loc)
+		}
+	}
+	return matched
+}
+
+// ProbeLocations is a method to print the matched locations ranked by connection quality and distance reported by back-end.
+// With summary, the locations are aggregated per country into a heatmap instead, see SummarizeProbes.
+func (w AuthClientWrapper) ProbeLocations(patterns []string, summary bool) error {
+	var data [][]string
+
+	locations, err := w.GetLocations()
+	if err != nil {
+		return err
+	}
+
+	matched := MatchLocations(GetLocationWrappers(locations), patterns)
+	if len(matched) == 0 {
+		return fmt.Errorf("no locations match %s", strings.Join(patterns, ","))
+	}
+
+	if summary {
+		PrintProbeSummary(SummarizeProbes(matched))
+		return nil
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Location.GetLatencyRate() > matched[j].Location.GetLatencyRate()
+	})
+
+	for _, loc := range matched {
+		quality, distance := "-", "-"
+		if rate, ok := loc.Location.GetLatencyRateOk(); ok {
+			quality = fmt.Sprintf("%.0f%%", *rate*100)
+		}
+		if km, ok := loc.Location.GetDistanceOk(); ok {
+			distance = fmt.Sprintf("%.0f km", *km)
+		}
+		country := loc.Location.GetCountry()
+		data = append(data, []string{LocationKey(loc.Location), loc.Location.GetName(), country.GetName(), quality, distance})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Key", "City", "Country", "Quality", "Distance"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// CountryProbe is a structure of the connection quality of the locations of a country aggregated by SummarizeProbes.
+// Best and Mean are from 0 to 1, or -1 if back-end reported no quality for any of the locations, and so is Nearest in km.
+type CountryProbe struct {
+	Country   string
+	Locations int
+	Best      float64
+	Mean      float64
+	Nearest   float64
+}
+
+// SummarizeProbes is a function that aggregates the connection quality and distance of the locations per country,
+// ranked by the best location of the country.
+func SummarizeProbes(locations []LocationWrapper) []CountryProbe {
+	var order []string
+	probes := make(map[string]*CountryProbe)
+	rated := make(map[string]int)
+	for _, loc := range locations {
+		country := loc.Location.GetCountry()
+		name := country.GetName()
+		probe, ok := probes[name]
+		if !ok {
+			probe = &CountryProbe{Country: name, Best: -1, Mean: -1, Nearest: -1}
+			probes[name] = probe
+			order = append(order, name)
+		}
+
+		probe.Locations++
+		if rate, ok := loc.Location.GetLatencyRateOk(); ok {
+			if *rate > probe.Best {
+				probe.Best = *rate
+			}
+			// the running mean starts from the first rated location
+			rated[name]++
+			if rated[name] == 1 {
+				probe.Mean = *rate
+			} else {
+				probe.Mean += (*rate - probe.Mean) / float64(rated[name])
+			}
+		}
+		if km, ok := loc.Location.GetDistanceOk(); ok && (probe.Nearest < 0 || *km < probe.Nearest) {
+			probe.Nearest = *km
+		}
+	}
+
+	summary := make([]CountryProbe, 0, len(order))
+	for _, name := range order {
+		summary = append(summary, *probes[name])
+	}
+
+	sort.SliceStable(summary, func(i, j int) bool {
+		return summary[i].Best > summary[j].Best
+	})
+
+	return summary
+}
+
+// PrintProbeSummary is a function to print the countries with a bar of the mean connection quality colored from red to green.
+func PrintProbeSummary(summary []CountryProbe) {
+	var data [][]string
+	for _, probe := range summary {
+		best, mean, bar, nearest := "-", "-", "", "-"
+		if probe.Best >= 0 {
+			percent := int(probe.Mean*100 + 0.5)
+			best, mean = fmt.Sprintf("%.0f%%", probe.Best*100), fmt.Sprintf("%d%%", percent)
+			bar = utils.HeatColor(percent, utils.ProgressBar(percent, 20))
+		}
+		if probe.Nearest >= 0 {
+			nearest = fmt.Sprintf("%.0f km", probe.Nearest)
+		}
+		data = append(data, []string{probe.Country, strconv.Itoa(probe.Locations), best, mean, bar, nearest})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Country", "Locations", "Best", "Mean", "Quality", "Nearest"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+// LocationWrapper is a structure of the location along with whether it requires a paid subscription
+// and whether the plan of the user allows it, once marked with Entitlements.Apply.
 type LocationWrapper struct {
 	Location forestvpn_api.Location
 	Premium  bool
+	Usable   bool
+	Slug     string
 }
 
 func GetLocationWrappers(locations []forestvpn_api.Location) []LocationWrapper {
 	wrappers := make([]LocationWrapper, 0, len(locations))
 	for _, location := range locations {
-		wrappers = append(wrappers, LocationWrapper{Location: location, Premium: IsPremiumLocation(location)})
+		premium := IsPremiumLocation(location)
+		wrappers = append(wrappers, LocationWrapper{Location: location, Premium: premium, Usable: !premium, Slug: LocationSlug(location)})
+	}
+
+	// the cities sharing a slug are told apart by a number in the order of their UUIDs, e.g. us-sfr and us-sfr2
+	sorted := make([]int, len(wrappers))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return wrappers[sorted[i]].Location.GetId() < wrappers[sorted[j]].Location.GetId()
+	})
+
+	seen := make(map[string]int)
+	for _, i := range sorted {
+		slug := wrappers[i].Slug
+		if seen[slug]++; seen[slug] > 1 {
+			wrappers[i].Slug = fmt.Sprintf("%s%d", slug, seen[slug])
+		}
 	}
 	return wrappers
 }
 
+// LocationSlug is a function that returns the short identifier of the location made of its country code and city, e.g. de-fra.
+// Use GetLocationWrappers to get the slugs of the cities sharing one told apart.
+func LocationSlug(location forestvpn_api.Location) string {
+	country := location.GetCountry()
+	return utils.Slug(country.GetId(), location.GetName())
+}
+
+// IsPremiumLocation is a function to check whether the location is left out of FreeLocations.
+// Use Entitlements to tell whether the user could connect to it.
 func IsPremiumLocation(location forestvpn_api.Location) bool {
-	switch location.GetId() {
-	case Helsinki, Falkenstein:
-		return false
-	case "":
-		return true
+	for _, id := range FreeLocations {
+		if location.GetId() == id {
+			return false
+		}
 	}
 	return true
 }
+
+// FindLocation is a function to look up a location by its UUID, its slug or its case-insensitive name.
+// The slugs take precedence over the names. Returns false if none matches any of given locations.
+func FindLocation(locations []LocationWrapper, arg string) (LocationWrapper, bool) {
+	id, err := uuid.Parse(arg)
+	for _, loc := range locations {
+		if err == nil && strings.EqualFold(loc.Location.GetId(), id.String()) {
+			return loc, true
+		}
+		if err != nil && strings.EqualFold(loc.Slug, arg) {
+			return loc, true
+		}
+	}
+	for _, loc := range locations {
+		if err != nil && strings.EqualFold(loc.Location.GetName(), arg) {
+			return loc, true
+		}
+	}
+	return LocationWrapper{}, false
+}
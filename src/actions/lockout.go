@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/api"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// LoginFreeAttempts is the number of failed logins in a row allowed before 'fvpn account login' starts to back off.
+const LoginFreeAttempts = 3
+
+// LoginBackoffBase is the time to wait after the first failed login over LoginFreeAttempts. It doubles with every other failure.
+const LoginBackoffBase = 30 * time.Second
+
+// LoginBackoffCap is the longest time to wait between the logins, still well within the lockout of the account by the back-end.
+const LoginBackoffCap = 15 * time.Minute
+
+// LoginFailureWindow is the time after the last failed login the failures are forgotten at.
+const LoginFailureWindow = time.Hour
+
+// LoginAttempts is a structure to store the failed logins in a row in auth.LoginAttemptsFile.
+type LoginAttempts struct {
+	Failures int       `json:"failures"`
+	Last     time.Time `json:"last"`
+}
+
+// loadLoginAttempts is a function to read the failed logins, forgetting them once LoginFailureWindow has passed.
+func loadLoginAttempts() LoginAttempts {
+	var attempts LoginAttempts
+	data, err := os.ReadFile(auth.AppDir + auth.LoginAttemptsFile)
+	if err != nil || json.Unmarshal(data, &attempts) != nil || time.Since(attempts.Last) > LoginFailureWindow {
+		return LoginAttempts{}
+	}
+	return attempts
+}
+
+// Backoff is a method to get the time to wait after the last failed login before the next one is attempted.
+func (a LoginAttempts) Backoff() time.Duration {
+	if a.Failures < LoginFreeAttempts {
+		return 0
+	}
+
+	backoff := LoginBackoffBase
+	for i := LoginFreeAttempts; i < a.Failures && backoff < LoginBackoffCap; i++ {
+		backoff *= 2
+	}
+	if backoff > LoginBackoffCap {
+		backoff = LoginBackoffCap
+	}
+	return backoff
+}
+
+// Remaining is a method to get the time left to wait before the next login at now.
+func (a LoginAttempts) Remaining(now time.Time) time.Duration {
+	remaining := a.Last.Add(a.Backoff()).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining.Round(time.Second)
+}
+
+// IsLoginRejected is a function to check whether err of the login means the credentials were rejected or the back-end
+// asks to slow down, as opposed to e.g. a network failure, which doesn't count towards the backoff.
+func IsLoginRejected(err error) bool {
+	if api.IsSessionExpired(err) {
+		return true
+	}
+
+	text := strings.ToLower(err.Error())
+	for _, reason := range []string{"too_many_attempts", "too many", "429", "invalid_grant", "invalid_password"} {
+		if strings.Contains(text, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordLoginResult is a function to count the login failed with err towards the backoff, or to forget the failures once it succeeds.
+func RecordLoginResult(err error) {
+	path := auth.AppDir + auth.LoginAttemptsFile
+	if err == nil {
+		_ = os.Remove(path)
+		return
+	}
+
+	if !IsLoginRejected(err) {
+		return
+	}
+
+	attempts := loadLoginAttempts()
+	attempts.Failures++
+	attempts.Last = time.Now()
+	if data, err := json.Marshal(attempts); err == nil {
+		_ = utils.WriteFileAtomic(path, data, 0600)
+	}
+}
+
+// LoginResetHint is a function to get the hint printed along the backoff, depending on whether the login is with a machine token.
+func LoginResetHint(withToken bool) string {
+	if withToken {
+		return "Check the token or issue a new one from the web dashboard"
+	}
+	return "Forgot the password? Reset it with 'Forgot password' on the sign-in page opened in the browser"
+}
+
+// WaitLoginBackoff is a function to hold the login back after the failed ones in a row, counting the time left down on the terminal,
+// so the account isn't locked by the back-end for hours. Without a terminal to count down on, an error with the time left is returned instead.
+func WaitLoginBackoff(withToken bool) error {
+	attempts := loadLoginAttempts()
+	remaining := attempts.Remaining(time.Now())
+	if remaining == 0 {
+		return nil
+	}
+
+	if !utils.IsInteractive() {
+		return fmt.Errorf("%d failed logins in a row, try again in %s. %s", attempts.Failures, utils.HumanizeDuration(remaining), LoginResetHint(withToken))
+	}
+
+	fmt.Printf("%d failed logins in a row\n", attempts.Failures)
+	fmt.Println(LoginResetHint(withToken))
+	for ; remaining > 0; remaining -= time.Second {
+		fmt.Printf("\rNext attempt in %s, press Ctrl+C to give up ", utils.HumanizeDuration(remaining))
+		time.Sleep(time.Second)
+	}
+	fmt.Printf("\r%s\r", strings.Repeat(" ", 60))
+	return nil
+}
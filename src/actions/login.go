@@ -10,17 +10,17 @@ import (
 func (w AuthClientWrapper) Login() error {
 	// Create the user profile
 	profile := w.AccountsMap.CreateUser()
-	token, loginErr := profile.Token()
+	token, loginErr := profile.AccessToken()
 	if loginErr != nil {
 		return loginErr
 	}
 	// Create a new context with the token as the access token
-	authCtx := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, token.Raw())
+	authCtx := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, token)
 	// Make a request to the WhoAmI endpoint
 	userInfo, _, loginErr := w.ApiClient.APIClient.AuthApi.WhoAmI(authCtx).Execute()
 	// If there is an error, log it and return it
 	if loginErr != nil {
-		fmt.Println(token.Raw())
+		fmt.Println(token)
 		return loginErr
 	}
 	profile.ID, profile.Email = auth.ProfileID(userInfo.GetId()), auth.ProfileEmail(userInfo.GetEmail())
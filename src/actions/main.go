@@ -6,6 +6,7 @@ package actions
 
 import (
 	"encoding/json"
+	"errors"
 	"sort"
 
 	forestvpn_api "github.com/forestvpn/api-client-go"
@@ -21,11 +22,11 @@ type AuthClientWrapper struct {
 }
 
 func GetAuthClientWrapper(profile *auth.Profile, apiHost string) (AuthClientWrapper, error) {
-	accessToken, err := profile.Token()
+	accessToken, err := profile.AccessToken()
 	if err != nil {
 		return AuthClientWrapper{}, err
 	}
-	return AuthClientWrapper{ApiClient: api.GetApiClient(accessToken.Raw(), apiHost)}, nil
+	return AuthClientWrapper{ApiClient: api.GetApiClient(accessToken, apiHost)}, nil
 }
 
 func (w AuthClientWrapper) GetUnexpiredOrMostRecentBillingFeature(userID auth.ProfileID) (forestvpn_api.BillingFeature, error) {
@@ -62,6 +63,10 @@ func (w AuthClientWrapper) GetUnexpiredOrMostRecentBillingFeature(userID auth.Pr
 	if err != nil {
 		return b, err
 	}
+	if len(billingFeatures) == 0 {
+		return b, errors.New("no billing features found for the account")
+	}
+
 	sort.Slice(billingFeatures, func(i, j int) bool {
 		return billingFeatures[i].GetExpiryDate().After(billingFeatures[j].GetExpiryDate())
 	})
@@ -0,0 +1,209 @@
+package actions
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// MonitorTarget is an address probed through the tunnel by 'fvpn monitor start'.
+const MonitorTarget = "1.1.1.1:443"
+
+// MonitorRetention is an age after which the samples are dropped.
+const MonitorRetention = 30 * 24 * time.Hour
+
+// MonitorPruneInterval is a time between the PruneSamples of 'fvpn monitor start' while it runs.
+const MonitorPruneInterval = 24 * time.Hour
+
+// Sample is a structure representing a single measurement of the tunnel quality.
+type Sample struct {
+	At       time.Time `json:"at"`
+	Location string    `json:"location"`
+	// Latency and Jitter are in milliseconds; both are 0 if every probe was lost.
+	Latency float64 `json:"latency_ms"`
+	Jitter  float64 `json:"jitter_ms"`
+	// Loss is the share of the lost probes from 0 to 1.
+	Loss float64 `json:"loss"`
+	// Label is the label of the connection given with 'fvpn state up --label'.
+	Label string `json:"label,omitempty"`
+}
+
+// LocationReport is a structure summarizing the samples recorded while connected to a location.
+type LocationReport struct {
+	Location string  `json:"location"`
+	Samples  int     `json:"samples"`
+	Latency  float64 `json:"latency_ms"`
+	P95      float64 `json:"p95_latency_ms"`
+	Jitter   float64 `json:"jitter_ms"`
+	Loss     float64 `json:"loss"`
+	// Uptime is the share of the samples with at least one probe answered.
+	Uptime float64 `json:"uptime"`
+}
+
+// TakeSample is a method to probe target count times through the running interface and summarize the round trips.
+func (s *State) TakeSample(userID auth.ProfileID, target string, count int) (Sample, error) {
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	location := device.GetLocation()
+	sample := Sample{At: time.Now(), Location: location.GetName(), Label: LoadLabel(userID)}
+	rtts, lost := utils.MeasureLatency(target, count, 5*time.Second)
+	sample.Loss = float64(lost) / float64(count)
+
+	if len(rtts) > 0 {
+		var sum time.Duration
+		for _, rtt := range rtts {
+			sum += rtt
+		}
+		sample.Latency = milliseconds(sum / time.Duration(len(rtts)))
+		sample.Jitter = milliseconds(utils.Jitter(rtts))
+	}
+
+	return sample, nil
+}
+
+// AppendSample is a function to record the sample of the user with id value of given user id.
+func AppendSample(userID auth.ProfileID, sample Sample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(auth.ProfilesDir+string(userID)+auth.MonitorFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// LoadSamples is a function to read the samples of the user with id value of given user id recorded since the time given.
+// Returns no samples if nothing has been recorded yet.
+func LoadSamples(userID auth.ProfileID, since time.Time) ([]Sample, error) {
+	var samples []Sample
+	file, err := os.Open(auth.ProfilesDir + string(userID) + auth.MonitorFile)
+	if os.IsNotExist(err) {
+		return samples, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var sample Sample
+		// a line could be cut short if the monitor was killed while writing it
+		if json.Unmarshal(scanner.Bytes(), &sample) == nil && !sample.At.Before(since) {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples, scanner.Err()
+}
+
+// PruneSamples is a function to drop the samples of the user with id value of given user id older than MonitorRetention.
+func PruneSamples(userID auth.ProfileID) error {
+	samples, err := LoadSamples(userID, time.Now().Add(-MonitorRetention))
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	for _, sample := range samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	return os.WriteFile(auth.ProfilesDir+string(userID)+auth.MonitorFile, data, 0644)
+}
+
+// FilterSamples is a function to get the samples taken while the connection was labeled with label.
+func FilterSamples(samples []Sample, label string) []Sample {
+	var filtered []Sample
+	for _, sample := range samples {
+		if sample.Label == label {
+			filtered = append(filtered, sample)
+		}
+	}
+	return filtered
+}
+
+// Summarize is a function to summarize the samples by location, sorted by the number of samples.
+func Summarize(samples []Sample) []LocationReport {
+	byLocation := make(map[string][]Sample)
+	for _, sample := range samples {
+		byLocation[sample.Location] = append(byLocation[sample.Location], sample)
+	}
+
+	var reports []LocationReport
+	for location, samples := range byLocation {
+		report := LocationReport{Location: location, Samples: len(samples)}
+		var latencies []float64
+		for _, sample := range samples {
+			report.Loss += sample.Loss
+			if sample.Loss < 1 {
+				latencies = append(latencies, sample.Latency)
+				report.Latency += sample.Latency
+				report.Jitter += sample.Jitter
+			}
+		}
+
+		report.Loss /= float64(len(samples))
+		report.Uptime = float64(len(latencies)) / float64(len(samples))
+		if len(latencies) > 0 {
+			report.Latency /= float64(len(latencies))
+			report.Jitter /= float64(len(latencies))
+			sort.Float64s(latencies)
+			report.P95 = latencies[(len(latencies)*95+99)/100-1]
+		}
+
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Samples != reports[j].Samples {
+			return reports[i].Samples > reports[j].Samples
+		}
+		return reports[i].Location < reports[j].Location
+	})
+
+	return reports
+}
+
+// PrintReport is a function to print the reports as a table.
+func PrintReport(reports []LocationReport) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Location", "Samples", "Latency", "P95", "Jitter", "Loss", "Uptime"})
+	table.SetBorder(false)
+	for _, r := range reports {
+		table.Append([]string{
+			r.Location,
+			fmt.Sprint(r.Samples),
+			fmt.Sprintf("%.0f ms", r.Latency),
+			fmt.Sprintf("%.0f ms", r.P95),
+			fmt.Sprintf("%.1f ms", r.Jitter),
+			fmt.Sprintf("%.1f%%", r.Loss*100),
+			fmt.Sprintf("%.1f%%", r.Uptime*100),
+		})
+	}
+
+	table.Render()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
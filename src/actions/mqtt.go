@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"encoding/json"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/crash"
+	"github.com/forestvpn/cli/utils"
+)
+
+// StateMessage is a structure that is published to the MQTT broker on every change of the connection state.
+type StateMessage struct {
+	State     string `json:"state"`
+	Location  string `json:"location,omitempty"`
+	Country   string `json:"country,omitempty"`
+	Label     string `json:"label,omitempty"`
+	RxBytes   int64  `json:"rx_bytes"`
+	TxBytes   int64  `json:"tx_bytes"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// StatePublisher is a structure that publishes the state of the connection to the MQTT broker once it changes,
+// so 'fvpn daemon' reports the transitions made by its own tasks and by the other commands alike.
+type StatePublisher struct {
+	last StateRecord
+}
+
+// Publish is a method to publish the state of the connection of the user with id value of given user id if the state file changed since the last call.
+func (p *StatePublisher) Publish(state *State, userID auth.ProfileID) error {
+	record, err := LoadStateRecord()
+	if err != nil {
+		return err
+	}
+
+	if record.State == p.last.State && record.Location == p.last.Location && record.Label == p.last.Label {
+		return nil
+	}
+	p.last = record
+
+	location := forestvpn_api.Location{}
+	if record.State == "up" {
+		device, err := ConnectedDevice(userID)
+		if err != nil {
+			return err
+		}
+		location = device.GetLocation()
+	}
+
+	state.PublishState(location)
+	return nil
+}
+
+// PublishState is a method that publishes the state of the connection to the MQTT broker set with 'fvpn config set mqtt'.
+// It does nothing if the broker is not set. Publishing errors are reported to Sentry and never interrupt the command.
+func (s *State) PublishState(location forestvpn_api.Location) {
+	c, err := config.Load()
+	if err != nil {
+		crash.CaptureException(err)
+		return
+	}
+
+	broker := c.Get(config.MQTT)
+	if len(broker) == 0 {
+		return
+	}
+
+	message := StateMessage{State: "down", Timestamp: time.Now().Unix()}
+	if s.GetStatus() {
+		country := location.GetCountry()
+		message.State = "up"
+		message.Location = location.GetName()
+		message.Country = country.GetName()
+		if db, err := auth.LoadUserDB(); err == nil {
+			if profile, err := db.SignedInUser(); err == nil {
+				message.Label = LoadLabel(profile.ID)
+			}
+		}
+		message.RxBytes, message.TxBytes, _ = utils.WireguardTransfer(s.WiregaurdInterface)
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		crash.CaptureException(err)
+		return
+	}
+
+	if err := utils.MQTTPublish(broker, c.Get(config.MQTTTopic), payload); err != nil {
+		crash.CaptureException(err)
+		if utils.Verbose {
+			utils.InfoLogger.Println(err)
+		}
+	}
+}
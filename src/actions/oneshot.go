@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"os"
+	"strings"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+)
+
+// UseLocationOnce is a method to move the device of the user to the location for a single connection.
+// The default location is remembered, so RestoreDefaultLocation moves the device back once the connection is set down.
+func (w AuthClientWrapper) UseLocationOnce(userID auth.ProfileID, state *State, location forestvpn_api.Location) (*forestvpn_api.Device, error) {
+	device, err := auth.LoadDevice(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := device.GetLocation()
+	if current.GetId() == location.GetId() {
+		return device, nil
+	}
+
+	// the default of the previous one-shot connection is kept, if it wasn't restored
+	if _, ok := LoadDefaultLocation(userID); !ok {
+		if err := os.WriteFile(auth.ProfilesDir+string(userID)+auth.DefaultLocationFile, []byte(current.GetId()), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return w.SwitchLocation(userID, state, location)
+}
+
+// LoadDefaultLocation is a function to read the UUID of the default location of the user with id value of given user id.
+// Returns false unless the connection uses another location with 'fvpn state up --location'.
+func LoadDefaultLocation(userID auth.ProfileID) (string, bool) {
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.DefaultLocationFile)
+	if err != nil {
+		return "", false
+	}
+
+	id := strings.TrimSpace(string(data))
+	return id, len(id) > 0
+}
+
+// ClearDefaultLocation is a function to forget the default location, e.g. once another one is set with 'fvpn location set'.
+func ClearDefaultLocation(userID auth.ProfileID) {
+	_ = os.Remove(auth.ProfilesDir + string(userID) + auth.DefaultLocationFile)
+}
+
+// RestoreDefaultLocation is a method to move the device of the user back to the default location after a one-shot connection.
+// It does nothing unless the connection used another location with 'fvpn state up --location'.
+func (w AuthClientWrapper) RestoreDefaultLocation(userID auth.ProfileID, state *State) error {
+	id, ok := LoadDefaultLocation(userID)
+	if !ok {
+		return nil
+	}
+
+	if _, err := w.SwitchLocation(userID, state, forestvpn_api.Location{Id: id}); err != nil {
+		return err
+	}
+
+	ClearDefaultLocation(userID)
+	return nil
+}
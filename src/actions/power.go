@@ -0,0 +1,44 @@
+package actions
+
+import (
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// Keepalive is a function to get the persistent keepalive interval for the peers, in seconds or off,
+// taking the battery-keepalive setting on battery.
+func Keepalive() string {
+	c, err := config.Load()
+	if err != nil {
+		return "off"
+	}
+
+	if utils.OnBattery() {
+		return c.Get(config.BatteryKeepalive)
+	}
+
+	return c.Get(config.Keepalive)
+}
+
+// ApplyKeepalive is a method to set the persistent keepalive interval of the running Wireguard interface, e.g. after switching to battery.
+func (s *State) ApplyKeepalive(device *forestvpn_api.Device, keepalive string) error {
+	for _, peer := range device.Wireguard.GetPeers() {
+		if err := utils.Run("wg", "set", s.WiregaurdInterface, "peer", peer.GetPubKey(), "persistent-keepalive", keepalive); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PauseOnMetered is a function to check whether the automatic reconnects and failovers are paused, as the connection is metered
+// and the metered setting is pause.
+func PauseOnMetered() bool {
+	c, err := config.Load()
+	if err != nil {
+		return false
+	}
+
+	return c.Get(config.Metered) == "pause" && utils.IsMetered()
+}
@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// QuotaThresholds are the percentages of the quota to warn about once the used data reaches them.
+var QuotaThresholds = []int{80, 90, 100}
+
+// Quota is a structure holding the monthly data quota of the profile and the data sent and received through the connection this month.
+type Quota struct {
+	// Limit is the data quota in bytes; 0 means the usage is only tracked.
+	Limit int64 `json:"limit"`
+	// Disconnect is whether to set the connection down once the quota is used up.
+	Disconnect bool   `json:"disconnect"`
+	Month      string `json:"month"`
+	Rx         int64  `json:"rx"`
+	Tx         int64  `json:"tx"`
+	// Warned is the highest of the QuotaThresholds already warned about this month.
+	Warned int `json:"warned"`
+	// LastRx and LastTx are the transfer counters of the interface at the last record, which start from zero every time the interface is set up.
+	LastRx int64 `json:"last_rx"`
+	LastTx int64 `json:"last_tx"`
+}
+
+// LoadQuota is a function to read the quota of the user with id value of given user id.
+// Returns an empty Quota if nothing has been recorded yet.
+func LoadQuota(userID auth.ProfileID) (Quota, error) {
+	var quota Quota
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.QuotaFile)
+	if os.IsNotExist(err) {
+		return quota, nil
+	} else if err != nil {
+		return quota, err
+	}
+
+	return quota, json.Unmarshal(data, &quota)
+}
+
+// SaveQuota is a function to store the quota of the user with id value of given user id.
+func SaveQuota(userID auth.ProfileID, quota Quota) error {
+	data, err := json.Marshal(quota)
+	if err != nil {
+		return err
+	}
+
+	return auth.JsonDump(data, auth.ProfilesDir+string(userID)+auth.QuotaFile)
+}
+
+// Used is a method to get the data sent and received this month in bytes.
+func (q *Quota) Used() int64 {
+	return q.Rx + q.Tx
+}
+
+// Percent is a method to get the share of the quota used this month, or 0 if there is no quota.
+func (q *Quota) Percent() int {
+	if q.Limit <= 0 {
+		return 0
+	}
+
+	return int(q.Used() * 100 / q.Limit)
+}
+
+// Exceeded is a method to check whether the quota is used up.
+func (q *Quota) Exceeded() bool {
+	return q.Limit > 0 && q.Used() >= q.Limit
+}
+
+// Add is a method to account the transfer counters rx and tx of the interface read at now.
+// The usage starts over with a new month, and counters lower than the last ones are taken as the interface set up anew.
+// Returns the highest of the QuotaThresholds reached since the last call, or 0.
+func (q *Quota) Add(rx int64, tx int64, now time.Time) int {
+	month := now.Format("2006-01")
+	if q.Month != month {
+		q.Month, q.Rx, q.Tx, q.Warned = month, 0, 0, 0
+	}
+
+	if rx < q.LastRx || tx < q.LastTx {
+		q.LastRx, q.LastTx = 0, 0
+	}
+
+	q.Rx += rx - q.LastRx
+	q.Tx += tx - q.LastTx
+	q.LastRx, q.LastTx = rx, tx
+
+	reached := 0
+	for _, threshold := range QuotaThresholds {
+		if q.Limit > 0 && q.Percent() >= threshold && threshold > q.Warned {
+			reached = threshold
+		}
+	}
+
+	if reached > 0 {
+		q.Warned = reached
+	}
+
+	return reached
+}
+
+// RecordUsage is a method to account the data transferred through the running interface since the last record to the quota of the user
+// with id value of given user id. Returns the quota and the threshold reached since the last record, or 0.
+func (s *State) RecordUsage(userID auth.ProfileID) (Quota, int, error) {
+	quota, err := LoadQuota(userID)
+	if err != nil {
+		return quota, 0, err
+	}
+
+	rx, tx, err := utils.WireguardTransfer(s.WiregaurdInterface)
+	if err != nil {
+		return quota, 0, err
+	}
+
+	reached := quota.Add(rx, tx, time.Now())
+	return quota, reached, SaveQuota(userID, quota)
+}
+
+// resetUsageCounters is a function to forget the transfer counters of the previous interface once the connection is set up,
+// so the traffic of the new one is accounted from zero.
+func resetUsageCounters(userID auth.ProfileID) {
+	quota, err := LoadQuota(userID)
+	if err != nil || (quota.LastRx == 0 && quota.LastTx == 0) {
+		return
+	}
+
+	quota.LastRx, quota.LastTx = 0, 0
+	_ = SaveQuota(userID, quota)
+}
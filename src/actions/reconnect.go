@@ -0,0 +1,295 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// ReconnectInterval is the time between the checks of the connection set up with 'fvpn state up --auto-reconnect'.
+const ReconnectInterval = 10 * time.Second
+
+// ReconnectEventsKept is the number of the latest reconnects kept for 'fvpn state status'.
+const ReconnectEventsKept = 10
+
+// ReconnectEvent is a structure representing an attempt to revive the connection supervised with 'fvpn state up --auto-reconnect'.
+type ReconnectEvent struct {
+	At time.Time `json:"at"`
+	// Cause is why the connection was considered dead, e.g. the interface is gone or the endpoint doesn't handshake.
+	Cause string `json:"cause"`
+	// Action is reconnect to rebuild the tunnel to the same location, or failover to switch to the next-nearest one.
+	Action   string `json:"action"`
+	Location string `json:"location"`
+	// Attempt is the number of the attempt since the connection was last healthy, starting at 1.
+	Attempt int    `json:"attempt"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Supervision is a structure holding the supervision of the connection set up with 'fvpn state up --auto-reconnect',
+// stored in auth.ReconnectFile until 'fvpn state down'.
+type Supervision struct {
+	// Started tells the supervisor apart, so the one left from the previous connection exits.
+	Started time.Time `json:"started"`
+	// FailoverAfter is the number of failed reconnects to the same location after which the connection fails over, or 0 to keep reconnecting.
+	FailoverAfter int              `json:"failover_after"`
+	Events        []ReconnectEvent `json:"events,omitempty"`
+}
+
+// SaveSupervision is a function to store the supervision of the connection of the user with id value of given user id.
+func SaveSupervision(userID auth.ProfileID, supervision Supervision) error {
+	data, err := json.Marshal(supervision)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(auth.ProfilesDir+string(userID)+auth.ReconnectFile, data, 0644)
+}
+
+// LoadSupervision is a function to read the supervision of the connection of the user with id value of given user id.
+// Returns false unless the connection was set up with 'fvpn state up --auto-reconnect'.
+func LoadSupervision(userID auth.ProfileID) (Supervision, bool) {
+	var supervision Supervision
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.ReconnectFile)
+	if err != nil {
+		return supervision, false
+	}
+
+	return supervision, json.Unmarshal(data, &supervision) == nil
+}
+
+// ClearSupervision is a function to stop the supervision, e.g. once the connection is set down by hand.
+// The running 'fvpn state supervise' finds it gone on the next check and exits without touching the connection.
+func ClearSupervision(userID auth.ProfileID) {
+	_ = os.Remove(auth.ProfilesDir + string(userID) + auth.ReconnectFile)
+}
+
+// RecordReconnect is a function to add the event to the supervision of the connection of the user with id value of given user id,
+// keeping the latest ReconnectEventsKept. Nothing is recorded once the supervision is stopped.
+func RecordReconnect(userID auth.ProfileID, event ReconnectEvent) error {
+	supervision, ok := LoadSupervision(userID)
+	if !ok {
+		return nil
+	}
+
+	supervision.Events = append(supervision.Events, event)
+	if len(supervision.Events) > ReconnectEventsKept {
+		supervision.Events = supervision.Events[len(supervision.Events)-ReconnectEventsKept:]
+	}
+	return SaveSupervision(userID, supervision)
+}
+
+// ScheduleSupervisor is a function to run 'fvpn state supervise' in the background to supervise the connection started at started.
+// It's run as a transient systemd service where available, so it survives the end of the login session,
+// or as a detached process otherwise, the same way as ScheduleExpiry.
+func ScheduleSupervisor(started time.Time) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"state", "supervise", started.UTC().Format(time.RFC3339Nano)}
+	if utils.Os == "linux" && utils.IsAdmin() && !utils.Fake {
+		if _, err := exec.LookPath("systemd-run"); err == nil {
+			unit := fmt.Sprintf("fvpn-reconnect-%d", started.Unix())
+			if err := utils.Run("systemd-run", append([]string{"--unit", unit, "--collect", "--quiet", "--setenv", "HOME=" + os.Getenv("HOME"), executable}, args...)...); err == nil {
+				return nil
+			}
+		}
+	}
+
+	cmd := exec.Command(executable, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// DeadCause is a method to check whether the supervised connection is dead, returning why, or an empty string if it's alive.
+// Wireguard handshakes at least every 2 minutes while there is traffic, so once the latest handshake is older than FailoverThreshold,
+// the endpoint is asked to handshake right away to tell an idle connection from an unreachable endpoint.
+func (s *State) DeadCause(device *forestvpn_api.Device) string {
+	if !s.GetStatus() {
+		return "interface gone"
+	}
+
+	handshake, err := utils.WireguardLatestHandshake(s.WiregaurdInterface)
+	if err != nil || time.Since(handshake) <= FailoverThreshold {
+		return ""
+	}
+
+	// the handshake timestamps are in seconds
+	if s.AwaitHandshakeSince(device, time.Now().Add(-time.Second), HandshakeTimeout) {
+		return ""
+	}
+
+	if handshake.IsZero() {
+		return "endpoint unreachable, no handshake yet"
+	}
+	return fmt.Sprintf("endpoint unreachable, last handshake %s ago", utils.HumanizeDuration(time.Since(handshake).Round(time.Second)))
+}
+
+// Revive is a method to rebuild the dead connection of the user with id value of given user id to the same location:
+// the interface is set down if it's still there and up again, and the alternative endpoints of the location are tried
+// unless it handshakes. Returns false if the location didn't respond.
+func (s *State) Revive(userID auth.ProfileID) (bool, error) {
+	device, err := auth.LoadDevice(userID)
+	if err != nil {
+		return false, err
+	}
+
+	if s.GetStatus() {
+		if err := s.SetDown(userID); err != nil {
+			return false, err
+		}
+	}
+
+	if err := s.SetUp(userID, false); err != nil {
+		return false, err
+	}
+
+	if !s.CanReconfigure() {
+		return s.GetStatus(), nil
+	}
+	return s.AwaitHandshake(device, HandshakeTimeout) || s.RetryEndpoints(device, HandshakeTimeout), nil
+}
+
+// DaemonPid is a function to get the process ID of the running 'fvpn daemon', or 0 if it's not running.
+func DaemonPid() int {
+	data, err := os.ReadFile(auth.AppDir + auth.DaemonPidFile)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !utils.ProcessAlive(pid) {
+		return 0
+	}
+
+	return pid
+}
+
+// SaveDaemonPid is a function to record the current process as the running 'fvpn daemon', so 'fvpn state supervise' leaves the supervision to it.
+func SaveDaemonPid() error {
+	return utils.WriteFileAtomic(auth.AppDir+auth.DaemonPidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// Supervisor is a structure to revive the connection supervised with 'fvpn state up --auto-reconnect'.
+// It's run by 'fvpn daemon' as one of its tasks, or by 'fvpn state supervise' while no daemon is running.
+type Supervisor struct {
+	Wrapper AuthClientWrapper
+	State   *State
+	// started is the supervision the attempts are counted for, they start anew with another one.
+	started  time.Time
+	attempts int
+}
+
+// Check is a method to check the supervised connection of the user with id value of given user id once and revive it if it's dead,
+// failing over to the next-nearest location after the FailoverAfter failed reconnects of the supervision.
+// Returns the recorded event, or nil if the connection is alive or unsupervised, or the reconnects are paused on metered connections, see PauseOnMetered.
+func (s *Supervisor) Check(userID auth.ProfileID) (*ReconnectEvent, error) {
+	supervision, ok := LoadSupervision(userID)
+	if !ok {
+		return nil, nil
+	}
+	if !supervision.Started.Equal(s.started) {
+		s.started = supervision.Started
+		s.attempts = 0
+	}
+
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cause := s.State.DeadCause(device)
+	if len(cause) == 0 {
+		s.attempts = 0
+		return nil, nil
+	}
+	if PauseOnMetered() {
+		return nil, nil
+	}
+
+	// the connection could have been set down by hand while it was checked
+	if supervision, ok := LoadSupervision(userID); !ok || !supervision.Started.Equal(s.started) {
+		return nil, nil
+	}
+
+	s.attempts++
+	location := device.GetLocation()
+	event := ReconnectEvent{At: time.Now(), Cause: cause, Action: "reconnect", Location: location.GetName(), Attempt: s.attempts}
+	if supervision.FailoverAfter > 0 && s.attempts > supervision.FailoverAfter {
+		event.Action = "failover"
+		event.OK, err = s.Wrapper.failoverSupervised(userID, s.State)
+	} else {
+		event.OK, err = s.State.Revive(userID)
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if event.OK {
+		s.attempts = 0
+		// the location could have changed with the failover
+		if device, err = ConnectedDevice(userID); err == nil {
+			location = device.GetLocation()
+			event.Location = location.GetName()
+			s.State.PublishState(location)
+		}
+	}
+
+	if utils.Verbose {
+		utils.InfoLogger.Printf("%s after %s: ok %t %s", event.Action, event.Cause, event.OK, event.Error)
+	}
+	return &event, RecordReconnect(userID, event)
+}
+
+// Supervise is a method that checks the connection of the user with id value of given user id every ReconnectInterval with Supervisor.Check.
+// It returns once the supervision started at started is stopped or replaced, e.g. with 'fvpn state down'.
+// The checks are skipped while 'fvpn daemon' is running, as the daemon supervises the connection itself, and resume once it exits.
+func (w AuthClientWrapper) Supervise(userID auth.ProfileID, state *State, started time.Time) error {
+	supervisor := Supervisor{Wrapper: w, State: state}
+	for {
+		time.Sleep(ReconnectInterval)
+
+		supervision, ok := LoadSupervision(userID)
+		if !ok || !supervision.Started.Equal(started) {
+			return nil
+		}
+		if DaemonPid() > 0 {
+			continue
+		}
+
+		if _, err := supervisor.Check(userID); err != nil {
+			return err
+		}
+	}
+}
+
+// failoverSupervised is a method to switch the dead connection to the next-nearest location that handshakes.
+func (w AuthClientWrapper) failoverSupervised(userID auth.ProfileID, state *State) (bool, error) {
+	b, err := w.GetUnexpiredOrMostRecentBillingFeature(userID)
+	if err != nil {
+		return false, err
+	}
+
+	if !state.GetStatus() {
+		if err := state.SetUp(userID, false); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := w.FailoverOnConnect(userID, state, b.GetBundleId() != "com.forestvpn.freemium", 3); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// MissingRoutes is a method to find the routes of the running tunnel of the user with id value of given user id that are gone from the host,
+// e.g. flushed by the DHCP client on a lease renewal or by the hypervisor resetting the network. Only Linux is checked,
+// where wg-quick adds the routes once and nothing puts them back.
+func (s *State) MissingRoutes(userID auth.ProfileID) (map[string]string, error) {
+	missing := make(map[string]string)
+	if utils.Os != "linux" || utils.IsOpenWRT() || utils.IsTermux() || utils.Fake || !s.GetStatus() {
+		return missing, nil
+	}
+
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.WireguardConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	table := configTable(string(data))
+	if table == "off" {
+		return missing, nil
+	}
+
+	// without a table of its own, wg-quick routes the default network through the table matching the firewall mark of the interface
+	defaultTable := table
+	if len(table) == 0 || table == "auto" {
+		table = "main"
+		stdout, err := utils.Output("wg", "show", s.WiregaurdInterface, "fwmark")
+		if mark := strings.TrimSpace(string(stdout)); err == nil && mark != "off" && len(mark) > 0 {
+			defaultTable = mark
+		} else {
+			defaultTable = ""
+		}
+	}
+
+	live := make(map[string]map[string]bool)
+	for network := range configAllowedIPs(string(data)) {
+		if len(network) == 0 {
+			continue
+		}
+
+		lookup := table
+		if isDefaultRoute(network) {
+			if len(defaultTable) == 0 {
+				continue
+			}
+			lookup = defaultTable
+		}
+
+		if _, ok := live[lookup]; !ok {
+			if live[lookup], err = s.tableRoutes(lookup); err != nil {
+				return nil, err
+			}
+		}
+
+		if !live[lookup][normalizeRoute(network)] {
+			missing[network] = lookup
+		}
+	}
+
+	return missing, nil
+}
+
+// RestoreRoutes is a method to put back the routes of the running tunnel of the user with id value of given user id found with MissingRoutes
+// and to record it to the connection history. Returns the networks restored.
+func (s *State) RestoreRoutes(userID auth.ProfileID) ([]string, error) {
+	missing, err := s.MissingRoutes(userID)
+	if err != nil || len(missing) == 0 {
+		return nil, err
+	}
+
+	var restored []string
+	for network, table := range missing {
+		if err := utils.Run("ip", "route", "replace", network, "dev", s.WiregaurdInterface, "table", table); err != nil {
+			return restored, err
+		}
+		restored = append(restored, network)
+	}
+
+	sort.Strings(restored)
+	record, _ := LoadStateRecord()
+	entry := HistoryEntry{At: time.Now(), Event: "routes", Location: record.Location, Country: record.Country, Reason: "restored " + strings.Join(restored, ", ")}
+	return restored, AppendHistory(userID, entry)
+}
+
+// tableRoutes is a method that lists the destinations routed through the Wireguard interface in the routing table given.
+func (s *State) tableRoutes(table string) (map[string]bool, error) {
+	routes := make(map[string]bool)
+	for _, family := range []string{"-4", "-6"} {
+		stdout, err := utils.Output("ip", family, "route", "show", "table", table, "dev", s.WiregaurdInterface)
+		if err != nil {
+			// the table doesn't exist once all of its routes are gone
+			continue
+		}
+
+		for _, line := range strings.Split(string(stdout), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+
+			destination := fields[0]
+			if destination == "default" {
+				destination = "0.0.0.0/0"
+				if family == "-6" {
+					destination = "::/0"
+				}
+			}
+			routes[normalizeRoute(destination)] = true
+		}
+	}
+
+	return routes, nil
+}
+
+// normalizeRoute is a function that turns the network into the form 'ip route' prints it in, e.g. 10.0.0.1/32 into 10.0.0.1.
+func normalizeRoute(network string) string {
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return network
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if ones == bits {
+		return ipnet.IP.String()
+	}
+	return ipnet.String()
+}
@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// RoutingTableStart is the first routing table tried with the auto routing-table setting, next to 51820 used by wg-quick.
+const RoutingTableStart = 51821
+
+// RoutingPriority is the priority of the policy routing rules of the tunnel, ahead of the ones wg-quick adds for other tunnels
+// right before the main table at 32766.
+const RoutingPriority = 32000
+
+// Routing is a structure of the routing table and the firewall mark of the tunnel on Linux.
+// The zero Routing leaves the routing to wg-quick.
+type Routing struct {
+	Table int
+	Mark  uint32
+}
+
+// LoadRouting is a function to get the routing of the tunnel of the user with id value of given user id according to the routing-table
+// and fwmark settings. With auto, a free table is picked once and kept in the profile, so it stays the same while connected.
+// The routing is left to wg-quick with off, unless coexist is set as another tunnel takes all the traffic, and where wg-quick isn't used.
+func LoadRouting(userID auth.ProfileID, coexist bool) (Routing, error) {
+	var routing Routing
+	if utils.Os != "linux" || utils.IsOpenWRT() || utils.IsTermux() {
+		return routing, nil
+	}
+
+	c, err := config.Load()
+	if err != nil {
+		return routing, err
+	}
+
+	switch value := c.Get(config.RoutingTable); value {
+	case "off":
+		if !coexist {
+			return routing, nil
+		}
+		// both tunnels would add the default route to the table 51820 otherwise
+		fallthrough
+	case "auto":
+		routing.Table, err = autoRoutingTable(userID)
+	default:
+		routing.Table, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		return routing, err
+	}
+
+	routing.Mark = uint32(routing.Table)
+	if value := c.Get(config.Fwmark); value != "auto" {
+		routing.Mark, err = config.ParseFwmark(value)
+	}
+
+	return routing, err
+}
+
+// Hooks is a method to get the PostUp and PostDown commands of wg-quick adding and removing the policy routing rules for the families
+// of allowedIPs. The unmarked traffic is routed through the table, while the encrypted packets of the tunnel and other tunnels are marked
+// and bypass it, so they don't loop. With a default route among allowedIPs, the more specific routes of the main table, e.g. the LAN,
+// are still used first, as wg-quick does.
+func (r Routing) Hooks(allowedIPs []string) ([]string, []string) {
+	var families []string
+	for _, family := range []string{"-4", "-6"} {
+		for _, network := range allowedIPs {
+			if strings.Contains(network, ":") == (family == "-6") {
+				families = append(families, family)
+				break
+			}
+		}
+	}
+
+	var postUp, postDown []string
+	for _, family := range families {
+		rules := []string{fmt.Sprintf("ip %s rule %%s fwmark 0/0xffffffff table %d priority %d", family, r.Table, RoutingPriority)}
+		for _, network := range allowedIPs {
+			if isDefaultRoute(network) && strings.Contains(network, ":") == (family == "-6") {
+				rules = append(rules, fmt.Sprintf("ip %s rule %%s table main suppress_prefixlength 0 priority %d", family, RoutingPriority-1))
+				break
+			}
+		}
+
+		for _, rule := range rules {
+			postUp = append(postUp, fmt.Sprintf(rule, "add"))
+			postDown = append(postDown, fmt.Sprintf(rule, "del")+" || true")
+		}
+	}
+
+	return postUp, postDown
+}
+
+// autoRoutingTable is a function that reads the routing table picked for the profile, or picks a free one and keeps it.
+func autoRoutingTable(userID auth.ProfileID) (int, error) {
+	path := auth.ProfilesDir + string(userID) + auth.RoutingTableFile
+	if data, err := os.ReadFile(path); err == nil {
+		if table, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			return table, nil
+		}
+	}
+
+	table, err := utils.FreeRoutingTable(RoutingTableStart)
+	if err != nil {
+		// e.g. a container without iproute2, where wg-quick can't set the connection up either
+		if utils.Verbose {
+			utils.InfoLogger.Println(err)
+		}
+		return RoutingTableStart, nil
+	}
+
+	return table, os.WriteFile(path, []byte(strconv.Itoa(table)), 0644)
+}
+
+// configTable is a function that parses Table of the Wireguard configuration, which 'wg-quick strip' drops.
+func configTable(config string) string {
+	for _, line := range strings.Split(config, "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if found && strings.EqualFold(strings.TrimSpace(key), "Table") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// liveTable is a function that returns the routing table the policy routing rule of the running tunnel looks up, if any.
+func liveTable() string {
+	for _, family := range []string{"-4", "-6"} {
+		stdout, err := utils.Output("ip", family, "rule", "show", "priority", strconv.Itoa(RoutingPriority))
+		if err != nil {
+			continue
+		}
+
+		fields := strings.Fields(string(stdout))
+		for i := 0; i < len(fields)-1; i++ {
+			if fields[i] == "lookup" {
+				return fields[i+1]
+			}
+		}
+	}
+	return ""
+}
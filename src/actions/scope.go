@@ -0,0 +1,345 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// AllNetworks are the networks routed through the tunnel with the all tunnel scope.
+var AllNetworks = []string{"0.0.0.0/0", "::/0"}
+
+// RouteExclusion is a structure of a network kept out of the tunnel with the reason, e.g. the local network or an SSH session.
+type RouteExclusion struct {
+	Network string
+	Reason  string
+}
+
+// RoutePlan is a structure describing how the networks routed through the tunnel are made: the networks of the tunnel-scope setting,
+// the DNS servers added to them, the networks excluded, and the resulting AllowedIPs of the WireGuard configuration.
+type RoutePlan struct {
+	Scope      string
+	Networks   []string
+	DNS        []string
+	Exclusions []RouteExclusion
+	AllowedIPs []string
+}
+
+// AllowedIPs is a function to get the networks routed through the tunnel to the peers of the device according to the tunnel-scope setting,
+// see PlanRoutes.
+func AllowedIPs(device *forestvpn_api.Device) ([]string, error) {
+	plan, err := PlanRoutes(device)
+	return plan.AllowedIPs, err
+}
+
+// PlanRoutes is a function to work out the networks routed through the tunnel to the peers of the device according to the tunnel-scope setting.
+// The DNS servers of the device are always routed through the tunnel, as they're usually in a private network on the other side.
+// The network of the active SSH client is excluded on Linux, so setting up the connection doesn't drop the session,
+// and so are the networks of the container bridges unless the container-networks setting is warn,
+// and the networks of the other tunnels of the host, e.g. the tailnet. On macOS and Windows the endpoints of the peers are excluded too
+// unless the networks include 0.0.0.0/0 or ::/0. On Linux, the all and internet-only scopes start from the AllowedIPs given by back-end.
+func PlanRoutes(device *forestvpn_api.Device) (RoutePlan, error) {
+	c, err := config.Load()
+	if err != nil {
+		return RoutePlan{}, err
+	}
+
+	plan := RoutePlan{Scope: c.Get(config.TunnelScope)}
+	switch plan.Scope {
+	case "all":
+		plan.Networks = peerNetworks(device)
+	case "custom":
+		plan.Networks, err = utils.ParseCIDRs(c.Get(config.TunnelCIDRs))
+		if err != nil {
+			return plan, err
+		}
+		if len(plan.Networks) == 0 {
+			return plan, errors.New("tunnel-scope is custom, but no networks are set, try 'fvpn config set tunnel-cidrs 203.0.113.0/24'")
+		}
+	default:
+		plan.Networks = peerNetworks(device)
+		for _, network := range utils.PrivateNetworks {
+			plan.Exclusions = append(plan.Exclusions, RouteExclusion{Network: network, Reason: "local network, see tunnel-scope"})
+		}
+	}
+
+	allowedIPs := plan.Networks
+	if len(plan.Exclusions) > 0 {
+		if allowedIPs, err = utils.ExcludeNetworks(allowedIPs, utils.PrivateNetworks); err != nil {
+			return plan, err
+		}
+	}
+
+	if plan.Scope != "all" {
+		plan.DNS = utils.HostNetworks(device.GetDns())
+		allowedIPs = append(allowedIPs, plan.DNS...)
+	}
+
+	// the DNS servers are routed through the tunnel even if they're in the local network, but not the networks excluded below
+	var excluded []RouteExclusion
+	if c.Get(config.ContainerNetworks) == "exclude" {
+		for _, conflict := range ContainerConflicts(allowedIPs) {
+			excluded = append(excluded, RouteExclusion{Network: conflict.Network, Reason: "container network of " + conflict.Interface})
+		}
+	}
+
+	for _, tunnel := range CoexistingTunnels(ownInterface) {
+		for _, network := range coexistNetworks([]utils.Tunnel{tunnel}) {
+			excluded = append(excluded, RouteExclusion{Network: network, Reason: "network of the tunnel " + tunnel.Interface})
+		}
+	}
+
+	if activeSShClient := utils.GetActiveSshClient(); len(activeSShClient) > 0 && utils.Os == "linux" {
+		excluded = append(excluded, RouteExclusion{Network: activeSShClient, Reason: "active SSH session"})
+	}
+
+	// wg-quick routes the endpoints around the tunnel with the fwmark on Linux, while wg-quick on macOS and the Wireguard service on Windows
+	// only do it for a /0 network, so the endpoints are kept out of a split list there, otherwise the tunnel is routed through itself
+	if utils.Os != "linux" {
+		routed := make(map[string]bool)
+		for _, network := range allowedIPs {
+			routed[network] = true
+		}
+
+		for _, network := range endpointNetworks(device) {
+			if routed[AllNetworks[0]] && strings.HasSuffix(network, "/32") || routed[AllNetworks[1]] && strings.HasSuffix(network, "/128") {
+				continue
+			}
+			excluded = append(excluded, RouteExclusion{Network: network, Reason: "endpoint of the peer"})
+		}
+	}
+
+	for _, exclusion := range excluded {
+		if allowedIPs, err = utils.ExcludeNetworks(allowedIPs, []string{exclusion.Network}); err != nil {
+			return plan, err
+		}
+	}
+
+	plan.Exclusions = append(plan.Exclusions, excluded...)
+	plan.AllowedIPs = allowedIPs
+	return plan, nil
+}
+
+// peerNetworks is a function to get the networks to route through the peers of the device before the exclusions:
+// the AllowedIPs given by back-end on Linux, and AllNetworks elsewhere or if back-end gives none.
+func peerNetworks(device *forestvpn_api.Device) []string {
+	if utils.Os != "linux" {
+		return AllNetworks
+	}
+
+	var networks []string
+	seen := make(map[string]bool)
+	for _, peer := range device.Wireguard.GetPeers() {
+		for _, network := range peer.GetAllowedIps() {
+			if !seen[network] {
+				seen[network] = true
+				networks = append(networks, network)
+			}
+		}
+	}
+
+	if len(networks) == 0 {
+		return AllNetworks
+	}
+	return networks
+}
+
+// endpointNetworks is a function to get the host networks of the endpoints of the peers of the device as they're written to the configuration,
+// resolving the host names.
+func endpointNetworks(device *forestvpn_api.Device) []string {
+	var networks []string
+	rewrite := endpointRewriter()
+	for _, peer := range device.Wireguard.GetPeers() {
+		host, _, err := net.SplitHostPort(rewrite(peer.GetEndpoint()))
+		if err != nil {
+			continue
+		}
+
+		addresses := []string{host}
+		if net.ParseIP(host) == nil {
+			addresses, _ = net.LookupHost(host)
+		}
+		networks = append(networks, utils.HostNetworks(addresses)...)
+	}
+
+	return networks
+}
+
+// ContainerConflicts is a function to find the networks of the container interfaces, e.g. docker0, overlapping allowedIPs,
+// which would be routed through the tunnel and break the networking of the containers. Only Linux is checked,
+// as Docker Desktop keeps the container networks inside its virtual machine elsewhere.
+func ContainerConflicts(allowedIPs []string) []utils.ContainerNetwork {
+	if utils.Os != "linux" {
+		return nil
+	}
+
+	networks, err := utils.ContainerNetworks()
+	if err != nil {
+		if utils.Verbose {
+			utils.InfoLogger.Println(err)
+		}
+		return nil
+	}
+
+	var conflicts []utils.ContainerNetwork
+	for _, network := range networks {
+		for _, allowed := range allowedIPs {
+			if utils.Overlaps(allowed, network.Network) {
+				conflicts = append(conflicts, network)
+				break
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// ResolveContainerConflicts is a method to check the Wireguard configuration of the user with id value of given user id
+// against the container networks right before setting the connection up, as the containers could have started since it was written.
+// The configuration is written anew to exclude the conflicting networks unless the container-networks setting is warn.
+// Returns the conflicts and whether they were excluded.
+func (w AuthClientWrapper) ResolveContainerConflicts(device *forestvpn_api.Device, userID auth.ProfileID) ([]utils.ContainerNetwork, bool, error) {
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.WireguardConfig)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var allowedIPs []string
+	for network := range configAllowedIPs(string(data)) {
+		allowedIPs = append(allowedIPs, network)
+	}
+
+	conflicts := ContainerConflicts(allowedIPs)
+	c, err := config.Load()
+	if err != nil || len(conflicts) == 0 || c.Get(config.ContainerNetworks) == "warn" {
+		return conflicts, false, err
+	}
+
+	return conflicts, true, w.SetLocation(device, userID)
+}
+
+// CapturedRoute is a structure of a route of the host the traffic of which is taken over by the tunnel, entirely or only for some networks.
+type CapturedRoute struct {
+	Route    utils.HostRoute
+	Networks []string
+	Entire   bool
+}
+
+// CaptureRoutes is a function to find the routes of the host the tunnel routed to allowedIPs takes over. In the main table the most specific
+// route wins, so a route is only taken over for the networks of allowedIPs at least as specific as it. With a routing table of its own,
+// looked up ahead of the main one, the tunnel takes over every route its networks overlap. Either way, with a default route among allowedIPs
+// the main table is looked up first for everything but the default route, so only the default route is taken over, as wg-quick does.
+func CaptureRoutes(routes []utils.HostRoute, allowedIPs []string, ownTable bool) []CapturedRoute {
+	defaults := make(map[bool]bool)
+	for _, allowed := range allowedIPs {
+		if isDefaultRoute(allowed) {
+			defaults[strings.Contains(allowed, ":")] = true
+		}
+	}
+
+	var captured []CapturedRoute
+	for _, route := range routes {
+		if route.Device == ownInterface {
+			continue
+		}
+
+		_, destination, err := net.ParseCIDR(route.Destination)
+		if err != nil {
+			continue
+		}
+		ones, _ := destination.Mask.Size()
+		ipv6 := strings.Contains(route.Destination, ":")
+
+		capture := CapturedRoute{Route: route}
+		for _, allowed := range allowedIPs {
+			_, network, err := net.ParseCIDR(allowed)
+			if err != nil || strings.Contains(allowed, ":") != ipv6 {
+				continue
+			}
+			allowedOnes, _ := network.Mask.Size()
+
+			var entire, partial bool
+			switch {
+			case defaults[ipv6]:
+				entire = allowedOnes == 0 && ones == 0
+			case ownTable:
+				entire = allowedOnes <= ones && network.Contains(destination.IP)
+				partial = allowedOnes > ones && destination.Contains(network.IP)
+			default:
+				entire = allowedOnes == ones && network.IP.Equal(destination.IP)
+				partial = allowedOnes > ones && destination.Contains(network.IP)
+			}
+
+			if entire {
+				capture.Entire, capture.Networks = true, []string{allowed}
+				break
+			} else if partial {
+				capture.Networks = append(capture.Networks, allowed)
+			}
+		}
+
+		if len(capture.Networks) > 0 {
+			captured = append(captured, capture)
+		}
+	}
+
+	return captured
+}
+
+// Describe is a method that lists the networks routed through the tunnel, the exclusions with their reasons,
+// and on Linux the routes of the host the tunnel of the user with id value of given user id takes over and the ones it keeps,
+// one per line, e.g. for 'fvpn state up --dry-run'.
+func (p RoutePlan) Describe(userID auth.ProfileID) []string {
+	lines := []string{fmt.Sprintf("Tunnel scope %s routes %s", p.Scope, strings.Join(p.Networks, ", "))}
+	if len(p.DNS) > 0 {
+		lines = append(lines, "DNS servers of the location routed through the tunnel: "+strings.Join(p.DNS, ", "))
+	}
+
+	for _, exclusion := range p.Exclusions {
+		lines = append(lines, fmt.Sprintf("Excluded %s: %s", exclusion.Network, exclusion.Reason))
+	}
+	lines = append(lines, "AllowedIPs: "+strings.Join(p.AllowedIPs, ", "))
+
+	if utils.Os != "linux" || utils.Fake {
+		return lines
+	}
+
+	routes, err := utils.HostRoutes()
+	if err != nil {
+		return append(lines, fmt.Sprintf("Routes of the host are unknown: %s", err))
+	}
+
+	routing, err := LoadRouting(userID, coexistDefault(CoexistingTunnels(ownInterface)))
+	if err != nil {
+		return append(lines, fmt.Sprintf("Routing of the tunnel is unknown: %s", err))
+	}
+
+	captured := CaptureRoutes(routes, p.AllowedIPs, routing.Table > 0)
+	taken := make(map[utils.HostRoute]bool)
+	for _, capture := range captured {
+		taken[capture.Route] = true
+		switch {
+		case capture.Entire:
+			lines = append(lines, fmt.Sprintf("Taken over: %s", capture.Route))
+		case len(capture.Networks) == 1:
+			lines = append(lines, fmt.Sprintf("Taken over for %s: %s", capture.Networks[0], capture.Route))
+		default:
+			lines = append(lines, fmt.Sprintf("Taken over for %d networks, e.g. %s: %s", len(capture.Networks), capture.Networks[0], capture.Route))
+		}
+	}
+
+	for _, route := range routes {
+		if !taken[route] && route.Device != ownInterface {
+			lines = append(lines, fmt.Sprintf("Kept: %s", route))
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,131 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// CipherSuite is the handshake and encryption of the WireGuard protocol, which are fixed rather than negotiated.
+const CipherSuite = "Noise_IKpsk2_25519_ChaChaPoly_BLAKE2s"
+
+// RekeyAfter is the interval WireGuard renegotiates the session keys at, fixed by the protocol.
+const RekeyAfter = 2 * time.Minute
+
+// RejectAfter is the age of the session keys after which WireGuard stops using them, fixed by the protocol.
+const RejectAfter = 3 * time.Minute
+
+// SecurityCheck is a structure of an item of the security posture report.
+// The checks of Weight 0 are informational and left out of the score.
+type SecurityCheck struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Passed bool   `json:"passed"`
+	Weight int    `json:"weight"`
+}
+
+// SecurityReport is a structure of the security posture of the connection with the score from 0 to 100.
+type SecurityReport struct {
+	Checks []SecurityCheck `json:"checks"`
+	Score  int             `json:"score"`
+}
+
+// CheckSecurity is a method to check the cipher suite, the handshake, the preshared key, DNS, the kill switch
+// and the leaks of the connection of the user with id value of given user id.
+func (s *State) CheckSecurity(userID auth.ProfileID) (SecurityReport, error) {
+	var report SecurityReport
+	if !s.GetStatus() {
+		return report, fmt.Errorf("%s is down, the security posture is only checked while connected", s.WiregaurdInterface)
+	}
+
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return report, err
+	}
+
+	report.Checks = append(report.Checks,
+		SecurityCheck{Name: "Cipher suite", Value: CipherSuite, Passed: true, Weight: 10},
+		SecurityCheck{Name: "Rekey interval", Value: "every " + RekeyAfter.String(), Passed: true},
+	)
+
+	handshake := SecurityCheck{Name: "Last handshake", Value: "never", Weight: 25}
+	if latest, err := utils.WireguardLatestHandshake(s.WiregaurdInterface); err != nil {
+		handshake.Value = "unknown: " + err.Error()
+	} else if !latest.IsZero() {
+		age := time.Since(latest)
+		handshake.Value = utils.HumanizeDuration(age) + " ago"
+		handshake.Passed = age < RejectAfter
+	}
+	report.Checks = append(report.Checks, handshake)
+
+	psk := SecurityCheck{Name: "Preshared key", Value: "absent", Weight: 15}
+	for _, peer := range device.Wireguard.GetPeers() {
+		if len(peer.GetPsKey()) > 0 {
+			psk.Value, psk.Passed = "present", true
+		}
+	}
+	report.Checks = append(report.Checks, psk)
+
+	// the DNS servers of the device are always routed through the tunnel, see AllowedIPs
+	dns := SecurityCheck{Name: "DNS", Value: "system resolvers", Weight: 20}
+	if servers := device.GetDns(); len(servers) > 0 {
+		dns.Value, dns.Passed = "through the tunnel ("+strings.Join(servers, ", ")+")", true
+	}
+	report.Checks = append(report.Checks, dns)
+
+	killSwitch := SecurityCheck{Name: "Kill switch", Value: "off, see 'fvpn state up --killswitch'"}
+	if KillSwitchEnabled(userID) {
+		killSwitch.Value, killSwitch.Passed = "on", true
+	}
+	report.Checks = append(report.Checks, killSwitch)
+
+	leaks := SecurityCheck{Name: "Leak test", Weight: 30}
+	if found, err := s.FindLeaks(); err != nil {
+		// the test is left out of the score where it can't run, e.g. off Linux
+		leaks.Value, leaks.Weight = "unavailable: "+err.Error(), 0
+	} else if len(found) > 0 {
+		leaks.Value = fmt.Sprintf("%d connections bypass the tunnel, see 'fvpn diagnose leaks'", len(found))
+	} else {
+		leaks.Value, leaks.Passed = "no leaks", true
+	}
+	report.Checks = append(report.Checks, leaks)
+
+	total, passed := 0, 0
+	for _, check := range report.Checks {
+		total += check.Weight
+		if check.Passed {
+			passed += check.Weight
+		}
+	}
+
+	if total > 0 {
+		report.Score = passed * 100 / total
+	}
+
+	return report, nil
+}
+
+// PrintSecurityReport is a function that prints the checks of the report as a table followed by the score.
+func PrintSecurityReport(report SecurityReport) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Check", "Value", "Result"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, check := range report.Checks {
+		result := "fail"
+		if check.Weight == 0 {
+			result = "-"
+		} else if check.Passed {
+			result = "pass"
+		}
+		table.Append([]string{check.Name, check.Value, result})
+	}
+
+	table.Render()
+	fmt.Printf("Score: %d/100\n", report.Score)
+}
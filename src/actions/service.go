@@ -0,0 +1,44 @@
+package actions
+
+import (
+	"fmt"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/utils"
+)
+
+// PrintServiceStatus is a function that prints the overall status of page and the incidents and maintenances affecting location.
+// The others are only counted, as they don't affect the connection of the user. All of them are printed if location is empty.
+func PrintServiceStatus(page utils.StatusPage, location forestvpn_api.Location) {
+	country := location.GetCountry()
+	affects := func(incident utils.Incident) bool {
+		return len(location.GetName()) == 0 || incident.Affects(location.GetName(), country.GetName())
+	}
+	fmt.Println(page.Status.Description)
+
+	var elsewhere int
+	for _, incident := range page.Incidents {
+		if !affects(incident) {
+			elsewhere++
+			continue
+		}
+		fmt.Printf("Incident: %s (%s, %s impact)\n", incident.Name, incident.Status, incident.Impact)
+	}
+
+	for _, maintenance := range page.ScheduledMaintenances {
+		if !affects(maintenance) {
+			elsewhere++
+			continue
+		}
+
+		fmt.Printf("Maintenance: %s (%s", maintenance.Name, maintenance.Status)
+		if maintenance.ScheduledFor != nil && maintenance.ScheduledUntil != nil {
+			fmt.Printf(", %s - %s", utils.FormatTime(*maintenance.ScheduledFor), utils.FormatTime(*maintenance.ScheduledUntil))
+		}
+		fmt.Println(")")
+	}
+
+	if elsewhere > 0 {
+		fmt.Printf("%d more incidents or maintenances don't affect %s\n", elsewhere, location.GetName())
+	}
+}
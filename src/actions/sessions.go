@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/forestvpn/cli/api"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ListSessions is a method to print the active sessions of the user across the machines, the most recently used first, marking the session of this machine.
+func (w AuthClientWrapper) ListSessions() error {
+	sessions, err := w.ApiClient.ListSessions()
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return lastUsed(sessions[i]).After(lastUsed(sessions[j]))
+	})
+
+	var data [][]string
+	for _, session := range sessions {
+		name := session.Name
+		if session.Current {
+			name += " (this machine)"
+		}
+
+		lastUsedAt := "never"
+		if session.LastUsedAt != nil {
+			lastUsedAt = utils.FormatTime(*session.LastUsedAt)
+		}
+
+		data = append(data, []string{name, utils.FormatTime(session.CreatedAt), lastUsedAt, session.ID})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Signed in", "Last used", "ID"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// FindSessions is a method to find the sessions of the user to revoke with RevokeSessions: the one with id, or all the others if others is set.
+// The session of this machine can't be revoked this way.
+func (w AuthClientWrapper) FindSessions(id string, others bool) ([]api.Session, error) {
+	sessions, err := w.ApiClient.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []api.Session
+	for _, session := range sessions {
+		if others && !session.Current {
+			found = append(found, session)
+		} else if !others && session.ID == id {
+			if session.Current {
+				return nil, errors.New("this is the session of this machine, use 'fvpn account logout' instead")
+			}
+			found = append(found, session)
+		}
+	}
+
+	if !others && len(found) == 0 {
+		return nil, fmt.Errorf("no such session: %s", id)
+	}
+
+	return found, nil
+}
+
+// RevokeSessions is a method to end the sessions found with FindSessions, so those machines have to log in again.
+func (w AuthClientWrapper) RevokeSessions(sessions []api.Session) error {
+	for _, session := range sessions {
+		if err := w.ApiClient.RevokeSession(session.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lastUsed is a function that returns the time the session was last used at, or started at if it's not known.
+func lastUsed(session api.Session) time.Time {
+	if session.LastUsedAt != nil {
+		return *session.LastUsedAt
+	}
+	return session.CreatedAt
+}
@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// PackageManager is a structure describing the command to install packages with.
+type PackageManager struct {
+	Name    string
+	Install []string
+	// Root is true if the packages are installed as root, with sudo if needed.
+	Root bool
+}
+
+// Dependency is a structure describing the command fvpn depends on and the packages providing it per package manager.
+// The dependency is not needed with the package managers missing in Packages.
+type Dependency struct {
+	Command  string
+	Packages map[string]string
+}
+
+var packageManagers = []PackageManager{
+	{Name: "apt-get", Install: []string{"apt-get", "install", "-y"}, Root: true},
+	{Name: "dnf", Install: []string{"dnf", "install", "-y"}, Root: true},
+	{Name: "yum", Install: []string{"yum", "install", "-y"}, Root: true},
+	{Name: "zypper", Install: []string{"zypper", "install", "-y"}, Root: true},
+	{Name: "pacman", Install: []string{"pacman", "-S", "--noconfirm"}, Root: true},
+	{Name: "apk", Install: []string{"apk", "add"}, Root: true},
+	{Name: "opkg", Install: []string{"opkg", "install"}, Root: true},
+	{Name: "brew", Install: []string{"brew", "install"}},
+	{Name: "winget", Install: []string{"winget", "install", "--exact", "--id"}},
+}
+
+// dependencies are the commands fvpn shells out to, matching the dependencies of the packages built with goreleaser.
+var dependencies = []Dependency{
+	{
+		Command: "wg",
+		Packages: map[string]string{
+			"apt-get": "wireguard-tools",
+			"dnf":     "wireguard-tools",
+			"yum":     "wireguard-tools",
+			"zypper":  "wireguard-tools",
+			"pacman":  "wireguard-tools",
+			"apk":     "wireguard-tools",
+			"opkg":    "wireguard-tools",
+			"brew":    "wireguard-tools",
+			"winget":  "WireGuard.WireGuard",
+		},
+	},
+	{
+		Command: "resolvconf",
+		Packages: map[string]string{
+			"apt-get": "openresolv",
+			"dnf":     "openresolv",
+			"yum":     "openresolv",
+			"zypper":  "openresolv",
+			"pacman":  "openresolv",
+			"apk":     "openresolv",
+		},
+	},
+	{
+		Command: "ip",
+		Packages: map[string]string{
+			"apt-get": "iproute2",
+			"dnf":     "iproute",
+			"yum":     "iproute",
+			"zypper":  "iproute2",
+			"pacman":  "iproute2",
+			"apk":     "iproute2",
+		},
+	},
+}
+
+// DetectPackageManager is a function to find the package manager of the system.
+func DetectPackageManager() (PackageManager, error) {
+	for _, pm := range packageManagers {
+		if _, err := exec.LookPath(pm.Name); err == nil {
+			return pm, nil
+		}
+	}
+
+	return PackageManager{}, errors.New("no supported package manager found, install wireguard-tools manually")
+}
+
+// MissingPackages is a function that lists the packages providing the commands fvpn depends on that are not installed.
+func MissingPackages(pm PackageManager) []string {
+	var packages []string
+	for _, dependency := range dependencies {
+		pkg, needed := dependency.Packages[pm.Name]
+		if !needed {
+			continue
+		}
+
+		if _, err := exec.LookPath(dependency.Command); err != nil {
+			packages = append(packages, pkg)
+		}
+	}
+
+	return packages
+}
+
+// InstallCommand is a method that returns the command to install packages, prefixed with sudo if root is needed.
+// winget installs a single package at a time, so it gets a command per package.
+func (pm PackageManager) InstallCommand(packages []string) [][]string {
+	var commands [][]string
+	if pm.Name == "winget" {
+		for _, pkg := range packages {
+			commands = append(commands, append(append([]string{}, pm.Install...), pkg))
+		}
+		return commands
+	}
+
+	command := append(append([]string{}, pm.Install...), packages...)
+	if pm.Root && utils.Os != "windows" && os.Geteuid() != 0 {
+		command = append([]string{"sudo"}, command...)
+	}
+
+	return append(commands, command)
+}
+
+// InstallPackages is a method to install packages, passing the terminal through for sudo and the package manager prompts.
+func (pm PackageManager) InstallPackages(packages []string) error {
+	for _, command := range pm.InstallCommand(packages) {
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return errors.New(strings.Join(command, " ") + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/auth"
+)
+
+// LoadStandbyDevice is a function to read the standby device of the user with id value of given user id registered by AuthClientWrapper.FailOver.
+func LoadStandbyDevice(userID auth.ProfileID) (*forestvpn_api.Device, error) {
+	var device *forestvpn_api.Device
+	data, err := os.ReadFile(auth.ProfilesDir + string(userID) + auth.StandbyDeviceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return device, json.Unmarshal(data, &device)
+}
+
+func saveStandbyDevice(userID auth.ProfileID, device *forestvpn_api.Device) error {
+	data, err := json.MarshalIndent(device, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return auth.JsonDump(data, auth.ProfilesDir+string(userID)+auth.StandbyDeviceFile)
+}
+
+// ConnectedDevice is a function to get the device the running connection of the user with id value of given user id is set up with:
+// the standby device while the connection is failed over to it, or the device of the profile otherwise.
+func ConnectedDevice(userID auth.ProfileID) (*forestvpn_api.Device, error) {
+	if event, ok := LoadFailoverEvent(userID); ok && len(event.Device) > 0 {
+		if device, err := LoadStandbyDevice(userID); err == nil && device.GetId() == event.Device {
+			return device, nil
+		}
+	}
+
+	return auth.LoadDevice(userID)
+}
+
+// standbyDevice is a method to get the standby device of the user with id value of given user id at location.
+// The standby device registered before is moved there, otherwise a new one is registered, so the device of the profile is never moved by a failover.
+func (w AuthClientWrapper) standbyDevice(userID auth.ProfileID, location forestvpn_api.Location) (*forestvpn_api.Device, error) {
+	if device, err := LoadStandbyDevice(userID); err == nil {
+		current := device.GetLocation()
+		if current.GetId() == location.GetId() {
+			return device, nil
+		}
+
+		// the standby device could have been revoked meanwhile, e.g. with 'fvpn device prune'
+		if device, err := w.ApiClient.UpdateDevice(device.GetId(), location.GetId()); err == nil {
+			return device, saveStandbyDevice(userID, device)
+		}
+	}
+
+	device, err := w.ApiClient.CreateDevice()
+	if err != nil {
+		return nil, err
+	}
+
+	device, err = w.ApiClient.UpdateDevice(device.GetId(), location.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return device, saveStandbyDevice(userID, device)
+}
+
+// FailOver is a method to switch the running connection of the user with id value of given user id to location with the standby device.
+// The device of the profile stays at its location, so the connection is back there once it's set up anew, e.g. with 'fvpn state up'.
+func (w AuthClientWrapper) FailOver(userID auth.ProfileID, state *State, location forestvpn_api.Location) (*forestvpn_api.Device, error) {
+	primary, err := auth.LoadDevice(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := ConnectedDevice(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := w.standbyDevice(userID, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.SetLocation(device, userID); err != nil {
+		return nil, err
+	}
+
+	// the event is saved first, so the running connection is described with the standby device while it's reconfigured
+	from := primary.GetLocation()
+	event := FailoverEvent{From: from.GetName(), To: location.GetName(), At: time.Now(), Device: device.GetId()}
+	if err := SaveFailoverEvent(userID, event); err != nil {
+		return nil, err
+	}
+
+	return device, state.Reconfigure(userID, previous, device)
+}
+
+// endFailover is a function to forget the failover of the connection of the user with id value of given user id once it's set up or down,
+// writing the Wireguard configuration of the device of the profile back in place of the standby one.
+func endFailover(userID auth.ProfileID) error {
+	event, ok := LoadFailoverEvent(userID)
+	ClearFailoverEvent(userID)
+	if !ok || len(event.Device) == 0 {
+		return nil
+	}
+
+	device, err := auth.LoadDevice(userID)
+	if err != nil {
+		return err
+	}
+
+	return AuthClientWrapper{}.SetLocation(device, userID)
+}
@@ -4,9 +4,12 @@ package actions
 
 import (
 	"fmt"
-	"os/exec"
+	"io"
+	"net"
+	"os"
 	"strings"
 
+	forestvpn_api "github.com/forestvpn/api-client-go"
 	"github.com/forestvpn/cli/auth"
 	"github.com/forestvpn/cli/utils"
 )
@@ -15,6 +18,20 @@ import (
 type State struct {
 	status             bool
 	WiregaurdInterface string
+	// Reason is a note on why the connection is set up or down, recorded to the connection history with the transition.
+	Reason string
+	// KillSwitch is set to enable the kill switch once the connection is up, see EnableKillSwitch.
+	KillSwitch bool
+	// Out is where the notices of the transitions go, e.g. the alternative endpoints tried, or the standard output if it's nil.
+	Out io.Writer
+}
+
+// out is a method to get the writer of the notices of the transitions, see State.Out.
+func (s *State) out() io.Writer {
+	if s.Out == nil {
+		return os.Stdout
+	}
+	return s.Out
 }
 
 // Deprecated: setStatus is used to set a status of Wireguard connection on the State structure.
@@ -23,14 +40,23 @@ type State struct {
 // Using api.ApiClientWrapper.GetStatus instead
 func (s *State) setStatus() {
 	s.status = false
-	if utils.IsOpenWRT() {
-		stdout, _ := exec.Command("uci", "show").CombinedOutput()
+	if utils.Fake {
+		record, _ := LoadStateRecord()
+		s.status = record.State == "up"
+	} else if utils.IsTermux() {
+		s.status = ProxyPid() != 0
+	} else if utils.IsOpenWRT() {
+		stdout, _ := utils.Output("uci", "show")
 
 		if strings.Contains(string(stdout), "wgserver") {
 			s.status = true
 		}
+	} else if utils.Os == "linux" && !utils.IsAdmin() {
+		// 'wg show' needs root, while the interface is visible to everyone, e.g. when the connection is controlled through polkit
+		s.status = utils.WireguardInterfaceUp(s.WiregaurdInterface)
 	} else {
-		stdout, _ := exec.Command("wg", "show").CombinedOutput()
+		// only the own interface counts, other WireGuard tunnels, e.g. a corporate one, may be up as well
+		stdout, _ := utils.Output("wg", "show", s.WiregaurdInterface)
 
 		if len(stdout) > 0 {
 			s.status = true
@@ -47,13 +73,40 @@ func (s *State) GetStatus() bool {
 }
 
 // SetUp is a method used to establish a Wireguard connection.
-// It executes 'wg-quick' shell command.
-func (s *State) SetUp(user_id auth.ProfileID, persist bool) error {
+// It executes 'wg-quick' shell command, or serves the connection as a SOCKS5 proxy in Termux.
+// The domains blocked with 'fvpn block add' are enforced once the connection is established.
+func (s *State) SetUp(user_id auth.ProfileID, persist bool) (err error) {
 	var allowedIPs []string
 	path := auth.ProfilesDir + string(user_id) + auth.WireguardConfig
+	if err := endFailover(user_id); err != nil {
+		return err
+	}
+	// the network may have changed since the location was set, e.g. to an IPv6-only one
+	if !utils.Fake {
+		if _, err := RefreshEndpoints(user_id); err != nil {
+			return err
+		}
+	}
+	resetUsageCounters(user_id)
+	defer func() {
+		if err == nil {
+			s.recordState(user_id, true)
+			if !utils.Fake {
+				err = ApplyBlocklist(true)
+			}
+			if err == nil && (s.KillSwitch || KillSwitchEnabled(user_id)) {
+				err = s.EnableKillSwitch(user_id)
+			}
+		}
+	}()
 
-	if utils.Os == "windows" {
-		return exec.Command("wireguard", "/installtunnelservice", path).Run()
+	if utils.Fake {
+		return nil
+	} else if utils.Os == "windows" {
+		if err := utils.Run("wireguard", "/installtunnelservice", path); err != nil {
+			return err
+		}
+		return s.setUpDNS(user_id)
 	} else if utils.IsOpenWRT() {
 		device, err := auth.LoadDevice(user_id)
 		if err != nil {
@@ -68,71 +121,257 @@ func (s *State) SetUp(user_id auth.ProfileID, persist bool) error {
 			}
 
 			peer := device.Wireguard.GetPeers()[0]
-			endpoint := strings.Split(peer.GetEndpoint(), ":")
-			activeSShClient := utils.GetActiveSshClient()
+			host, port, err := net.SplitHostPort(endpointRewriter()(peer.GetEndpoint()))
 			if err != nil {
 				return err
 			}
 
-			if len(activeSShClient) > 0 {
-				allowedIPs, err = utils.ExcludeDisallowedIps(peer.GetAllowedIps(), activeSShClient)
-				if err != nil {
-					return err
-				}
+			allowedIPs, err = AllowedIPs(device)
+			if err != nil {
+				return err
 			}
 
-			return utils.Network(s.WiregaurdInterface, device.Wireguard.GetPrivKey(), IPs, peer.GetPubKey(), peer.GetPsKey(), endpoint[0], endpoint[1], allowedIPs)
+			return utils.Network(s.WiregaurdInterface, device.Wireguard.GetPrivKey(), IPs, peer.GetPubKey(), peer.GetPsKey(), host, port, allowedIPs)
 		} else {
-			err := exec.Command("ip", "link", "add", "dev", s.WiregaurdInterface, "type", "wireguard").Run()
+			err := utils.Run("ip", "link", "add", "dev", s.WiregaurdInterface, "type", "wireguard")
 			if err != nil {
 				return err
 			}
 
-			err = exec.Command("ip", "address", "add", "dev", s.WiregaurdInterface, IPs[1]).Run()
+			err = utils.Run("ip", "address", "add", "dev", s.WiregaurdInterface, IPs[1])
 			if err != nil {
 				return err
 			}
 
-			err = exec.Command("ip", "-6", "address", "add", "dev", s.WiregaurdInterface, IPs[2]).Run()
+			err = utils.Run("ip", "-6", "address", "add", "dev", s.WiregaurdInterface, IPs[2])
 			if err != nil {
 				return err
 			}
 
-			err = exec.Command("wg", "setconf", s.WiregaurdInterface, path).Run()
+			err = utils.Run("wg", "setconf", s.WiregaurdInterface, path)
 			if err != nil {
 				return err
 			}
 
-			err = exec.Command("ip", "link", "set", "up", "dev", s.WiregaurdInterface).Run()
+			err = utils.Run("ip", "link", "set", "up", "dev", s.WiregaurdInterface)
 			if err != nil {
 				return err
 			}
 
-			return exec.Command("ip", "route", "add", "default", "dev", s.WiregaurdInterface).Run()
+			return utils.Run("ip", "route", "add", "default", "dev", s.WiregaurdInterface)
 		}
+	} else if utils.IsTermux() {
+		return s.proxyUp(user_id)
 	} else {
-		return exec.Command("wg-quick", "up", path).Run()
+		if err := utils.Run("wg-quick", "up", path); err != nil {
+			return err
+		}
+		return s.setUpDNS(user_id)
+	}
+}
+
+// setUpDNS is a method to set the DNS of the connection just set up, setting it down again on failure, so the queries don't leak.
+func (s *State) setUpDNS(user_id auth.ProfileID) error {
+	err := s.setDNS(user_id)
+	if err != nil {
+		s.revertDNS(user_id)
+		if utils.Os == "windows" {
+			_ = utils.Run("wireguard", "/uninstalltunnelservice", s.WiregaurdInterface)
+		} else {
+			_ = utils.Run("wg-quick", "down", auth.ProfilesDir+string(user_id)+auth.WireguardConfig)
+		}
 	}
+	return err
 }
 
 // SetDown is used to terminate a Wireguard connection.
 // It executes 'wg-quick' shell command.
-func (s *State) SetDown(user_id auth.ProfileID) error {
+// The domains blocked with 'fvpn block add' are released once the connection is terminated.
+// The data transferred through the connection is accounted to the quota before.
+func (s *State) SetDown(user_id auth.ProfileID) (err error) {
 	configPath := auth.ProfilesDir + string(user_id) + auth.WireguardConfig
-	var command *exec.Cmd
+	// the transfer counters are gone with the interface
+	_, _, _ = s.RecordUsage(user_id)
+	defer func() {
+		if err == nil {
+			// the standby configuration is only needed to take the interface down
+			err = endFailover(user_id)
+			s.recordState(user_id, false)
+			if err == nil && !utils.Fake {
+				err = ApplyBlocklist(false)
+			}
+		}
+	}()
+	if !utils.Fake {
+		s.revertDNS(user_id)
+	}
+
 	switch {
+	case utils.Fake:
+		return nil
 	case utils.Os == "windows":
-		command = exec.Command("wireguard", "/uninstalltunnelservice", s.WiregaurdInterface)
+		return utils.Run("wireguard", "/uninstalltunnelservice", s.WiregaurdInterface)
+	case utils.IsTermux():
+		return s.proxyDown()
 	case utils.IsOpenWRT():
-		if err := exec.Command("uci", "-q", "delete", fmt.Sprintf("network.%s", s.WiregaurdInterface)).Run(); err != nil {
+		if err := utils.Run("uci", "-q", "delete", fmt.Sprintf("network.%s", s.WiregaurdInterface)); err != nil {
 			return err
 		}
-		if err := exec.Command("uci", "-q", "delete", "network.wgserver").Run(); err != nil {
+		if err := utils.Run("uci", "-q", "delete", "network.wgserver"); err != nil {
 			return err
 		}
 		return utils.Commit()
 	default:
-		command = exec.Command("wg-quick", "down", configPath)
+		return utils.Run("wg-quick", "down", configPath)
+	}
+}
+
+// CanReconfigure is a method to check whether the running connection could be switched to another location without bringing it down.
+// Only the routes of Linux are swapped by Reconfigure; on macOS wg-quick runs the tunnel on a utun interface of its own and routes around the endpoint.
+func (s *State) CanReconfigure() bool {
+	return utils.Fake || utils.Os == "linux" && !utils.IsOpenWRT() && !utils.IsTermux()
+}
+
+// Reconfigure is a method to switch the running Wireguard interface from the previous to the current configuration of the device.
+// The current configuration is expected to be already written with AuthClientWrapper.SetLocation.
+// It replaces the peers with 'wg syncconf' and adds routes of new AllowedIPs before removing the stale ones,
+// so switching drops at most a few packets. If the addresses or DNS of the device changed, it falls back to down and up.
+func (s *State) Reconfigure(user_id auth.ProfileID, previous *forestvpn_api.Device, device *forestvpn_api.Device) (err error) {
+	path := auth.ProfilesDir + string(user_id) + auth.WireguardConfig
+	// the peers are replaced along with their transfer counters
+	_, _, _ = s.RecordUsage(user_id)
+	defer resetUsageCounters(user_id)
+	defer func() {
+		if err == nil {
+			s.recordState(user_id, true)
+			// the endpoints have changed
+			if KillSwitchEnabled(user_id) {
+				err = s.EnableKillSwitch(user_id)
+			}
+		}
+	}()
+
+	if utils.Fake {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// the policy routing rules are only added by wg-quick, so a changed table needs the interface to be set up anew
+	table := configTable(string(data))
+	if !equalStrings(previous.GetIps(), device.GetIps()) || !equalStrings(previous.GetDns(), device.GetDns()) || (utils.Os == "linux" && table != liveTable()) {
+		s.revertDNS(user_id)
+		if err := utils.Run("wg-quick", "down", path); err != nil {
+			return err
+		}
+		if err := utils.Run("wg-quick", "up", path); err != nil {
+			return err
+		}
+		return s.setUpDNS(user_id)
+	}
+
+	stripped, err := utils.Output("wg-quick", "strip", path)
+	if err != nil {
+		return fmt.Errorf("wg-quick strip: %s", strings.TrimSpace(string(stripped)))
+	}
+
+	file, err := os.CreateTemp("", "fvpn-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(stripped); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	live, err := utils.Output("wg", "show", s.WiregaurdInterface, "allowed-ips")
+	if err != nil {
+		return fmt.Errorf("wg show: %s", strings.TrimSpace(string(live)))
+	}
+
+	if err := utils.Run("wg", "syncconf", s.WiregaurdInterface, file.Name()); err != nil {
+		return err
+	}
+
+	// without a table of its own, wg-quick routes the default network through its own routing table, so only narrower networks need to be swapped
+	route := func(action string, network string) error {
+		if len(table) > 0 {
+			return utils.Run("ip", "route", action, network, "dev", s.WiregaurdInterface, "table", table)
+		}
+		return utils.Run("ip", "route", action, network, "dev", s.WiregaurdInterface)
+	}
+
+	oldRoutes, newRoutes := liveAllowedIPs(string(live)), configAllowedIPs(string(stripped))
+	for network := range newRoutes {
+		if !oldRoutes[network] && (len(table) > 0 || !isDefaultRoute(network)) {
+			if err := route("replace", network); err != nil {
+				return err
+			}
+		}
+	}
+
+	for network := range oldRoutes {
+		if !newRoutes[network] && (len(table) > 0 || !isDefaultRoute(network)) {
+			if err := route("del", network); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// liveAllowedIPs is a function that parses the output of 'wg show <interface> allowed-ips'.
+func liveAllowedIPs(output string) map[string]bool {
+	routes := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, ip := range fields[1:] {
+			routes[ip] = true
+		}
+	}
+	return routes
+}
+
+// configAllowedIPs is a function that parses AllowedIPs of all the peers of the Wireguard configuration.
+func configAllowedIPs(config string) map[string]bool {
+	routes := make(map[string]bool)
+	for _, line := range strings.Split(config, "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "AllowedIPs") {
+			continue
+		}
+		for _, ip := range strings.Split(value, ",") {
+			routes[strings.TrimSpace(ip)] = true
+		}
+	}
+	return routes
+}
+
+func isDefaultRoute(route string) bool {
+	return strings.HasSuffix(route, "/0")
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
-	return command.Run()
+	return true
 }
@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/crash"
+	"github.com/forestvpn/cli/utils"
+)
+
+// SupervisorPid is a process ID of the long-running command supervising the connection, e.g. 'fvpn daemon', recorded to the state file.
+// It's 0 for the commands that exit right away.
+var SupervisorPid int
+
+// StateRecord is a structure of the state file read by external supervisors and status bars instead of invoking the CLI.
+type StateRecord struct {
+	// State is up or down.
+	State     string `json:"state"`
+	Location  string `json:"location,omitempty"`
+	Country   string `json:"country,omitempty"`
+	Interface string `json:"iface"`
+	// Label is the label the connection is set up with using 'fvpn state up --label'.
+	Label string `json:"label,omitempty"`
+	// Profile is the id of the profile the connection is up with, so the status of the other profiles on the machine tells it apart.
+	Profile string `json:"profile,omitempty"`
+	// Pid is a process ID of 'fvpn daemon' or 'fvpn monitor start' while one of them is running.
+	Pid int `json:"pid,omitempty"`
+	// Since is the time of the last change of State or Location.
+	Since time.Time `json:"since"`
+}
+
+// LoadStateRecord is a function to read the state file. Returns the down state if it hasn't been written yet.
+func LoadStateRecord() (StateRecord, error) {
+	record := StateRecord{State: "down"}
+	data, err := os.ReadFile(auth.AppDir + auth.StateFile)
+	if os.IsNotExist(err) {
+		return record, nil
+	} else if err != nil {
+		return record, err
+	}
+
+	return record, json.Unmarshal(data, &record)
+}
+
+// RecordState is a method to write the state of the connection of the user with id value of given user id to the state file.
+// The file is replaced atomically, so the readers never see it partially written.
+func (s *State) RecordState(userID auth.ProfileID, up bool) error {
+	previous, _ := LoadStateRecord()
+	record := StateRecord{State: "down", Interface: s.WiregaurdInterface, Since: time.Now()}
+
+	if up {
+		record.State, record.Profile = "up", string(userID)
+		if device, err := ConnectedDevice(userID); err == nil {
+			location := device.GetLocation()
+			country := location.GetCountry()
+			record.Location, record.Country = location.GetName(), country.GetName()
+		}
+		record.Label = LoadLabel(userID)
+	}
+
+	if previous.State == record.State && previous.Location == record.Location && !previous.Since.IsZero() {
+		record.Since = previous.Since
+	}
+
+	// a transition made by another command keeps the running supervisor
+	record.Pid = SupervisorPid
+	if record.Pid == 0 && previous.Pid != 0 && utils.ProcessAlive(previous.Pid) {
+		record.Pid = previous.Pid
+	}
+
+	data, err := json.MarshalIndent(record, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(auth.AppDir+auth.StateFile, data, 0644)
+}
+
+// recordState is a method to write the state file after a transition. The errors are reported to Sentry and never interrupt the command.
+func (s *State) recordState(userID auth.ProfileID, up bool) {
+	previous, _ := LoadStateRecord()
+	err := s.RecordState(userID, up)
+	if err == nil {
+		err = s.recordHistory(userID, previous)
+	}
+
+	if err != nil {
+		crash.CaptureException(err)
+		if utils.Verbose {
+			utils.InfoLogger.Println(err)
+		}
+	}
+}
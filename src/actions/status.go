@@ -0,0 +1,110 @@
+package actions
+
+import (
+	"sort"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/schema"
+)
+
+// ConnectionStatus is a structure representing the state of the connection printed by 'fvpn state status --json'.
+// Its fields are described by the state-status schema of the schema package.
+type ConnectionStatus struct {
+	SchemaVersion  int        `json:"schemaVersion"`
+	Connected      bool       `json:"connected"`
+	Interface      string     `json:"interface"`
+	Location       string     `json:"location,omitempty"`
+	LocationID     string     `json:"location_id,omitempty"`
+	Country        string     `json:"country,omitempty"`
+	CountryCode    string     `json:"country_code,omitempty"`
+	Since          *time.Time `json:"since,omitempty"`
+	FailedOverFrom string     `json:"failed_over_from,omitempty"`
+	DisconnectsAt  *time.Time `json:"disconnects_at,omitempty"`
+	Label          string     `json:"label,omitempty"`
+	KillSwitch     bool       `json:"kill_switch,omitempty"`
+	// AutoReconnect is true while the connection is supervised with 'fvpn state up --auto-reconnect', with the latest Reconnects.
+	AutoReconnect bool             `json:"auto_reconnect,omitempty"`
+	Reconnects    []ReconnectEvent `json:"reconnects,omitempty"`
+}
+
+// GetConnectionStatus is a method to collect the state of the connection of the user with id value of given user id out of the local files.
+func (s *State) GetConnectionStatus(userID auth.ProfileID) (ConnectionStatus, error) {
+	status := ConnectionStatus{SchemaVersion: schema.Version, Connected: s.GetStatus(), Interface: s.WiregaurdInterface, KillSwitch: KillSwitchEnabled(userID)}
+	if supervision, ok := LoadSupervision(userID); ok {
+		status.AutoReconnect, status.Reconnects = true, supervision.Events
+	}
+	if !status.Connected {
+		return status, nil
+	}
+
+	device, err := ConnectedDevice(userID)
+	if err != nil {
+		return status, err
+	}
+
+	location := device.GetLocation()
+	country := location.GetCountry()
+	status.Location, status.LocationID = location.GetName(), location.GetId()
+	status.Country, status.CountryCode = country.GetName(), country.GetId()
+
+	if record, err := LoadStateRecord(); err == nil && record.State == "up" && !record.Since.IsZero() {
+		status.Since = &record.Since
+	}
+	if event, ok := LoadFailoverEvent(userID); ok {
+		status.FailedOverFrom = event.From
+	}
+	if deadline, ok := LoadExpiry(userID); ok {
+		status.DisconnectsAt = &deadline
+	}
+	status.Label = LoadLabel(userID)
+
+	return status, nil
+}
+
+// ProfileStatus is a structure representing the state of the connection of one of the profiles on the machine
+// printed by 'fvpn state status --all-profiles --json'.
+type ProfileStatus struct {
+	Email     string `json:"email"`
+	ProfileID string `json:"profile_id"`
+	// Current is true for the profile the commands run with, the one logged in last.
+	Current bool `json:"current"`
+	ConnectionStatus
+}
+
+// GetProfileStatuses is a method to collect the state of the connection of every logged-in profile on the machine, sorted by email.
+// There is a single interface, so at most one of them is connected: the one the state file records the connection up with,
+// or the current profile if the connection was set up before the state file recorded profiles.
+func (s *State) GetProfileStatuses() ([]ProfileStatus, error) {
+	db := auth.OpenUserDB()
+	current := db.CurrentUser()
+	connected := s.GetStatus()
+
+	owner := current.ID
+	if record, err := LoadStateRecord(); err == nil && len(record.Profile) > 0 {
+		owner = auth.ProfileID(record.Profile)
+	}
+
+	statuses := []ProfileStatus{}
+	for _, profile := range db.ListUsers() {
+		status := ProfileStatus{
+			Email:            string(profile.Email),
+			ProfileID:        string(profile.ID),
+			Current:          profile.Pk == current.Pk,
+			ConnectionStatus: ConnectionStatus{SchemaVersion: schema.Version, Interface: s.WiregaurdInterface},
+		}
+
+		if connected && profile.ID == owner {
+			connection, err := s.GetConnectionStatus(profile.ID)
+			if err != nil {
+				return statuses, err
+			}
+			status.ConnectionStatus = connection
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Email < statuses[j].Email })
+	return statuses, nil
+}
@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SupportEmail is an address to contact the support if the tickets couldn't be submitted.
+const SupportEmail = "support@forestvpn.com"
+
+// CreateSupportTicket is a method to submit text to the support, attaching the files.
+// The category is matched against the IDs and names of the ticket categories; the first category is used if it's empty.
+func (w AuthClientWrapper) CreateSupportTicket(text string, category string, files []*os.File) (string, error) {
+	categories, err := w.ApiClient.GetTicketCategories()
+	if err != nil {
+		return "", err
+	}
+
+	if len(categories) == 0 {
+		return "", fmt.Errorf("no support ticket categories available, contact %s", SupportEmail)
+	}
+
+	slug := categories[0].GetId()
+	if len(category) > 0 {
+		var names []string
+		slug = ""
+		for _, c := range categories {
+			names = append(names, c.GetId())
+			if strings.EqualFold(c.GetId(), category) || strings.EqualFold(c.GetName(), category) {
+				slug = c.GetId()
+			}
+		}
+
+		if len(slug) == 0 {
+			return "", fmt.Errorf("unknown category %s, must be one of: %s", category, strings.Join(names, ", "))
+		}
+	}
+
+	return w.ApiClient.CreateSupportTicket(text, slug, files)
+}
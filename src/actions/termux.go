@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+)
+
+// ProxyBinary is a userspace Wireguard client built on wireguard-go that serves the connection as a SOCKS5 proxy without a network interface.
+const ProxyBinary = "wireproxy"
+
+// ProxyAddress is a function to get the address of the SOCKS5 proxy set with 'fvpn config set proxy-address'.
+func ProxyAddress() string {
+	c, err := config.Load()
+	if err != nil {
+		return "127.0.0.1:1080"
+	}
+
+	return c.Get(config.ProxyAddress)
+}
+
+// ProxyConfig is a function to get the wireproxy configuration serving the Wireguard configuration at path as a SOCKS5 proxy on address.
+func ProxyConfig(path string, address string) string {
+	return fmt.Sprintf("WGConfig = %s\n\n[Socks5]\nBindAddress = %s\n", path, address)
+}
+
+// ProxyPid is a function to get the process ID of the running wireproxy, or 0 if it's not running.
+func ProxyPid() int {
+	data, err := os.ReadFile(auth.AppDir + auth.ProxyPidFile)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !utils.ProcessAlive(pid) {
+		return 0
+	}
+
+	return pid
+}
+
+// proxyUp is a method to serve the connection as a SOCKS5 proxy with wireproxy, e.g. in Termux, where the interface needs root.
+func (s *State) proxyUp(user_id auth.ProfileID) error {
+	if _, err := exec.LookPath(ProxyBinary); err != nil {
+		return errors.New("wireproxy not found: install it with 'go install github.com/pufferffish/wireproxy/cmd/wireproxy@latest' to connect without root")
+	}
+
+	if err := s.proxyDown(); err != nil {
+		return err
+	}
+
+	path := auth.ProfilesDir + string(user_id) + auth.ProxyConfig
+	address := ProxyAddress()
+	err := os.WriteFile(path, []byte(ProxyConfig(auth.ProfilesDir+string(user_id)+auth.WireguardConfig, address)), 0600)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(ProxyBinary, "--silent", "--config", path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	pid := cmd.Process.Pid
+	if err := os.WriteFile(auth.AppDir+auth.ProxyPidFile, []byte(strconv.Itoa(pid)), 0644); err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+
+	// wireproxy exits right away on the invalid configuration or the busy address
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		os.Remove(auth.AppDir + auth.ProxyPidFile)
+		return fmt.Errorf("wireproxy exited: %v", err)
+	case <-time.After(time.Second):
+	}
+
+	fmt.Fprintf(s.out(), "Serving the connection as a SOCKS5 proxy on %s, e.g. export ALL_PROXY=socks5h://%s\n", address, address)
+	return nil
+}
+
+// proxyDown is a method to stop the running wireproxy.
+func (s *State) proxyDown() error {
+	pid := ProxyPid()
+	if pid == 0 {
+		return nil
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	if err := process.Kill(); err != nil {
+		return err
+	}
+
+	return os.Remove(auth.AppDir + auth.ProxyPidFile)
+}
@@ -0,0 +1,32 @@
+package actions
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+)
+
+// UpsellInterval is the least time between the reminders of the ending trial or subscription.
+const UpsellInterval = 24 * time.Hour
+
+// ShouldUpsell is a function to check whether the user with id value of given user id could be reminded of the ending trial
+// or subscription now: the upsell setting is on and no reminder was given within UpsellInterval. The reminder is recorded once true is returned.
+func ShouldUpsell(userID auth.ProfileID) bool {
+	c, err := config.Load()
+	if err == nil && c.Get(config.Upsell) == "off" {
+		return false
+	}
+
+	path := auth.ProfilesDir + string(userID) + auth.UpsellFile
+	if data, err := os.ReadFile(path); err == nil {
+		if last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data))); err == nil && time.Since(last) < UpsellInterval {
+			return false
+		}
+	}
+
+	_ = os.WriteFile(path, []byte(time.Now().Format(time.RFC3339)), 0644)
+	return true
+}
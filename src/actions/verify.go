@@ -0,0 +1,105 @@
+package actions
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// ReleasesURL is a base URL of the release assets published by goreleaser, followed by the tag and the file name.
+var ReleasesURL = "https://github.com/forestvpn/cli/releases/download/"
+
+// ReleaseManifestName is a name of the release manifest signed with 'fvpn release manifest' and published along with the archives.
+const ReleaseManifestName = "manifest.json"
+
+// ReleasePublicKey is a base64 encoded ed25519 public key the release manifests are signed with.
+// It's embedded by the release pipeline with -ldflags "-X github.com/forestvpn/cli/actions.ReleasePublicKey=..." from the FVPN_RELEASE_PUBLIC_KEY secret,
+// while the private key is the FVPN_RELEASE_KEY secret given to 'fvpn release manifest' by goreleaser only.
+var ReleasePublicKey string
+
+// FetchReleaseManifest is a function to download the release manifest of version and check it's signed with the key of ReleasePublicKey.
+func FetchReleaseManifest(version string) (utils.ReleaseManifest, error) {
+	key, err := base64.StdEncoding.DecodeString(ReleasePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return utils.ReleaseManifest{}, errors.New("unofficial build: no release public key is embedded in the binary")
+	}
+
+	manifest, err := utils.FetchReleaseManifest(ReleasesURL+version+"/"+ReleaseManifestName, version, ed25519.PublicKey(key))
+	if err != nil {
+		return manifest, fmt.Errorf("failed to get the release manifest of %s, it's either unofficial or unpublished: %s", version, err)
+	}
+
+	return manifest, nil
+}
+
+// VerifyBinary is a function that compares the running binary with the one in the release archive for version, OS and architecture.
+// The archive is checked against the signed manifest of the release first.
+// Returns the error describing the mismatch if the binary is an unofficial build or has been modified.
+func VerifyBinary(version string) error {
+	if len(version) == 0 {
+		return errors.New("unofficial build: no version is embedded in the binary")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return err
+	}
+
+	return VerifyFile(executable, version, runtime.GOOS, runtime.GOARCH)
+}
+
+// VerifyFile is a function that compares the file at path with the binary in the release archive for version, goos and goarch,
+// checking the archive against the signed manifest of the release first.
+// Returns the error describing the mismatch if the manifest isn't signed with ReleasePublicKey, or the archive or the file has been modified.
+func VerifyFile(path string, version string, goos string, goarch string) error {
+	manifest, err := FetchReleaseManifest(version)
+	if err != nil {
+		return err
+	}
+
+	asset, ok := manifest.Asset(goos, goarch)
+	if !ok {
+		return fmt.Errorf("no %s/%s archive in the release %s", goos, goarch, version)
+	}
+
+	data, err := utils.FetchReleaseAsset(asset.URL)
+	if err != nil {
+		return err
+	}
+
+	if utils.SHA256(data) != asset.SHA256 {
+		return fmt.Errorf("checksum of %s doesn't match the release manifest", asset.URL)
+	}
+
+	binary := "fvpn"
+	if goos == "windows" {
+		binary = "fvpn.exe"
+	}
+
+	released, err := utils.ExtractFile(data, binary)
+	if err != nil {
+		return err
+	}
+
+	actual, err := utils.SHA256File(path)
+	if err != nil {
+		return err
+	}
+
+	if actual != utils.SHA256(released) {
+		return fmt.Errorf("%s doesn't match the release %s, it's been modified or built from source", path, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// WSLDaemonPort is a port of the 'fvpn daemon' looked up on the Windows host from WSL2.
+const WSLDaemonPort = "9999"
+
+// WSLDaemonAddress is a function to find the 'fvpn daemon' of the Windows host to control from WSL2 according to the wsl setting mode.
+// Returns an empty address if the connection should be set up in WSL itself.
+func WSLDaemonAddress(mode string) (string, error) {
+	if mode == "userspace" {
+		return "", nil
+	}
+
+	addresses := utils.WSLHostAddresses()
+	for _, address := range addresses {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, WSLDaemonPort), 500*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return "tls://" + net.JoinHostPort(address, WSLDaemonPort), nil
+		}
+	}
+
+	if mode == "host" {
+		return "", fmt.Errorf("no fvpn daemon found on the Windows host at %v port %s, run 'fvpn daemon --http 0.0.0.0:%s --tls-cert cert.pem --tls-key key.pem' on Windows", addresses, WSLDaemonPort, WSLDaemonPort)
+	}
+
+	return "", nil
+}
+
+// CheckWSLBackend is a function to make sure the connection could be set up inside WSL2, where the kernel usually lacks the Wireguard module.
+// wg-quick falls back to wireguard-go on its own, so it only explains the options if neither is available.
+func CheckWSLBackend() error {
+	switch backend, _ := utils.WireguardBackend(); backend {
+	case "kernel":
+		return nil
+	case "wireguard-go":
+		fmt.Println("The WSL2 kernel has no Wireguard, using wireguard-go in the userspace")
+		return nil
+	}
+
+	return errors.New("no Wireguard in the WSL2 kernel: run 'fvpn daemon --http 0.0.0.0:9999 --tls-cert cert.pem --tls-key key.pem' on Windows and 'fvpn config set daemon-token TOKEN' and 'fvpn config set daemon-ca cert.pem' here to connect the host, or install wireguard-go to connect inside WSL")
+}
@@ -4,7 +4,10 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	forestvpn_api "github.com/forestvpn/api-client-go"
 	"github.com/forestvpn/cli/utils"
@@ -14,6 +17,8 @@ import (
 	"net/http/httputil"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 )
 
 // ApiClientWrapper is a structure that wraps forestvpn_api.APIClient to extend it.
@@ -33,14 +38,10 @@ func (w *ApiClientWrapper) CreateDevice() (*forestvpn_api.Device, error) {
 		return nil, err
 	}
 
-	info := map[string]string{"arch": runtime.GOARCH}
 	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
 	request := *forestvpn_api.NewCreateOrUpdateDeviceRequest()
 	request.SetName(hostname)
-	rInfo := request.GetInfo()
-	rInfo.SetType(forestvpn_api.DeviceType(runtime.GOOS))
-	rInfo.SetInfo(info)
-	request.SetInfo(rInfo)
+	request.SetInfo(deviceInfo())
 	dev, resp, err := w.APIClient.DeviceApi.CreateDevice(auth).CreateOrUpdateDeviceRequest(request).Execute()
 	if err != nil {
 		return dev, err
@@ -61,20 +62,34 @@ func (w *ApiClientWrapper) CreateDevice() (*forestvpn_api.Device, error) {
 //
 // See https://github.com/forestvpn/api-client-go/blob/main/docs/DeviceApi.md#updatedevice for more information.
 func (w *ApiClientWrapper) UpdateDevice(deviceID string, locationID string) (*forestvpn_api.Device, error) {
-	hostname, err := os.Hostname()
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	request := *forestvpn_api.NewCreateOrUpdateDeviceRequest()
+	request.SetInfo(deviceInfo())
+	request.SetLocation(locationID)
+
+	dev, resp, err := w.APIClient.DeviceApi.UpdateDevice(auth, deviceID).CreateOrUpdateDeviceRequest(request).Execute()
 	if err != nil {
-		return nil, err
+		return dev, err
+	}
+
+	if utils.Verbose {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return dev, err
+		}
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
 	}
 
-	info := map[string]string{"arch": runtime.GOARCH}
+	return dev, nil
+}
+
+// RenameDevice is a method to set the name of the device shown in the list of devices of the user.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/DeviceApi.md#updatedevice for more information.
+func (w *ApiClientWrapper) RenameDevice(deviceID string, name string) (*forestvpn_api.Device, error) {
 	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
 	request := *forestvpn_api.NewCreateOrUpdateDeviceRequest()
-	request.SetName(hostname)
-	createOrUpdateDeviceRequestInfo := request.GetInfo()
-	createOrUpdateDeviceRequestInfo.SetType(forestvpn_api.DeviceType(runtime.GOOS))
-	createOrUpdateDeviceRequestInfo.SetInfo(info)
-	request.SetInfo(createOrUpdateDeviceRequestInfo)
-	request.SetLocation(locationID)
+	request.SetName(name)
 
 	dev, resp, err := w.APIClient.DeviceApi.UpdateDevice(auth, deviceID).CreateOrUpdateDeviceRequest(request).Execute()
 	if err != nil {
@@ -92,6 +107,16 @@ func (w *ApiClientWrapper) UpdateDevice(deviceID string, locationID string) (*fo
 	return dev, nil
 }
 
+// deviceInfo is a function that describes the platform of the device and the version of Forest CLI to the back-end.
+func deviceInfo() forestvpn_api.CreateOrUpdateDeviceRequestInfo {
+	info := map[string]string{"arch": runtime.GOARCH, "client": "fvpn"}
+	if len(utils.AppVersion) > 0 {
+		info["version"] = utils.AppVersion
+	}
+
+	return *forestvpn_api.NewCreateOrUpdateDeviceRequestInfo(forestvpn_api.DeviceType(runtime.GOOS), info)
+}
+
 // GetLocations is a method for getting all the locations available at back-end.
 //
 // See https://github.com/forestvpn/api-client-go/blob/main/docs/GeoApi.md#listlocations for more information.
@@ -113,6 +138,27 @@ func (w *ApiClientWrapper) GetLocations() ([]forestvpn_api.Location, error) {
 	return loc, nil
 }
 
+// GetCountries is a method for getting all the countries available at back-end.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/GeoApi.md#listcountries for more information.
+func (w *ApiClientWrapper) GetCountries() ([]forestvpn_api.Country, error) {
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	countries, resp, err := w.APIClient.GeoApi.ListCountries(auth).Execute()
+	if err != nil {
+		return countries, err
+	}
+
+	if utils.Verbose {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return countries, err
+		}
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	return countries, nil
+}
+
 // GetBillingFeatures is a method for getting locations available to the user.
 //
 // See https://github.com/forestvpn/api-client-go/blob/main/docs/BillingApi.md#listbillingfeatures for more information.
@@ -153,11 +199,15 @@ func (t AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 
 	// Call the original RoundTrip function to send the request and receive the response
-	resp, err := t.rt.RoundTrip(req)
+	started := time.Now()
+	resp, err := t.roundTrip(req)
+	utils.RecordTiming("api", req.Method+" "+req.URL.Path, started)
 	if err != nil {
 		return nil, err
 	}
 
+	utils.ObserveClock(resp, started)
+
 	// Log the outgoing request
 	if utils.Verbose {
 		// Dump the request in a pretty format
@@ -171,6 +221,63 @@ func (t AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+// ErrSessionExpired is returned once the back-end rejects the token of the profile, e.g. after the password change or the revocation of the session.
+var ErrSessionExpired = errors.New("session expired, run 'fvpn account login' to sign in again")
+
+// IsSessionExpired is a function to check whether err is caused by the back-end rejecting the token of the profile,
+// i.e. it's ErrSessionExpired or the 401 Unauthorized response to the request err was returned for.
+func IsSessionExpired(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrSessionExpired) {
+		return true
+	}
+
+	var apiErr *forestvpn_api.GenericOpenAPIError
+	return errors.As(err, &apiErr) && strings.HasPrefix(apiErr.Error(), "401")
+}
+
+// Recorder is a recorder of the API interactions set with --record, if any.
+var Recorder *utils.HARRecorder
+
+// roundTrip is a method to send the request, recording the interaction with Recorder if it's set.
+func (t AuthTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if Recorder == nil {
+		return t.rt.RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	started := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	if err := Recorder.Record(started, req, reqBody, resp, respBody); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 // GetApiClient is a factory function that returns the ApiClientWrapper structure.
 // It configures and wraps an instance of forestvpn_api.APIClient.
 //
@@ -178,6 +285,7 @@ func (t AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 func GetApiClient(accessToken string, apiHost string) *ApiClientWrapper {
 	configuration := forestvpn_api.NewConfiguration()
 	configuration.Host = apiHost
+	configuration.Scheme = utils.ApiScheme
 	httpClient := utils.GetHttpClient(10)
 	httpClient.Transport = AuthTransport{rt: httpClient.Transport, AccessToken: accessToken}
 	configuration.HTTPClient = httpClient
@@ -226,3 +334,154 @@ func (w *ApiClientWrapper) DeleteDevice(id string) error {
 
 	return nil
 }
+
+// GetUser is a method to get the profile of the user the AccessToken belongs to.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/AuthApi.md#whoami for more information.
+func (w *ApiClientWrapper) GetUser() (*forestvpn_api.User, error) {
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	user, resp, err := w.APIClient.AuthApi.WhoAmI(auth).Execute()
+	if err != nil {
+		return user, err
+	}
+
+	if utils.Verbose {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return user, err
+		}
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	return user, nil
+}
+
+// AccountSettings is a structure of the defaults of the account set in the mobile apps, returned in the settings of the profile of the user.
+type AccountSettings struct {
+	// Location is the UUID of the preferred location, if any.
+	Location string `json:"location,omitempty"`
+	// Region is the ISO code or name of the preferred country, used when no location is preferred.
+	Region     string `json:"region,omitempty"`
+	DNSFilter  string `json:"dns_filter,omitempty"`
+	KillSwitch bool   `json:"killswitch,omitempty"`
+}
+
+// GetAccountSettings is a method to get the defaults of the account out of the profile of the user.
+// The zero AccountSettings is returned for the accounts without any.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/AuthApi.md#userprofile for more information.
+func (w *ApiClientWrapper) GetAccountSettings() (AccountSettings, error) {
+	var profile struct {
+		Settings AccountSettings `json:"settings"`
+	}
+
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	_, resp, err := w.APIClient.AuthApi.UserProfile(auth).Execute()
+	if err != nil {
+		return profile.Settings, err
+	}
+
+	// the settings aren't part of the User of the API client, so they're read from the body
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return profile.Settings, err
+	}
+
+	if utils.Verbose {
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	if err := json.Unmarshal(body, &profile); err != nil {
+		return profile.Settings, err
+	}
+
+	return profile.Settings, nil
+}
+
+// ListDevices is a method to get all the devices of the user.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/DeviceApi.md#listdevices for more information.
+func (w *ApiClientWrapper) ListDevices() ([]forestvpn_api.Device, error) {
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	devices, resp, err := w.APIClient.DeviceApi.ListDevices(auth).Execute()
+	if err != nil {
+		return devices, err
+	}
+
+	if utils.Verbose {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return devices, err
+		}
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	return devices, nil
+}
+
+// IsDeviceLimitError is a function to check whether err is returned by the back-end because the account has reached its limit of devices.
+func IsDeviceLimitError(err error) bool {
+	var apiErr *forestvpn_api.GenericOpenAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	text := apiErr.Error()
+	if model, ok := apiErr.Model().(forestvpn_api.Error); ok {
+		text += " " + model.GetCode() + " " + model.GetMessage()
+	}
+
+	text = strings.ToLower(text)
+	return strings.Contains(text, "device") && strings.Contains(text, "limit")
+}
+
+// GetTicketCategories is a method to get the categories of support tickets.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/SupportApi.md#getsupportticketcategory for more information.
+func (w *ApiClientWrapper) GetTicketCategories() ([]forestvpn_api.TicketCategory, error) {
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	categories, resp, err := w.APIClient.SupportApi.GetSupportTicketCategory(auth).Execute()
+	if err != nil {
+		return categories, err
+	}
+
+	if utils.Verbose {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return categories, err
+		}
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	return categories, nil
+}
+
+// CreateSupportTicket is a method to submit a support ticket with optional files attached.
+// Returns the ID of the ticket if the back-end responds with one.
+//
+// See https://github.com/forestvpn/api-client-go/blob/main/docs/SupportApi.md#createsupportticket for more information.
+func (w *ApiClientWrapper) CreateSupportTicket(text string, category string, files []*os.File) (string, error) {
+	auth := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, w.AccessToken)
+	resp, err := w.APIClient.SupportApi.CreateSupportTicket(auth).Text(text).Category(category).Files(files).Execute()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if utils.Verbose {
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	var ticket struct {
+		ID interface{} `json:"id"`
+	}
+	if json.Unmarshal(body, &ticket) != nil || ticket.ID == nil {
+		return "", nil
+	}
+
+	return fmt.Sprint(ticket.ID), nil
+}
@@ -0,0 +1,40 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forestvpn/cli/api"
+	"github.com/forestvpn/cli/utils"
+)
+
+func TestIsSessionExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	scheme, host := utils.ApiScheme, utils.ApiHost
+	if err := utils.SetApiURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { utils.ApiScheme, utils.ApiHost = scheme, host })
+
+	_, err := api.GetApiClient("revoked", utils.ApiHost).GetBillingFeatures()
+	if !api.IsSessionExpired(err) {
+		t.Errorf("expected the 401 response to expire the session, got %v", err)
+	}
+
+	// the 401 of the previous request doesn't count for the errors of the others
+	for _, err := range []error{errors.New("connection refused"), errors.New("500 Internal Server Error"), nil} {
+		if api.IsSessionExpired(err) {
+			t.Errorf("expected %v not to expire the session", err)
+		}
+	}
+
+	if !api.IsSessionExpired(api.ErrSessionExpired) {
+		t.Error("expected ErrSessionExpired to expire the session")
+	}
+}
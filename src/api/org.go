@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+)
+
+// OrgDevice is a structure of the device of a member of the organization, seen by its admins.
+type OrgDevice struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Owner is the email of the member the device belongs to, e.g. the team account the machine is enrolled in.
+	Owner        string                  `json:"owner"`
+	Location     *forestvpn_api.Location `json:"location,omitempty"`
+	LastActiveAt *time.Time              `json:"last_active_at,omitempty"`
+}
+
+// ListOrgDevices is a method to get the devices of all the members of the organization of the user, who must be its admin.
+func (w *ApiClientWrapper) ListOrgDevices() ([]OrgDevice, error) {
+	var devices []OrgDevice
+	err := w.send(http.MethodGet, "/org/devices/", &devices)
+	return devices, err
+}
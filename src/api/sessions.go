@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/utils"
+)
+
+// Session is a structure of the sign-in session of the user on a machine, i.e. the token issued to it.
+type Session struct {
+	ID string `json:"id"`
+	// Name is the name of the machine or the app the session was started from.
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// Current is whether the session is the one of the AccessToken.
+	Current bool `json:"current"`
+}
+
+// ListSessions is a method to get the active sessions of the user across the machines.
+func (w *ApiClientWrapper) ListSessions() ([]Session, error) {
+	var sessions []Session
+	err := w.send(http.MethodGet, "/auth/sessions/", &sessions)
+	return sessions, err
+}
+
+// RevokeSession is a method to end the session of the user with id, so its token is rejected and the machine has to log in again.
+func (w *ApiClientWrapper) RevokeSession(id string) error {
+	return w.send(http.MethodDelete, "/auth/sessions/"+url.PathEscape(id)+"/", nil)
+}
+
+// send is a method to make the request to the endpoint of the back-end forestvpn_api.APIClient doesn't cover, decoding the JSON response into out, if set.
+// The 401 Unauthorized response is returned as ErrSessionExpired, and other failures as the message of the back-end.
+func (w *ApiClientWrapper) send(method string, path string, out interface{}) error {
+	config := w.APIClient.GetConfig()
+	req, err := http.NewRequest(method, config.Scheme+"://"+config.Host+"/v2"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", config.UserAgent)
+
+	// the access token is set by AuthTransport
+	resp, err := config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if utils.Verbose {
+		utils.InfoLogger.Printf("%s %s \n %s\n", resp.Request.Method, resp.Request.URL.String(), string(body))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrSessionExpired
+	case resp.StatusCode >= 300:
+		var model forestvpn_api.Error
+		if json.Unmarshal(body, &model) == nil && len(model.Message) > 0 {
+			return fmt.Errorf("%s: %s", resp.Status, model.Message)
+		}
+		return errors.New(resp.Status)
+	case out == nil || len(body) == 0:
+		return nil
+	}
+
+	return json.Unmarshal(body, out)
+}
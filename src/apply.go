@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/pkg/forestvpn"
+	"github.com/forestvpn/cli/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a structure of the declarative fvpn.yaml applied with 'fvpn apply'.
+// Every field is optional; the fields left out are not changed.
+type Spec struct {
+	// Account is an email of the logged-in account to switch to.
+	Account string `yaml:"account"`
+	// Location is a UUID, slug or name of the default location.
+	Location string `yaml:"location"`
+	// Connected is whether the connection should be up.
+	Connected *bool `yaml:"connected"`
+	// Blocklist is a list of the domains to block while connected, replacing the current one.
+	Blocklist *[]string `yaml:"blocklist"`
+	// Config is the settings to set, see 'fvpn config ls'.
+	Config map[string]string `yaml:"config"`
+}
+
+// loadSpec is a function that reads the Spec from path, rejecting unknown fields so typos aren't silently ignored.
+func loadSpec(path string) (Spec, error) {
+	var spec Spec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return spec, fmt.Errorf("invalid spec %s: %s", path, err)
+	}
+
+	return spec, nil
+}
+
+// applySpec is a function that converges the local state to spec, printing every change.
+// Nothing is changed if it already matches, so it could be run repeatedly, e.g. by configuration management.
+// With dryRun the changes are only printed.
+func applySpec(ctx context.Context, spec Spec, dryRun bool) error {
+	change := func(format string, a ...interface{}) {
+		if dryRun {
+			format = "would " + format
+		}
+		fmt.Printf(format+"\n", a...)
+	}
+
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(spec.Config))
+	for key := range spec.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if conf.Get(key) == spec.Config[key] {
+			continue
+		}
+
+		change("set %s to %s", key, spec.Config[key])
+		if !dryRun {
+			if err := conf.Set(key, spec.Config[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if spec.Blocklist != nil {
+		blocked, err := actions.LoadBlocklist()
+		if err != nil {
+			return err
+		}
+
+		for _, domain := range *spec.Blocklist {
+			if !utils.IsDomain(domain) {
+				return fmt.Errorf("invalid domain: %s", domain)
+			}
+		}
+
+		wanted := actions.NormalizeDomains(*spec.Blocklist)
+		if len(wanted) != len(blocked) || (len(wanted) > 0 && !reflect.DeepEqual(wanted, blocked)) {
+			change("block %d domains instead of %d", len(wanted), len(blocked))
+			if !dryRun {
+				if _, err := actions.SaveBlocklist(wanted); err != nil {
+					return err
+				}
+
+				state := actions.State{WiregaurdInterface: forestvpn.DefaultInterface}
+				if err := actions.ApplyBlocklist(state.GetStatus()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(spec.Account) > 0 {
+		profile := auth.OpenUserDB().CurrentUser()
+		if string(profile.Email) != spec.Account {
+			other, found := auth.OpenUserDB().FindUser(spec.Account)
+			if !found {
+				return fmt.Errorf("%s is not logged in, log in with 'fvpn account login' first", spec.Account)
+			}
+
+			state := actions.State{WiregaurdInterface: forestvpn.DefaultInterface}
+			if state.GetStatus() {
+				return fmt.Errorf("set down the connection before switching to %s", spec.Account)
+			}
+
+			change("switch to %s", spec.Account)
+			if !dryRun {
+				other.Touch()
+			}
+		}
+	}
+
+	if len(spec.Location) == 0 && spec.Connected == nil {
+		return nil
+	}
+
+	client, err := forestvpn.NewClient(ctx, utils.ApiHost)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(spec.Location) > 0 {
+		current := forestvpn_api.Location{Id: status.Location.ID, Name: status.Location.Name}
+		if _, same := actions.FindLocation(actions.GetLocationWrappers([]forestvpn_api.Location{current}), spec.Location); !same {
+			change("set the default location to %s", spec.Location)
+			if !dryRun {
+				if _, err := client.SetLocation(ctx, spec.Location); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if spec.Connected != nil && *spec.Connected != status.Connected {
+		if *spec.Connected {
+			change("connect")
+			if !dryRun {
+				return client.Connect(ctx, false)
+			}
+		} else {
+			change("disconnect")
+			if !dryRun {
+				return client.Disconnect(ctx)
+			}
+		}
+	}
+
+	return nil
+}
@@ -2,48 +2,80 @@ package auth
 
 import (
 	"fmt"
+	"github.com/forestvpn/cli/secrets"
 	"github.com/forestvpn/cli/utils"
 	"github.com/forestvpn/goauthlib/pkg/logger"
 	"github.com/forestvpn/goauthlib/pkg/svc"
 	"github.com/sirupsen/logrus"
 	"log"
+	"path/filepath"
 	"strings"
 )
 
 var AuthStore, _ = svc.NewFilePersistentStore()
 
+// MachineTokens is a storage of the machine tokens of the profiles by their primary keys.
+var MachineTokens secrets.Store = secrets.NewFileStore(filepath.Join(AppDir, MachineTokensDir))
+
+// Credentials is a storage of the credentials of the other providers, e.g. the tokens of OIDCProvider, by the provider and the primary key of the profile.
+var Credentials secrets.Store = secrets.NewFileStore(filepath.Join(AppDir, CredentialsDir))
+
+// UseSecretStore is a function to keep the refresh and machine tokens of the profiles in store, e.g. in Vault, rather than in AppDir.
+func UseSecretStore(store secrets.Store) {
+	AuthStore = store
+	MachineTokens = store
+	Credentials = store
+}
+
 // SimpleLogger implements the Logger interface using the Go standard library's log package
 type SimpleLogger struct {
 	fields logrus.Fields
+	// quiet discards the messages and panics on the fatal errors instead of exiting, see BrowserProvider.UnattendedAccessToken.
+	quiet bool
 }
 
 func (l *SimpleLogger) Debugf(format string, args ...interface{}) {
-	if utils.Verbose {
+	if utils.Verbose && !l.quiet {
 		utils.InfoLogger.Println(l.renderLogString(format, args...))
 	}
 }
 
 func (l *SimpleLogger) Infof(format string, args ...interface{}) {
-	utils.InfoLogger.Println(l.renderLogString(format, args...))
+	l.println(format, args...)
 }
 
 func (l *SimpleLogger) Printf(format string, args ...interface{}) {
-	utils.InfoLogger.Println(l.renderLogString(format, args...))
+	l.println(format, args...)
 }
 
 func (l *SimpleLogger) Warnf(format string, args ...interface{}) {
-	utils.InfoLogger.Println(l.renderLogString(format, args...))
+	l.println(format, args...)
 }
 
 func (l *SimpleLogger) Errorf(format string, args ...interface{}) {
-	utils.InfoLogger.Println(l.renderLogString(format, args...))
+	l.println(format, args...)
 }
 
 func (l *SimpleLogger) Fatalf(format string, args ...interface{}) {
-	log.Fatalln(l.renderLogString(format, args...))
+	l.fatalln(format, args...)
 }
 
 func (l *SimpleLogger) Panicf(format string, args ...interface{}) {
+	l.fatalln(format, args...)
+}
+
+// println is a method to log the message unless the logger is quiet.
+func (l *SimpleLogger) println(format string, args ...interface{}) {
+	if !l.quiet {
+		utils.InfoLogger.Println(l.renderLogString(format, args...))
+	}
+}
+
+// fatalln is a method to log the message and exit, or to panic with it if the logger is quiet.
+func (l *SimpleLogger) fatalln(format string, args ...interface{}) {
+	if l.quiet {
+		panic(l.renderLogString(format, args...))
+	}
 	log.Fatalln(l.renderLogString(format, args...))
 }
 
@@ -52,6 +84,7 @@ func (l *SimpleLogger) WithField(key string, value interface{}) logger.Logger {
 	newFields[key] = value
 	return &SimpleLogger{
 		fields: newFields,
+		quiet:  l.quiet,
 	}
 }
 
@@ -62,6 +95,7 @@ func (l *SimpleLogger) WithFields(fields logrus.Fields) logger.Logger {
 	}
 	return &SimpleLogger{
 		fields: newFields,
+		quiet:  l.quiet,
 	}
 }
 
@@ -70,6 +104,7 @@ func (l *SimpleLogger) WithError(err error) logger.Logger {
 	newFields["error"] = err
 	return &SimpleLogger{
 		fields: newFields,
+		quiet:  l.quiet,
 	}
 }
 
@@ -98,10 +133,15 @@ func NewSimpleLogger() logger.Logger {
 }
 
 func AuthService(userID string) svc.Svc {
+	return newAuthService(userID, true, NewSimpleLogger())
+}
+
+// newAuthService is a function to get the goauthlib service of the user, opening the browser to sign in if autoOpen is set.
+func newAuthService(userID string, autoOpen bool, l logger.Logger) svc.Svc {
 	return svc.New(userID,
-		svc.WithAuthSvcBaseUrl(utils.ApiHost[4:]),
-		svc.WithAuthSvcLogger(NewSimpleLogger()),
-		svc.WithAuthSvcAutoOpen(true),
+		svc.WithAuthSvcBaseUrl(strings.TrimPrefix(utils.ApiHost, "api.")),
+		svc.WithAuthSvcLogger(l),
+		svc.WithAuthSvcAutoOpen(autoOpen),
 		svc.WithAuthSvcPersistentStore(AuthStore),
 	)
 }
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// OIDCScopes are the scopes requested from the identity provider. offline_access grants the refresh token.
+var OIDCScopes = []string{"openid", "email", "offline_access"}
+
+// OIDCProvider is a provider signing in with the identity provider of the organization, e.g. Okta, Entra ID or Keycloak,
+// with the device authorization grant of RFC 8628, so it works on machines without a browser as well.
+// The access tokens of the identity provider are sent to the API, which accepts them for the organizations set up for SSO.
+type OIDCProvider struct {
+	// Issuer is the URL of the identity provider serving /.well-known/openid-configuration.
+	Issuer string
+	// ClientID is the ID of the public client registered at the identity provider for the CLI.
+	ClientID string
+}
+
+// oidcSession is a structure of the tokens of the profile signed in with OIDCProvider or SSOProvider, stored in Credentials.
+// It carries the token endpoint and the client, so the tokens are refreshed even if the settings change meanwhile.
+type oidcSession struct {
+	TokenEndpoint string    `json:"token_endpoint"`
+	ClientID      string    `json:"client_id"`
+	AccessToken   string    `json:"access_token"`
+	RefreshToken  string    `json:"refresh_token,omitempty"`
+	Expiry        time.Time `json:"expiry"`
+}
+
+// oidcDiscovery is a structure of the endpoints of the identity provider.
+type oidcDiscovery struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+// oidcDeviceAuthorization is a structure of the response to the device authorization request.
+type oidcDeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// oidcTokens is a structure of the response of the token endpoint, either the tokens or the error.
+type oidcTokens struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Name is a method to get the name of the provider.
+func (p *OIDCProvider) Name() string {
+	return ProviderOIDC
+}
+
+func (p *OIDCProvider) key(pk ProfilePK) string {
+	return ProviderOIDC + "-" + string(pk)
+}
+
+// Login is a method to sign the profile in with the device code shown to the user, waiting until it's entered at the identity provider.
+func (p *OIDCProvider) Login(pk ProfilePK, credential string) error {
+	if len(p.Issuer) == 0 || len(p.ClientID) == 0 {
+		return errors.New("sign-in with OIDC isn't set up, set oidc-issuer and oidc-client-id with 'fvpn config set'")
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return fmt.Errorf("failed to discover the identity provider: %s", err)
+	}
+	if len(discovery.DeviceAuthorizationEndpoint) == 0 {
+		return errors.New("the identity provider doesn't support the device authorization grant")
+	}
+
+	var authorization oidcDeviceAuthorization
+	form := url.Values{"client_id": {p.ClientID}, "scope": {strings.Join(OIDCScopes, " ")}}
+	if err := postForm(discovery.DeviceAuthorizationEndpoint, form, &authorization); err != nil {
+		return fmt.Errorf("failed to request the device code: %s", err)
+	}
+	if len(authorization.DeviceCode) == 0 {
+		return fmt.Errorf("no device code issued by the identity provider, check that %s is a public client allowed the device authorization grant", p.ClientID)
+	}
+
+	if len(authorization.VerificationURIComplete) > 0 {
+		fmt.Printf("Open %s to sign in, and check that it shows the code %s\n", authorization.VerificationURIComplete, authorization.UserCode)
+	} else {
+		fmt.Printf("Open %s to sign in, and enter the code %s\n", authorization.VerificationURI, authorization.UserCode)
+	}
+
+	interval := time.Duration(authorization.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+	form = url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {authorization.DeviceCode},
+		"client_id":   {p.ClientID},
+	}
+	for {
+		time.Sleep(interval)
+		var tokens oidcTokens
+		if err := postForm(discovery.TokenEndpoint, form, &tokens); err != nil {
+			return err
+		}
+
+		switch tokens.Error {
+		case "":
+			session := oidcSession{TokenEndpoint: discovery.TokenEndpoint, ClientID: p.ClientID}
+			return p.save(pk, session, tokens)
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return errors.New("sign-in denied at the identity provider")
+		case "expired_token":
+			return errors.New("the code expired before the sign-in, try again")
+		default:
+			return tokens.err()
+		}
+
+		if authorization.ExpiresIn > 0 && time.Now().After(deadline) {
+			return errors.New("the code expired before the sign-in, try again")
+		}
+	}
+}
+
+// AccessToken is a method to get the access token of the profile, refreshing it shortly before it expires.
+func (p *OIDCProvider) AccessToken(pk ProfilePK) (string, error) {
+	return sessionAccessToken(p.key(pk))
+}
+
+// Logout is a method to forget the tokens of the profile.
+func (p *OIDCProvider) Logout(pk ProfilePK) {
+	_ = Credentials.Delete(p.key(pk))
+}
+
+// Unattended is a method to report that the profiles sign in with the user at the identity provider.
+func (p *OIDCProvider) Unattended() bool {
+	return false
+}
+
+// save is a method to store the tokens in the session of the profile.
+func (p *OIDCProvider) save(pk ProfilePK, session oidcSession, tokens oidcTokens) error {
+	return saveSession(p.key(pk), session, tokens)
+}
+
+// sessionAccessToken is a function to get the access token of the session stored in Credentials under key,
+// refreshing it at the token endpoint of the session shortly before it expires.
+func sessionAccessToken(key string) (string, error) {
+	value, err := Credentials.Load(key)
+	if err != nil {
+		return "", err
+	}
+
+	var session oidcSession
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		return "", err
+	}
+
+	if session.Expiry.IsZero() || time.Until(session.Expiry) > time.Minute {
+		return session.AccessToken, nil
+	}
+
+	if len(session.RefreshToken) == 0 {
+		// reported as the expired session, so the user is asked to log in again
+		return "", errors.New("token_expired: no refresh token granted")
+	}
+
+	var tokens oidcTokens
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {session.RefreshToken}}
+	if len(session.ClientID) > 0 {
+		form.Set("client_id", session.ClientID)
+	}
+	if err := postForm(session.TokenEndpoint, form, &tokens); err != nil {
+		return "", err
+	}
+	if len(tokens.Error) > 0 {
+		return "", tokens.err()
+	}
+
+	if err := saveSession(key, session, tokens); err != nil {
+		return "", err
+	}
+	return tokens.AccessToken, nil
+}
+
+// saveSession is a function to store the tokens in the session under key. The refresh token is kept unless a new one is issued.
+func saveSession(key string, session oidcSession, tokens oidcTokens) error {
+	if len(tokens.AccessToken) == 0 {
+		return errors.New("no access token issued")
+	}
+
+	session.AccessToken = tokens.AccessToken
+	if len(tokens.RefreshToken) > 0 {
+		session.RefreshToken = tokens.RefreshToken
+	}
+	session.Expiry = time.Time{}
+	if tokens.ExpiresIn > 0 {
+		session.Expiry = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return Credentials.Save(key, string(data))
+}
+
+// err is a method to get the error of the token endpoint, keeping its code, e.g. invalid_grant once the refresh token is revoked.
+func (t oidcTokens) err() error {
+	if len(t.ErrorDescription) > 0 {
+		return fmt.Errorf("%s: %s", t.Error, t.ErrorDescription)
+	}
+	return errors.New(t.Error)
+}
+
+// getJSON is a function to decode the JSON response to GET request to endpoint into v.
+func getJSON(endpoint string, v interface{}) error {
+	resp, err := utils.GetHttpClient(10).Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// postForm is a function to decode the JSON response to POST request of form to endpoint into v.
+// The token endpoint responds to the pending and rejected grants with 400 Bad Request and the error in the body, so it's decoded as well.
+func postForm(endpoint string, form url.Values, v interface{}) error {
+	resp, err := utils.GetHttpClient(10).PostForm(endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("%s responded with %s", endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s responded with %s", endpoint, resp.Status)
+	}
+	return nil
+}
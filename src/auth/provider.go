@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/secrets"
+)
+
+// Provider is an interface of the backends the profiles sign in with. The commands only ask the profile for the access token,
+// so another backend is added by implementing Provider and listing it in Providers.
+type Provider interface {
+	// Name is the name of the provider stored with the profile and picked with 'fvpn account login --provider'.
+	Name() string
+	// Login signs the profile with pk in, with the credential if the provider takes one, e.g. the machine token.
+	Login(pk ProfilePK, credential string) error
+	// AccessToken gets the raw token to authenticate the requests to the API, refreshing it if needed.
+	AccessToken(pk ProfilePK) (string, error)
+	// Logout forgets the credentials of the profile with pk.
+	Logout(pk ProfilePK)
+	// Unattended reports whether the profiles sign in without the user, e.g. on servers, so they're kept however long they stay inactive.
+	Unattended() bool
+}
+
+// ProviderBrowser is the name of BrowserProvider.
+const ProviderBrowser = "browser"
+
+// ProviderToken is the name of TokenProvider.
+const ProviderToken = "token"
+
+// ProviderTokenFile is the name of TokenFileProvider.
+const ProviderTokenFile = "token-file"
+
+// ProviderOIDC is the name of OIDCProvider.
+const ProviderOIDC = "oidc"
+
+// ProviderSSO is the name of SSOProvider.
+const ProviderSSO = "sso"
+
+// OIDC is the provider signing in with the identity provider of the organization, set up with the oidc-issuer and oidc-client-id settings.
+var OIDC = &OIDCProvider{}
+
+// Providers are the providers the profiles could sign in with by name.
+var Providers = map[string]Provider{
+	ProviderBrowser:   BrowserProvider{},
+	ProviderToken:     TokenProvider{},
+	ProviderTokenFile: TokenFileProvider{},
+	ProviderOIDC:      OIDC,
+	ProviderSSO:       SSOProvider{},
+}
+
+// ProviderNames is a function to get the names of Providers sorted.
+func ProviderNames() []string {
+	names := make([]string, 0, len(Providers))
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProvider is a function to get the provider by name.
+func GetProvider(name string) (Provider, error) {
+	provider, ok := Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown authentication provider %s, must be one of %s", name, strings.Join(ProviderNames(), ", "))
+	}
+	return provider, nil
+}
+
+// UnattendedTimeout is a time given to BrowserProvider.UnattendedAccessToken to refresh the token.
+const UnattendedTimeout = 30 * time.Second
+
+// ErrSignInRequired is returned by BrowserProvider.UnattendedAccessToken when the profile has to sign in in the browser again.
+var ErrSignInRequired = errors.New("sign-in required, run 'fvpn account login' to sign in again")
+
+// BrowserProvider is a provider signing in on the ForestVPN website opened in the browser, with the refresh token kept in AuthStore.
+type BrowserProvider struct{}
+
+// Name is a method to get the name of the provider.
+func (BrowserProvider) Name() string {
+	return ProviderBrowser
+}
+
+// Login is a method to sign the profile in. The browser is opened once the token is first requested.
+func (BrowserProvider) Login(pk ProfilePK, credential string) error {
+	return nil
+}
+
+// AccessToken is a method to get the token of the profile, opening the browser to sign in if there is none yet.
+func (BrowserProvider) AccessToken(pk ProfilePK) (string, error) {
+	token, err := AuthService(string(pk)).GetToken(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return token.Raw(), nil
+}
+
+// UnattendedAccessToken is a method to get the token of the profile with the refresh token kept in AuthStore, without opening the browser.
+// Returns ErrSignInRequired if the profile has to sign in again, or if goauthlib gives up on the token within UnattendedTimeout.
+func (BrowserProvider) UnattendedAccessToken(ctx context.Context, pk ProfilePK) (token string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, UnattendedTimeout)
+	defer cancel()
+	// the quiet logger panics on the fatal errors of goauthlib instead of exiting
+	defer func() {
+		if r := recover(); r != nil {
+			token, err = "", ErrSignInRequired
+		}
+	}()
+
+	t, err := newAuthService(string(pk), false, &SimpleLogger{quiet: true}).GetToken(ctx)
+	if err != nil && ctx.Err() != nil {
+		return "", ErrSignInRequired
+	}
+	if err != nil {
+		return "", err
+	}
+	return t.Raw(), nil
+}
+
+// Logout is a method to forget the credentials of the profile. The refresh token is left to goauthlib, which can't delete it.
+func (BrowserProvider) Logout(pk ProfilePK) {}
+
+// Unattended is a method to report that the profiles sign in with the user at the browser.
+func (BrowserProvider) Unattended() bool {
+	return false
+}
+
+// TokenProvider is a provider signing in with a long-lived machine token issued from the web dashboard, kept in MachineTokens.
+type TokenProvider struct{}
+
+// Name is a method to get the name of the provider.
+func (TokenProvider) Name() string {
+	return ProviderToken
+}
+
+// Login is a method to store the machine token of the profile.
+func (TokenProvider) Login(pk ProfilePK, credential string) error {
+	if len(credential) == 0 {
+		return errors.New("machine token required")
+	}
+	return MachineTokens.Save(string(pk), credential)
+}
+
+// AccessToken is a method to get the machine token of the profile.
+func (TokenProvider) AccessToken(pk ProfilePK) (string, error) {
+	return MachineTokens.Load(string(pk))
+}
+
+// Logout is a method to forget the machine token of the profile.
+func (TokenProvider) Logout(pk ProfilePK) {
+	_ = MachineTokens.Delete(string(pk))
+}
+
+// Unattended is a method to report that the profiles sign in without the user.
+func (TokenProvider) Unattended() bool {
+	return true
+}
+
+// TokenFileProvider is a provider reading the token from a file on every request, e.g. a secret mounted into a container
+// and rotated by the orchestrator. The path of the file is kept in Credentials.
+type TokenFileProvider struct{}
+
+// Name is a method to get the name of the provider.
+func (TokenFileProvider) Name() string {
+	return ProviderTokenFile
+}
+
+func (TokenFileProvider) key(pk ProfilePK) string {
+	return ProviderTokenFile + "-" + string(pk)
+}
+
+// Login is a method to store the path of the token file of the profile, which must hold a token already.
+func (p TokenFileProvider) Login(pk ProfilePK, credential string) error {
+	if len(credential) == 0 {
+		return errors.New("path of the token file required")
+	}
+
+	path, err := filepath.Abs(credential)
+	if err != nil {
+		return err
+	}
+
+	if _, err := readTokenFile(path); err != nil {
+		return err
+	}
+	return Credentials.Save(p.key(pk), path)
+}
+
+// AccessToken is a method to read the token from the file of the profile.
+func (p TokenFileProvider) AccessToken(pk ProfilePK) (string, error) {
+	path, err := Credentials.Load(p.key(pk))
+	if err != nil {
+		return "", err
+	}
+	return readTokenFile(path)
+}
+
+// Logout is a method to forget the path of the token file of the profile. The file itself is left alone.
+func (p TokenFileProvider) Logout(pk ProfilePK) {
+	_ = Credentials.Delete(p.key(pk))
+}
+
+// Unattended is a method to report that the profiles sign in without the user.
+func (TokenFileProvider) Unattended() bool {
+	return true
+}
+
+// readTokenFile is a function to read the token from the file at path.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", fmt.Errorf("no token in %s", path)
+	}
+	return token, nil
+}
+
+// legacyProvider is a function to get the provider of the profile with pk logged in before the providers were stored with the profiles.
+func legacyProvider(pk ProfilePK) Provider {
+	if _, err := MachineTokens.Load(string(pk)); !errors.Is(err, secrets.ErrNotFound) {
+		return TokenProvider{}
+	}
+	return BrowserProvider{}
+}
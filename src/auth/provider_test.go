@@ -0,0 +1,189 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/mock"
+	"github.com/forestvpn/cli/secrets"
+	"github.com/forestvpn/cli/utils"
+)
+
+func useTempStores(t *testing.T) {
+	dir := t.TempDir()
+	machineTokens, credentials := auth.MachineTokens, auth.Credentials
+	auth.MachineTokens = secrets.NewFileStore(filepath.Join(dir, "machine-tokens"))
+	auth.Credentials = secrets.NewFileStore(filepath.Join(dir, "credentials"))
+	t.Cleanup(func() {
+		auth.MachineTokens, auth.Credentials = machineTokens, credentials
+	})
+}
+
+func TestOIDCProvider(t *testing.T) {
+	useTempStores(t)
+
+	var polls, refreshes int
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"device_authorization_endpoint": server.URL + "/device",
+			"token_endpoint":                server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "cli" {
+			t.Errorf("client_id = %q", r.FormValue("client_id"))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code":      "device",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": server.URL + "/activate",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			polls++
+			if polls == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+				return
+			}
+			// expires right away, so the next request refreshes it
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "first", "refresh_token": "refresh", "expires_in": 30})
+		case "refresh_token":
+			refreshes++
+			if r.FormValue("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "second", "expires_in": 3600})
+		}
+	})
+
+	provider := &auth.OIDCProvider{Issuer: server.URL + "/", ClientID: "cli"}
+	if err := provider.Login("pk", ""); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 {
+		t.Errorf("polled %d times, want 2", polls)
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := provider.AccessToken("pk")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "second" {
+			t.Errorf("AccessToken() = %q, want second", token)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes)
+	}
+
+	provider.Logout("pk")
+	if _, err := provider.AccessToken("pk"); err != secrets.ErrNotFound {
+		t.Errorf("AccessToken() after Logout = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOIDCProviderNotSetUp(t *testing.T) {
+	useTempStores(t)
+	if err := (&auth.OIDCProvider{}).Login("pk", ""); err == nil {
+		t.Error("Login() without the issuer succeeded")
+	}
+}
+
+func TestSSOProvider(t *testing.T) {
+	useTempStores(t)
+	server := httptest.NewServer(mock.New())
+	defer server.Close()
+
+	scheme, host := utils.ApiScheme, utils.ApiHost
+	if err := utils.SetApiURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	openBrowser := auth.OpenBrowser
+	// following the redirects stands for the user signing in
+	auth.OpenBrowser = func(url string) error {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	t.Cleanup(func() {
+		utils.ApiScheme, utils.ApiHost = scheme, host
+		auth.OpenBrowser = openBrowser
+	})
+
+	provider := auth.SSOProvider{}
+	if err := provider.Login("pk", "unknown"); err == nil {
+		t.Error("Login() to the organization without the single sign-on succeeded")
+	}
+
+	if err := provider.Login("pk", mock.Organization); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := provider.AccessToken("pk"); err != nil || len(token) == 0 {
+		t.Errorf("AccessToken() = %q, %v", token, err)
+	}
+
+	provider.Logout("pk")
+	if _, err := provider.AccessToken("pk"); err != secrets.ErrNotFound {
+		t.Errorf("AccessToken() after Logout = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTokenFileProvider(t *testing.T) {
+	useTempStores(t)
+	path := filepath.Join(t.TempDir(), "token")
+
+	provider := auth.TokenFileProvider{}
+	if err := provider.Login("pk", path); err == nil {
+		t.Error("Login() with a missing file succeeded")
+	}
+
+	if err := os.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Login("pk", path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rotated token is picked up
+	if err := os.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := provider.AccessToken("pk"); err != nil || token != "second" {
+		t.Errorf("AccessToken() = %q, %v, want second", token, err)
+	}
+}
+
+func TestGetProvider(t *testing.T) {
+	for _, name := range auth.ProviderNames() {
+		provider, err := auth.GetProvider(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if provider.Name() != name {
+			t.Errorf("GetProvider(%q).Name() = %q", name, provider.Name())
+		}
+	}
+
+	if _, err := auth.GetProvider("saml"); err == nil {
+		t.Error("GetProvider(saml) succeeded")
+	}
+}
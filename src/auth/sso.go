@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// SSOTimeout is the time to wait for the user to sign in at the identity provider of the organization.
+const SSOTimeout = 5 * time.Minute
+
+// OpenBrowser is a function to open the sign-in page of SSOProvider, replaced in tests.
+var OpenBrowser = utils.OpenBrowser
+
+// SSOProvider is a provider signing in with the single sign-on of the organization set up at ForestVPN, SAML or OIDC alike.
+// The back-end takes the user through the identity provider of the organization in the browser, checks the assertion
+// and redirects to the CLI listening on the loopback interface with the code, which is exchanged for the ForestVPN tokens.
+// The code is bound to the CLI with PKCE of RFC 7636, so it's useless to anyone else who gets hold of it.
+type SSOProvider struct{}
+
+// Name is a method to get the name of the provider.
+func (SSOProvider) Name() string {
+	return ProviderSSO
+}
+
+func (SSOProvider) key(pk ProfilePK) string {
+	return ProviderSSO + "-" + string(pk)
+}
+
+// ssoURL is a function to get the URL of the SSO endpoint of the back-end at path.
+func ssoURL(path string) string {
+	return utils.ApiScheme + "://" + utils.ApiHost + "/v2/auth/sso/" + path
+}
+
+// Login is a method to sign the profile in with the single sign-on of the organization given by its slug as the credential.
+func (p SSOProvider) Login(pk ProfilePK, credential string) error {
+	if len(credential) == 0 {
+		return errors.New("organization required, e.g. --sso acme")
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr())
+	query := url.Values{
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	authorizeURL := ssoURL(url.PathEscape(credential) + "/authorize?" + query.Encode())
+
+	type callback struct {
+		code string
+		err  error
+	}
+	callbacks := make(chan callback, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+
+		// the page could be reloaded, or opened by another site to inject its own code
+		result := callback{code: r.FormValue("code")}
+		if r.FormValue("state") != state {
+			http.Error(w, "Unexpected sign-in, start it anew with 'fvpn account login --sso'", http.StatusBadRequest)
+			return
+		} else if e := r.FormValue("error"); len(e) > 0 {
+			result.err = errors.New(e)
+			if description := r.FormValue("error_description"); len(description) > 0 {
+				result.err = fmt.Errorf("%s: %s", e, description)
+			}
+			fmt.Fprintln(w, "Sign-in failed, see the terminal for the details.")
+		} else {
+			fmt.Fprintln(w, "Signed in to ForestVPN, you can close this window.")
+		}
+
+		select {
+		case callbacks <- result:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	fmt.Printf("Sign in with %s at %s\n", credential, authorizeURL)
+	if err := OpenBrowser(authorizeURL); err != nil && utils.Verbose {
+		utils.InfoLogger.Println(err)
+	}
+
+	var result callback
+	select {
+	case result = <-callbacks:
+	case <-time.After(SSOTimeout):
+		return fmt.Errorf("no sign-in within %s", SSOTimeout)
+	}
+	if result.err != nil {
+		return result.err
+	}
+
+	var tokens oidcTokens
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {result.code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURI},
+	}
+	if err := postForm(ssoURL("token/"), form, &tokens); err != nil {
+		return err
+	}
+	if len(tokens.Error) > 0 {
+		return tokens.err()
+	}
+
+	return saveSession(p.key(pk), oidcSession{TokenEndpoint: ssoURL("token/")}, tokens)
+}
+
+// AccessToken is a method to get the ForestVPN token of the profile, refreshing it shortly before it expires.
+func (p SSOProvider) AccessToken(pk ProfilePK) (string, error) {
+	return sessionAccessToken(p.key(pk))
+}
+
+// Logout is a method to forget the tokens of the profile.
+func (p SSOProvider) Logout(pk ProfilePK) {
+	_ = Credentials.Delete(p.key(pk))
+}
+
+// Unattended is a method to report that the profiles sign in with the user at the identity provider.
+func (SSOProvider) Unattended() bool {
+	return false
+}
+
+// randomString is a function to get a random URL-safe string to use once, e.g. the state or the PKCE verifier.
+func randomString() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -3,13 +3,15 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	forestvpn_api "github.com/forestvpn/api-client-go"
 	"github.com/forestvpn/cli/api"
-	"github.com/forestvpn/goauthlib/pkg/svc"
+	"github.com/forestvpn/cli/utils"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,6 +30,11 @@ type Profile struct {
 	LastSeen int64
 	Active   bool
 	Pk       ProfilePK
+	// SessionExpired is set once the back-end rejects the token of the profile, so the commands ask to log in again before doing anything.
+	SessionExpired bool
+	// Provider is the name of the provider the profile signed in with, see Providers.
+	// It's empty for the profiles logged in before, which sign in with a machine token if there is one, or in the browser otherwise.
+	Provider string `json:",omitempty"`
 	db       *UserDB
 }
 
@@ -46,6 +53,12 @@ func (p *Profile) MarkAsInactive() {
 	p.Save()
 }
 
+// MarkSessionExpired is a method to remember that the back-end rejected the token of the profile.
+func (p *Profile) MarkSessionExpired() {
+	p.SessionExpired = true
+	p.Save()
+}
+
 func (p *Profile) Save() {
 	path := filepath.Join(AppDir + "/profiles")
 	_ = os.Mkdir(path, 0755)
@@ -56,39 +69,119 @@ func (p *Profile) Save() {
 	p.db.persist()
 }
 
-func (p *Profile) Token() (svc.Token, error) {
-	return AuthService(string(p.Pk)).GetToken(context.Background())
+// AccessToken is a method to get the raw token to authenticate the requests to the API from the provider of the profile.
+func (p *Profile) AccessToken() (string, error) {
+	defer utils.RecordTiming("auth", "access token", time.Now())
+	token, err := p.AuthProvider().AccessToken(p.Pk)
+	if err != nil {
+		if isRevoked(err) {
+			return "", api.ErrSessionExpired
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// AuthProvider is a method to get the provider the profile signs in with.
+func (p *Profile) AuthProvider() Provider {
+	if provider, ok := Providers[p.Provider]; ok {
+		return provider
+	}
+	return legacyProvider(p.Pk)
+}
+
+// LoginWith is a method to sign the profile in with the provider, e.g. to store the machine token taken as the credential.
+// The profile keeps the provider to get the access tokens from.
+func (p *Profile) LoginWith(provider Provider, credential string) error {
+	if err := provider.Login(p.Pk, credential); err != nil {
+		return err
+	}
+
+	p.Provider = provider.Name()
+	p.Save()
+	return nil
+}
+
+// Logout is a method to forget the credentials of the profile kept by its provider.
+func (p *Profile) Logout() {
+	p.AuthProvider().Logout(p.Pk)
 }
 
-func (p *Profile) ApiClient(apiHost string) *api.ApiClientWrapper {
-	token, err := p.Token()
+// ApiClient is a method to get the client of the API authenticated with the access token of the profile.
+func (p *Profile) ApiClient(apiHost string) (*api.ApiClientWrapper, error) {
+	token, err := p.AccessToken()
 	if err != nil {
-		log.Fatalf("failed to get token for user %s: %v", p.Pk, err)
+		return nil, err
+	}
+	return api.GetApiClient(token, apiHost), nil
+}
+
+// UnattendedAccessToken is a method to get the access token like AccessToken, but failing with ErrSignInRequired
+// instead of opening the browser when the profile has to sign in again, e.g. to control the connection from another program.
+func (p *Profile) UnattendedAccessToken(ctx context.Context) (string, error) {
+	var token string
+	var err error
+	if browser, ok := p.AuthProvider().(BrowserProvider); ok {
+		token, err = browser.UnattendedAccessToken(ctx, p.Pk)
+	} else {
+		token, err = p.AuthProvider().AccessToken(p.Pk)
+	}
+
+	if err != nil && isRevoked(err) {
+		return "", api.ErrSessionExpired
+	}
+	return token, err
+}
+
+// isRevoked is a function to check whether err of the token refresh means the refresh token is no longer accepted, as opposed to a network failure.
+func isRevoked(err error) bool {
+	text := strings.ToLower(err.Error())
+	for _, reason := range []string{"invalid_grant", "revoked", "401", "unauthorized", "token_expired", "user_disabled"} {
+		if strings.Contains(text, reason) {
+			return true
+		}
 	}
-	return api.GetApiClient(token.Raw(), apiHost)
+	return false
 }
 
+// DeviceLimitHandler is a function type to pick the device to revoke when the account has reached its limit of devices.
+// It returns the UUID of the device to revoke.
+type DeviceLimitHandler func(devices []forestvpn_api.Device) (string, error)
+
 func (p *Profile) SignIn(apiHost string) error {
-	token, err := p.Token()
+	return p.SignInWith(apiHost, nil)
+}
+
+// SignInWith is a method to sign in the profile, calling onDeviceLimit if the device of the profile couldn't be created
+// as the account has reached its limit of devices. The device picked by onDeviceLimit is revoked to make room for the new one.
+func (p *Profile) SignInWith(apiHost string, onDeviceLimit DeviceLimitHandler) error {
+	return utils.ExplainClockSkew(p.signIn(apiHost, onDeviceLimit))
+}
+
+func (p *Profile) signIn(apiHost string, onDeviceLimit DeviceLimitHandler) error {
+	if p.SessionExpired {
+		return api.ErrSessionExpired
+	}
+
+	token, err := p.AccessToken()
 	if err != nil {
 		return err
 	}
 
 	if p.Email == "" {
 		// Create a new context with the token as the access token
-		authCtx := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, token.Raw())
-		apiClient := api.GetApiClient(token.Raw(), apiHost)
+		authCtx := context.WithValue(context.Background(), forestvpn_api.ContextAccessToken, token)
+		apiClient := api.GetApiClient(token, apiHost)
 		// Make a request to the WhoAmI endpoint
 		userInfo, _, loginErr := apiClient.APIClient.AuthApi.WhoAmI(authCtx).Execute()
-		// If there is an error, log it and return it
+		// If there is an error, return it
 		if loginErr != nil {
-			fmt.Println(token.Raw())
 			return loginErr
 		}
 		p.ID, p.Email = ProfileID(userInfo.GetId()), ProfileEmail(userInfo.GetEmail())
 		p.Touch()
 		// Create a new device for the user
-		if device, err := apiClient.CreateDevice(); err != nil {
+		if device, err := createDevice(apiClient, onDeviceLimit); err != nil {
 			return err
 		} else if err = UpdateProfileDevice(device, p.ID); err != nil {
 			return err
@@ -99,6 +192,34 @@ func (p *Profile) SignIn(apiHost string) error {
 	return nil
 }
 
+// createDevice is a function to create a new device for the user, revoking the device picked by onDeviceLimit if the limit of devices is reached.
+func createDevice(apiClient *api.ApiClientWrapper, onDeviceLimit DeviceLimitHandler) (*forestvpn_api.Device, error) {
+	device, err := apiClient.CreateDevice()
+	if err == nil || !api.IsDeviceLimitError(err) {
+		return device, err
+	}
+
+	if onDeviceLimit == nil {
+		return nil, errors.New("the account has reached its limit of devices, try 'fvpn account login --replace-oldest'")
+	}
+
+	devices, err := apiClient.ListDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := onDeviceLimit(devices)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apiClient.DeleteDevice(id); err != nil {
+		return nil, err
+	}
+
+	return apiClient.CreateDevice()
+}
+
 func (p *Profile) DB() *UserDB {
 	return p.db
 }
@@ -109,6 +230,24 @@ type UserDB struct {
 	Users   map[ProfilePK]*Profile
 }
 
+// ErrNotLoggedIn is returned by SignedInUser when no profile is signed in on the machine.
+var ErrNotLoggedIn = errors.New("not logged in, log in with 'fvpn account login' first")
+
+// SignedInUser is a method to get the current profile like CurrentUser, but returning ErrNotLoggedIn instead of creating a profile
+// to sign in if there is none, and api.ErrSessionExpired once the back-end rejected its token.
+func (db *UserDB) SignedInUser() (*Profile, error) {
+	p := db.Users[db.current]
+	if p == nil || len(p.ID) == 0 || !p.Active {
+		return nil, ErrNotLoggedIn
+	}
+	if p.SessionExpired {
+		return nil, api.ErrSessionExpired
+	}
+
+	p.db = db
+	return p, nil
+}
+
 func (db *UserDB) CurrentUser() *Profile {
 	db.Sync()
 	p := db.Users[db.current]
@@ -130,6 +269,16 @@ func (db *UserDB) ListUsers() []*Profile {
 	return users
 }
 
+// FindUser is a method to find the active profile by email.
+func (db *UserDB) FindUser(email string) (*Profile, bool) {
+	for _, profile := range db.ListUsers() {
+		if strings.EqualFold(string(profile.Email), email) {
+			return profile, true
+		}
+	}
+	return nil, false
+}
+
 func (db *UserDB) CreateUser() *Profile {
 	profile := &Profile{Pk: ProfilePK(uuid.New().String()), db: db}
 	profile.db = db
@@ -141,36 +290,55 @@ func (db *UserDB) CreateUser() *Profile {
 }
 
 func (db *UserDB) persist() {
+	if err := db.write(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// write is a method to store the profiles in the accounts map file.
+func (db *UserDB) write() error {
 	data, err := json.Marshal(db)
 	if err != nil {
-		log.Fatalf("failed to marshal Users to json: %v", err)
+		return fmt.Errorf("failed to marshal Users to json: %v", err)
 	}
 
 	err = os.WriteFile(db.path, data, 0644)
 	if err != nil {
-		log.Fatalf("failed to write to accounts map file %s: %v", db.path, err)
+		return fmt.Errorf("failed to write to accounts map file %s: %v", db.path, err)
 	}
+	return nil
 }
 
 func (db *UserDB) Sync() *UserDB {
+	if err := db.sync(); err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// sync is a method to read the profiles from the accounts map file and pick the current one,
+// marking the profiles inactive for 30 days or more as logged out.
+func (db *UserDB) sync() error {
 	data, err := os.ReadFile(db.path)
 	if err != nil {
-		log.Fatalf("failed to read accounts map file %s: %v", db.path, err)
+		return fmt.Errorf("failed to read accounts map file %s: %v", db.path, err)
 	}
 
 	err = json.Unmarshal(data, &db)
 	if err != nil {
-		log.Fatalf("failed to unmarshal Users from json: %v", err)
-
+		return fmt.Errorf("failed to unmarshal Users from json: %v", err)
 	}
 
 	// if there is no user with last seen time in 30 days or more, create one
+	inactive := false
 	for _, user := range db.Users {
 		if !user.Active {
 			continue
 		}
-		if time.Now().Unix()-user.LastSeen > 30*24*60*60 {
-			user.MarkAsInactive()
+		// machine tokens are used on servers that could stay unattended for long
+		if time.Now().Unix()-user.LastSeen > 30*24*60*60 && !user.AuthProvider().Unattended() {
+			user.Active = false
+			inactive = true
 			continue
 		}
 		if db.current == "" {
@@ -182,7 +350,22 @@ func (db *UserDB) Sync() *UserDB {
 		}
 	}
 
-	return db
+	if inactive {
+		return db.write()
+	}
+	return nil
+}
+
+// LoadUserDB is a function to read the profiles like OpenUserDB, but returning the error instead of exiting the process.
+// The accounts map file isn't created if it's missing: ErrNotLoggedIn is returned then.
+func LoadUserDB() (*UserDB, error) {
+	path := filepath.Join(AppDir, AccountsMapFile)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotLoggedIn
+	}
+
+	db := &UserDB{path: path, Users: map[ProfilePK]*Profile{}}
+	return db, db.sync()
 }
 
 func OpenUserDB() *UserDB {
@@ -31,6 +31,77 @@ const WireguardConfig = "/fvpn0.conf"
 
 var ProfilesDir = AppDir + "profiles/"
 
+// CountriesFile is a file to cache the countries available at back-end.
+const CountriesFile = "countries.json"
+
+// LocationsFile is a file to cache the locations last fetched from back-end, e.g. for shell completion.
+const LocationsFile = "locations.json"
+
+// BlocklistFile is a file in AppDir to store the domains blocked with 'fvpn block add'.
+const BlocklistFile = "blocklist.json"
+
+// MachineTokensDir is a directory in AppDir to store the machine tokens of the profiles logged in with 'fvpn account login --token'.
+const MachineTokensDir = "machine-tokens"
+
+// CredentialsDir is a directory in AppDir to store the credentials of the profiles logged in with the other providers, e.g. 'fvpn account login --provider oidc'.
+const CredentialsDir = "credentials"
+
+// FailoverFile is a file to store the last failover of the connection to the standby location.
+const FailoverFile = "/failover.json"
+
+// StandbyDeviceFile is a file to store the device registered at the standby location the connection fails over to.
+const StandbyDeviceFile = "/standby-device.json"
+
+// LabelFile is a file to store the label of the connection given with 'fvpn state up --label'.
+const LabelFile = "/label"
+
+// QuotaFile is a file to store the monthly data quota and the data used through the connection.
+const QuotaFile = "/quota.json"
+
+// MonitorFile is a file to store the samples of the tunnel quality recorded with 'fvpn monitor start', one JSON object per line.
+const MonitorFile = "/monitor.jsonl"
+
+// StateFile is a file in AppDir to store the state of the connection for external supervisors and status bars, updated on every transition.
+const StateFile = "state.json"
+
+// ProxyConfig is a configuration file of wireproxy serving the connection as a SOCKS5 proxy, e.g. in Termux.
+const ProxyConfig = "/proxy.conf"
+
+// ProxyPidFile is a file in AppDir to store the process ID of the running wireproxy.
+const ProxyPidFile = "proxy.pid"
+
+// DaemonPidFile is a file in AppDir to store the process ID of the running 'fvpn daemon', which supervises the connection instead of 'fvpn state supervise'.
+const DaemonPidFile = "daemon.pid"
+
+// DefaultLocationFile is a file to store the UUID of the default location while the connection uses another one with 'fvpn state up --location'.
+const DefaultLocationFile = "/default-location"
+
+// ExpiryFile is a file to store the time the connection set up with 'fvpn state up --for' is set down at.
+const ExpiryFile = "/expiry"
+
+// HistoryFile is a file to store the connection history recorded on every transition, one JSON object per line.
+const HistoryFile = "/history.jsonl"
+
+// RoutingTableFile is a file to store the routing table picked for the tunnel with the auto routing-table setting.
+const RoutingTableFile = "/routing-table"
+
+// DNSBackendFile is a file to store the DNS backend the servers of the running connection were set with, so they're reverted with the same one.
+const DNSBackendFile = "/dns-backend"
+
+// KillSwitchFile is a file to store the backend the kill switch of the connection was enabled with, see 'fvpn state up --killswitch'.
+// It's kept until 'fvpn state down', so the kill switch is enabled again whenever the connection is set up anew meanwhile.
+const KillSwitchFile = "/killswitch"
+
+// LoginAttemptsFile is a file in AppDir to store the failed logins in a row, so 'fvpn account login' backs off before the back-end locks the account.
+const LoginAttemptsFile = "login-attempts.json"
+
+// ReconnectFile is a file to store the supervision of the connection set up with 'fvpn state up --auto-reconnect' and its latest reconnects.
+// It's kept until 'fvpn state down', which stops the supervisor.
+const ReconnectFile = "/reconnect.json"
+
+// UpsellFile is a file to store the time 'fvpn state up' last reminded of the ending trial or subscription.
+const UpsellFile = "/upsell"
+
 // BillingFeatureFile is a file to store user's billing features locally.
 const BillingFeatureFile = "/billing.json"
 
@@ -156,12 +227,17 @@ func PrintLocalAccounts() error {
 		if v.Pk == current.Pk {
 			mark = "*"
 		}
-		data = append(data, []string{mark, string(v.Email), string(v.ID)})
+		email := string(v.Email)
+		if v.SessionExpired {
+			email += " (session expired)"
+		}
+		data = append(data, []string{mark, email, string(v.ID)})
 	}
 
 	t := tablewriter.NewWriter(os.Stdout)
 	t.SetHeader([]string{"IsActive", "Email", "UUID"})
 	t.SetBorder(false)
+	t.SetAutoWrapText(false)
 	t.AppendBulk(data)
 	t.Render()
 
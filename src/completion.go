@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/auth"
+	"github.com/urfave/cli/v2"
+)
+
+// bashCompletion is a bash script that completes fvpn commands and their arguments with the dynamic completion callbacks.
+const bashCompletion = `_fvpn_bash_autocomplete() {
+  if [[ "${COMP_WORDS[0]}" != "source" ]]; then
+    local cur opts
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    if [[ "$cur" == "-"* ]]; then
+      opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} ${cur} --generate-bash-completion )
+    else
+      opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} --generate-bash-completion )
+    fi
+    local IFS=$'\n'
+    COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+    return 0
+  fi
+}
+
+complete -o bashdefault -o default -F _fvpn_bash_autocomplete fvpn
+`
+
+// zshCompletion is a zsh script that completes fvpn commands and their arguments with the dynamic completion callbacks.
+const zshCompletion = `#compdef fvpn
+
+_fvpn() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _fvpn fvpn
+`
+
+// completeLocations is a completion callback that prints the names of the locations cached by the last location listing.
+func completeLocations(c *cli.Context) {
+	if c.NArg() > 0 {
+		return
+	}
+
+	locations, err := actions.LoadLocations()
+	if err != nil {
+		return
+	}
+
+	for _, loc := range locations {
+		fmt.Println(loc.GetName())
+	}
+}
+
+// completeAccounts is a completion callback that prints the emails of the local accounts.
+func completeAccounts(c *cli.Context) {
+	if c.NArg() > 0 {
+		return
+	}
+
+	for _, profile := range auth.OpenUserDB().ListUsers() {
+		if len(profile.Email) > 0 {
+			fmt.Println(profile.Email)
+		}
+	}
+}
+
+// completeCountries is a completion callback that prints the names and codes of the countries of the cached locations
+// after the --country flag, and the flags otherwise.
+func completeCountries(c *cli.Context) {
+	if len(os.Args) < 3 || (os.Args[len(os.Args)-2] != "--country" && os.Args[len(os.Args)-2] != "-c") {
+		cli.DefaultCompleteWithFlags(c.Command)(c)
+		return
+	}
+
+	locations, err := actions.LoadLocations()
+	if err != nil {
+		return
+	}
+
+	printed := make(map[string]bool)
+	for _, loc := range locations {
+		country := loc.GetCountry()
+		for _, name := range []string{country.GetName(), country.GetId()} {
+			if len(name) > 0 && !printed[name] {
+				printed[name] = true
+				fmt.Println(name)
+			}
+		}
+	}
+}
@@ -0,0 +1,534 @@
+// config is a package to store user settings of Forest CLI that are set with 'fvpn config set'.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/dns"
+	"github.com/forestvpn/cli/secrets"
+	"github.com/forestvpn/cli/utils"
+)
+
+// MQTT is a setting holding the MQTT broker URL to publish the state of the connection to, e.g. tcp://broker:1883.
+const MQTT = "mqtt"
+
+// MQTTTopic is a setting holding the MQTT topic to publish the state of the connection to.
+const MQTTTopic = "mqtt-topic"
+
+// DaemonAddress is a setting holding the address of the remote daemon to control with the CLI, e.g. tls://router.lan:9999.
+const DaemonAddress = "daemon-address"
+
+// DaemonToken is a setting holding the bearer token to authenticate to the remote daemon.
+const DaemonToken = "daemon-token"
+
+// DaemonCA is a setting holding the PEM file to verify the TLS certificate of the remote daemon.
+const DaemonCA = "daemon-ca"
+
+// CrashReports is a setting holding the mode of crash reports: upload, local or off.
+const CrashReports = "crash-reports"
+
+// Failover is a setting holding the standby location to switch to once the connected location stops responding:
+// off, auto to pick the next-best location, or the UUID, slug or name of the location.
+const Failover = "failover"
+
+// PromptFormat is a setting holding the format of 'fvpn status --prompt' with {flag}, {country} and {city} placeholders.
+const PromptFormat = "prompt-format"
+
+// StatusPage is a setting holding the URL of the Statuspage-compatible status page to check with 'fvpn service status'.
+const StatusPage = "status-page"
+
+// Keepalive is a setting holding the interval of the persistent keepalive to the peers in seconds, or off.
+const Keepalive = "keepalive"
+
+// BatteryKeepalive is a setting holding the interval of the persistent keepalive to the peers in seconds while on battery, or off.
+const BatteryKeepalive = "battery-keepalive"
+
+// Metered is a setting holding whether the automatic reconnects and failovers are paused on metered connections: pause or ignore.
+const Metered = "metered"
+
+// AutoSwitch is a setting holding the policy to switch to the next-best location once the quality of the connection degrades,
+// e.g. loss>5% for 2m, or off.
+const AutoSwitch = "auto-switch"
+
+// IPv6Only is a setting holding whether the endpoints are rewritten to IPv6 for networks without IPv4, e.g. with NAT64: auto, on or off.
+const IPv6Only = "ipv6-only"
+
+// TunnelScope is a setting holding the traffic routed through the tunnel: all, internet-only or custom.
+const TunnelScope = "tunnel-scope"
+
+// TunnelCIDRs is a setting holding the comma-separated networks routed through the tunnel with the custom TunnelScope.
+const TunnelCIDRs = "tunnel-cidrs"
+
+// ContainerNetworks is a setting holding what to do with the networks of Docker and Kubernetes overlapping the tunnel: exclude or warn.
+const ContainerNetworks = "container-networks"
+
+// WSL is a setting holding how 'fvpn state up' connects inside WSL2: auto, host to drive the fvpn daemon of the Windows host,
+// or userspace to run wireguard-go in WSL.
+const WSL = "wsl"
+
+// ProxyAddress is a setting holding the address of the SOCKS5 proxy the connection is served on in Termux, where it can't be routed without root.
+const ProxyAddress = "proxy-address"
+
+// SecretBackend is a setting holding the storage of the refresh and machine tokens:
+// file, keychain, vault://MOUNT/PATH or ssm:///PATH, see secrets.Open.
+const SecretBackend = "secret-backend"
+
+// IdleTimeout is a setting holding the time without traffic through the tunnel after which 'fvpn daemon' sets the connection down, or off.
+const IdleTimeout = "idle-timeout"
+
+// RequireReason is a setting holding whether 'fvpn state up' refuses to connect without --reason: on or off.
+const RequireReason = "require-reason"
+
+// RoutingTable is a setting holding the routing table of the routes of the tunnel on Linux: auto to pick a free one per profile,
+// the number of the table, or off to leave the routing to wg-quick.
+const RoutingTable = "routing-table"
+
+// Fwmark is a setting holding the firewall mark of the packets of the tunnel on Linux: auto for the number of the routing table, or the mark.
+const Fwmark = "fwmark"
+
+// DNSBackend is a setting holding the backend setting the DNS servers of the connection: auto to match the resolver stack of the host,
+// or one of dns.Backends.
+const DNSBackend = "dns-backend"
+
+// Upsell is a setting holding whether 'fvpn state up' reminds of the ending trial or subscription with the checkout URL, at most once a day:
+// on or off, e.g. for the machines of an organization paying for them.
+const Upsell = "upsell"
+
+// AuthProvider is a setting holding the provider 'fvpn account login' signs in with when neither --token nor --token-file is given: browser or oidc.
+const AuthProvider = "auth-provider"
+
+// OIDCIssuer is a setting holding the URL of the OpenID Connect identity provider of the organization to sign in with the oidc AuthProvider.
+const OIDCIssuer = "oidc-issuer"
+
+// OIDCClientID is a setting holding the ID of the client registered for the CLI at the OIDCIssuer.
+const OIDCClientID = "oidc-client-id"
+
+var home, _ = os.UserHomeDir()
+
+// Path is a file to store the settings.
+var Path = filepath.Join(home, ".forestvpn", "config.json")
+
+// Key is a structure describing a setting that could be set by the user.
+type Key struct {
+	Name     string
+	Usage    string
+	Default  string
+	Validate func(value string) error
+}
+
+var keys = map[string]Key{
+	Failover: {
+		Name:    Failover,
+		Usage:   "standby location 'fvpn daemon' switches to with a standby device once the connected location stops responding, until the next 'fvpn state up': off, auto or UUID, slug or name",
+		Default: "off",
+	},
+	AutoSwitch: {
+		Name:     AutoSwitch,
+		Usage:    "switch 'fvpn daemon' to the next-best location once the quality degrades, e.g. loss>5% for 2m, latency>200ms for 5m or jitter>30ms for 2m, or off",
+		Default:  "off",
+		Validate: validateAutoSwitch,
+	},
+	Keepalive: {
+		Name:     Keepalive,
+		Usage:    "interval of the keepalive to the peers in seconds to keep NAT mappings open, or off",
+		Default:  "off",
+		Validate: validateKeepalive,
+	},
+	BatteryKeepalive: {
+		Name:     BatteryKeepalive,
+		Usage:    "interval of the keepalive to the peers in seconds while on battery, or off",
+		Default:  "off",
+		Validate: validateKeepalive,
+	},
+	IPv6Only: {
+		Name:     IPv6Only,
+		Usage:    "rewrite the endpoints to IPv6 with NAT64 on networks without IPv4, e.g. mobile carriers, when detected (auto), always (on) or never (off)",
+		Default:  "auto",
+		Validate: oneOf("auto", "on", "off"),
+	},
+	Metered: {
+		Name:     Metered,
+		Usage:    "pause the automatic reconnects and failovers of 'fvpn daemon' on metered connections, e.g. LTE (pause), or not (ignore)",
+		Default:  "pause",
+		Validate: oneOf("pause", "ignore"),
+	},
+	MQTT: {
+		Name:     MQTT,
+		Usage:    "MQTT broker URL to publish the state of the connection to, e.g. tcp://broker:1883",
+		Validate: validateBrokerURL,
+	},
+	ContainerNetworks: {
+		Name:     ContainerNetworks,
+		Usage:    "exclude the networks of Docker, Kubernetes and other container bridges overlapping the tunnel from it (exclude) or only warn about them (warn)",
+		Default:  "exclude",
+		Validate: oneOf("exclude", "warn"),
+	},
+	CrashReports: {
+		Name:     CrashReports,
+		Usage:    "upload scrubbed crash reports, keep them in ~/.forestvpn/crash-reports (local) or drop them (off)",
+		Default:  "upload",
+		Validate: oneOf("upload", "local", "off"),
+	},
+	DaemonAddress: {
+		Name:     DaemonAddress,
+		Usage:    "address of the remote daemon to control, e.g. tls://router.lan:9999, tcp://127.0.0.1:9999, unix:///run/fvpn.sock or npipe:////./pipe/fvpn",
+		Validate: validateDaemonAddress,
+	},
+	DaemonToken: {
+		Name:  DaemonToken,
+		Usage: "bearer token to authenticate to the remote daemon",
+	},
+	DaemonCA: {
+		Name:     DaemonCA,
+		Usage:    "PEM file to verify the TLS certificate of the remote daemon",
+		Validate: validateFile,
+	},
+	PromptFormat: {
+		Name:    PromptFormat,
+		Usage:   "format of 'fvpn status --prompt' with {flag}, {country} and {city} placeholders",
+		Default: "🌲 {country}",
+	},
+	StatusPage: {
+		Name:     StatusPage,
+		Usage:    "URL of the Statuspage-compatible status page to check with 'fvpn service status'",
+		Validate: validateHTTPURL,
+	},
+	TunnelScope: {
+		Name:     TunnelScope,
+		Usage:    "traffic routed through the tunnel: all, internet-only to keep the local network reachable, or custom networks set with tunnel-cidrs",
+		Default:  "internet-only",
+		Validate: oneOf("all", "internet-only", "custom"),
+	},
+	TunnelCIDRs: {
+		Name:     TunnelCIDRs,
+		Usage:    "comma-separated networks routed through the tunnel with the custom tunnel-scope, e.g. 203.0.113.0/24,2001:db8::/32",
+		Validate: validateCIDRs,
+	},
+	WSL: {
+		Name:     WSL,
+		Usage:    "inside WSL2, drive the 'fvpn daemon' of the Windows host (host), run wireguard-go in WSL (userspace), or the daemon if it's reachable (auto)",
+		Default:  "auto",
+		Validate: oneOf("auto", "host", "userspace"),
+	},
+	ProxyAddress: {
+		Name:     ProxyAddress,
+		Usage:    "address of the SOCKS5 proxy the connection is served on in Termux, e.g. 127.0.0.1:1080",
+		Default:  "127.0.0.1:1080",
+		Validate: validateHostPort,
+	},
+	SecretBackend: {
+		Name:     SecretBackend,
+		Usage:    "storage of the refresh and machine tokens: file in ~/.forestvpn, keychain, vault://MOUNT/PATH with VAULT_ADDR and VAULT_TOKEN, or ssm:///PATH with the AWS CLI",
+		Default:  "file",
+		Validate: validateSecretBackend,
+	},
+	IdleTimeout: {
+		Name:     IdleTimeout,
+		Usage:    "time without traffic through the tunnel after which 'fvpn daemon' sets the connection down, e.g. 30m, or off",
+		Default:  "off",
+		Validate: validateIdleTimeout,
+	},
+	RequireReason: {
+		Name:     RequireReason,
+		Usage:    "refuse to connect without the reason given with 'fvpn state up --reason' for the connection history (on) or not (off)",
+		Default:  "off",
+		Validate: oneOf("on", "off"),
+	},
+	DNSBackend: {
+		Name:     DNSBackend,
+		Usage:    "backend setting the DNS servers of the connection: auto to match the resolver stack of the host, or " + strings.Join(dns.Backends, ", "),
+		Default:  "auto",
+		Validate: oneOf(append([]string{"auto"}, dns.Backends...)...),
+	},
+	RoutingTable: {
+		Name:     RoutingTable,
+		Usage:    "routing table of the routes of the tunnel on Linux, looked up ahead of the main table for the traffic not marked with fwmark: auto to pick a free one, a number, or off to leave the routing to wg-quick",
+		Default:  "auto",
+		Validate: validateRoutingTable,
+	},
+	Fwmark: {
+		Name:     Fwmark,
+		Usage:    "firewall mark of the encrypted packets of the tunnel on Linux, which bypass its routing table: auto for the number of routing-table, or a mark, e.g. 0xca6c",
+		Default:  "auto",
+		Validate: validateFwmark,
+	},
+	Upsell: {
+		Name:     Upsell,
+		Usage:    "remind of the ending trial or subscription with the checkout URL on 'fvpn state up', at most once a day (on) or never (off); the expired access is reported anyway",
+		Default:  "on",
+		Validate: oneOf("on", "off"),
+	},
+	AuthProvider: {
+		Name:     AuthProvider,
+		Usage:    "provider 'fvpn account login' signs in with: the ForestVPN website in the browser (browser), or the identity provider of the organization set with oidc-issuer (oidc)",
+		Default:  "browser",
+		Validate: oneOf("browser", "oidc"),
+	},
+	OIDCIssuer: {
+		Name:     OIDCIssuer,
+		Usage:    "URL of the OpenID Connect identity provider of the organization to sign in with, e.g. https://login.example.com/realms/staff",
+		Validate: validateHTTPURL,
+	},
+	OIDCClientID: {
+		Name:  OIDCClientID,
+		Usage: "ID of the public client registered for the CLI at oidc-issuer, allowed the device authorization grant",
+	},
+	MQTTTopic: {
+		Name:    MQTTTopic,
+		Usage:   "MQTT topic to publish the state of the connection to",
+		Default: "fvpn/state",
+	},
+}
+
+// Keys is a function to get all the known settings sorted by name.
+func Keys() []Key {
+	result := make([]Key, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, k)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// Config is a structure holding the settings set by the user.
+type Config map[string]string
+
+// Load is a function that reads the settings from the Path.
+// Returns an empty Config if the file does not exist yet.
+func Load() (Config, error) {
+	c := Config{}
+	data, err := os.ReadFile(Path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Get is a method to get the value of the setting or its default value if the setting is not set.
+func (c Config) Get(key string) string {
+	if value, ok := c[key]; ok {
+		return value
+	}
+
+	return keys[key].Default
+}
+
+// Set is a method to validate and store the value of the setting.
+func (c Config) Set(key string, value string) error {
+	k, ok := keys[key]
+	if !ok {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+
+	if k.Validate != nil {
+		if err := k.Validate(value); err != nil {
+			return err
+		}
+	}
+
+	c[key] = value
+	return c.save()
+}
+
+// Unset is a method to reset the setting to its default value.
+func (c Config) Unset(key string) error {
+	if _, ok := keys[key]; !ok {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+
+	delete(c, key)
+	return c.save()
+}
+
+func (c Config) save() error {
+	data, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(Path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(Path, data, 0644)
+}
+
+func validateBrokerURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "mqtts":
+		return nil
+	}
+
+	return fmt.Errorf("unsupported MQTT broker URL: %s", value)
+}
+
+func validateDaemonAddress(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "tcp", "tls", "http", "https", "unix", "npipe":
+		return nil
+	}
+
+	return fmt.Errorf("unsupported daemon address: %s", value)
+}
+
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("unsupported URL: %s", value)
+	}
+
+	return nil
+}
+
+func validateKeepalive(value string) error {
+	if value == "off" {
+		return nil
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 1 || seconds > 65535 {
+		return fmt.Errorf("must be off or seconds between 1 and 65535: %s", value)
+	}
+
+	return nil
+}
+
+func validateIdleTimeout(value string) error {
+	_, err := ParseIdleTimeout(value)
+	return err
+}
+
+// ParseIdleTimeout is a function to parse the value of the IdleTimeout setting. Returns 0 for off.
+func ParseIdleTimeout(value string) (time.Duration, error) {
+	if value == "off" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < time.Minute {
+		return 0, fmt.Errorf("must be off or a duration of at least a minute, e.g. 30m: %s", value)
+	}
+
+	return timeout, nil
+}
+
+func validateRoutingTable(value string) error {
+	if value == "auto" || value == "off" {
+		return nil
+	}
+
+	// 253 to 255 are the default, main and local tables of the kernel
+	table, err := strconv.Atoi(value)
+	if err != nil || table < 1 || table > 2147483647 || (table >= 253 && table <= 255) {
+		return fmt.Errorf("must be auto, off or a table number other than 253, 254 and 255: %s", value)
+	}
+
+	return nil
+}
+
+func validateFwmark(value string) error {
+	if value == "auto" {
+		return nil
+	}
+
+	if _, err := ParseFwmark(value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ParseFwmark is a function to parse the firewall mark of the Fwmark setting, decimal or hexadecimal with 0x.
+func ParseFwmark(value string) (uint32, error) {
+	mark, err := strconv.ParseUint(value, 0, 32)
+	if err != nil || mark == 0 {
+		return 0, fmt.Errorf("must be auto or a nonzero 32-bit mark, e.g. 51821 or 0xca6d: %s", value)
+	}
+
+	return uint32(mark), nil
+}
+
+func validateAutoSwitch(value string) error {
+	if value == "off" {
+		return nil
+	}
+
+	_, err := ParseSwitchPolicy(value)
+	return err
+}
+
+func validateCIDRs(value string) error {
+	networks, err := utils.ParseCIDRs(value)
+	if err == nil && len(networks) == 0 {
+		return fmt.Errorf("no networks given: %s", value)
+	}
+	return err
+}
+
+func validateHostPort(value string) error {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return err
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port: %s", port)
+	}
+
+	return nil
+}
+
+func validateSecretBackend(value string) error {
+	_, err := secrets.Open(value, "")
+	return err
+}
+
+func validateFile(value string) error {
+	_, err := os.Stat(value)
+	return err
+}
+
+func oneOf(values ...string) func(value string) error {
+	return func(value string) error {
+		for _, v := range values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of: %s", strings.Join(values, ", "))
+	}
+}
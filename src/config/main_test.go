@@ -0,0 +1,39 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/forestvpn/cli/config"
+)
+
+func TestParseSwitchPolicy(t *testing.T) {
+	for _, c := range []struct {
+		value    string
+		expected config.SwitchPolicy
+		valid    bool
+	}{
+		{"loss>5% for 2m", config.SwitchPolicy{Metric: "loss", Threshold: 5, For: 2 * time.Minute}, true},
+		{"latency>200ms for 5m", config.SwitchPolicy{Metric: "latency", Threshold: 200, For: 5 * time.Minute}, true},
+		{" jitter > 30.5ms for 90s ", config.SwitchPolicy{Metric: "jitter", Threshold: 30.5, For: 90 * time.Second}, true},
+		{"latency>200 for 5m", config.SwitchPolicy{Metric: "latency", Threshold: 200, For: 5 * time.Minute}, true},
+		{"loss>5%", config.SwitchPolicy{}, false},
+		{"loss=5% for 2m", config.SwitchPolicy{}, false},
+		{"speed>5ms for 2m", config.SwitchPolicy{}, false},
+		{"loss>5ms for 2m", config.SwitchPolicy{}, false},
+		{"latency>0ms for 2m", config.SwitchPolicy{}, false},
+		{"latency>-5ms for 2m", config.SwitchPolicy{}, false},
+		{"latency>200ms for 0s", config.SwitchPolicy{}, false},
+		{"latency>200ms for soon", config.SwitchPolicy{}, false},
+		{"", config.SwitchPolicy{}, false},
+	} {
+		policy, err := config.ParseSwitchPolicy(c.value)
+		if c.valid && err != nil {
+			t.Errorf("ParseSwitchPolicy(%q): %s", c.value, err)
+		} else if !c.valid && err == nil {
+			t.Errorf("ParseSwitchPolicy(%q) = %+v, expected an error", c.value, policy)
+		} else if c.valid && policy != c.expected {
+			t.Errorf("ParseSwitchPolicy(%q) = %+v, expected %+v", c.value, policy, c.expected)
+		}
+	}
+}
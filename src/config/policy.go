@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SwitchPolicy is a structure representing the auto-switch setting: the connection is switched once Metric stays above Threshold For long.
+type SwitchPolicy struct {
+	// Metric is one of loss in percents, latency or jitter in milliseconds.
+	Metric    string
+	Threshold float64
+	For       time.Duration
+}
+
+// ParseSwitchPolicy is a function that parses the auto-switch setting, e.g. loss>5% for 2m or latency>200ms for 5m.
+func ParseSwitchPolicy(value string) (SwitchPolicy, error) {
+	var policy SwitchPolicy
+	invalid := fmt.Errorf("invalid policy %q, expected e.g. loss>5%% for 2m, latency>200ms for 5m or jitter>30ms for 2m", value)
+
+	condition, duration, found := strings.Cut(strings.TrimSpace(value), " for ")
+	if !found {
+		return policy, invalid
+	}
+
+	metric, threshold, found := strings.Cut(strings.ReplaceAll(condition, " ", ""), ">")
+	if !found {
+		return policy, invalid
+	}
+
+	unit := "ms"
+	if metric == "loss" {
+		unit = "%"
+	} else if metric != "latency" && metric != "jitter" {
+		return policy, invalid
+	}
+
+	var err error
+	policy.Metric = metric
+	policy.Threshold, err = strconv.ParseFloat(strings.TrimSuffix(threshold, unit), 64)
+	if err != nil || policy.Threshold <= 0 {
+		return policy, invalid
+	}
+
+	policy.For, err = time.ParseDuration(strings.TrimSpace(duration))
+	if err != nil || policy.For <= 0 {
+		return policy, invalid
+	}
+
+	return policy, nil
+}
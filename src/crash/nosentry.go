@@ -0,0 +1,44 @@
+//go:build notelemetry
+
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// Telemetry is false as Forest CLI is built with the notelemetry build tag that compiles out Sentry.
+const Telemetry = false
+
+// Init is a function that does nothing, as Sentry is compiled out.
+func Init(dsn string, mode string) error {
+	return nil
+}
+
+// CaptureException is a function that does nothing, as Sentry is compiled out.
+func CaptureException(err error) {}
+
+// Flush is a function that does nothing, as Sentry is compiled out.
+func Flush(timeout time.Duration) {}
+
+// Recover is a function to be deferred by the CLI actions to convert a panic into an error pointed by err.
+// The scrubbed stack trace is saved to Dir, so the user could attach it to a support request.
+func Recover(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	report := Scrub(fmt.Sprintf("panic: %v\n\n%s", r, debug.Stack()))
+	path := filepath.Join(Dir, time.Now().Format("20060102150405")+".txt")
+
+	if os.MkdirAll(Dir, 0700) != nil || os.WriteFile(path, []byte(report), 0600) != nil {
+		*err = fmt.Errorf("unexpected error: %v", r)
+		return
+	}
+
+	*err = fmt.Errorf("unexpected error: %v\nCrash report is saved to %s, please attach it when contacting support", r, path)
+}
@@ -0,0 +1,129 @@
+//go:build !notelemetry
+
+package crash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/google/uuid"
+)
+
+// Telemetry is true unless Forest CLI is built with the notelemetry build tag that compiles out Sentry.
+const Telemetry = true
+
+// Init is a function that initializes Sentry with dsn, scrubbing every event and handling it according to the crash reports mode.
+func Init(dsn string, mode string) error {
+	return sentry.Init(sentry.ClientOptions{
+		Dsn:        dsn,
+		BeforeSend: BeforeSend(mode),
+	})
+}
+
+// CaptureException is a function that reports err to Sentry.
+func CaptureException(err error) {
+	sentry.CaptureException(err)
+}
+
+// Flush is a function that waits until the buffered events are sent to Sentry, but no longer than timeout.
+func Flush(timeout time.Duration) {
+	sentry.Flush(timeout)
+}
+
+// ScrubEvent is a function that scrubs every free-form field of the Sentry event.
+func ScrubEvent(event *sentry.Event) *sentry.Event {
+	event.ServerName = ""
+	event.User = sentry.User{}
+	event.Request = nil
+	event.Message = Scrub(event.Message)
+
+	for i := range event.Exception {
+		event.Exception[i].Value = Scrub(event.Exception[i].Value)
+		if st := event.Exception[i].Stacktrace; st != nil {
+			for j := range st.Frames {
+				st.Frames[j].AbsPath = Scrub(st.Frames[j].AbsPath)
+			}
+		}
+	}
+
+	for _, b := range event.Breadcrumbs {
+		b.Message = Scrub(b.Message)
+		b.Data = nil
+	}
+
+	for k, v := range event.Extra {
+		event.Extra[k] = Scrub(fmt.Sprint(v))
+	}
+
+	for k, v := range event.Tags {
+		event.Tags[k] = Scrub(v)
+	}
+
+	return event
+}
+
+// BeforeSend is a function that returns the sentry.ClientOptions.BeforeSend callback for the given crash reports mode.
+func BeforeSend(mode string) func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+	return func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+		event = ScrubEvent(event)
+		switch mode {
+		case Local:
+			_, _ = Save(event)
+			return nil
+		case Off:
+			return nil
+		}
+		return event
+	}
+}
+
+// Save is a function that writes the Sentry event to Dir as JSON and returns the path to the report.
+func Save(event *sentry.Event) (string, error) {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(event, "", "    ")
+	if err != nil {
+		return "", err
+	}
+
+	name := string(event.EventID)
+	if len(name) == 0 {
+		name = event.Timestamp.Format("20060102150405")
+	}
+
+	path := filepath.Join(Dir, name+".json")
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// Recover is a function to be deferred by the CLI actions to convert a panic into an error pointed by err.
+// The crash report is saved to Dir regardless of the crash reports mode, so the user could attach it to a support request.
+func Recover(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	event := sentry.NewEvent()
+	event.EventID = sentry.EventID(strings.ReplaceAll(uuid.New().String(), "-", ""))
+	event.Level = sentry.LevelFatal
+	event.Timestamp = time.Now()
+	event.Message = fmt.Sprint(r)
+	event.Exception = []sentry.Exception{{Type: "panic", Value: fmt.Sprint(r), Stacktrace: sentry.NewStacktrace()}}
+
+	path, saveErr := Save(ScrubEvent(event))
+	sentry.CaptureEvent(event)
+
+	if saveErr != nil {
+		*err = fmt.Errorf("unexpected error: %v", r)
+		return
+	}
+
+	*err = fmt.Errorf("unexpected error: %v\nCrash report is saved to %s, please attach it when contacting support", r, path)
+}
@@ -0,0 +1,28 @@
+//go:build !notelemetry
+
+package crash_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/forestvpn/cli/crash"
+	"github.com/getsentry/sentry-go"
+)
+
+func TestScrubEvent(t *testing.T) {
+	event := &sentry.Event{
+		ServerName: "johns-laptop",
+		User:       sentry.User{Email: "john.doe@example.com", IPAddress: "10.0.0.2"},
+		Exception:  []sentry.Exception{{Value: "no such user john.doe@example.com"}},
+	}
+
+	event = crash.ScrubEvent(event)
+	if len(event.ServerName) > 0 || len(event.User.Email) > 0 || len(event.User.IPAddress) > 0 {
+		t.Errorf("expected server name and user to be removed, got %q and %+v", event.ServerName, event.User)
+	}
+
+	if strings.Contains(event.Exception[0].Value, "@") {
+		t.Errorf("expected email to be scrubbed, got %q", event.Exception[0].Value)
+	}
+}
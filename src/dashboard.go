@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// dashboard is an action of 'fvpn dashboard' that redraws the state of the connection full-screen until interrupted.
+func dashboard(c *cli.Context) error {
+	profile := auth.OpenUserDB().CurrentUser()
+	if len(profile.ID) == 0 {
+		return errors.New("not logged in, log in with 'fvpn account login' first")
+	}
+
+	state := actions.State{WiregaurdInterface: "fvpn0"}
+	board := actions.Dashboard{State: &state, UserID: profile.ID, Target: c.String("target")}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the alternate screen keeps the scrollback of the terminal intact, the cursor is hidden while drawing
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	ticker := time.NewTicker(c.Duration("interval"))
+	defer ticker.Stop()
+
+	for {
+		columns, rows := utils.TerminalSize()
+		if err := board.Update(); err != nil {
+			return err
+		}
+
+		fmt.Print("\033[H\033[2J" + board.Render(columns, rows))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// dashboardServe is an action of 'fvpn dashboard serve' that serves the state of the connection over HTTP until interrupted.
+func dashboardServe(c *cli.Context) error {
+	profile := auth.OpenUserDB().CurrentUser()
+	if len(profile.ID) == 0 {
+		return errors.New("not logged in, log in with 'fvpn account login' first")
+	}
+
+	state := actions.State{WiregaurdInterface: "fvpn0"}
+	server := &actions.DashboardServer{
+		Board:   &actions.Dashboard{State: &state, UserID: profile.ID, Target: c.String("target")},
+		Refresh: c.Duration("interval"),
+	}
+	if err := server.Update(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", c.String("listen"))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Shutdown(context.Background())
+	}()
+
+	go func() {
+		ticker := time.NewTicker(c.Duration("interval"))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := server.Update(); err != nil && utils.Verbose {
+					utils.InfoLogger.Println(err)
+				}
+			}
+		}
+	}()
+
+	fmt.Printf("Serving the dashboard at http://%s\n", listener.Addr())
+	if ip, ok := listener.Addr().(*net.TCPAddr); ok && !ip.IP.IsLoopback() {
+		fmt.Println("Anyone who reaches it sees the location and the traffic of the connection, though nothing can be changed")
+	}
+
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package dns
+
+import (
+	"os"
+	"strings"
+)
+
+// File is a structure of the backend writing the servers to the resolver configuration file at Path directly,
+// for the hosts without resolvconf, systemd-resolved or NetworkManager. The original file, or the symlink, is kept next to it with the .fvpn suffix.
+type File struct {
+	Path string
+}
+
+// Name is a method that returns resolv.conf.
+func (File) Name() string {
+	return "resolv.conf"
+}
+
+// Set is a method to keep the original file and write the servers in its place.
+func (f File) Set(iface string, servers []string) error {
+	// the original of the previous connection is kept, if it wasn't reverted, e.g. after a crash
+	if _, err := os.Lstat(f.backup()); os.IsNotExist(err) {
+		if err := os.Rename(f.Path, f.backup()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var content strings.Builder
+	content.WriteString("# Generated by fvpn for " + iface + ", the original is kept in " + f.backup() + "\n")
+	for _, server := range servers {
+		content.WriteString("nameserver " + server + "\n")
+	}
+
+	_ = os.Remove(f.Path)
+	return os.WriteFile(f.Path, []byte(content.String()), 0644)
+}
+
+// Revert is a method to put the original file back.
+func (f File) Revert(iface string) error {
+	if _, err := os.Lstat(f.backup()); os.IsNotExist(err) {
+		return nil
+	}
+
+	return os.Rename(f.backup(), f.Path)
+}
+
+func (f File) backup() string {
+	return f.Path + ".fvpn"
+}
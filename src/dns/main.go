@@ -0,0 +1,117 @@
+// dns is a package containing the backends setting the DNS servers of the connection on the host, picked with 'fvpn config set dns-backend',
+// so the DNS is configured the way the resolver stack of the host expects rather than always through wg-quick and resolvconf.
+package dns
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// Backends are the names of the backends accepted by Open besides auto.
+var Backends = []string{"wg-quick", "resolvconf", "systemd-resolved", "networkmanager", "resolv.conf", "scutil", "netsh"}
+
+// ResolvConf is the resolver configuration file of the host.
+var ResolvConf = "/etc/resolv.conf"
+
+// Configurator is an interface of the backend setting the DNS servers of the Wireguard interface while connected.
+type Configurator interface {
+	// Name is a method that returns the name of the backend as accepted by Open.
+	Name() string
+	// Set is a method to make the host resolve through the servers of the Wireguard interface.
+	Set(iface string, servers []string) error
+	// Revert is a method to restore the DNS of the host before Set.
+	Revert(iface string) error
+}
+
+// Open is a factory function that returns the Configurator of the backend, or the one detected with Detect for auto.
+func Open(backend string) (Configurator, error) {
+	switch backend {
+	case "auto":
+		return Detect(), nil
+	case "wg-quick":
+		return WgQuick{}, nil
+	case "resolvconf":
+		return Resolvconf{}, nil
+	case "systemd-resolved":
+		return Resolved{}, nil
+	case "networkmanager":
+		return NetworkManager{}, nil
+	case "resolv.conf":
+		return File{Path: ResolvConf}, nil
+	case "scutil":
+		return Scutil{}, nil
+	case "netsh":
+		return Netsh{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported DNS backend: %s, must be auto, %s", backend, strings.Join(Backends, ", "))
+}
+
+// Detect is a function that picks the backend matching the resolver stack of the host: systemd-resolved if it owns resolv.conf,
+// resolvconf if installed, NetworkManager if it owns resolv.conf, or resolv.conf itself on Linux, and scutil on macOS.
+// The DNS is left to wg-quick elsewhere, e.g. to the tunnel service on Windows, and where fvpn can't change it itself,
+// i.e. without root, when only wg-quick is elevated with polkit.
+func Detect() Configurator {
+	if (utils.Os != "linux" && utils.Os != "darwin") || utils.IsOpenWRT() || utils.IsTermux() || !utils.IsAdmin() {
+		return WgQuick{}
+	}
+
+	if utils.Os == "darwin" {
+		return Scutil{}
+	}
+
+	// the stub resolver of systemd-resolved is linked as /run/systemd/resolve/stub-resolv.conf
+	if target, err := os.Readlink(ResolvConf); err == nil && strings.Contains(target, "systemd/resolve") && installed("resolvectl") {
+		return Resolved{}
+	}
+
+	if installed("resolvconf") {
+		return Resolvconf{}
+	}
+
+	if data, err := os.ReadFile(ResolvConf); err == nil && strings.Contains(string(data), "NetworkManager") && utils.IsNetworkManager() {
+		return NetworkManager{}
+	}
+
+	return File{Path: ResolvConf}
+}
+
+// WgQuick is a structure of the backend leaving the DNS to wg-quick, which sets it from the DNS of the Wireguard configuration.
+type WgQuick struct{}
+
+// Name is a method that returns wg-quick.
+func (WgQuick) Name() string {
+	return "wg-quick"
+}
+
+// Set is a method that does nothing, as wg-quick has set the DNS already.
+func (WgQuick) Set(iface string, servers []string) error {
+	return nil
+}
+
+// Revert is a method that does nothing, as wg-quick reverts the DNS itself.
+func (WgQuick) Revert(iface string) error {
+	return nil
+}
+
+func installed(command string) bool {
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+// families is a function that splits the servers into IPv4 and IPv6 ones.
+func families(servers []string) ([]string, []string) {
+	var v4, v6 []string
+	for _, server := range servers {
+		if strings.Contains(server, ":") {
+			v6 = append(v6, server)
+		} else {
+			v4 = append(v4, server)
+		}
+	}
+	return v4, v6
+}
@@ -0,0 +1,71 @@
+package dns_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/forestvpn/cli/dns"
+)
+
+func TestOpen(t *testing.T) {
+	for _, backend := range dns.Backends {
+		configurator, err := dns.Open(backend)
+		if err != nil {
+			t.Errorf("Open(%q): %s", backend, err)
+		} else if configurator.Name() != backend {
+			t.Errorf("Open(%q) returned %s", backend, configurator.Name())
+		}
+	}
+
+	if _, err := dns.Open("dnsmasq"); err == nil {
+		t.Error("expected an unsupported backend to fail")
+	}
+}
+
+func TestFileRevertsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	stub := filepath.Join(dir, "stub-resolv.conf")
+	if err := os.WriteFile(stub, []byte("nameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "resolv.conf")
+	if err := os.Symlink(stub, path); err != nil {
+		t.Fatal(err)
+	}
+
+	file := dns.File{Path: path}
+	if err := file.Set("fvpn0", []string{"10.0.0.1", "fd00::1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "nameserver 10.0.0.1\nnameserver fd00::1\n") {
+		t.Errorf("unexpected resolv.conf:\n%s", data)
+	}
+	if stubData, _ := os.ReadFile(stub); string(stubData) != "nameserver 127.0.0.53\n" {
+		t.Errorf("expected the target of the symlink to stay intact, got:\n%s", stubData)
+	}
+
+	// setting again, e.g. after a crash, keeps the original
+	if err := file.Set("fvpn0", []string{"10.0.0.2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Revert("fvpn0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if target, err := os.Readlink(path); err != nil || target != stub {
+		t.Errorf("expected the symlink to %s back, got %q, %v", stub, target, err)
+	}
+
+	if err := file.Revert("fvpn0"); err != nil {
+		t.Errorf("expected reverting twice to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package dns
+
+import (
+	"strconv"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// Netsh is a structure of the backend setting the servers of the adapter of the tunnel with netsh on Windows,
+// e.g. where the DNS of the tunnel service is overridden by the policies of the host.
+type Netsh struct{}
+
+// Name is a method that returns netsh.
+func (Netsh) Name() string {
+	return "netsh"
+}
+
+// Set is a method to set the servers of the adapter, the first one of each family replacing the ones it has.
+func (Netsh) Set(iface string, servers []string) error {
+	v4, v6 := families(servers)
+	for family, addresses := range map[string][]string{"ipv4": v4, "ipv6": v6} {
+		for i, address := range addresses {
+			args := []string{"interface", family, "add", "dnsservers", "name=" + iface, "address=" + address, "index=" + strconv.Itoa(i+1), "validate=no"}
+			if i == 0 {
+				args = []string{"interface", family, "set", "dnsservers", "name=" + iface, "source=static", "address=" + address, "register=none", "validate=no"}
+			}
+
+			if err := utils.Run("netsh", args...); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Revert is a method to let the adapter take the servers from DHCP again, in case it outlives the connection.
+func (Netsh) Revert(iface string) error {
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if err := utils.Run("netsh", "interface", family, "set", "dnsservers", "name="+iface, "source=dhcp"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// NetworkManager is a structure of the backend putting the servers ahead of the ones of the device with the default route,
+// as the interface of wg-quick isn't managed by NetworkManager. The change is made to the running device only and undone by reapplying
+// its connection, so the connection profile is never modified.
+type NetworkManager struct{}
+
+// Name is a method that returns networkmanager.
+func (NetworkManager) Name() string {
+	return "networkmanager"
+}
+
+// Set is a method to replace the servers of the device with the default route with the servers of the interface.
+func (NetworkManager) Set(iface string, servers []string) error {
+	device, err := defaultRouteDevice()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"device", "modify", device}
+	v4, v6 := families(servers)
+	if len(v4) > 0 {
+		args = append(args, "ipv4.dns", strings.Join(v4, " "), "ipv4.ignore-auto-dns", "yes", "ipv4.dns-priority", "-50")
+	}
+	if len(v6) > 0 {
+		args = append(args, "ipv6.dns", strings.Join(v6, " "), "ipv6.ignore-auto-dns", "yes", "ipv6.dns-priority", "-50")
+	}
+
+	return utils.Run("nmcli", args...)
+}
+
+// Revert is a method to reapply the connection of the device with the default route, restoring its servers.
+func (NetworkManager) Revert(iface string) error {
+	device, err := defaultRouteDevice()
+	if err != nil {
+		return err
+	}
+
+	return utils.Run("nmcli", "device", "reapply", device)
+}
+
+// defaultRouteDevice is a function that returns the device of the default route of the main table, which the tunnel leaves in place.
+func defaultRouteDevice() (string, error) {
+	stdout, err := utils.Output("ip", "-4", "route", "show", "default", "table", "main")
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(stdout))
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "dev" {
+			return fields[i+1], nil
+		}
+	}
+
+	return "", errors.New("no default route to set the DNS of")
+}
@@ -0,0 +1,47 @@
+package dns
+
+import (
+	"os"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// ResolvconfInterfaceOrder is the file of Debian's resolvconf ordering the records by interface, where the tunnels are expected as tun.*.
+var ResolvconfInterfaceOrder = "/etc/resolvconf/interface-order"
+
+// Resolvconf is a structure of the backend adding the servers as the record of the interface to resolvconf or openresolv,
+// the same way wg-quick does.
+type Resolvconf struct{}
+
+// Name is a method that returns resolvconf.
+func (Resolvconf) Name() string {
+	return "resolvconf"
+}
+
+// Set is a method to add the record of the interface with the servers to resolvconf.
+func (r Resolvconf) Set(iface string, servers []string) error {
+	var record strings.Builder
+	for _, server := range servers {
+		record.WriteString("nameserver " + server + "\n")
+	}
+
+	return utils.RunInput(record.String(), "resolvconf", "-a", r.record(iface), "-m", "0", "-x")
+}
+
+// Revert is a method to delete the record of the interface from resolvconf.
+func (r Resolvconf) Revert(iface string) error {
+	return utils.Run("resolvconf", "-d", r.record(iface), "-f")
+}
+
+// record is a method that returns the name of the record of the interface, prefixed with tun. where Debian's resolvconf expects it.
+func (Resolvconf) record(iface string) string {
+	if data, err := os.ReadFile(ResolvconfInterfaceOrder); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "tun.") {
+				return "tun." + iface
+			}
+		}
+	}
+	return iface
+}
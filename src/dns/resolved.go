@@ -0,0 +1,34 @@
+package dns
+
+import (
+	"github.com/forestvpn/cli/utils"
+)
+
+// Resolved is a structure of the backend setting the servers of the interface with resolvectl, making it the default route of systemd-resolved,
+// so all the queries go through the tunnel rather than only the ones of its domains.
+type Resolved struct{}
+
+// Name is a method that returns systemd-resolved.
+func (Resolved) Name() string {
+	return "systemd-resolved"
+}
+
+// Set is a method to set the servers of the interface and route all the domains to them.
+func (Resolved) Set(iface string, servers []string) error {
+	if err := utils.Run("resolvectl", append([]string{"dns", iface}, servers...)...); err != nil {
+		return err
+	}
+
+	if err := utils.Run("resolvectl", "domain", iface, "~."); err != nil {
+		return err
+	}
+
+	// systemd before 246 has no default-route, but routes ~. to the interface anyway
+	_ = utils.Run("resolvectl", "default-route", iface, "true")
+	return nil
+}
+
+// Revert is a method to drop the servers and domains of the interface.
+func (Resolved) Revert(iface string) error {
+	return utils.Run("resolvectl", "revert", iface)
+}
@@ -0,0 +1,37 @@
+package dns
+
+import (
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+)
+
+// Scutil is a structure of the backend publishing the servers to the dynamic store of macOS with scutil as a resolver matching every domain,
+// which is how the VPN clients of the system take over the DNS, instead of changing the servers of the network services with networksetup.
+type Scutil struct{}
+
+// Name is a method that returns scutil.
+func (Scutil) Name() string {
+	return "scutil"
+}
+
+// Set is a method to publish the servers to the dynamic store.
+func (s Scutil) Set(iface string, servers []string) error {
+	script := "d.init\n" +
+		"d.add ServerAddresses * " + strings.Join(servers, " ") + "\n" +
+		"d.add SupplementalMatchDomains * \"\"\n" +
+		"d.add SupplementalMatchOrder # 1\n" +
+		"set " + s.key(iface) + "\n" +
+		"quit\n"
+
+	return utils.RunInput(script, "scutil")
+}
+
+// Revert is a method to remove the servers from the dynamic store.
+func (s Scutil) Revert(iface string) error {
+	return utils.RunInput("remove "+s.key(iface)+"\nquit\n", "scutil")
+}
+
+func (Scutil) key(iface string) string {
+	return "State:/Network/Service/fvpn-" + iface + "/DNS"
+}
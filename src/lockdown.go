@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/forestvpn/cli/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// lockdown is set to on during the build with ldflags to produce a binary for kiosk or lab machines, e.g. -X main.lockdown=on.
+var lockdown string
+
+// lockdownFile is a file the administrators create to lock down the installed binary, e.g. 'sudo touch /etc/forestvpn/lockdown'.
+// It's outside the home directory, so the users can't remove it.
+var lockdownFile = "/etc/forestvpn/lockdown"
+
+// lockdownAllowed are the commands available to the users of the locked down machine.
+var lockdownAllowed = map[string]bool{
+	"status":          true,
+	"state up":        true,
+	"state down":      true,
+	"state status":    true,
+	"location status": true,
+	"location ls":     true,
+	"account status":  true,
+	"version":         true,
+}
+
+// lockdownFlags are the flags of lockdownAllowed commands left to the administrators, as they change the location of the account.
+var lockdownFlags = map[string][]string{
+	"state up": {"location", "failover", "failover-after"},
+}
+
+// lockdownGlobalFlags are the global flags left to the administrators, as they point fvpn to another API or daemon, or fake the connection,
+// bypassing the policy of the machine.
+var lockdownGlobalFlags = []string{"host", "api-url", "fake-backend"}
+
+// isAdmin is a function to check whether the user is the administrator, see utils.IsAdmin.
+var isAdmin = utils.IsAdmin
+
+func init() {
+	if utils.Os == "windows" {
+		lockdownFile = filepath.Join(os.Getenv("ProgramData"), "ForestVPN", "lockdown")
+	}
+}
+
+// lockedDown is a function to check whether the machine is locked down with the build flag or the lockdownFile.
+func lockedDown() bool {
+	if lockdown == "on" {
+		return true
+	}
+
+	_, err := os.Stat(lockdownFile)
+	return err == nil
+}
+
+// lockdownActions is a function that makes the commands other than lockdownAllowed, and the lockdownFlags of the allowed ones,
+// fail for the users other than the administrators while the machine is locked down.
+func lockdownActions(commands []*cli.Command, parent string) {
+	for _, command := range commands {
+		name := strings.TrimSpace(parent + " " + command.Name)
+		if action := command.Action; action != nil && !lockdownAllowed[name] {
+			command.Action = func(c *cli.Context) error {
+				if lockedDown() && !isAdmin() {
+					return fmt.Errorf("disabled on this machine by the administrator: fvpn %s", name)
+				}
+				return action(c)
+			}
+		} else if flags := lockdownFlags[name]; action != nil && len(flags) > 0 {
+			command.Action = func(c *cli.Context) error {
+				for _, flag := range flags {
+					if c.IsSet(flag) && lockedDown() && !isAdmin() {
+						return fmt.Errorf("disabled on this machine by the administrator: fvpn %s --%s", name, flag)
+					}
+				}
+				return action(c)
+			}
+		}
+		lockdownActions(command.Subcommands, name)
+	}
+}
+
+// lockdownGlobal is a function that fails for the users other than the administrators if any of lockdownGlobalFlags is set,
+// with the flag or its environment variable, while the machine is locked down.
+func lockdownGlobal(c *cli.Context) error {
+	for _, flag := range lockdownGlobalFlags {
+		if c.IsSet(flag) && lockedDown() && !isAdmin() {
+			return fmt.Errorf("disabled on this machine by the administrator: fvpn --%s", flag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestLockdownGlobal(t *testing.T) {
+	file, admin := lockdownFile, isAdmin
+	t.Cleanup(func() { lockdownFile, isAdmin = file, admin })
+
+	lockdownFile = filepath.Join(t.TempDir(), "lockdown")
+	if err := os.WriteFile(lockdownFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) error {
+		app := &cli.App{
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "host", EnvVars: []string{"FVPN_TEST_HOST"}},
+				&cli.StringFlag{Name: "api-url"},
+				&cli.BoolFlag{Name: "fake-backend"},
+				&cli.BoolFlag{Name: "verbose"},
+			},
+			Before: lockdownGlobal,
+			Action: func(c *cli.Context) error { return nil },
+		}
+		return app.Run(append([]string{"fvpn"}, args...))
+	}
+
+	isAdmin = func() bool { return false }
+	for _, args := range [][]string{
+		{"--host", "tls://router.lan:9999"},
+		{"--api-url", "http://127.0.0.1:8080"},
+		{"--fake-backend"},
+	} {
+		if err := run(args...); err == nil || !strings.Contains(err.Error(), "disabled on this machine") {
+			t.Errorf("%v: expected the flag to be disabled, got %v", args, err)
+		}
+	}
+
+	t.Setenv("FVPN_TEST_HOST", "tls://router.lan:9999")
+	if err := run(); err == nil {
+		t.Error("expected the flag set with the environment variable to be disabled")
+	}
+	os.Unsetenv("FVPN_TEST_HOST")
+
+	if err := run("--verbose"); err != nil {
+		t.Errorf("expected the other flags to be allowed, got %v", err)
+	}
+
+	isAdmin = func() bool { return true }
+	if err := run("--api-url", "http://127.0.0.1:8080"); err != nil {
+		t.Errorf("expected the administrator to be allowed, got %v", err)
+	}
+
+	isAdmin = func() bool { return false }
+	if err := os.Remove(lockdownFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("--fake-backend"); err != nil && lockdown != "on" {
+		t.Errorf("expected the flags to be allowed unless locked down, got %v", err)
+	}
+}
@@ -1,23 +1,40 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
+	forestvpn_api "github.com/forestvpn/api-client-go"
 	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/api"
 	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/crash"
+	"github.com/forestvpn/cli/mock"
+	"github.com/forestvpn/cli/pkg/forestvpn"
+	"github.com/forestvpn/cli/schema"
+	"github.com/forestvpn/cli/secrets"
+	"github.com/forestvpn/cli/server"
 	"github.com/forestvpn/cli/timezone"
 	"github.com/forestvpn/cli/utils"
-	"github.com/google/uuid"
+	"github.com/olekukonko/tablewriter"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
-	"github.com/getsentry/sentry-go"
 	"github.com/urfave/cli/v2"
 )
 
@@ -37,29 +54,44 @@ func main() {
 	// country is stores prompted country name to filter locations by country.
 	var country string
 
+	// installed as the helper, fvpn only makes the changes it's given by the unprivileged fvpn, see utils.Helper
+	if filepath.Base(os.Args[0]) == utils.HelperName {
+		if err := utils.Helper(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	utils.AppVersion = appVersion
 	err := auth.Init()
 
 	if err != nil {
-		sentry.CaptureException(err)
+		crash.CaptureException(err)
 		log.Fatal(err)
 		os.Exit(1)
 	}
 
-	err = sentry.Init(sentry.ClientOptions{
-		Dsn: Dsn,
-	})
+	conf, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = crash.Init(Dsn, conf.Get(config.CrashReports))
 
 	if err != nil {
 		log.Fatalf("sentry.Init: %s", err)
 		os.Exit(1)
 	}
 
-	defer sentry.Flush(2 * time.Second)
+	defer crash.Flush(2 * time.Second)
 
 	cli.VersionPrinter = func(cCtx *cli.Context) {
 		fmt.Println(cCtx.App.Version)
 	}
 
+	// started is the time the command started at for --timings
+	started := time.Now()
 	app := &cli.App{
 		Version:              appVersion,
 		EnableBashCompletion: true,
@@ -74,6 +106,91 @@ func main() {
 				Value:       false,
 				Destination: &utils.Verbose,
 			},
+			&cli.StringFlag{
+				Name:    "host",
+				Usage:   "control the daemon at `ADDRESS`, e.g. tls://router.lan:9999, instead of this machine",
+				EnvVars: []string{"FVPN_HOST"},
+			},
+			&cli.StringFlag{
+				Name:    "daemon-token",
+				Usage:   "bearer `TOKEN` to authenticate to the daemon set with --host",
+				EnvVars: []string{"FVPN_DAEMON_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:  "record",
+				Usage: "record the API requests and responses of the command into the HAR `FILE` with the secrets redacted, e.g. for a bug report",
+			},
+			&cli.StringFlag{
+				Name:    "api-url",
+				Usage:   "send the requests to the ForestVPN API at `URL`, e.g. http://127.0.0.1:8080 for 'fvpn dev mock-server'",
+				EnvVars: []string{"FVPN_API_URL"},
+			},
+			&cli.BoolFlag{
+				Name:        "fake-backend",
+				Usage:       "only record the connection to the local state files without any networking, e.g. to test scripts in CI without privileges",
+				EnvVars:     []string{"FVPN_FAKE"},
+				Destination: &utils.Fake,
+			},
+			&cli.BoolFlag{
+				Name:        "non-interactive",
+				Usage:       "never prompt, e.g. in scripts run from a terminal; the destructive commands then need --yes",
+				EnvVars:     []string{"FVPN_NON_INTERACTIVE"},
+				Destination: &utils.NonInteractive,
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "print the output of the commands as `FORMAT`, text or json, e.g. for scripts parsing it, where the command supports it",
+				Value:   "text",
+				EnvVars: []string{"FVPN_OUTPUT"},
+			},
+			&cli.BoolFlag{
+				Name:        "timings",
+				Usage:       "print the time spent in the auth refresh, each API call and system command after the command to stderr",
+				Destination: &utils.Timings,
+			},
+		},
+		After: func(c *cli.Context) error {
+			if utils.Timings {
+				utils.PrintTimings(os.Stderr, time.Since(started))
+			}
+			return nil
+		},
+		Before: func(c *cli.Context) error {
+			if err := lockdownGlobal(c); err != nil {
+				return err
+			}
+
+			switch c.String("output") {
+			case "json":
+				utils.JSONOutput = true
+			case "text":
+			default:
+				return fmt.Errorf("unknown output format %s, text or json expected", c.String("output"))
+			}
+
+			if path := c.String("record"); len(path) > 0 {
+				api.Recorder = utils.NewHARRecorder(path)
+			}
+
+			// the broken backend could still be changed
+			if c.Args().First() != "config" {
+				if err := useSecretBackend(); err != nil {
+					return err
+				}
+			}
+
+			// the commands run in the background, e.g. 'fvpn state expire', fake the connection as well
+			if utils.Fake {
+				if err := os.Setenv("FVPN_FAKE", "1"); err != nil {
+					return err
+				}
+			}
+
+			if apiURL := c.String("api-url"); len(apiURL) > 0 {
+				return utils.SetApiURL(apiURL)
+			}
+			return nil
 		},
 		Commands: []*cli.Command{
 			{
@@ -90,6 +207,20 @@ func main() {
 					{
 						Name:  "status",
 						Usage: "see logged-in account info",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "utc",
+								Usage: "print the expiry date in UTC instead of the local timezone",
+							},
+							&cli.BoolFlag{
+								Name:  "rfc3339",
+								Usage: "print the expiry date in RFC 3339 format, e.g. for scripts",
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "print plan, expiry date, verification state and number of devices as JSON",
+							},
+						},
 						Action: func(c *cli.Context) error {
 							profile := auth.OpenUserDB().CurrentUser()
 							if err = profile.SignIn(utils.ApiHost); err != nil {
@@ -101,6 +232,21 @@ func main() {
 								return err
 							}
 
+							if jsonOutput(c) {
+								status, err := authClientWrapper.GetAccountStatus(profile)
+								if err != nil {
+									return err
+								}
+
+								if err := printJSON(status); err != nil {
+									return err
+								}
+								if status.Expired {
+									return cli.Exit("", actions.ExitSubscriptionExpired)
+								}
+								return nil
+							}
+
 							b, err := authClientWrapper.GetUnexpiredOrMostRecentBillingFeature(profile.ID)
 							if err != nil {
 								return err
@@ -113,20 +259,34 @@ func main() {
 							plan := caser.String(strings.Split(b.GetBundleId(), ".")[2])
 							fmt.Printf("Logged-in as %s\n", profile.Email)
 							fmt.Printf("Plan: %s\n", plan)
-							tz, err := utils.GetLocalTimezone()
 
-							if err != nil {
-								sentry.CaptureException(err)
-								_, offset := now.Zone()
+							var at string
+							switch {
+							case c.Bool("rfc3339") && c.Bool("utc"):
+								at = expiryDate.UTC().Format(time.RFC3339)
+							case c.Bool("rfc3339"):
+								at = expiryDate.Local().Format(time.RFC3339)
+							case c.Bool("utc"):
+								at = expiryDate.UTC().Format("2006-01-02 15:04:05") + " UTC"
+							default:
+								tz, err := utils.GetLocalTimezone()
+
+								if err != nil {
+									crash.CaptureException(err)
+									_, offset := now.Zone()
 
-								tz = timezone.GetGmtTimezone(offset)
+									tz = timezone.GetGmtTimezone(offset)
+								}
+
+								at = utils.FormatTime(expiryDate) + " " + tz
 							}
 
 							if now.After(expiryDate) {
 								t := now.Sub(expiryDate)
-								fmt.Printf("Status: expired %s ago at %s %s\n", utils.HumanizeDuration(t), expiryDate.Format("2006-01-02 15:04:05"), tz)
+								fmt.Printf("Status: expired %s ago at %s\n", utils.HumanizeDuration(t), at)
+								return cli.Exit("", actions.ExitSubscriptionExpired)
 							} else {
-								fmt.Printf("Status: expires in %s at %s %s\n", utils.HumanizeDuration(left), expiryDate.Format("2006-01-02 15:04:05"), tz)
+								fmt.Printf("Status: expires in %s at %s\n", utils.HumanizeDuration(left), at)
 
 							}
 
@@ -144,26 +304,153 @@ func main() {
 								Value:       "",
 								Aliases:     []string{"e"},
 							},
+							&cli.BoolFlag{
+								Name:  "replace-oldest",
+								Usage: "revoke the least recently active device if the account has reached its limit of devices",
+							},
+							&cli.StringFlag{
+								Name:    "token",
+								Usage:   "log in with the machine `TOKEN` issued from the web dashboard instead of the browser, e.g. on servers and CI runners",
+								EnvVars: []string{"FVPN_MACHINE_TOKEN"},
+							},
+							&cli.StringFlag{
+								Name:      "token-file",
+								Usage:     "log in with the token read from `FILE` on every request, e.g. a secret mounted into a container and rotated by the orchestrator",
+								EnvVars:   []string{"FVPN_TOKEN_FILE"},
+								TakesFile: true,
+							},
+							&cli.StringFlag{
+								Name:    "sso",
+								Usage:   "sign in with the single sign-on of the organization by its `SLUG`, SAML or OIDC, e.g. when it disables the password sign-in",
+								EnvVars: []string{"FVPN_SSO_ORG"},
+							},
+							&cli.StringFlag{
+								Name:  "provider",
+								Usage: "sign in with the ForestVPN website in the browser (browser) or the identity provider of the organization (oidc), the auth-provider setting if not set",
+							},
+							&cli.BoolFlag{
+								Name:  "no-account-defaults",
+								Usage: "keep this machine's settings instead of applying the preferred location of the account set in the mobile app",
+							},
 						},
 						Action: func(c *cli.Context) error {
+							onDeviceLimit := actions.PromptDeviceToRevoke
+							if c.Bool("replace-oldest") {
+								onDeviceLimit = actions.ReplaceOldestDevice
+							}
+
+							conf, err := config.Load()
+							if err != nil {
+								return err
+							}
+
+							name, credential := c.String("provider"), ""
+							if len(name) == 0 {
+								name = conf.Get(config.AuthProvider)
+							}
+							if token := strings.TrimSpace(c.String("token")); len(token) > 0 {
+								name, credential = auth.ProviderToken, token
+							} else if path := c.String("token-file"); len(path) > 0 {
+								name, credential = auth.ProviderTokenFile, path
+							} else if org := strings.TrimSpace(c.String("sso")); len(org) > 0 {
+								name, credential = auth.ProviderSSO, org
+							}
+
+							provider, err := auth.GetProvider(name)
+							if err != nil {
+								return err
+							}
+							auth.OIDC.Issuer, auth.OIDC.ClientID = conf.Get(config.OIDCIssuer), conf.Get(config.OIDCClientID)
+
+							withToken := name == auth.ProviderToken || name == auth.ProviderTokenFile
+							if err = actions.WaitLoginBackoff(withToken); err != nil {
+								return err
+							}
+
 							profile := auth.OpenUserDB().CreateUser()
-							if err = profile.SignIn(utils.ApiHost); err != nil {
+							if err = profile.LoginWith(provider, credential); err == nil {
+								err = profile.SignInWith(utils.ApiHost, onDeviceLimit)
+							}
+							actions.RecordLoginResult(err)
+							if err != nil {
+								profile.Logout()
+								// not to be taken for the expiry of the session logged in before
+								if api.IsSessionExpired(err) && withToken {
+									return cli.Exit("Sign-in rejected, check the token or issue a new one", 1)
+								} else if api.IsSessionExpired(err) && name == auth.ProviderOIDC {
+									return cli.Exit("Sign-in rejected, check that the organization is set up for the sign-in with oidc-issuer", 1)
+								} else if api.IsSessionExpired(err) && name == auth.ProviderSSO {
+									return cli.Exit("Sign-in rejected, check that your account belongs to the organization", 1)
+								} else if api.IsSessionExpired(err) {
+									return cli.Exit("Sign-in rejected, check the credentials and try again", 1)
+								}
 								return err
 							}
 
-							if err == nil {
-								fmt.Println("Logged in")
+							fmt.Println("Logged in")
+							if c.Bool("no-account-defaults") {
+								return nil
 							}
 
-							return err
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
+							// the login itself succeeded, so the defaults failing to apply is only reported
+							notes, err := authClientWrapper.ApplyAccountDefaults(profile.ID)
+							for _, note := range notes {
+								fmt.Println(note)
+							}
+							if err != nil {
+								fmt.Printf("Failed to apply the defaults of the account: %s\n", err)
+							}
+							return nil
+						},
+					},
+					{
+						Name:         "use",
+						Usage:        "switch to another local account",
+						ArgsUsage:    "EMAIL",
+						BashComplete: completeAccounts,
+						Action: func(c *cli.Context) error {
+							arg := c.Args().Get(0)
+
+							if len(arg) < 1 {
+								return errors.New("EMAIL required")
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							if state.GetStatus() {
+								fmt.Println("Please, set down the connection before switching the account.")
+								fmt.Println("Try 'fvpn state down'")
+								return nil
+							}
+
+							profile, found := auth.OpenUserDB().FindUser(arg)
+							if !found {
+								return fmt.Errorf("no such account: %s", arg)
+							}
+
+							profile.Touch()
+							fmt.Printf("Switched to %s\n", profile.Email)
+							return nil
 						},
 					},
 					{
 						Name:  "logout",
 						Usage: "unlink this device from your ForstVPN account",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "log out without confirmation",
+							},
+						},
 						Action: func(c *cli.Context) error {
 							profile := auth.OpenUserDB().CurrentUser()
-							if err = profile.SignIn(utils.ApiHost); err != nil {
+							// the profile with the expired session is logged out all the same
+							if err = profile.SignIn(utils.ApiHost); err != nil && !api.IsSessionExpired(err) {
 								return err
 							}
 
@@ -175,329 +462,2522 @@ func main() {
 								return nil
 							}
 
+							if err := utils.Confirm(fmt.Sprintf("Log out of %s on this device?", profile.Email), c.Bool("yes")); err != nil {
+								return err
+							}
+
+							profile.Logout()
 							profile.MarkAsInactive()
 							fmt.Println("Logged out")
 							return nil
 						},
 					},
+					{
+						Name:  "sessions",
+						Usage: "manage the sessions of the account across the machines",
+						Subcommands: []*cli.Command{
+							{
+								Name:  "ls",
+								Usage: "see the active sessions of the account",
+								Action: func(c *cli.Context) error {
+									profile := auth.OpenUserDB().CurrentUser()
+									if err = profile.SignIn(utils.ApiHost); err != nil {
+										return err
+									}
+
+									authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+									if err != nil {
+										return err
+									}
+
+									return authClientWrapper.ListSessions()
+								},
+							},
+							{
+								Name:      "revoke",
+								Usage:     "revoke a session of another machine, so it has to log in again, e.g. after the machine is lost",
+								ArgsUsage: "ID",
+								Flags: []cli.Flag{
+									&cli.BoolFlag{
+										Name:  "others",
+										Usage: "revoke all the sessions but the one of this machine",
+									},
+									&cli.BoolFlag{
+										Name:    "yes",
+										Aliases: []string{"y"},
+										Usage:   "revoke without confirmation",
+									},
+								},
+								Action: func(c *cli.Context) error {
+									id := c.Args().First()
+									if len(id) == 0 && !c.Bool("others") {
+										return errors.New("ID of the session or --others is required")
+									}
+
+									profile := auth.OpenUserDB().CurrentUser()
+									if err = profile.SignIn(utils.ApiHost); err != nil {
+										return err
+									}
+
+									authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+									if err != nil {
+										return err
+									}
+
+									sessions, err := authClientWrapper.FindSessions(id, c.Bool("others"))
+									if err != nil {
+										return err
+									}
+
+									if len(sessions) == 0 {
+										fmt.Println("No other sessions")
+										return nil
+									}
+
+									question := fmt.Sprintf("Revoke the session of %s? It has to log in again.", sessions[0].Name)
+									if c.Bool("others") {
+										question = fmt.Sprintf("Revoke %d sessions of other machines? They have to log in again.", len(sessions))
+									}
+									if err := utils.Confirm(question, c.Bool("yes")); err != nil {
+										return err
+									}
+
+									if err := authClientWrapper.RevokeSessions(sessions); err != nil {
+										return err
+									}
+
+									if len(sessions) == 1 {
+										fmt.Printf("Revoked the session of %s\n", sessions[0].Name)
+									} else {
+										fmt.Printf("Revoked %d sessions\n", len(sessions))
+									}
+									return nil
+								},
+							},
+						},
+					},
 				},
 			},
 			{
-				Name:  "state",
-				Usage: "control the state of the ForestVPN connection",
+				Name:  "enroll",
+				Usage: "provision this machine against a team account with the enrollment token, without the browser",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "token",
+						Usage:    "enrollment `TOKEN` of the team account",
+						EnvVars:  []string{"FVPN_ENROLL_TOKEN"},
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "label",
+						Usage: "name of the device shown to the admins, the hostname if not set",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					profile := auth.OpenUserDB().CreateUser()
+					if err = profile.LoginWith(auth.TokenProvider{}, strings.TrimSpace(c.String("token"))); err != nil {
+						return err
+					}
+
+					// revoking another machine of the fleet is up to the admins
+					if err = profile.SignInWith(utils.ApiHost, nil); err != nil {
+						profile.Logout()
+						return err
+					}
+
+					if label := strings.TrimSpace(c.String("label")); len(label) > 0 {
+						authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+						if err != nil {
+							return err
+						}
+
+						if err := authClientWrapper.RenameDevice(profile.ID, label); err != nil {
+							return err
+						}
+					}
+
+					device, err := auth.LoadDevice(profile.ID)
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Enrolled %s as %s\n", device.GetName(), device.GetId())
+					return nil
+				},
+			},
+			{
+				Name:  "org",
+				Usage: "manage the organization of the logged-in account as its admin",
 				Subcommands: []*cli.Command{
 					{
+						Name:  "devices",
+						Usage: "manage the devices of the members of the organization",
+						Subcommands: []*cli.Command{
+							{
+								Name:  "ls",
+								Usage: "see the devices of all the members, e.g. the machines enrolled with 'fvpn enroll'",
+								Action: func(c *cli.Context) error {
+									profile := auth.OpenUserDB().CurrentUser()
+									if err = profile.SignIn(utils.ApiHost); err != nil {
+										return err
+									}
 
-						Name:  "up",
-						Usage: "connect to the ForestVPN location",
-						Flags: []cli.Flag{
-							&cli.BoolFlag{
-								Name:    "persist",
-								Usage:   "Persist VPN connnection through reboots",
-								Value:   false,
-								Aliases: []string{"p"},
+									authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+									if err != nil {
+										return err
+									}
+
+									return authClientWrapper.ListOrgDevices()
+								},
 							},
 						},
+					},
+				},
+			},
+			{
+				Name:  "device",
+				Usage: "manage the devices of the logged-in account",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "ls",
+						Usage: "see the devices of the account",
 						Action: func(c *cli.Context) error {
 							profile := auth.OpenUserDB().CurrentUser()
 							if err = profile.SignIn(utils.ApiHost); err != nil {
 								return err
 							}
-							state := actions.State{WiregaurdInterface: "fvpn0"}
-							if state.GetStatus() {
-								fmt.Println("State is already up and running")
-								os.Exit(1)
-							}
 
-							client, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
 							if err != nil {
 								return err
 							}
 
-							b, err := client.GetUnexpiredOrMostRecentBillingFeature(profile.ID)
-							if err != nil {
-								return err
+							return authClientWrapper.ListDevices(profile.ID)
+						},
+					},
+					{
+						Name:      "rename",
+						Usage:     "rename this device",
+						ArgsUsage: "NAME",
+						Action: func(c *cli.Context) error {
+							name := strings.TrimSpace(c.Args().First())
+							if len(name) == 0 {
+								return errors.New("device name is required")
 							}
 
-							device, err := auth.LoadDevice(profile.ID)
-							if err != nil {
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
 								return err
 							}
 
-							bid := b.GetBundleId()
-							location := device.GetLocation()
-							now := time.Now()
-							exp := b.GetExpiryDate()
-							left := exp.Sub(now)
-							days := int64(left.Hours() / 24)
-
-							if now.After(exp) {
-								if actions.IsPremiumLocation(location) && bid == "com.forestvpn.premium" {
-									fmt.Println("The location you were using is now unavailable, as your paid subscription has ended.")
-									fmt.Printf("You can keep using ForestVPN once you watch an ad in our mobile app, or simply go Premium at %s.\n", url)
-									os.Exit(1)
-								} else {
-									fmt.Println("Your 30-minute session is over.")
-									fmt.Printf("You can keep using ForestVPN once you watch an ad in our mobile app, or simply go Premium at %s.\n", url)
-									os.Exit(1)
-								}
-							} else if bid == "com.forestvpn.freemium" && int(left.Minutes()) < 5 {
-								fmt.Println("You currently have less than 5 minutes of free trial left.")
-							} else if days == 3 && left.Hours() == 0 || days < 3 && bid == "com.forestvpn.premium" {
-								fmt.Println("Your premium subscription will end in less than 3 days.")
-							}
-
-							persist := c.Bool("persist")
-							err = state.SetUp(profile.ID, persist)
-
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
 							if err != nil {
 								return err
 							}
 
-							time.Sleep(1 * time.Second)
-
-							if state.GetStatus() {
-								country := location.GetCountry()
-								fmt.Printf("Connected to %s, %s\n", location.GetName(), country.GetName())
-							} else {
-								return errors.New("unexpected error: state.status is false after state is up")
+							if err := authClientWrapper.RenameDevice(profile.ID, name); err != nil {
+								return err
 							}
 
+							fmt.Printf("Renamed to %s\n", name)
 							return nil
 						},
 					},
 					{
-						Name:        "down",
-						Description: "disconnect from the ForestVPN location",
-						Action: func(ctx *cli.Context) error {
+						Name:      "revoke",
+						Usage:     "revoke another device of the account, e.g. after the machine is lost",
+						ArgsUsage: "UUID|NAME",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "revoke without confirmation",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							arg := c.Args().First()
+							if len(arg) == 0 {
+								return errors.New("UUID or NAME of the device is required")
+							}
+
 							profile := auth.OpenUserDB().CurrentUser()
 							if err = profile.SignIn(utils.ApiHost); err != nil {
 								return err
 							}
 
-							state := actions.State{WiregaurdInterface: "fvpn0"}
-
-							if state.GetStatus() {
-								err = state.SetDown(profile.ID)
-
-								if err != nil {
-									return err
-								}
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
 
-								if runtime.GOOS == "windows" {
-									time.Sleep(1 * time.Second)
-								}
+							device, err := authClientWrapper.FindDevice(profile.ID, arg)
+							if err != nil {
+								return err
+							}
 
-								if state.GetStatus() {
-									return errors.New("unexpected error: state.status is true after state is down")
-								}
+							if err := utils.Confirm(fmt.Sprintf("Revoke %s? It loses access to the VPN.", device.GetName()), c.Bool("yes")); err != nil {
+								return err
+							}
 
-								fmt.Println("Disconnected")
-							} else {
-								fmt.Println("State is already down")
-								os.Exit(1)
+							if err := authClientWrapper.RevokeDevice(device); err != nil {
+								return err
 							}
 
+							fmt.Printf("Revoked %s\n", device.GetName())
 							return nil
 						},
 					},
 					{
-						Name:  "status",
-						Usage: "see wether connection is active",
-						Action: func(ctx *cli.Context) error {
+						Name:  "prune",
+						Usage: "remove the devices of the account not active recently",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "inactive-for",
+								Usage: "remove the devices not active for the `DURATION`, e.g. 90d; the ones never active are removed as well",
+								Value: "90d",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "remove the devices without confirmation",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							inactiveFor, err := utils.ParseDays(c.String("inactive-for"))
+							if err != nil {
+								return fmt.Errorf("invalid --inactive-for: %s", err)
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
+							devices, err := authClientWrapper.InactiveDevices(profile.ID, inactiveFor)
+							if err != nil {
+								return err
+							}
+
+							if len(devices) == 0 {
+								fmt.Printf("No devices inactive for %s\n", c.String("inactive-for"))
+								return nil
+							}
+
+							actions.PrintDevices(devices)
+							if err := utils.Confirm(fmt.Sprintf("Remove these %d devices?", len(devices)), c.Bool("yes")); err != nil {
+								return err
+							}
+
+							removed, err := authClientWrapper.PruneDevices(devices)
+							fmt.Printf("Removed %d devices\n", removed)
+							return err
+						},
+					},
+				},
+			},
+			{
+				Name:  "block",
+				Usage: "block domains in the hosts file while connected",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "add",
+						Usage:     "block the domains",
+						ArgsUsage: "DOMAIN...",
+						Action: func(c *cli.Context) error {
+							if c.NArg() == 0 {
+								return errors.New("DOMAIN required")
+							}
+
+							added, err := actions.BlockDomains(c.Args().Slice())
+							if err != nil {
+								return err
+							}
+
+							fmt.Printf("Blocked %d domains\n", added)
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							return actions.ApplyBlocklist(state.GetStatus())
+						},
+					},
+					{
+						Name:      "rm",
+						Usage:     "unblock the domains",
+						ArgsUsage: "DOMAIN...",
+						Action: func(c *cli.Context) error {
+							if c.NArg() == 0 {
+								return errors.New("DOMAIN required")
+							}
+
+							if err := actions.UnblockDomains(c.Args().Slice()); err != nil {
+								return err
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							return actions.ApplyBlocklist(state.GetStatus())
+						},
+					},
+					{
+						Name:  "ls",
+						Usage: "see the blocked domains",
+						Action: func(c *cli.Context) error {
+							domains, err := actions.LoadBlocklist()
+							if err != nil {
+								return err
+							}
+
+							for _, domain := range domains {
+								fmt.Println(domain)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "block the domains of a hosts-file formatted list, e.g. 0.0.0.0 ads.example.com",
+						ArgsUsage: "FILE",
+						Action: func(c *cli.Context) error {
+							file, err := os.Open(c.Args().First())
+							if err != nil {
+								return err
+							}
+							defer file.Close()
+
+							domains, err := utils.ParseHosts(file)
+							if err != nil {
+								return err
+							}
+
+							added, err := actions.BlockDomains(domains)
+							if err != nil {
+								return err
+							}
+
+							fmt.Printf("Blocked %d domains\n", added)
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							return actions.ApplyBlocklist(state.GetStatus())
+						},
+					},
+				},
+			},
+			{
+				Name:  "state",
+				Usage: "control the state of the ForestVPN connection",
+				Subcommands: []*cli.Command{
+					{
+
+						Name:  "up",
+						Usage: "connect to the ForestVPN location",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "persist",
+								Usage:   "Persist VPN connnection through reboots",
+								Value:   false,
+								Aliases: []string{"p"},
+							},
+							&cli.BoolFlag{
+								Name:  "failover",
+								Usage: "switch to the next-best location if the chosen one does not respond",
+							},
+							&cli.BoolFlag{
+								Name:  "wait-for-handshake",
+								Usage: "fail unless the location handshakes, on every platform",
+							},
+							&cli.BoolFlag{
+								Name:  "require-internet-check",
+								Usage: "fail unless an HTTPS request gets through the connection",
+							},
+							&cli.StringFlag{
+								Name:  "location",
+								Usage: "connect to the location by `UUID`, slug or name just this time, keeping the default one",
+							},
+							&cli.DurationFlag{
+								Name:  "for",
+								Usage: "set the connection down automatically after the `DURATION`, e.g. 2h",
+							},
+							&cli.StringFlag{
+								Name:  "reason",
+								Usage: "note on why you connect recorded to the connection history, e.g. \"accessing EU dataset\"",
+							},
+							&cli.BoolFlag{
+								Name:  "killswitch",
+								Usage: "block the traffic outside of the tunnel, also if the connection drops, until 'fvpn state down'",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the networks routed through the tunnel, the ones excluded and why, and the routes of the host taken over, without connecting",
+							},
+							&cli.BoolFlag{
+								Name:  "auto-reconnect",
+								Usage: "rebuild the tunnel in the background once the interface is gone or the endpoint stops handshaking, until 'fvpn state down'",
+							},
+							&cli.IntFlag{
+								Name:  "failover-after",
+								Usage: "with --auto-reconnect, switch to the next-nearest location after `N` failed reconnects to the same one, 0 to keep reconnecting",
+							},
+							&cli.StringFlag{
+								Name:  "label",
+								Usage: "`LABEL` tagging the connection in the status, the history and the MQTT messages until the next 'fvpn state up', e.g. work-sync",
+							},
+						},
+						Action: func(c *cli.Context) (err error) {
+							remote, err := remoteController(c)
+							if err != nil {
+								return err
+							} else if remote != nil {
+								if c.Bool("wait-for-handshake") || c.Bool("require-internet-check") || c.IsSet("location") || c.IsSet("for") || c.Bool("dry-run") || c.Bool("killswitch") || c.Bool("auto-reconnect") || c.IsSet("label") {
+									return errors.New("--wait-for-handshake, --require-internet-check, --location, --for, --dry-run, --killswitch, --auto-reconnect and --label are not supported with the remote daemon")
+								}
+								return remoteUp(c, remote)
+							}
+
+							if utils.IsWSL2() {
+								if err := actions.CheckWSLBackend(); err != nil {
+									return err
+								}
+							}
+
+							if utils.IsTermux() && c.Bool("wait-for-handshake") {
+								return errors.New("--wait-for-handshake is not supported with the SOCKS5 proxy in Termux")
+							}
+
+							conf, err := config.Load()
+							if err != nil {
+								return err
+							}
+
+							if conf.Get(config.RequireReason) == "on" && !c.Bool("dry-run") && len(strings.TrimSpace(c.String("reason"))) == 0 {
+								return errors.New("the reason is required by the require-reason setting, try 'fvpn state up --reason \"...\"'")
+							}
+
 							profile := auth.OpenUserDB().CurrentUser()
 							if err = profile.SignIn(utils.ApiHost); err != nil {
 								return err
 							}
+							state := actions.State{WiregaurdInterface: "fvpn0", Reason: strings.TrimSpace(c.String("reason")), KillSwitch: c.Bool("killswitch")}
+							if state.KillSwitch {
+								if err := actions.CheckKillSwitch(); err != nil {
+									return err
+								}
+							}
+
+							if c.Bool("dry-run") {
+								device, err := auth.LoadDevice(profile.ID)
+								if err != nil {
+									return err
+								}
+
+								plan, err := actions.PlanRoutes(device)
+								if err != nil {
+									return err
+								}
+
+								for _, line := range plan.Describe(profile.ID) {
+									fmt.Println(line)
+								}
+								return nil
+							}
+
+							if state.GetStatus() {
+								fmt.Println("State is already up and running")
+								os.Exit(1)
+							}
+
+							client, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
+							b, err := client.GetUnexpiredOrMostRecentBillingFeature(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							// the expired subscription is reported below as for the default location
+							if arg := c.String("location"); len(arg) > 0 && time.Now().Before(b.GetExpiryDate()) {
+								locations, err := client.GetLocations()
+								if err != nil {
+									return err
+								}
+
+								target, found := actions.FindLocation(actions.GetLocationWrappers(locations), arg)
+								if !found {
+									return fmt.Errorf("no such location: %s", arg)
+								}
+
+								if !actions.NewEntitlements(b).Allows(target.Location.GetId()) {
+									return fmt.Errorf("%s requires a paid subscription, you can unlock it by going Premium at %s", target.Location.GetName(), url)
+								}
+
+								if _, err := client.UseLocationOnce(profile.ID, &state, target.Location); err != nil {
+									return err
+								}
+							}
+
+							// the default location is back if the connection fails to come up
+							defer func() {
+								if err != nil {
+									_ = client.RestoreDefaultLocation(profile.ID, &state)
+								}
+							}()
+
+							device, err := auth.LoadDevice(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							bid := b.GetBundleId()
+							location := device.GetLocation()
+							now := time.Now()
+							exp := b.GetExpiryDate()
+							left := exp.Sub(now)
+							days := int64(left.Hours() / 24)
+
+							if now.After(exp) {
+								if actions.IsPremiumLocation(location) && bid == "com.forestvpn.premium" {
+									fmt.Println("The location you were using is now unavailable, as your paid subscription has ended.")
+									fmt.Printf("You can keep using ForestVPN once you watch an ad in our mobile app, or simply go Premium at %s.\n", url)
+									os.Exit(1)
+								} else {
+									fmt.Println("Your 30-minute session is over.")
+									fmt.Printf("You can keep using ForestVPN once you watch an ad in our mobile app, or simply go Premium at %s.\n", url)
+									os.Exit(1)
+								}
+							} else if bid == "com.forestvpn.freemium" && int(left.Minutes()) < 5 && actions.ShouldUpsell(profile.ID) {
+								fmt.Printf("You currently have less than 5 minutes of free trial left. Go Premium at %s to keep using ForestVPN.\n", url)
+							} else if (days == 3 && left.Hours() == 0 || days < 3 && bid == "com.forestvpn.premium") && actions.ShouldUpsell(profile.ID) {
+								fmt.Printf("Your premium subscription will end in less than 3 days. Renew it at %s.\n", url)
+							}
+
+							if err := actions.SaveLabel(profile.ID, strings.TrimSpace(c.String("label"))); err != nil {
+								return err
+							}
+
+							if !utils.IsOpenWRT() && !utils.IsTermux() && !utils.Fake {
+								conflicts, excluded, err := client.ResolveContainerConflicts(device, profile.ID)
+								if err != nil {
+									return err
+								}
+
+								for _, conflict := range conflicts {
+									if excluded {
+										fmt.Printf("Excluded %s of %s from the tunnel to keep the containers reachable\n", conflict.Network, conflict.Interface)
+									} else {
+										fmt.Printf("Warning: %s of %s overlaps the tunnel, the containers could lose connectivity; try 'fvpn config set container-networks exclude'\n", conflict.Network, conflict.Interface)
+									}
+								}
+
+								tunnels, err := client.ResolveCoexistence(device, profile.ID, state.WiregaurdInterface)
+								if err != nil {
+									return err
+								}
+
+								for _, tunnel := range tunnels {
+									if tunnel.Default() && utils.Os == "linux" {
+										fmt.Printf("%s (%s) takes all the traffic as well, routing through %s with its own table\n", tunnel.Interface, tunnel.Kind, state.WiregaurdInterface)
+									} else if len(tunnel.Networks) > 0 {
+										fmt.Printf("Keeping %s of %s (%s) out of the tunnel\n", strings.Join(tunnel.Networks, ", "), tunnel.Interface, tunnel.Kind)
+									}
+								}
+							}
+
+							if c.Duration("for") < 0 {
+								return errors.New("--for must be positive")
+							}
+							if c.Int("failover-after") < 0 {
+								return errors.New("--failover-after must be positive")
+							} else if c.IsSet("failover-after") && !c.Bool("auto-reconnect") {
+								return errors.New("--failover-after requires --auto-reconnect")
+							}
+							actions.ClearExpiry(profile.ID)
+							actions.ClearSupervision(profile.ID)
+
+							persist := c.Bool("persist")
+							err = state.SetUp(profile.ID, persist)
+
+							if err != nil {
+								return err
+							}
+
+							time.Sleep(1 * time.Second)
+
+							if state.GetStatus() && state.CanReconfigure() && !state.AwaitHandshake(device, actions.HandshakeTimeout) && !state.RetryEndpoints(device, actions.HandshakeTimeout) {
+								if !c.Bool("failover") {
+									_ = state.SetDown(profile.ID)
+									return fmt.Errorf("%s does not respond, try 'fvpn state up --failover' to connect to the next-best location", location.GetName())
+								}
+
+								location, err = client.FailoverOnConnect(profile.ID, &state, bid != "com.forestvpn.freemium", 3)
+								if err != nil {
+									_ = state.SetDown(profile.ID)
+									return err
+								}
+							}
+
+							if c.Bool("wait-for-handshake") && state.GetStatus() {
+								// the connection could have failed over to the standby device with --failover
+								device, err = actions.ConnectedDevice(profile.ID)
+								if err != nil {
+									return err
+								}
+
+								if !state.AwaitHandshake(device, actions.HandshakeTimeout) {
+									_ = state.SetDown(profile.ID)
+									return fmt.Errorf("%s did not handshake within %s", location.GetName(), actions.HandshakeTimeout)
+								}
+							}
+
+							if c.Bool("require-internet-check") && state.GetStatus() {
+								if err := utils.ProbeHTTPS(utils.ApiScheme+"://"+utils.ApiHost, actions.InternetCheckTimeout); err != nil {
+									_ = state.SetDown(profile.ID)
+									return fmt.Errorf("internet is not reachable through %s: %s", location.GetName(), err)
+								}
+							}
+
+							if state.GetStatus() {
+								country := location.GetCountry()
+								fmt.Printf("Connected to %s, %s\n", location.GetName(), country.GetName())
+								state.PublishState(location)
+							} else {
+								return errors.New("unexpected error: state.status is false after state is up")
+							}
+
+							if d := c.Duration("for"); d > 0 {
+								deadline := time.Now().Add(d).Truncate(time.Second)
+								if err := actions.SaveExpiry(profile.ID, deadline); err != nil {
+									return err
+								}
+
+								if err := actions.ScheduleExpiry(deadline); err != nil {
+									return err
+								}
+								fmt.Printf("Disconnecting at %s\n", utils.FormatTime(deadline))
+							}
+
+							if c.Bool("auto-reconnect") {
+								supervision := actions.Supervision{Started: time.Now(), FailoverAfter: c.Int("failover-after")}
+								if err := actions.SaveSupervision(profile.ID, supervision); err != nil {
+									return err
+								}
+
+								if err := actions.ScheduleSupervisor(supervision.Started); err != nil {
+									return err
+								}
+								fmt.Println("Reconnecting automatically until 'fvpn state down'")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:        "down",
+						Description: "disconnect from the ForestVPN location",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "reason",
+								Usage: "note on why you disconnect recorded to the connection history",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							remote, err := remoteController(ctx)
+							if err != nil {
+								return err
+							} else if remote != nil {
+								return remoteDown(ctx, remote)
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0", Reason: strings.TrimSpace(ctx.String("reason"))}
+
+							// the supervisor of 'fvpn state up --auto-reconnect' is stopped first, not to take it for a drop
+							_, supervised := actions.LoadSupervision(profile.ID)
+							actions.ClearSupervision(profile.ID)
+
+							if state.GetStatus() {
+								err = state.SetDown(profile.ID)
+
+								if err != nil {
+									return err
+								}
+
+								if runtime.GOOS == "windows" {
+									time.Sleep(1 * time.Second)
+								}
+
+								if state.GetStatus() {
+									return errors.New("unexpected error: state.status is true after state is down")
+								}
+
+								if err := state.DisableKillSwitch(profile.ID); err != nil {
+									return err
+								}
+
+								actions.ClearExpiry(profile.ID)
+								if _, ok := actions.LoadDefaultLocation(profile.ID); ok {
+									client, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+									if err != nil {
+										return err
+									}
+
+									if err := client.RestoreDefaultLocation(profile.ID, &state); err != nil {
+										return fmt.Errorf("failed to restore the default location: %s", err)
+									}
+								}
+
+								fmt.Println("Disconnected")
+								state.PublishState(forestvpn_api.Location{})
+							} else if actions.KillSwitchEnabled(profile.ID) {
+								// the connection dropped and the kill switch is still blocking the traffic
+								if err := state.DisableKillSwitch(profile.ID); err != nil {
+									return err
+								}
+								fmt.Println("State is already down, kill switch is off")
+							} else if supervised {
+								fmt.Println("State is already down, auto-reconnect is off")
+							} else {
+								fmt.Println("State is already down")
+								os.Exit(1)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:   "status",
+						Usage:  "see wether connection is active",
+						Flags:  statusFlags(),
+						Action: stateStatus,
+					},
+					{
+						Name:  "security",
+						Usage: "summarize the cipher suite, handshake, preshared key, DNS, kill switch and leaks of the connection with a score",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "print the report as JSON, e.g. for a compliance check",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							report, err := state.CheckSecurity(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							if jsonOutput(c) {
+								data, err := json.MarshalIndent(report, "", "    ")
+								if err != nil {
+									return err
+								}
+
+								fmt.Println(string(data))
+								return nil
+							}
+
+							actions.PrintSecurityReport(report)
+							return nil
+						},
+					},
+					{
+						Name:  "history",
+						Usage: "show when the connection was set up and down, by whom and why",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "last",
+								Usage: "period to show, e.g. 24h",
+								Value: 30 * 24 * time.Hour,
+							},
+							&cli.StringFlag{
+								Name:  "label",
+								Usage: "show only the connections labeled with `LABEL` by 'fvpn state up --label'",
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "print the history as JSON, e.g. for an audit",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							entries, err := actions.LoadHistory(profile.ID, time.Now().Add(-c.Duration("last")))
+							if err != nil {
+								return err
+							}
+							if c.IsSet("label") {
+								entries = actions.FilterHistory(entries, c.String("label"))
+							}
+
+							if jsonOutput(c) {
+								data, err := json.MarshalIndent(entries, "", "    ")
+								if err != nil {
+									return err
+								}
+
+								fmt.Println(string(data))
+								return nil
+							}
+
+							if len(entries) == 0 {
+								fmt.Println("Nothing recorded yet")
+								return nil
+							}
+
+							actions.PrintHistory(entries)
+							return nil
+						},
+					},
+					{
+						Name:      "supervise",
+						Usage:     "reconnect the connection set up with 'fvpn state up --auto-reconnect' once it drops",
+						ArgsUsage: "STARTED",
+						Hidden:    true,
+						Action: func(c *cli.Context) error {
+							started, err := time.Parse(time.RFC3339Nano, c.Args().First())
+							if err != nil {
+								return err
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							// the detached process outlives the terminal it was started from
+							signal.Ignore(syscall.SIGHUP)
+							if err := profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							client, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0", Reason: "auto-reconnect"}
+							return client.Supervise(profile.ID, &state, started)
+						},
+					},
+					{
+						Name:      "expire",
+						Usage:     "set the connection down at the time scheduled with 'fvpn state up --for'",
+						ArgsUsage: "DEADLINE",
+						Hidden:    true,
+						Action: func(c *cli.Context) error {
+							deadline, err := time.Parse(time.RFC3339, c.Args().First())
+							if err != nil {
+								return err
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							// the detached process outlives the terminal it was started from
+							signal.Ignore(syscall.SIGHUP)
+							if !actions.AwaitExpiry(profile.ID, deadline) {
+								return nil
+							}
+							actions.ClearExpiry(profile.ID)
+							actions.ClearSupervision(profile.ID)
+
+							state := actions.State{WiregaurdInterface: "fvpn0", Reason: "expired"}
+							if !state.GetStatus() {
+								return nil
+							}
+
+							if err := state.SetDown(profile.ID); err != nil {
+								return err
+							}
+							if err := state.DisableKillSwitch(profile.ID); err != nil {
+								return err
+							}
+							state.PublishState(forestvpn_api.Location{})
+
+							if _, ok := actions.LoadDefaultLocation(profile.ID); ok {
+								if err := profile.SignIn(utils.ApiHost); err != nil {
+									return err
+								}
+
+								client, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+								if err != nil {
+									return err
+								}
+								return client.RestoreDefaultLocation(profile.ID, &state)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:   "status",
+				Usage:  "see wether connection is active, same as 'fvpn state status'",
+				Flags:  statusFlags(),
+				Action: stateStatus,
+			},
+			{
+				Name:  "diagnose",
+				Usage: "troubleshoot the connection",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "leaks",
+						Usage: "find the connections to the internet that bypass the tunnel and the processes making them (Linux)",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "watch",
+								Usage: "keep checking until interrupted",
+							},
+							&cli.DurationFlag{
+								Name:  "interval",
+								Usage: "time between the checks with --watch",
+								Value: 5 * time.Second,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							if !c.Bool("watch") {
+								leaks, err := state.FindLeaks()
+								if err != nil {
+									return err
+								}
+
+								if len(leaks) == 0 {
+									fmt.Println("No leaks found")
+									return nil
+								}
+
+								actions.PrintLeaks(leaks)
+								return cli.Exit("", 1)
+							}
+
+							// only the connections not reported yet are printed
+							reported := make(map[actions.Leak]bool)
+							for {
+								leaks, err := state.FindLeaks()
+								if err != nil {
+									return err
+								}
+
+								var found []actions.Leak
+								for _, leak := range leaks {
+									if !reported[leak] {
+										reported[leak] = true
+										found = append(found, leak)
+									}
+								}
+
+								if len(found) > 0 {
+									fmt.Printf("%s: %d connections bypass %s\n", time.Now().Format(time.RFC3339), len(found), state.WiregaurdInterface)
+									actions.PrintLeaks(found)
+								}
+
+								time.Sleep(c.Duration("interval"))
+							}
+						},
+					},
+				},
+			},
+			{
+				Name:  "assert",
+				Usage: "exit with 0 only if the connection is up and the egress country matches, e.g. before starting a torrent client",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "country",
+						Usage:    "ISO 3166-1 alpha-2 code of the expected egress country, e.g. DE",
+						Required: true,
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "time to wait for the connection and the egress country to match, e.g. 30s",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					state := actions.State{WiregaurdInterface: "fvpn0"}
+					if err := state.AssertCountry(c.String("country"), c.Duration("timeout")); err != nil {
+						return cli.Exit(err.Error(), actions.ExitAssertionFailed)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "location",
+				Usage: "manage ForestVPN locations",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "status",
+						Usage: "see the location is set as default location to connect",
+						Action: func(cCtx *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							device, err := auth.LoadDevice(profile.ID)
+
+							if err != nil {
+								return err
+							}
+
+							location := device.GetLocation()
+							if jsonOutput(cCtx) {
+								return printJSON(actions.NewLocationEntry(actions.GetLocationWrappers([]forestvpn_api.Location{location})[0]))
+							}
+
+							country := location.GetCountry()
+							fmt.Printf("Default location is set to %s, %s\n", location.GetName(), country.GetName())
+							return nil
+						},
+					},
+					{
+						Name:         "set",
+						Usage:        "set the default location by specifying `UUID` or `Name`",
+						BashComplete: completeLocations,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "yes",
+								Usage:   "change the location without confirmation",
+								Aliases: []string{"y"},
+							},
+							&cli.BoolFlag{
+								Name:  "reconnect",
+								Usage: "set the connection down and up again if it can't be switched to the new location on the fly",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							arg := cCtx.Args().Get(0)
+
+							if len(arg) < 1 {
+								return errors.New("UUID, slug or name required")
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							connected := state.GetStatus()
+							// the connection is set down and up again where it can't be switched on the fly
+							reconnect := connected && !state.CanReconfigure()
+
+							if reconnect && !cCtx.Bool("reconnect") {
+								fmt.Println("Please, set down the connection before setting a new location.")
+								fmt.Printf("Try 'fvpn state down' or 'fvpn location set %s --reconnect'\n", arg)
+								return nil
+							}
+
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
+							locations, err := authClientWrapper.GetLocations()
+							if err != nil {
+								return err
+							}
+
+							wrappedLocations := actions.GetLocationWrappers(locations)
+							location, found := actions.FindLocation(wrappedLocations, arg)
+
+							if !found {
+								err := fmt.Errorf("no such location: %s", arg)
+								return err
+							}
+
+							b, err := authClientWrapper.GetUnexpiredOrMostRecentBillingFeature(profile.ID)
+
+							if err != nil {
+								return err
+							}
+
+							expired := time.Now().After(b.GetExpiryDate())
+
+							if !actions.NewEntitlements(b).Allows(location.Location.GetId()) || expired {
+								fmt.Printf("The location you want to use is now unavailable, as it requires a paid subscription. You can unlock it by going Premium at %s.\n", url)
+								return nil
+							}
+
+							oldDevice, err := auth.LoadDevice(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							// the running connection could be failed over to the standby device
+							running, err := actions.ConnectedDevice(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							device, err := authClientWrapper.ApiClient.UpdateDevice(oldDevice.GetId(), location.Location.GetId())
+							if err != nil {
+								return err
+							}
+
+							// the device is moved first to learn the new endpoint, and moved back unless the change is confirmed
+							oldLocation := oldDevice.GetLocation()
+							if !cCtx.Bool("yes") && oldLocation.GetId() != location.Location.GetId() {
+								changes, err := actions.DiffDevices(oldDevice, device)
+								if err != nil {
+									return err
+								}
+
+								for _, change := range changes {
+									fmt.Printf("  %s\n", change)
+								}
+
+								if err := utils.Confirm("Change the location?", false); err != nil {
+									if _, err := authClientWrapper.ApiClient.UpdateDevice(oldDevice.GetId(), oldLocation.GetId()); err != nil {
+										return fmt.Errorf("failed to restore the previous location: %s", err)
+									}
+									return err
+								}
+							}
+
+							if reconnect {
+								if err := state.SetDown(profile.ID); err != nil {
+									return err
+								}
+							}
+
+							err = auth.UpdateProfileDevice(device, profile.ID)
+							if err != nil {
+								return err
+							}
+
+							if !utils.IsOpenWRT() {
+								err = authClientWrapper.SetLocation(device, profile.ID)
+
+								if err != nil {
+									return err
+								}
+							}
+							actions.ClearFailoverEvent(profile.ID)
+
+							if utils.NMConnectionExists(state.WiregaurdInterface) {
+								path := auth.ProfilesDir + string(profile.ID) + auth.WireguardConfig
+								err = utils.NMImport(state.WiregaurdInterface, path)
+
+								if err != nil {
+									return err
+								}
+							}
+
+							if reconnect {
+								err = state.SetUp(profile.ID, false)
+								if err != nil {
+									return fmt.Errorf("the connection is down, as setting it up at the new location failed: %s", err)
+								}
+							} else if connected {
+								err = state.Reconfigure(profile.ID, running, device)
+								if err != nil {
+									return err
+								}
+							}
+
+							actions.ClearDefaultLocation(profile.ID)
+							country := location.Location.GetCountry()
+							fmt.Printf("Default location is set to %s, %s\n", location.Location.GetName(), country.GetName())
+							return nil
+						},
+					},
+					{
+						Name:         "ls",
+						Usage:        "show available ForestVPN locations",
+						ArgsUsage:    "[PATTERN[,PATTERN...]]",
+						BashComplete: completeCountries,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:        "country",
+								Destination: &country,
+								Usage:       "show locations by specific country, given by its name, e.g. \"United States\" or \"Deutschland\", or ISO code, e.g. US or USA",
+								Value:       "",
+								Aliases:     []string{"c"},
+								Required:    false,
+							},
+							&cli.BoolFlag{
+								Name:  "refresh",
+								Usage: "update the local snapshot of the locations from back-end",
+							},
+							&cli.BoolFlag{
+								Name:  "available-only",
+								Usage: "leave out the degraded or full locations",
+							},
+							&cli.BoolFlag{
+								Name:  "usable-only",
+								Usage: "leave out the locations your plan doesn't allow",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							remote, err := remoteController(c)
+							if err != nil {
+								return err
+							} else if remote != nil {
+								return remoteLocations(c, remote, country, c.Bool("available-only"), c.Bool("usable-only"))
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+
+							if err != nil {
+								return err
+							}
+
+							return authClientWrapper.ListLocations(profile.ID, country, utils.SplitPatterns(c.Args().Slice()), c.Bool("refresh"), c.Bool("available-only"), c.Bool("usable-only"), jsonOutput(c))
+						},
+					},
+					{
+						Name:      "probe",
+						Usage:     "rank the locations matching the patterns by connection quality",
+						ArgsUsage: "PATTERN[,PATTERN...]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "probe all the locations instead of the ones matching the patterns",
+							},
+							&cli.BoolFlag{
+								Name:  "summary",
+								Usage: "aggregate the locations per country into a heatmap of the connection quality",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							patterns := utils.SplitPatterns(c.Args().Slice())
+							if c.Bool("all") {
+								if len(patterns) > 0 {
+									return errors.New("PATTERN is not applicable with --all")
+								}
+								patterns = []string{"*"}
+							}
+
+							if len(patterns) < 1 {
+								return errors.New("PATTERN required, e.g. 'de-*,nl-*', or --all")
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
+								return err
+							}
+
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
+							return authClientWrapper.ProbeLocations(patterns, c.Bool("summary"))
+						},
+					},
+				},
+			},
+			{
+				Name:      "completion",
+				Usage:     "print the shell completion script",
+				ArgsUsage: "bash|zsh",
+				Action: func(c *cli.Context) error {
+					switch c.Args().Get(0) {
+					case "bash":
+						fmt.Print(bashCompletion)
+					case "zsh":
+						fmt.Print(zshCompletion)
+					default:
+						return errors.New("bash or zsh required")
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "manage Forest CLI settings",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "set the setting to the value",
+						ArgsUsage: "KEY VALUE",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "topic",
+								Usage: "MQTT topic to publish the state of the connection to (mqtt only)",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							key := c.Args().Get(0)
+							value := c.Args().Get(1)
+
+							if len(key) < 1 || len(value) < 1 {
+								return errors.New("KEY and VALUE required")
+							}
+
+							topic := c.String("topic")
+							if len(topic) > 0 && key != config.MQTT {
+								return fmt.Errorf("--topic is not applicable to %s", key)
+							}
+
+							conf, err := config.Load()
+							if err != nil {
+								return err
+							}
+
+							err = conf.Set(key, value)
+							if err != nil {
+								return err
+							}
+
+							if len(topic) > 0 {
+								err = conf.Set(config.MQTTTopic, topic)
+								if err != nil {
+									return err
+								}
+							}
+
+							fmt.Printf("%s is set to %s\n", key, value)
+							return nil
+						},
+					},
+					{
+						Name:      "get",
+						Usage:     "see the value of the setting",
+						ArgsUsage: "KEY",
+						Action: func(c *cli.Context) error {
+							key := c.Args().Get(0)
+
+							if len(key) < 1 {
+								return errors.New("KEY required")
+							}
+
+							conf, err := config.Load()
+							if err != nil {
+								return err
+							}
+
+							fmt.Println(conf.Get(key))
+							return nil
+						},
+					},
+					{
+						Name:      "unset",
+						Usage:     "reset the setting to its default value",
+						ArgsUsage: "KEY",
+						Action: func(c *cli.Context) error {
+							key := c.Args().Get(0)
+
+							if len(key) < 1 {
+								return errors.New("KEY required")
+							}
+
+							conf, err := config.Load()
+							if err != nil {
+								return err
+							}
+
+							return conf.Unset(key)
+						},
+					},
+					{
+						Name:  "ls",
+						Usage: "see all the settings",
+						Action: func(c *cli.Context) error {
+							conf, err := config.Load()
+							if err != nil {
+								return err
+							}
+
+							var data [][]string
+							for _, k := range config.Keys() {
+								data = append(data, []string{k.Name, conf.Get(k.Name), k.Usage})
+							}
+
+							table := tablewriter.NewWriter(os.Stdout)
+							table.SetHeader([]string{"Key", "Value", "Description"})
+							table.SetBorder(false)
+							table.AppendBulk(data)
+							table.Render()
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "quota",
+				Usage: "track the data used through the connection this month against a quota, e.g. of a data-capped ISP",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "set the monthly quota; 'fvpn daemon' warns at 80%, 90% and 100% of it",
+						ArgsUsage: "LIMIT, e.g. 100GB",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "disconnect",
+								Usage: "set the connection down once the quota is used up",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							limit, err := utils.ParseBytes(c.Args().First())
+							if err != nil {
+								return err
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							quota, err := actions.LoadQuota(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							quota.Limit, quota.Disconnect = limit, c.Bool("disconnect")
+							// warn again about the thresholds of the new limit
+							quota.Warned = 0
+							if err := actions.SaveQuota(profile.ID, quota); err != nil {
+								return err
+							}
+
+							fmt.Printf("Monthly quota set to %s\n", utils.FormatBytes(limit))
+							return nil
+						},
+					},
+					{
+						Name:  "unset",
+						Usage: "remove the monthly quota, keeping the usage tracked",
+						Action: func(c *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							quota, err := actions.LoadQuota(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							quota.Limit, quota.Disconnect, quota.Warned = 0, false, 0
+							return actions.SaveQuota(profile.ID, quota)
+						},
+					},
+					{
+						Name:  "show",
+						Usage: "see the data used this month",
+						Action: func(c *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+
+							var quota actions.Quota
+							if state.GetStatus() {
+								quota, _, err = state.RecordUsage(profile.ID)
+							} else {
+								quota, err = actions.LoadQuota(profile.ID)
+							}
+							if err != nil {
+								return err
+							}
+
+							if quota.Month != time.Now().Format("2006-01") {
+								quota.Rx, quota.Tx = 0, 0
+							}
+
+							if quota.Limit > 0 {
+								fmt.Printf("Used %s of %s this month (%d%%)\n", utils.FormatBytes(quota.Used()), utils.FormatBytes(quota.Limit), quota.Percent())
+							} else {
+								fmt.Printf("Used %s this month, no quota set\n", utils.FormatBytes(quota.Used()))
+							}
+							fmt.Printf("Received: %s\n", utils.FormatBytes(quota.Rx))
+							fmt.Printf("Sent: %s\n", utils.FormatBytes(quota.Tx))
+							if quota.Disconnect {
+								fmt.Println("The connection is set down once the quota is used up")
+							}
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "monitor",
+				Usage: "record the latency, jitter and loss of the tunnel over time",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "start",
+						Usage: "probe through the tunnel periodically until interrupted, e.g. as a service",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "interval",
+								Usage: "time between the samples",
+								Value: time.Minute,
+							},
+							&cli.IntFlag{
+								Name:  "count",
+								Usage: "number of probes per sample",
+								Value: 5,
+							},
+							&cli.StringFlag{
+								Name:  "target",
+								Usage: "`HOST:PORT` to probe with TCP connections",
+								Value: actions.MonitorTarget,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Int("count") < 1 {
+								return errors.New("count must be at least 1")
+							}
+
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							if err := actions.PruneSamples(profile.ID); err != nil {
+								return err
+							}
+							pruned := time.Now()
+
+							superviseState()
+
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+							ticker := time.NewTicker(c.Duration("interval"))
+							defer ticker.Stop()
+
+							fmt.Printf("Probing %s every %s, see the results with 'fvpn monitor report'\n", c.String("target"), c.Duration("interval"))
+							for {
+								// the samples are only recorded while connected, so the report tells about the tunnel rather than the ISP
+								if state.GetStatus() {
+									sample, err := state.TakeSample(profile.ID, c.String("target"), c.Int("count"))
+									if err == nil {
+										err = actions.AppendSample(profile.ID, sample)
+									}
+									if err != nil {
+										return err
+									}
+
+									if utils.Verbose {
+										utils.InfoLogger.Printf("%s: %.0f ms, %.1f ms jitter, %.0f%% loss", sample.Location, sample.Latency, sample.Jitter, sample.Loss*100)
+									}
+								}
+
+								// the monitor runs as a service for weeks, so the samples are dropped as they age rather than only at the start
+								if time.Since(pruned) >= actions.MonitorPruneInterval {
+									if err := actions.PruneSamples(profile.ID); err != nil {
+										return err
+									}
+									pruned = time.Now()
+								}
+
+								select {
+								case <-c.Context.Done():
+									return nil
+								case <-ticker.C:
+								}
+							}
+						},
+					},
+					{
+						Name:  "report",
+						Usage: "summarize the quality of the tunnel by location",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "last",
+								Usage: "period to summarize, e.g. 24h",
+								Value: 24 * time.Hour,
+							},
+							&cli.StringFlag{
+								Name:  "label",
+								Usage: "summarize only the samples taken while the connection was labeled `LABEL` by 'fvpn state up --label'",
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "print the report as JSON, e.g. to attach to a support ticket",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := auth.OpenUserDB().CurrentUser()
+							if len(profile.ID) == 0 {
+								return errors.New("not logged in, log in with 'fvpn account login' first")
+							}
+
+							samples, err := actions.LoadSamples(profile.ID, time.Now().Add(-c.Duration("last")))
+							if err != nil {
+								return err
+							}
+							if c.IsSet("label") {
+								samples = actions.FilterSamples(samples, c.String("label"))
+							}
+
+							reports := actions.Summarize(samples)
+							if jsonOutput(c) {
+								data, err := json.MarshalIndent(reports, "", "    ")
+								if err != nil {
+									return err
+								}
+
+								fmt.Println(string(data))
+								return nil
+							}
+
+							if len(reports) == 0 {
+								fmt.Println("Nothing recorded yet, try 'fvpn monitor start'")
+								return nil
+							}
+
+							actions.PrintReport(reports)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "dashboard",
+				Usage: "show the connection, traffic, quality and quota full-screen until interrupted, e.g. on the display of a Raspberry Pi gateway",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "interval",
+						Usage: "time between the updates",
+						Value: 2 * time.Second,
+					},
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "`HOST:PORT` to probe with TCP connections for the quality graph",
+						Value: actions.MonitorTarget,
+					},
+				},
+				Action: dashboard,
+				Subcommands: []*cli.Command{
+					{
+						Name:  "serve",
+						Usage: "serve the dashboard read-only as a web page and JSON, e.g. to check the connection of a headless machine from a browser on the LAN",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "listen",
+								Usage: "`HOST:PORT` to serve at, e.g. 0.0.0.0:8999 to let in the LAN",
+								Value: "127.0.0.1:8999",
+							},
+							&cli.DurationFlag{
+								Name:  "interval",
+								Usage: "time between the updates",
+								Value: 10 * time.Second,
+							},
+							&cli.StringFlag{
+								Name:  "target",
+								Usage: "`HOST:PORT` to probe with TCP connections for the latency graph",
+								Value: actions.MonitorTarget,
+							},
+						},
+						Action: dashboardServe,
+					},
+				},
+			},
+			{
+				Name:   "dev",
+				Usage:  "tools for the development of fvpn",
+				Hidden: true,
+				Subcommands: []*cli.Command{
+					{
+						Name:  "mock-server",
+						Usage: "serve a fake ForestVPN API with canned locations, billing and devices to run the commands offline",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "http",
+								Usage: "`ADDRESS` to listen on",
+								Value: "127.0.0.1:8080",
+							},
+							&cli.BoolFlag{
+								Name:  "premium",
+								Usage: "give the user the premium subscription instead of the free one",
+							},
+							&cli.IntFlag{
+								Name:  "device-limit",
+								Usage: "number of devices after which creating a device fails, or 0 for no limit",
+							},
+							&cli.StringFlag{
+								Name:  "revoke",
+								Usage: "reject the `TOKEN` as unauthorized to exercise the expiry of the session",
+							},
+							&cli.StringFlag{
+								Name:  "account-settings",
+								Usage: "`JSON` of the defaults of the account returned with the profile, e.g. '{\"region\": \"DE\", \"killswitch\": true}'",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							handler := mock.New()
+							handler.Premium, handler.DeviceLimit = c.Bool("premium"), c.Int("device-limit")
+							handler.Revoked = c.String("revoke")
+							if settings := c.String("account-settings"); len(settings) > 0 {
+								if err := json.Unmarshal([]byte(settings), &handler.Settings); err != nil {
+									return fmt.Errorf("invalid --account-settings: %s", err)
+								}
+							}
+							address := c.String("http")
+
+							fmt.Printf("Listening on %s\n", address)
+							fmt.Printf("Try 'FVPN_API_URL=http://%s fvpn account login --token demo'\n", address)
+							return http.ListenAndServe(address, handler)
+						},
+					},
+					{
+						Name:  "stress",
+						Usage: "cycle the connection up and down, check the status and set the location in parallel against the fake backend to shake out races",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "iterations",
+								Usage: "number of the operations to run",
+								Value: 100,
+							},
+							&cli.IntFlag{
+								Name:  "parallel",
+								Usage: "number of the operations to run at once",
+								Value: 4,
+							},
+							&cli.Int64Flag{
+								Name:  "seed",
+								Usage: "seed of the random order of the operations, e.g. to repeat a failed run; random if not set",
+							},
+						},
+						Action: stressAction,
+					},
+					{
+						Name:      "schema",
+						Usage:     "print the JSON schema of the output of 'fvpn state status --json' or 'fvpn account status --json'",
+						ArgsUsage: "state-status|account-status",
+						Action: func(c *cli.Context) error {
+							data, err := schema.Get(c.Args().First())
+							if err != nil {
+								return err
+							}
+
+							fmt.Print(string(data))
+							return nil
+						},
+					},
+					{
+						Name:            "replay",
+						Usage:           "run the command against the API responses recorded with --record, e.g. to reproduce a bug report",
+						ArgsUsage:       "FILE COMMAND...",
+						SkipFlagParsing: true,
+						Action: func(c *cli.Context) error {
+							if c.NArg() < 2 {
+								return errors.New("expected the HAR file and the command to run, e.g. 'fvpn dev replay session.har location ls'")
+							}
+
+							har, err := utils.ReadHAR(c.Args().First())
+							if err != nil {
+								return err
+							}
+
+							listener, err := net.Listen("tcp", "127.0.0.1:0")
+							if err != nil {
+								return err
+							}
+							defer listener.Close()
 
-							state := actions.State{WiregaurdInterface: "fvpn0"}
+							go http.Serve(listener, utils.NewHARReplayer(har))
 
-							if state.GetStatus() {
-								device, err := auth.LoadDevice(profile.ID)
+							// the command is run anew, so the API URL is passed the same way as --api-url
+							if err := os.Setenv("FVPN_API_URL", "http://"+listener.Addr().String()); err != nil {
+								return err
+							}
 
-								if err != nil {
-									return err
+							// the App of the subcommand only knows the subcommands of dev
+							var root *cli.App
+							for _, ctx := range c.Lineage() {
+								if ctx.App != nil {
+									root = ctx.App
 								}
+							}
+							return root.RunContext(c.Context, append([]string{root.Name}, c.Args().Tail()...))
+						},
+					},
+				},
+			},
+			{
+				Name:  "daemon",
+				Usage: "serve the REST API to manage ForestVPN remotely",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "http",
+						Usage: "`ADDRESS` to listen on, e.g. :8080 to listen on all interfaces with --tls-cert, unix:///run/fvpn.sock or npipe:////./pipe/fvpn",
+						Value: "127.0.0.1:9999",
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						Usage:   "bearer `TOKEN` required to authenticate the requests; optional on a unix:// socket or npipe:// pipe, which are authenticated by the system",
+						EnvVars: []string{"FVPN_DAEMON_TOKEN"},
+					},
+					&cli.StringFlag{
+						Name:  "socket-group",
+						Usage: "let the members of `GROUP` control the daemon over the unix:// socket or npipe:// pipe besides root, the administrators and the user running it",
+					},
+					&cli.StringFlag{
+						Name:  "tls-cert",
+						Usage: "PEM certificate `FILE` to serve the REST API over TLS, required beyond the loopback interface",
+					},
+					&cli.StringFlag{
+						Name:  "tls-key",
+						Usage: "PEM private key `FILE` of the certificate set with --tls-cert",
+					},
+					&cli.DurationFlag{
+						Name:  "watchdog",
+						Usage: "exit for a restart by the service manager once a request or task hangs for `DURATION`, e.g. with the Windows service recovery; under systemd, WatchdogSec= is used instead",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					client, err := forestvpn.NewClient(c.Context, utils.ApiHost)
+					if err != nil {
+						return err
+					}
+
+					address := c.String("http")
+					if len(c.String("token")) == 0 && !server.Authenticates(address) {
+						return errors.New("--token is required, unless the daemon listens on a unix:// socket or npipe:// pipe authenticated by the system")
+					}
+					if !server.Loopback(address) && len(c.String("tls-cert")) == 0 {
+						return fmt.Errorf("--tls-cert is required to listen on %s beyond the loopback interface, the token would be sent in plaintext otherwise", address)
+					}
+					handler := server.New(client, c.String("token"))
+
+					// 'fvpn state supervise' leaves the connection set up with --auto-reconnect to the daemon while it's running
+					if err := actions.SaveDaemonPid(); err != nil {
+						return err
+					}
+					superviseState()
+
+					logError := func(err error) {
+						crash.CaptureException(err)
+						if utils.Verbose {
+							utils.InfoLogger.Println(err.Error())
+						}
+					}
+
+					resume := func(ctx context.Context) error {
+						woke, err := client.Resume(ctx)
+						if woke && err == nil {
+							fmt.Println("System woke up, revived the connection")
+						}
+						if err != nil {
+							return err
+						}
+
+						// run in the same task as Resume, so the two never rebuild the tunnel at once
+						event, err := client.Supervise(ctx)
+						if event != nil && event.OK && event.Action == "failover" {
+							fmt.Printf("Connection dropped (%s), failed over to %s\n", event.Cause, event.Location)
+						} else if event != nil && event.OK {
+							fmt.Printf("Connection dropped (%s), reconnected to %s\n", event.Cause, event.Location)
+						}
+						if err != nil {
+							return err
+						}
+						return client.AdjustKeepalive(ctx)
+					}
+					if err := client.WatchWake(c.Context); err != nil && utils.Verbose {
+						utils.InfoLogger.Printf("detecting the wakes with the wall clock: %s", err)
+					}
+					go handler.Every(c.Context, 5*time.Second, resume, logError)
+
+					quota := func(ctx context.Context) error {
+						quota, reached, disconnected, err := client.EnforceQuota(ctx)
+						if reached > 0 {
+							fmt.Printf("Used %d%% of the monthly quota: %s of %s\n", reached, utils.FormatBytes(quota.Used()), utils.FormatBytes(quota.Limit))
+						}
+						if disconnected {
+							fmt.Println("Monthly quota is used up, set the connection down")
+						}
+						return err
+					}
+					go handler.Every(c.Context, 30*time.Second, quota, logError)
+
+					// the settings are read on every run, so 'fvpn config set' applies without restarting the daemon
+					failover := func(ctx context.Context) error {
+						cfg, err := config.Load()
+						if err != nil {
+							return err
+						}
 
-								location := device.GetLocation()
-								country := location.GetCountry()
+						standby := cfg.Get(config.Failover)
+						if standby == "off" || actions.PauseOnMetered() {
+							return nil
+						}
+
+						switched, err := client.Failover(ctx, standby)
+						if switched {
+							fmt.Println("Connected location stopped responding, failed over to the standby location")
+						}
+						return err
+					}
+					go handler.Every(c.Context, 5*time.Second, failover, logError)
+
+					monitor := &actions.QualityMonitor{}
+					autoSwitch := func(ctx context.Context) error {
+						cfg, err := config.Load()
+						if err != nil {
+							return err
+						}
 
-								fmt.Printf("Connected to %s, %s\n", location.GetName(), country.GetName())
-							} else {
-								fmt.Println("Disconnected")
+						value := cfg.Get(config.AutoSwitch)
+						if value == "off" || actions.PauseOnMetered() {
+							return nil
+						}
+
+						policy, err := config.ParseSwitchPolicy(value)
+						if err != nil {
+							return err
+						}
+
+						if policy != monitor.Policy {
+							monitor = &actions.QualityMonitor{Policy: policy}
+						}
+
+						switched, err := client.AutoSwitch(ctx, monitor)
+						if switched {
+							fmt.Printf("Connection quality degraded (%s), switched to the next-best location\n", value)
+						}
+						return err
+					}
+					go handler.Every(c.Context, 30*time.Second, autoSwitch, logError)
+
+					idle := &actions.IdleMonitor{}
+					idleTimeout := func(ctx context.Context) error {
+						cfg, err := config.Load()
+						if err != nil {
+							return err
+						}
+
+						timeout, err := config.ParseIdleTimeout(cfg.Get(config.IdleTimeout))
+						if err != nil || timeout == 0 {
+							return err
+						}
+
+						if timeout != idle.Timeout {
+							idle = &actions.IdleMonitor{Timeout: timeout}
+						}
+
+						disconnected, err := client.EnforceIdle(ctx, idle)
+						if disconnected {
+							fmt.Printf("No traffic for %s, set the connection down\n", timeout)
+						}
+						return err
+					}
+					go handler.Every(c.Context, 30*time.Second, idleTimeout, logError)
+
+					routes := func(ctx context.Context) error {
+						restored, err := client.RestoreRoutes(ctx)
+						if len(restored) > 0 {
+							fmt.Printf("Routes of the connection were removed from the host, restored %s\n", strings.Join(restored, ", "))
+						}
+						return err
+					}
+					go handler.Every(c.Context, 10*time.Second, routes, logError)
+
+					roam := func(ctx context.Context) error {
+						roamed, err := client.Roam(ctx)
+						if roamed && err == nil {
+							fmt.Println("Network changed, revived the connection")
+						}
+						return err
+					}
+					go handler.Every(c.Context, 5*time.Second, roam, logError)
+
+					profile := auth.OpenUserDB().CurrentUser()
+					changes, err := utils.WatchFiles(c.Context, []string{config.Path, auth.ProfilesDir + string(profile.ID) + auth.DeviceFile})
+					if err != nil {
+						return err
+					}
+
+					reload := func(ctx context.Context) error {
+						reloaded, err := client.Reload(ctx)
+						if reloaded {
+							fmt.Println("Settings changed, reloaded the connection")
+						}
+						return err
+					}
+					go handler.OnChange(c.Context, changes, time.Second, reload, logError)
+
+					publisher := &actions.StatePublisher{}
+					publish := func(ctx context.Context) error {
+						return publisher.Publish(&actions.State{WiregaurdInterface: "fvpn0"}, profile.ID)
+					}
+					go handler.Every(c.Context, 5*time.Second, publish, logError)
+
+					listener, err := server.Listen(address, c.String("socket-group"))
+					if err != nil {
+						return err
+					}
+					defer listener.Close()
+
+					// systemd is pinged twice per WatchdogSec and restarts the daemon once the pings stop
+					interval, systemd := utils.SdWatchdogInterval()
+					if systemd {
+						interval /= 2
+					} else {
+						interval = c.Duration("watchdog")
+					}
+
+					if interval > 0 {
+						alive := func() error {
+							_, err := utils.SdNotify("WATCHDOG=1")
+							return err
+						}
+						unhealthy := func() {
+							logError(fmt.Errorf("daemon hung for %s", interval))
+							if systemd {
+								_, _ = utils.SdNotify("WATCHDOG=trigger")
+								return
 							}
 
-							return nil
+							fmt.Printf("Daemon hung for %s, exiting for a restart\n", interval)
+							crash.Flush(2 * time.Second)
+							os.Exit(1)
+						}
+						go handler.Watchdog(c.Context, interval, alive, unhealthy, logError)
+					}
 
-						},
-					},
+					if _, err := utils.SdNotify("READY=1"); err != nil {
+						logError(err)
+					}
+
+					cert, key := c.String("tls-cert"), c.String("tls-key")
+
+					if len(cert) > 0 || len(key) > 0 {
+						fmt.Printf("Listening on %s (TLS)\n", address)
+						return http.ServeTLS(listener, handler, cert, key)
+					}
+
+					fmt.Printf("Listening on %s\n", address)
+					return http.Serve(listener, handler)
 				},
 			},
 			{
-				Name:  "location",
-				Usage: "manage ForestVPN locations",
+				Name:  "nm",
+				Usage: "manage the NetworkManager connection of ForestVPN",
 				Subcommands: []*cli.Command{
 					{
-						Name:  "status",
-						Usage: "see the location is set as default location to connect",
-						Action: func(cCtx *cli.Context) error {
+						Name:  "export",
+						Usage: "register the default location as a NetworkManager WireGuard connection",
+						Action: func(c *cli.Context) error {
+							if !utils.IsNetworkManager() {
+								return errors.New("NetworkManager is not running")
+							}
+
 							profile := auth.OpenUserDB().CurrentUser()
 							if err = profile.SignIn(utils.ApiHost); err != nil {
 								return err
 							}
 
+							state := actions.State{WiregaurdInterface: "fvpn0"}
+
+							if state.GetStatus() {
+								fmt.Println("Please, set down the connection before exporting it to NetworkManager.")
+								fmt.Println("Try 'fvpn state down'")
+								return nil
+							}
+
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							if err != nil {
+								return err
+							}
+
 							device, err := auth.LoadDevice(profile.ID)
+							if err != nil {
+								return err
+							}
+
+							err = authClientWrapper.SetLocation(device, profile.ID)
+							if err != nil {
+								return err
+							}
 
+							path := auth.ProfilesDir + string(profile.ID) + auth.WireguardConfig
+							err = utils.NMImport(state.WiregaurdInterface, path)
 							if err != nil {
 								return err
 							}
 
 							location := device.GetLocation()
 							country := location.GetCountry()
-							fmt.Printf("Default location is set to %s, %s\n", location.GetName(), country.GetName())
+							fmt.Printf("Exported %s, %s as NetworkManager connection %s\n", location.GetName(), country.GetName(), state.WiregaurdInterface)
 							return nil
 						},
 					},
 					{
-						Name:  "set",
-						Usage: "set the default location by specifying `UUID` or `Name`",
-						Action: func(cCtx *cli.Context) error {
-							profile := auth.OpenUserDB().CurrentUser()
-							if err = profile.SignIn(utils.ApiHost); err != nil {
-								return err
-							}
-
+						Name:  "rm",
+						Usage: "remove the NetworkManager connection of ForestVPN",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "remove without confirmation",
+							},
+						},
+						Action: func(c *cli.Context) error {
 							state := actions.State{WiregaurdInterface: "fvpn0"}
 
-							if state.GetStatus() {
-								fmt.Println("Please, set down the connection before setting a new location.")
-								fmt.Println("Try 'fvpn state down'")
-								return nil
+							if !utils.NMConnectionExists(state.WiregaurdInterface) {
+								return fmt.Errorf("no such NetworkManager connection: %s", state.WiregaurdInterface)
 							}
 
-							arg := cCtx.Args().Get(0)
-
-							if len(arg) < 1 {
-								return errors.New("UUID or name required")
+							if err := utils.Confirm(fmt.Sprintf("Remove the NetworkManager connection %s?", state.WiregaurdInterface), c.Bool("yes")); err != nil {
+								return err
 							}
 
-							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							err = utils.NMDelete(state.WiregaurdInterface)
 							if err != nil {
 								return err
 							}
 
-							locations, err := authClientWrapper.ApiClient.GetLocations()
+							fmt.Println("Removed")
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "service",
+				Usage: "check ForestVPN for outages",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "status",
+						Usage: "see the incidents and maintenances affecting the default location",
+						Action: func(c *cli.Context) error {
+							pageURL := conf.Get(config.StatusPage)
+							if len(pageURL) == 0 {
+								return errors.New("no status page set, set it with 'fvpn config set status-page URL'")
+							}
+
+							page, err := utils.FetchStatusPage(pageURL)
 							if err != nil {
 								return err
 							}
 
-							wrappedLocations := actions.GetLocationWrappers(locations)
-							var location actions.LocationWrapper
-							id, err := uuid.Parse(arg)
-							found := false
+							// the incidents are not filtered by location if no one is logged in
+							var location forestvpn_api.Location
+							profile := auth.OpenUserDB().CurrentUser()
+							if device, err := auth.LoadDevice(profile.ID); err == nil {
+								location = device.GetLocation()
+							}
 
-							if err != nil {
-								for _, loc := range wrappedLocations {
-									if strings.EqualFold(loc.Location.GetName(), arg) {
-										location = loc
-										found = true
-										break
-									}
-								}
-							} else {
-								for _, loc := range wrappedLocations {
-									if strings.EqualFold(location.Location.GetId(), id.String()) {
-										location = loc
-										found = false
-										break
-									}
-								}
+							actions.PrintServiceStatus(page, location)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "support",
+				Usage: "contact ForestVPN support",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "ticket",
+						Usage:     "submit a support ticket",
+						ArgsUsage: "DESCRIPTION",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "category",
+								Usage: "category of the ticket, the first one if not set",
+							},
+							&cli.BoolFlag{
+								Name:  "attach-diagnostics",
+								Usage: "attach the versions, settings and the state of the connection, scrubbed of emails, keys and IP addresses",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							text := strings.TrimSpace(strings.Join(c.Args().Slice(), " "))
+							if len(text) == 0 {
+								return errors.New("DESCRIPTION required")
 							}
 
-							if !found {
-								err := fmt.Errorf("no such location: %s", arg)
+							profile := auth.OpenUserDB().CurrentUser()
+							if err = profile.SignIn(utils.ApiHost); err != nil {
 								return err
 							}
 
-							b, err := authClientWrapper.GetUnexpiredOrMostRecentBillingFeature(profile.ID)
-
+							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
 							if err != nil {
 								return err
 							}
 
-							bid := b.GetBundleId()
-							expired := time.Now().After(b.GetExpiryDate())
-
-							if location.Premium && bid == "com.forestvpn.freemium" || expired {
-								fmt.Printf("The location you want to use is now unavailable, as it requires a paid subscription. You can unlock it by going Premium at %s.\n", url)
-								return nil
+							var files []*os.File
+							if c.Bool("attach-diagnostics") {
+								bundle, err := diagnosticsBundle(conf)
+								if err != nil {
+									return err
+								}
+								defer os.Remove(bundle.Name())
+								defer bundle.Close()
+								files = append(files, bundle)
 							}
 
-							device, err := auth.LoadDevice(profile.ID)
+							id, err := authClientWrapper.CreateSupportTicket(text, c.String("category"), files)
 							if err != nil {
 								return err
 							}
 
-							device, err = authClientWrapper.ApiClient.UpdateDevice(device.GetId(), location.Location.GetId())
-							if err != nil {
+							if len(id) > 0 {
+								fmt.Printf("Submitted ticket %s\n", id)
+							} else {
+								fmt.Println("Submitted")
+							}
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "system",
+				Usage: "integrate fvpn with the system",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "install",
+						Usage: "install the helper and its polkit policy, so 'fvpn state up' and 'down' prompt for the password instead of requiring sudo (Linux)",
+						Action: func(c *cli.Context) error {
+							if !utils.IsAdmin() {
+								return errors.New("run 'sudo fvpn system install' to install the polkit policy")
+							}
+
+							if err := utils.InstallPolkitPolicy(); err != nil {
 								return err
 							}
 
-							err = auth.UpdateProfileDevice(device, profile.ID)
+							fmt.Printf("Installed %s and %s\n", utils.HelperPath, utils.PolkitPolicyFile)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "setup",
+				Usage: "prepare the system to run fvpn",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "deps",
+						Usage: "install the missing dependencies, e.g. wireguard-tools, with the package manager of the system",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "print-only",
+								Usage: "print the commands instead of running them",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Usage:   "install without confirmation",
+								Aliases: []string{"y"},
+							},
+						},
+						Action: func(c *cli.Context) error {
+							pm, err := actions.DetectPackageManager()
 							if err != nil {
 								return err
 							}
 
-							if !utils.IsOpenWRT() {
-								err = authClientWrapper.SetLocation(device, profile.ID)
+							packages := actions.MissingPackages(pm)
+							if len(packages) == 0 {
+								fmt.Println("All dependencies are installed")
+								return nil
+							}
 
-								if err != nil {
-									return err
+							commands := pm.InstallCommand(packages)
+							if c.Bool("print-only") {
+								for _, command := range commands {
+									fmt.Println(strings.Join(command, " "))
 								}
+								return nil
 							}
 
-							country := location.Location.GetCountry()
-							fmt.Printf("Default location is set to %s, %s\n", location.Location.GetName(), country.GetName())
-							return nil
+							if !c.Bool("yes") {
+								fmt.Printf("Missing %s:\n", strings.Join(packages, ", "))
+								for _, command := range commands {
+									fmt.Printf("  %s\n", strings.Join(command, " "))
+								}
+							}
+
+							if err := utils.Confirm("Run these commands?", c.Bool("yes")); err != nil {
+								return err
+							}
+
+							return pm.InstallPackages(packages)
 						},
 					},
+				},
+			},
+			{
+				Name:   "release",
+				Usage:  "tools of the release pipeline",
+				Hidden: true,
+				Subcommands: []*cli.Command{
 					{
-						Name:  "ls",
-						Usage: "show available ForestVPN locations",
+						Name:  "manifest",
+						Usage: "print the release manifest with the URLs and checksums of the archives, signed with the ed25519 key",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:        "country",
-								Destination: &country,
-								Usage:       "show locations by specific country",
-								Value:       "",
-								Aliases:     []string{"c"},
-								Required:    false,
+								Name:     "version",
+								Usage:    "tag of the release, e.g. v0.5.0",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "checksums",
+								Usage: "checksums.txt produced by goreleaser",
+								Value: "dist/checksums.txt",
+							},
+							&cli.StringFlag{
+								Name:    "key",
+								Usage:   "base64 encoded ed25519 private key or seed",
+								EnvVars: []string{"FVPN_RELEASE_KEY"},
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "`FILE` to write the manifest to instead of the standard output",
 							},
 						},
 						Action: func(c *cli.Context) error {
-							profile := auth.OpenUserDB().CurrentUser()
-							if err = profile.SignIn(utils.ApiHost); err != nil {
+							key, err := base64.StdEncoding.DecodeString(c.String("key"))
+							if err != nil {
 								return err
 							}
 
-							authClientWrapper, err := actions.GetAuthClientWrapper(profile, utils.ApiHost)
+							switch len(key) {
+							case ed25519.SeedSize:
+								key = ed25519.NewKeyFromSeed(key)
+							case ed25519.PrivateKeySize:
+							default:
+								return errors.New("ed25519 private key or seed required, set it with --key or FVPN_RELEASE_KEY")
+							}
+
+							file, err := os.Open(c.String("checksums"))
+							if err != nil {
+								return err
+							}
+							defer file.Close()
+
+							checksums, err := utils.ParseChecksums(file)
+							if err != nil {
+								return err
+							}
+
+							manifest := utils.NewReleaseManifest(c.String("version"), checksums, actions.ReleasesURL)
+							if err := manifest.Sign(ed25519.PrivateKey(key)); err != nil {
+								return err
+							}
 
+							data, err := json.MarshalIndent(manifest, "", "    ")
 							if err != nil {
 								return err
 							}
 
-							return authClientWrapper.ListLocations(country)
+							if len(c.String("output")) > 0 {
+								return os.WriteFile(c.String("output"), append(data, '\n'), 0644)
+							}
+
+							fmt.Println(string(data))
+							return nil
 						},
 					},
 				},
 			},
+			{
+				Name:  "apply",
+				Usage: "converge the account, location, connection and settings to the declarative spec, e.g. fvpn.yaml kept in git",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "`FILE` of the spec",
+						Aliases:  []string{"f"},
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "print the changes without making them",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					spec, err := loadSpec(c.String("file"))
+					if err != nil {
+						return err
+					}
+
+					return applySpec(c.Context, spec, c.Bool("dry-run"))
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "check the binary is the one published in the release",
+				Action: func(c *cli.Context) error {
+					if err := actions.VerifyBinary(appVersion); err != nil {
+						return cli.Exit("Warning: "+err.Error(), 1)
+					}
+
+					fmt.Printf("fvpn %s matches the published release\n", appVersion)
+					return nil
+				},
+			},
+			{
+				Name:  "version",
+				Usage: "see the version of fvpn",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "build-info",
+						Usage: "list the optional features of the build",
+					},
+					&cli.BoolFlag{
+						Name:  "verbose",
+						Usage: "print the versions of Go, Wireguard and other components to attach to bug reports",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					fmt.Println(appVersion)
+
+					if c.Bool("verbose") || utils.Verbose {
+						daemon := c.String("host")
+						if len(daemon) == 0 {
+							daemon = conf.Get(config.DaemonAddress)
+						}
+
+						for _, detail := range buildDetails(utils.ApiHost, daemon) {
+							fmt.Printf("%s: %s\n", detail[0], detail[1])
+						}
+					}
+
+					if c.Bool("build-info") {
+						for _, feature := range buildFeatures(conf) {
+							fmt.Printf("%s: %s\n", feature[0], feature[1])
+						}
+					}
+
+					return nil
+				},
+			},
 		},
 	}
 
+	lockdownActions(app.Commands, "")
+	recoverActions(app.Commands)
 	err = app.Run(os.Args)
 
+	if api.IsSessionExpired(err) {
+		auth.OpenUserDB().CurrentUser().MarkSessionExpired()
+		fmt.Println("Session expired, run 'fvpn account login' to sign in again")
+		os.Exit(actions.ExitSessionExpired)
+	}
+
 	if err != nil {
-		sentry.CaptureException(err)
+		crash.CaptureException(err)
 		caser := cases.Title(language.AmericanEnglish)
 		msg := strings.Split(err.Error(), " ")
 		msg[0] = caser.String(msg[0])
@@ -505,3 +2985,37 @@ func main() {
 
 	}
 }
+
+// recoverActions is a function that wraps the actions of the commands and their subcommands to convert panics into errors.
+func recoverActions(commands []*cli.Command) {
+	for _, command := range commands {
+		if action := command.Action; action != nil {
+			command.Action = func(c *cli.Context) (err error) {
+				defer crash.Recover(&err)
+				return action(c)
+			}
+		}
+		recoverActions(command.Subcommands)
+	}
+}
+
+// useSecretBackend is a function that keeps the tokens of the profiles in the storage set with 'fvpn config set secret-backend'.
+func useSecretBackend() error {
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	backend := conf.Get(config.SecretBackend)
+	if backend == "file" {
+		return nil
+	}
+
+	store, err := secrets.Open(backend, "")
+	if err != nil {
+		return err
+	}
+
+	auth.UseSecretStore(store)
+	return nil
+}
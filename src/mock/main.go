@@ -0,0 +1,356 @@
+// mock is a package containing a fake ForestVPN API with canned locations, billing features and devices,
+// served with 'fvpn dev mock-server' to exercise the CLI offline, e.g. in CI or demos.
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/google/uuid"
+)
+
+// Email is the email of the user every token signs in as.
+const Email = "demo@example.com"
+
+// Server is a structure that serves the subset of the ForestVPN API used by the CLI out of memory.
+// Any bearer token is accepted, so sign in with 'fvpn account login --token anything'.
+type Server struct {
+	// Premium is whether the user has the premium subscription rather than the free one.
+	Premium bool
+	// DeviceLimit is the number of devices after which creating a device fails, or 0 for no limit.
+	DeviceLimit int
+	// Revoked is a token rejected as unauthorized, as once the session is revoked, if set.
+	Revoked string
+	// Settings are the defaults of the account returned along with the profile, e.g. {"region": "DE"}.
+	Settings  map[string]interface{}
+	user      forestvpn_api.User
+	locations []forestvpn_api.Location
+	devices   map[string]*forestvpn_api.Device
+	// codes are the PKCE challenges of the SSO codes issued and not yet exchanged by code.
+	codes map[string]string
+	// sessions are the sessions of the tokens seen by the Server by token, and revoked are the tokens of the revoked ones.
+	sessions map[string]*session
+	revoked  map[string]bool
+	mu       sync.Mutex
+}
+
+// session is a structure of the sign-in session listed by GET /v2/auth/sessions/.
+type session struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// New is a factory function that returns the Server with the canned user and locations and no devices.
+func New() *Server {
+	email := Email
+	return &Server{
+		user:      forestvpn_api.User{Id: "00000000-0000-4000-8000-000000000001", Username: "demo", Email: &email},
+		locations: Locations(),
+		devices:   make(map[string]*forestvpn_api.Device),
+		codes:     make(map[string]string),
+		sessions:  make(map[string]*session),
+		revoked:   make(map[string]bool),
+	}
+}
+
+// Locations is a function that returns the canned locations. Helsinki and Falkenstein are free like on the real API.
+func Locations() []forestvpn_api.Location {
+	finland := forestvpn_api.Country{Id: "FI", Name: "Finland", Emoji: "🇫🇮"}
+	germany := forestvpn_api.Country{Id: "DE", Name: "Germany", Emoji: "🇩🇪"}
+	netherlands := forestvpn_api.Country{Id: "NL", Name: "Netherlands", Emoji: "🇳🇱"}
+	japan := forestvpn_api.Country{Id: "JP", Name: "Japan", Emoji: "🇯🇵"}
+	good, degraded := 0.95, 0.2
+
+	return []forestvpn_api.Location{
+		{Id: "7fc5b17c-eddf-413f-8b37-9d36eb5e33ec", Name: "Helsinki", Latitude: 60.17, Longitude: 24.94, Country: finland, LatencyRate: &good},
+		{Id: "b134d679-8697-4dc6-b629-c4c189392fca", Name: "Falkenstein", Latitude: 50.48, Longitude: 12.37, Country: germany, LatencyRate: &good},
+		{Id: "2a6e0e4a-3a4b-4a1e-9a8e-6c1f5d0b1a01", Name: "Frankfurt", Latitude: 50.11, Longitude: 8.68, Country: germany, LatencyRate: &good},
+		{Id: "2a6e0e4a-3a4b-4a1e-9a8e-6c1f5d0b1a02", Name: "Amsterdam", Latitude: 52.37, Longitude: 4.9, Country: netherlands, LatencyRate: &good},
+		{Id: "2a6e0e4a-3a4b-4a1e-9a8e-6c1f5d0b1a03", Name: "Tokyo", Latitude: 35.68, Longitude: 139.69, Country: japan, LatencyRate: &degraded},
+	}
+}
+
+// ServeHTTP is a method that implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the single sign-on comes before the user has a token
+	if strings.HasPrefix(r.URL.Path, "/v2/auth/sso/") {
+		s.handleSSO(w, r, strings.TrimPrefix(r.URL.Path, "/v2/auth/sso/"))
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "not_authenticated", "Authentication credentials were not provided.")
+		return
+	}
+
+	if len(s.Revoked) > 0 && r.Header.Get("Authorization") == "Bearer "+s.Revoked {
+		writeError(w, http.StatusUnauthorized, "token_not_valid", "Token is invalid or expired.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.revoked[token] {
+		writeError(w, http.StatusUnauthorized, "token_not_valid", "Token is invalid or expired.")
+		return
+	}
+	s.touchSession(token, r.UserAgent())
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2")
+	switch {
+	case path == "/auth/sessions/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.listSessions(token))
+	case strings.HasPrefix(path, "/auth/sessions/") && r.Method == http.MethodDelete:
+		s.revokeSession(w, strings.Trim(strings.TrimPrefix(path, "/auth/sessions/"), "/"))
+	case path == "/auth/whoami/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.user)
+	case path == "/auth/profile/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.profile())
+	case path == "/locations/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.locations)
+	case path == "/geo/countries/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.countries())
+	case path == "/billing/features/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.billingFeatures())
+	case path == "/support/ticket-categories/" && r.Method == http.MethodGet:
+		id, name := "general", "General"
+		writeJSON(w, http.StatusOK, []forestvpn_api.TicketCategory{{Id: &id, Name: &name}})
+	case path == "/support/tickets/" && r.Method == http.MethodPost:
+		writeJSON(w, http.StatusCreated, map[string]string{"id": uuid.New().String()})
+	case path == "/org/devices/" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.orgDevices())
+	case path == "/devices/":
+		s.handleDevices(w, r)
+	case strings.HasPrefix(path, "/devices/"):
+		s.handleDevice(w, r, strings.Trim(strings.TrimPrefix(path, "/devices/"), "/"))
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not found.")
+	}
+}
+
+// deviceRequest is a body of the requests to create or update the device, limited to the fields the Server handles.
+type deviceRequest struct {
+	Name     *string `json:"name"`
+	Location *string `json:"location"`
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		devices := make([]forestvpn_api.Device, 0, len(s.devices))
+		for _, device := range s.devices {
+			devices = append(devices, *device)
+		}
+		writeJSON(w, http.StatusOK, devices)
+	case http.MethodPost:
+		if s.DeviceLimit > 0 && len(s.devices) >= s.DeviceLimit {
+			writeError(w, http.StatusForbidden, "device_limit_exceeded", "The limit of devices is reached.")
+			return
+		}
+
+		var request deviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+
+		device := s.newDevice()
+		if request.Name != nil {
+			device.Name = request.Name
+		}
+		s.devices[device.Id] = device
+		writeJSON(w, http.StatusCreated, device)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed.")
+	}
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request, id string) {
+	device, ok := s.devices[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "Not found.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, device)
+	case http.MethodPatch, http.MethodPut:
+		var request deviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+
+		if request.Name != nil {
+			device.Name = request.Name
+		}
+
+		if request.Location != nil {
+			location, ok := s.location(*request.Location)
+			if !ok {
+				writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("Location %s does not exist.", *request.Location))
+				return
+			}
+			device.Location = &location
+		}
+		writeJSON(w, http.StatusOK, device)
+	case http.MethodDelete:
+		delete(s.devices, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed.")
+	}
+}
+
+// orgDevices is a method that returns the devices with their owner as listed by GET /v2/org/devices/ to the admins.
+// The user is the only member of the organization.
+func (s *Server) orgDevices() []map[string]interface{} {
+	devices := make([]map[string]interface{}, 0, len(s.devices))
+	for _, device := range s.devices {
+		devices = append(devices, map[string]interface{}{
+			"id":             device.Id,
+			"name":           device.GetName(),
+			"type":           device.GetType(),
+			"owner":          Email,
+			"location":       device.Location,
+			"last_active_at": device.LastActiveAt,
+		})
+	}
+	return devices
+}
+
+// touchSession is a method to record the use of token, starting its session the first time it's seen.
+func (s *Server) touchSession(token string, name string) {
+	now := time.Now()
+	if current, ok := s.sessions[token]; ok {
+		current.LastUsedAt = now
+		return
+	}
+
+	s.sessions[token] = &session{ID: uuid.New().String(), Name: name, CreatedAt: now, LastUsedAt: now}
+}
+
+// listSessions is a method that returns the sessions not revoked, marking the one of token as current.
+func (s *Server) listSessions(token string) []session {
+	sessions := make([]session, 0, len(s.sessions))
+	for t, value := range s.sessions {
+		listed := *value
+		listed.Current = t == token
+		sessions = append(sessions, listed)
+	}
+	return sessions
+}
+
+// revokeSession is a method to end the session with id, rejecting its token from then on.
+func (s *Server) revokeSession(w http.ResponseWriter, id string) {
+	for token, value := range s.sessions {
+		if value.ID == id {
+			delete(s.sessions, token)
+			s.revoked[token] = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "not_found", "Not found.")
+}
+
+// newDevice is a method to create the device in the first location with a fresh Wireguard key pair.
+// The peer points to a documentation address, see RFC 5737, so the tunnel never carries traffic.
+func (s *Server) newDevice() *forestvpn_api.Device {
+	name, kind, location := "device", "cli", s.locations[0]
+	endpoint := "192.0.2.1:51820"
+	now := time.Now()
+	return &forestvpn_api.Device{
+		Id:       uuid.New().String(),
+		Name:     &name,
+		Type:     &kind,
+		Ips:      []string{"10.42.0.2/32", "fd42::2/128"},
+		Dns:      []string{"10.42.0.1"},
+		Location: &location,
+		Wireguard: &forestvpn_api.WireGuard{
+			Id:      uuid.New().String(),
+			PrivKey: randomKey(),
+			PubKey:  randomKey(),
+			Peers: []forestvpn_api.WireGuardPeer{
+				{PubKey: randomKey(), Endpoint: &endpoint, AllowedIps: []string{"0.0.0.0/0", "::/0"}},
+			},
+		},
+		LastActiveAt: &now,
+	}
+}
+
+func (s *Server) location(id string) (forestvpn_api.Location, bool) {
+	for _, location := range s.locations {
+		if location.Id == id {
+			return location, true
+		}
+	}
+
+	return forestvpn_api.Location{}, false
+}
+
+// profile is a method that returns the user along with the Settings of the account.
+func (s *Server) profile() map[string]interface{} {
+	profile := map[string]interface{}{"settings": map[string]interface{}{}}
+	if data, err := json.Marshal(s.user); err == nil {
+		_ = json.Unmarshal(data, &profile)
+	}
+
+	if s.Settings != nil {
+		profile["settings"] = s.Settings
+	}
+	return profile
+}
+
+func (s *Server) countries() []forestvpn_api.Country {
+	seen := make(map[string]bool)
+	var countries []forestvpn_api.Country
+	for _, location := range s.locations {
+		if !seen[location.Country.Id] {
+			seen[location.Country.Id] = true
+			countries = append(countries, location.Country)
+		}
+	}
+
+	return countries
+}
+
+func (s *Server) billingFeatures() []forestvpn_api.BillingFeature {
+	bundle := "com.forestvpn.freemium"
+	if s.Premium {
+		bundle = "com.forestvpn.premium"
+	}
+
+	expiry := time.Now().AddDate(0, 1, 0)
+	return []forestvpn_api.BillingFeature{{BundleId: bundle, ExpiryDate: &expiry}}
+}
+
+func randomKey() string {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func writeError(w http.ResponseWriter, code int, errorCode string, message string) {
+	writeJSON(w, code, forestvpn_api.Error{Code: errorCode, Message: message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
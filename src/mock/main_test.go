@@ -0,0 +1,177 @@
+package mock_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/forestvpn/cli/api"
+	"github.com/forestvpn/cli/mock"
+	"github.com/forestvpn/cli/utils"
+)
+
+func TestServer(t *testing.T) {
+	handler := mock.New()
+	handler.DeviceLimit = 1
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	if err := utils.SetApiURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL)
+	client := api.GetApiClient("demo", u.Host)
+
+	user, err := client.GetUser()
+	if err != nil || user.GetEmail() != mock.Email {
+		t.Fatalf("GetUser = %v, %v", user, err)
+	}
+
+	locations, err := client.GetLocations()
+	if err != nil || len(locations) != len(mock.Locations()) {
+		t.Fatalf("GetLocations = %d locations, %v", len(locations), err)
+	}
+
+	device, err := client.CreateDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.CreateDevice(); !api.IsDeviceLimitError(err) {
+		t.Errorf("expected the device limit error, got %v", err)
+	}
+
+	target := locations[len(locations)-1]
+	device, err = client.UpdateDevice(device.GetId(), target.GetId())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location := device.GetLocation()
+	if location.GetId() != target.GetId() {
+		t.Errorf("expected the device in %s, got %s", target.GetName(), location.GetName())
+	}
+
+	features, err := client.GetBillingFeatures()
+	if err != nil || len(features) != 1 || features[0].GetBundleId() != "com.forestvpn.freemium" {
+		t.Errorf("GetBillingFeatures = %v, %v", features, err)
+	}
+
+	if err := client.DeleteDevice(device.GetId()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetDevice(device.GetId()); err == nil {
+		t.Error("expected the deleted device to be gone")
+	}
+}
+
+func TestServerRevoked(t *testing.T) {
+	handler := mock.New()
+	handler.Revoked = "revoked"
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	if err := utils.SetApiURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL)
+
+	if _, err := api.GetApiClient("revoked", u.Host).GetUser(); !api.IsSessionExpired(err) {
+		t.Errorf("expected the session expired, got %v", err)
+	}
+}
+
+func TestServerAccountSettings(t *testing.T) {
+	handler := mock.New()
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	if err := utils.SetApiURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL)
+	client := api.GetApiClient("demo", u.Host)
+
+	settings, err := client.GetAccountSettings()
+	if err != nil || settings != (api.AccountSettings{}) {
+		t.Fatalf("GetAccountSettings = %+v, %v, expected none", settings, err)
+	}
+
+	handler.Settings = map[string]interface{}{"region": "DE", "killswitch": true}
+	settings, err = client.GetAccountSettings()
+	if err != nil || settings.Region != "DE" || !settings.KillSwitch {
+		t.Errorf("GetAccountSettings = %+v, %v", settings, err)
+	}
+}
+
+func TestServerSessions(t *testing.T) {
+	handler := mock.New()
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	if err := utils.SetApiURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL)
+	client, lost := api.GetApiClient("demo", u.Host), api.GetApiClient("lost", u.Host)
+
+	if _, err := lost.GetUser(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := client.ListSessions()
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListSessions = %+v, %v, expected 2 sessions", sessions, err)
+	}
+
+	var other api.Session
+	for _, session := range sessions {
+		if !session.Current {
+			other = session
+		}
+	}
+
+	if err := client.RevokeSession(other.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lost.GetUser(); !api.IsSessionExpired(err) {
+		t.Errorf("expected the revoked session expired, got %v", err)
+	}
+
+	if err := client.RevokeSession(other.ID); err == nil || api.IsSessionExpired(err) {
+		t.Errorf("expected the revoked session not found, got %v", err)
+	}
+
+	sessions, err = client.ListSessions()
+	if err != nil || len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("ListSessions = %+v, %v, expected the current session only", sessions, err)
+	}
+}
+
+func TestServerOrgDevices(t *testing.T) {
+	handler := mock.New()
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	if err := utils.SetApiURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL)
+	client := api.GetApiClient("demo", u.Host)
+
+	device, err := client.CreateDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := client.ListOrgDevices()
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("ListOrgDevices = %+v, %v, expected 1 device", devices, err)
+	}
+
+	if devices[0].ID != device.GetId() || devices[0].Owner != mock.Email || devices[0].Location == nil {
+		t.Errorf("expected %s of %s with its location, got %+v", device.GetId(), mock.Email, devices[0])
+	}
+}
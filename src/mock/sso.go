@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Organization is the slug of the organization set up for the single sign-on, so sign in with 'fvpn account login --sso demo'.
+// The identity provider is skipped and the user is signed in right away.
+const Organization = "demo"
+
+// handleSSO is a method that serves the authorization and token endpoints of the single sign-on at path.
+func (s *Server) handleSSO(w http.ResponseWriter, r *http.Request, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case path == "token/" && r.Method == http.MethodPost:
+		s.handleSSOToken(w, r)
+	case strings.HasSuffix(path, "/authorize") && r.Method == http.MethodGet:
+		redirectURI, err := url.Parse(r.FormValue("redirect_uri"))
+		if err != nil || redirectURI.Hostname() != "127.0.0.1" || r.FormValue("code_challenge_method") != "S256" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "The loopback redirect URI and the S256 code challenge are required.")
+			return
+		}
+
+		query := url.Values{"state": {r.FormValue("state")}}
+		if org := strings.TrimSuffix(path, "/authorize"); org == Organization {
+			code := uuid.New().String()
+			s.codes[code] = r.FormValue("code_challenge")
+			query.Set("code", code)
+		} else {
+			query.Set("error", "access_denied")
+			query.Set("error_description", "The organization "+org+" is not set up for single sign-on.")
+		}
+		redirectURI.RawQuery = query.Encode()
+		http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not found.")
+	}
+}
+
+// handleSSOToken is a method that exchanges the code for the tokens, or refreshes them, the same way as the OAuth token endpoint.
+func (s *Server) handleSSOToken(w http.ResponseWriter, r *http.Request) {
+	switch r.FormValue("grant_type") {
+	case "authorization_code":
+		challenge, ok := s.codes[r.FormValue("code")]
+		delete(s.codes, r.FormValue("code"))
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "refresh_token":
+		if len(r.FormValue("refresh_token")) == 0 || r.FormValue("refresh_token") == s.Revoked {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  uuid.New().String(),
+		"refresh_token": uuid.New().String(),
+		"expires_in":    3600,
+	})
+}
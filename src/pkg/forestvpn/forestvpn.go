@@ -0,0 +1,647 @@
+// forestvpn is a package that lets other Go programs control ForestVPN connections without shelling out to fvpn.
+// It wraps the auth, api and actions packages and reports every outcome as a value or an error:
+// it never prints to the standard output and never exits the process.
+package forestvpn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/api"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/utils"
+)
+
+// DefaultInterface is a name of the Wireguard interface used by ForestVPN.
+const DefaultInterface = "fvpn0"
+
+// WakeThreshold is a sleep of the system after which Resume revives the connection.
+// Wireguard rejects the session keys after 3 minutes, so the peers handshake again after longer sleeps.
+const WakeThreshold = 3 * time.Minute
+
+var (
+	// ErrAlreadyConnected is returned by Connect when the Wireguard connection is already up.
+	ErrAlreadyConnected = errors.New("state is already up")
+	// ErrNotConnected is returned by Disconnect when the Wireguard connection is already down.
+	ErrNotConnected = errors.New("state is already down")
+	// ErrSubscriptionExpired is returned when the user's billing feature is expired.
+	ErrSubscriptionExpired = errors.New("subscription expired")
+	// ErrPremiumRequired is returned when the location requires a paid subscription.
+	ErrPremiumRequired = errors.New("location requires a paid subscription")
+	// ErrLocationNotFound is returned when no location matches the given UUID, slug or name.
+	ErrLocationNotFound = errors.New("no such location")
+)
+
+// Location is a structure representing a ForestVPN location.
+type Location struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
+	// Slug is a short identifier of the location accepted along with the UUID and name, e.g. de-fra.
+	Slug    string `json:"slug"`
+	Premium bool   `json:"premium"`
+	// Usable is true if the plan of the user allows to connect to the location.
+	Usable bool `json:"usable"`
+	// Quality is a connection quality reported by back-end from 0 to 1, if any.
+	Quality *float64 `json:"quality,omitempty"`
+}
+
+// Status is a structure representing the state of the ForestVPN connection and the subscription of the user.
+// FailedOverFrom is the name of the location the connection was switched from by Failover, if any.
+type Status struct {
+	Connected      bool      `json:"connected"`
+	Location       Location  `json:"location"`
+	FailedOverFrom string    `json:"failed_over_from,omitempty"`
+	Email          string    `json:"email"`
+	Plan           string    `json:"plan"`
+	ExpiryDate     time.Time `json:"expiry_date"`
+}
+
+// Client is a structure to control ForestVPN on behalf of the logged-in user.
+type Client struct {
+	profile *auth.Profile
+	wrapper actions.AuthClientWrapper
+	state   actions.State
+	monitor actions.HandshakeMonitor
+	wake    *utils.WakeDetector
+	// supervisor revives the connection set up with 'fvpn state up --auto-reconnect', see Supervise.
+	supervisor *actions.Supervisor
+	// keepalive is the persistent keepalive last set by AdjustKeepalive.
+	keepalive string
+	// device is the device last seen by Reload.
+	device *forestvpn_api.Device
+	// network is the NetworkFingerprint last seen by Roam.
+	network string
+}
+
+// NewClient is a factory function that returns the Client of the current user profile, signed in with 'fvpn account login' before.
+// It returns auth.ErrNotLoggedIn if there is none, and auth.ErrSignInRequired if the profile has to sign in in the browser again,
+// as the Client never opens the browser. apiHost defaults to utils.ApiHost when empty.
+func NewClient(ctx context.Context, apiHost string) (*Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(apiHost) == 0 {
+		apiHost = utils.ApiHost
+	}
+
+	db, err := auth.LoadUserDB()
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := db.SignedInUser()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := profile.UnattendedAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := actions.AuthClientWrapper{ApiClient: api.GetApiClient(token, apiHost)}
+	return &Client{
+		profile: profile,
+		wrapper: wrapper,
+		state:   actions.State{WiregaurdInterface: DefaultInterface, Out: io.Discard},
+		monitor: actions.HandshakeMonitor{WiregaurdInterface: DefaultInterface},
+		wake:    utils.NewWakeDetector(WakeThreshold),
+		supervisor: &actions.Supervisor{
+			Wrapper: wrapper,
+			State:   &actions.State{WiregaurdInterface: DefaultInterface, Reason: "auto-reconnect", Out: io.Discard},
+		},
+	}, nil
+}
+
+// Locations is a method to get all the locations available at back-end.
+func (c *Client) Locations(ctx context.Context) ([]Location, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	locations, err := c.wrapper.GetLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	entitlements, err := c.wrapper.GetEntitlements(c.profile.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Location, 0, len(locations))
+	for _, loc := range entitlements.Apply(actions.GetLocationWrappers(locations)) {
+		result = append(result, newLocation(loc))
+	}
+
+	return result, nil
+}
+
+// SetLocation is a method to set the default location by its UUID, slug or name.
+// If the connection is up, it's switched to the new location on the fly where supported,
+// otherwise SetLocation fails with ErrAlreadyConnected.
+func (c *Client) SetLocation(ctx context.Context, arg string) (Location, error) {
+	if err := ctx.Err(); err != nil {
+		return Location{}, err
+	}
+
+	connected := c.state.GetStatus()
+	if connected && !c.state.CanReconfigure() {
+		return Location{}, ErrAlreadyConnected
+	}
+
+	locations, err := c.wrapper.GetLocations()
+	if err != nil {
+		return Location{}, err
+	}
+
+	location, found := actions.FindLocation(actions.GetLocationWrappers(locations), arg)
+	if !found {
+		return Location{}, ErrLocationNotFound
+	}
+
+	b, err := c.wrapper.GetUnexpiredOrMostRecentBillingFeature(c.profile.ID)
+	if err != nil {
+		return Location{}, err
+	}
+
+	if time.Now().After(b.GetExpiryDate()) {
+		return Location{}, ErrSubscriptionExpired
+	}
+
+	if !actions.NewEntitlements(b).Allows(location.Location.GetId()) {
+		return Location{}, ErrPremiumRequired
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Location{}, err
+	}
+
+	oldDevice, err := auth.LoadDevice(c.profile.ID)
+	if err != nil {
+		return Location{}, err
+	}
+
+	// the running connection could be failed over to the standby device
+	running, err := actions.ConnectedDevice(c.profile.ID)
+	if err != nil {
+		return Location{}, err
+	}
+
+	device, err := c.wrapper.ApiClient.UpdateDevice(oldDevice.GetId(), location.Location.GetId())
+	if err != nil {
+		return Location{}, err
+	}
+
+	if err := auth.UpdateProfileDevice(device, c.profile.ID); err != nil {
+		return Location{}, err
+	}
+
+	if !utils.IsOpenWRT() {
+		if err := c.wrapper.SetLocation(device, c.profile.ID); err != nil {
+			return Location{}, err
+		}
+	}
+	actions.ClearFailoverEvent(c.profile.ID)
+
+	if utils.NMConnectionExists(c.state.WiregaurdInterface) {
+		path := auth.ProfilesDir + string(c.profile.ID) + auth.WireguardConfig
+		if err := utils.NMImport(c.state.WiregaurdInterface, path); err != nil {
+			return Location{}, err
+		}
+	}
+
+	if connected {
+		if err := c.state.Reconfigure(c.profile.ID, running, device); err != nil {
+			return Location{}, err
+		}
+	}
+
+	return newLocation(location), nil
+}
+
+// Connect is a method to establish the Wireguard connection to the default location.
+// If persist is true, the connection survives reboots where supported.
+func (c *Client) Connect(ctx context.Context, persist bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.state.GetStatus() {
+		return ErrAlreadyConnected
+	}
+
+	b, err := c.wrapper.GetUnexpiredOrMostRecentBillingFeature(c.profile.ID)
+	if err != nil {
+		return err
+	}
+
+	if time.Now().After(b.GetExpiryDate()) {
+		return ErrSubscriptionExpired
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := c.state.SetUp(c.profile.ID, persist); err != nil {
+		return err
+	}
+
+	if !c.state.GetStatus() {
+		return errors.New("unexpected error: state.status is false after state is up")
+	}
+
+	return nil
+}
+
+// Disconnect is a method to terminate the Wireguard connection.
+func (c *Client) Disconnect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !c.state.GetStatus() {
+		return ErrNotConnected
+	}
+
+	// the supervisor of 'fvpn state up --auto-reconnect' would take it for a drop
+	actions.ClearSupervision(c.profile.ID)
+	if err := c.state.SetDown(c.profile.ID); err != nil {
+		return err
+	}
+
+	if c.state.GetStatus() {
+		return errors.New("unexpected error: state.status is true after state is down")
+	}
+
+	if err := c.state.DisableKillSwitch(c.profile.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Status is a method to get the state of the connection along with the subscription of the user.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	status := Status{Email: string(c.profile.Email)}
+	if err := ctx.Err(); err != nil {
+		return status, err
+	}
+
+	status.Connected = c.state.GetStatus()
+
+	device, err := actions.ConnectedDevice(c.profile.ID)
+	if err != nil {
+		return status, err
+	}
+
+	location := device.GetLocation()
+	status.Location = newLocation(actions.LocationWrapper{Location: location, Premium: actions.IsPremiumLocation(location), Slug: actions.LocationSlug(location)})
+
+	if event, ok := actions.LoadFailoverEvent(c.profile.ID); ok && status.Connected {
+		status.FailedOverFrom = event.From
+	}
+
+	b, err := c.wrapper.GetUnexpiredOrMostRecentBillingFeature(c.profile.ID)
+	if err != nil {
+		return status, err
+	}
+
+	status.ExpiryDate = b.GetExpiryDate()
+	status.Plan = planName(b)
+	return status, nil
+}
+
+// Failover is a method to switch the connection to the standby location once the connected location stops handshaking.
+// standby is either auto to pick the next-best location, or the UUID, slug or name of the location.
+// It's meant to be called every few seconds and returns true if the connection was switched.
+// The connection set up with 'fvpn state up --auto-reconnect' is left to Supervise, which fails over after --failover-after reconnects.
+func (c *Client) Failover(ctx context.Context, standby string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if !c.state.GetStatus() || !c.state.CanReconfigure() {
+		return false, nil
+	}
+
+	if _, supervised := actions.LoadSupervision(c.profile.ID); supervised {
+		return false, nil
+	}
+
+	stalled, err := c.monitor.Stalled()
+	if err != nil || !stalled {
+		return false, err
+	}
+
+	return true, c.switchLocation(ctx, standby)
+}
+
+// AutoSwitch is a method to switch the connection to the next-best location once its quality stays degraded according to the policy of monitor.
+// It's meant to be called every few seconds; every call probes through the tunnel and records the sample for 'fvpn monitor report'.
+// Returns true if the connection was switched.
+func (c *Client) AutoSwitch(ctx context.Context, monitor *actions.QualityMonitor) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if !c.state.GetStatus() || !c.state.CanReconfigure() {
+		monitor.Reset()
+		return false, nil
+	}
+
+	sample, err := c.state.TakeSample(c.profile.ID, actions.MonitorTarget, 3)
+	if err != nil {
+		return false, err
+	}
+
+	if err := actions.AppendSample(c.profile.ID, sample); err != nil {
+		return false, err
+	}
+
+	if !monitor.Observe(sample) {
+		return false, nil
+	}
+
+	if err := c.switchLocation(ctx, "auto"); err != nil {
+		return false, err
+	}
+
+	monitor.Switched(time.Now())
+	return true, nil
+}
+
+// switchLocation is a method to switch the connection to the standby location with the standby device, see actions.AuthClientWrapper.FailOver.
+func (c *Client) switchLocation(ctx context.Context, standby string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	device, err := actions.ConnectedDevice(c.profile.ID)
+	if err != nil {
+		return err
+	}
+
+	locations, err := c.wrapper.GetLocations()
+	if err != nil {
+		return err
+	}
+
+	b, err := c.wrapper.GetUnexpiredOrMostRecentBillingFeature(c.profile.ID)
+	if err != nil {
+		return err
+	}
+
+	current := device.GetLocation()
+	premium := b.GetBundleId() != "com.forestvpn.freemium"
+	location, found := actions.StandbyLocation(actions.GetLocationWrappers(locations), current, standby, premium)
+	if !found || location.Location.GetId() == current.GetId() {
+		return ErrLocationNotFound
+	}
+
+	if !actions.NewEntitlements(b).Allows(location.Location.GetId()) {
+		return ErrPremiumRequired
+	}
+
+	_, err = c.wrapper.FailOver(c.profile.ID, &c.state, location.Location)
+	return err
+}
+
+// WatchWake is a method to detect the wakes for Resume with the power events of the system until ctx is done, i.e. logind on Linux.
+// Returns the error if they aren't available, Resume compares the wall clock then.
+func (c *Client) WatchWake(ctx context.Context) error {
+	return c.wake.Watch(ctx)
+}
+
+// Supervise is a method to revive the connection set up with 'fvpn state up --auto-reconnect' once it drops, see actions.Supervisor.Check.
+// It's meant to be called every few seconds, in place of 'fvpn state supervise' while the daemon is running.
+// Returns the reconnect attempted, or nil if there was none.
+func (c *Client) Supervise(ctx context.Context) (*actions.ReconnectEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.supervisor.Check(c.profile.ID)
+}
+
+// Resume is a method to revive the connection after the system wakes up from sleep, as the tunnel often stays dead until it's cycled.
+// It's meant to be called every few seconds. The peers are asked to handshake first, and the tunnel is rebuilt if they don't respond.
+// Returns true if the system woke up with the connection active.
+func (c *Client) Resume(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	woke := time.Now()
+	if !c.wake.Woke() || !c.state.GetStatus() {
+		return false, nil
+	}
+
+	device, err := actions.ConnectedDevice(c.profile.ID)
+	if err != nil {
+		return true, err
+	}
+
+	// the handshake timestamps are in seconds
+	if c.state.AwaitHandshakeSince(device, woke.Add(-time.Second), actions.HandshakeTimeout) || utils.IsOpenWRT() {
+		return true, nil
+	}
+
+	if err := c.state.SetDown(c.profile.ID); err != nil {
+		return true, err
+	}
+
+	return true, c.state.SetUp(c.profile.ID, false)
+}
+
+// Roam is a method to follow the host to another network, e.g. from Wi-Fi to an IPv6-only carrier, where the endpoints may need NAT64.
+// It's meant to be called every few seconds. The peers are asked to handshake first, and the connection is set up anew if they don't respond,
+// which rewrites the endpoints for the new network, see actions.RefreshEndpoints. Returns true if the network changed with the connection active.
+func (c *Client) Roam(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	network := utils.NetworkFingerprint(DefaultInterface)
+	changed := len(c.network) > 0 && network != c.network
+	c.network = network
+	if !changed || !c.state.GetStatus() {
+		return false, nil
+	}
+
+	device, err := actions.ConnectedDevice(c.profile.ID)
+	if err != nil {
+		return true, err
+	}
+
+	// the handshake timestamps are in seconds
+	if c.state.AwaitHandshakeSince(device, time.Now().Add(-time.Second), actions.HandshakeTimeout) || utils.IsOpenWRT() {
+		return true, nil
+	}
+
+	if err := c.state.SetDown(c.profile.ID); err != nil {
+		return true, err
+	}
+
+	return true, c.state.SetUp(c.profile.ID, false)
+}
+
+// AdjustKeepalive is a method to switch the persistent keepalive of the connection between the keepalive and battery-keepalive settings
+// as the system switches between the mains and battery. It's meant to be called every few seconds.
+func (c *Client) AdjustKeepalive(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	keepalive := actions.Keepalive()
+	if keepalive == c.keepalive || !c.state.GetStatus() || utils.IsOpenWRT() {
+		return nil
+	}
+
+	device, err := auth.LoadDevice(c.profile.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.state.ApplyKeepalive(device, keepalive); err != nil {
+		return err
+	}
+
+	c.keepalive = keepalive
+	return nil
+}
+
+// Reload is a method to apply the settings and the location changed with the CLI to the running connection without restarting it:
+// the Wireguard configuration is rewritten with the current settings, and the peers and routes are updated on the fly,
+// or the connection is cycled if the addresses or DNS changed. Returns true if the connection was changed.
+func (c *Client) Reload(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	device, err := actions.ConnectedDevice(c.profile.ID)
+	if err != nil {
+		return false, err
+	}
+
+	previous := c.device
+	c.device = device
+	if !c.state.GetStatus() || utils.IsOpenWRT() {
+		return false, nil
+	}
+
+	path := auth.ProfilesDir + string(c.profile.ID) + auth.WireguardConfig
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	if err := c.wrapper.SetLocation(device, c.profile.ID); err != nil {
+		return false, err
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	// the location set with the CLI is already applied by it
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	if previous == nil {
+		previous = device
+	}
+
+	if !c.state.CanReconfigure() {
+		if err := c.state.SetDown(c.profile.ID); err != nil {
+			return false, err
+		}
+		return true, c.state.SetUp(c.profile.ID, false)
+	}
+
+	return true, c.state.Reconfigure(c.profile.ID, previous, device)
+}
+
+// EnforceQuota is a method to account the data transferred through the connection to the monthly quota set with 'fvpn quota set'
+// and to disconnect once it's used up, if asked to. It's meant to be called every few seconds.
+// Returns the quota, the threshold reached since the last call, or 0, and whether the connection was set down.
+func (c *Client) EnforceQuota(ctx context.Context) (actions.Quota, int, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return actions.Quota{}, 0, false, err
+	}
+
+	if !c.state.GetStatus() {
+		return actions.Quota{}, 0, false, nil
+	}
+
+	quota, reached, err := c.state.RecordUsage(c.profile.ID)
+	if err != nil || !quota.Exceeded() || !quota.Disconnect {
+		return quota, reached, false, err
+	}
+
+	return quota, reached, true, c.Disconnect(ctx)
+}
+
+// EnforceIdle is a method to set the connection down once no traffic went through it for the timeout of the monitor.
+// It's meant to be called every few seconds with the same monitor. Returns whether the connection was set down.
+func (c *Client) EnforceIdle(ctx context.Context, monitor *actions.IdleMonitor) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if !c.state.GetStatus() {
+		monitor.Reset()
+		return false, nil
+	}
+
+	rx, tx, err := utils.WireguardTransfer(c.state.WiregaurdInterface)
+	if err != nil || !monitor.Observe(rx, tx, time.Now()) {
+		return false, err
+	}
+
+	monitor.Reset()
+	c.state.Reason = "idle"
+	defer func() { c.state.Reason = "" }()
+	return true, c.Disconnect(ctx)
+}
+
+// RestoreRoutes is a method to reinstall the routes of the connection removed from the host, e.g. by the DHCP client on a lease renewal,
+// as the traffic would leak past the tunnel otherwise. It's meant to be called every few seconds. Returns the networks restored.
+func (c *Client) RestoreRoutes(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.state.RestoreRoutes(c.profile.ID)
+}
+
+func newLocation(loc actions.LocationWrapper) Location {
+	country := loc.Location.GetCountry()
+	return Location{
+		ID:      loc.Location.GetId(),
+		Name:    loc.Location.GetName(),
+		Country: country.GetName(),
+		Slug:    loc.Slug,
+		Premium: loc.Premium,
+		Usable:  loc.Usable,
+		Quality: loc.Location.LatencyRate,
+	}
+}
+
+// planName is a function to extract the plan name out of the bundle id, e.g. "premium" out of "com.forestvpn.premium".
+func planName(b forestvpn_api.BillingFeature) string {
+	bid := b.GetBundleId()
+	return bid[strings.LastIndex(bid, ".")+1:]
+}
@@ -0,0 +1,122 @@
+package forestvpn_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/forestvpn/cli/api"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/mock"
+	"github.com/forestvpn/cli/pkg/forestvpn"
+	"github.com/forestvpn/cli/secrets"
+	"github.com/forestvpn/cli/utils"
+)
+
+// useTempAppDir is a function to keep the profiles of the test in a temporary directory and serve the API with the mock server.
+func useTempAppDir(t *testing.T) {
+	dir := t.TempDir()
+	appDir, profilesDir, machineTokens := auth.AppDir, auth.ProfilesDir, auth.MachineTokens
+	scheme, host := utils.ApiScheme, utils.ApiHost
+	auth.AppDir, auth.ProfilesDir = dir+"/", dir+"/profiles/"
+	auth.MachineTokens = secrets.NewFileStore(filepath.Join(dir, "machine-tokens"))
+
+	server := httptest.NewServer(mock.New())
+	if err := utils.SetApiURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		server.Close()
+		auth.AppDir, auth.ProfilesDir, auth.MachineTokens = appDir, profilesDir, machineTokens
+		utils.ApiScheme, utils.ApiHost = scheme, host
+	})
+}
+
+// login is a function to sign a profile in with a machine token, like 'fvpn account login --token demo'.
+func login(t *testing.T) *auth.Profile {
+	profile := auth.OpenUserDB().CreateUser()
+	if err := profile.LoginWith(auth.TokenProvider{}, "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.SignIn(utils.ApiHost); err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+// captureStdout is a function to run f with the standard output redirected, returning what it printed.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestNewClientNotLoggedIn(t *testing.T) {
+	useTempAppDir(t)
+
+	if _, err := forestvpn.NewClient(context.Background(), ""); !errors.Is(err, auth.ErrNotLoggedIn) {
+		t.Errorf("expected ErrNotLoggedIn, got %v", err)
+	}
+
+	// no profile is created to sign in
+	if _, err := os.Stat(filepath.Join(auth.AppDir, auth.AccountsMapFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the accounts map file not to be created, got %v", err)
+	}
+}
+
+func TestNewClientSessionExpired(t *testing.T) {
+	useTempAppDir(t)
+	login(t).MarkSessionExpired()
+
+	if _, err := forestvpn.NewClient(context.Background(), ""); !errors.Is(err, api.ErrSessionExpired) {
+		t.Errorf("expected ErrSessionExpired, got %v", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	useTempAppDir(t)
+	login(t)
+
+	var locations []forestvpn.Location
+	var err error
+	output := captureStdout(t, func() {
+		var client *forestvpn.Client
+		if client, err = forestvpn.NewClient(context.Background(), ""); err == nil {
+			locations, err = client.Locations(context.Background())
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) == 0 {
+		t.Error("expected the locations of the mock server")
+	}
+	if len(output) > 0 {
+		t.Errorf("expected nothing printed, got %q", output)
+	}
+}
+
+func TestNewClientCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := forestvpn.NewClient(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
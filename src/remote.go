@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/pkg/forestvpn"
+	"github.com/forestvpn/cli/schema"
+	"github.com/forestvpn/cli/server"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+// remoteController is a function that returns the client of the remote daemon set with --host or 'fvpn config set daemon-address'.
+// Inside WSL2 it falls back to the daemon of the Windows host, see actions.WSLDaemonAddress.
+// Returns nil if the commands should control the local machine.
+func remoteController(c *cli.Context) (*server.Client, error) {
+	conf, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	address := c.String("host")
+	if len(address) == 0 {
+		address = conf.Get(config.DaemonAddress)
+	}
+
+	if len(address) == 0 && utils.IsWSL2() {
+		address, err = actions.WSLDaemonAddress(conf.Get(config.WSL))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(address) == 0 {
+		return nil, nil
+	}
+
+	token := c.String("daemon-token")
+	if len(token) == 0 {
+		token = conf.Get(config.DaemonToken)
+	}
+
+	if len(token) == 0 && !server.Authenticates(address) {
+		return nil, errors.New("daemon token required, try 'fvpn config set daemon-token TOKEN'")
+	}
+
+	return server.NewClient(address, token, conf.Get(config.DaemonCA))
+}
+
+func remoteUp(c *cli.Context, remote *server.Client) error {
+	err := remote.Connect(c.Context, c.Bool("persist"))
+	if errors.Is(err, forestvpn.ErrAlreadyConnected) {
+		fmt.Println("State is already up and running")
+		os.Exit(1)
+	} else if errors.Is(err, forestvpn.ErrSubscriptionExpired) {
+		fmt.Printf("Your subscription is over. You can keep using ForestVPN once you watch an ad in our mobile app, or simply go Premium at %s.\n", url)
+		os.Exit(1)
+	} else if err != nil {
+		return err
+	}
+
+	return remoteStatus(c, remote)
+}
+
+func remoteDown(c *cli.Context, remote *server.Client) error {
+	err := remote.Disconnect(c.Context)
+	if errors.Is(err, forestvpn.ErrNotConnected) {
+		fmt.Println("State is already down")
+		os.Exit(1)
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Println("Disconnected")
+	return nil
+}
+
+func remoteStatus(c *cli.Context, remote *server.Client) error {
+	status, err := remote.Status(c.Context)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput(c) {
+		connection := actions.ConnectionStatus{SchemaVersion: schema.Version, Connected: status.Connected, Interface: "fvpn0"}
+		if status.Connected {
+			connection.Location, connection.LocationID, connection.Country = status.Location.Name, status.Location.ID, status.Location.Country
+			connection.FailedOverFrom = status.FailedOverFrom
+		}
+		return printJSON(connection)
+	}
+
+	if status.Connected {
+		fmt.Printf("Connected to %s, %s\n", status.Location.Name, status.Location.Country)
+		if len(status.FailedOverFrom) > 0 {
+			fmt.Printf("Failed over from %s\n", status.FailedOverFrom)
+		}
+	} else {
+		fmt.Println("Disconnected")
+	}
+
+	return nil
+}
+
+func remoteLocations(c *cli.Context, remote *server.Client, country string, availableOnly bool, usableOnly bool) error {
+	var data [][]string
+	entries := []actions.LocationEntry{}
+
+	locations, err := remote.Locations(c.Context)
+	if err != nil {
+		return err
+	}
+
+	patterns := utils.SplitPatterns(c.Args().Slice())
+	for _, loc := range locations {
+		if len(country) > 0 && !utils.MatchCountry(country, "", loc.Country) {
+			continue
+		}
+
+		if !utils.GlobMatch(patterns, loc.Slug, loc.Name, loc.Country, loc.ID) {
+			continue
+		}
+
+		if availableOnly && !actions.IsAvailable(loc.Quality) || usableOnly && !loc.Usable {
+			continue
+		}
+
+		if jsonOutput(c) {
+			entries = append(entries, actions.LocationEntry{ID: loc.ID, Name: loc.Name, Country: loc.Country, Slug: loc.Slug, Premium: loc.Premium, Usable: loc.Usable, Quality: loc.Quality})
+			continue
+		}
+
+		premiumMark, usableMark := "", ""
+		if loc.Premium {
+			premiumMark = "*"
+		}
+		if loc.Usable {
+			usableMark = "yes"
+		}
+		data = append(data, []string{loc.Name, loc.Country, loc.Slug, loc.ID, premiumMark, usableMark, actions.FormatQuality(loc.Quality)})
+	}
+
+	if jsonOutput(c) {
+		return printJSON(entries)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"City", "Country", "Slug", "UUID", "Premium", "Usable", "Quality"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,28 @@
+// schema is a package containing the JSON schemas of the output of 'fvpn state status --json' and 'fvpn account status --json'
+// that dashboards and scripts rely on. The output carries the schemaVersion of its schema, and only gains fields within a version:
+// a field is never removed, renamed or retyped without bumping Version.
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+// Version is the schemaVersion of the JSON output described by the schemas.
+const Version = 1
+
+// Names are the names of the schemas accepted by Get.
+var Names = []string{"state-status", "account-status"}
+
+//go:embed *.json
+var schemas embed.FS
+
+// Get is a function that returns the JSON schema of the output of the command with the name given, e.g. state-status.
+func Get(name string) ([]byte, error) {
+	data, err := schemas.ReadFile(name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no schema named %s, must be %s", name, strings.Join(Names, " or "))
+	}
+	return data, nil
+}
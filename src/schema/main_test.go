@@ -0,0 +1,134 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/schema"
+)
+
+// outputs are the outputs with every field set, keyed by the name of their schema.
+func outputs() map[string]interface{} {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	deadline := since.Add(2 * time.Hour)
+
+	return map[string]interface{}{
+		"state-status": actions.ConnectionStatus{
+			SchemaVersion:  schema.Version,
+			Connected:      true,
+			Interface:      "fvpn0",
+			Location:       "Frankfurt",
+			LocationID:     "2a6e0e4a-3a4b-4a1e-9a8e-6c1f5d0b1a01",
+			Country:        "Germany",
+			CountryCode:    "DE",
+			Since:          &since,
+			FailedOverFrom: "Helsinki",
+			DisconnectsAt:  &deadline,
+			Label:          "work-sync",
+			AutoReconnect:  true,
+			Reconnects: []actions.ReconnectEvent{
+				{At: since.Add(time.Hour), Cause: "interface gone", Action: "reconnect", Location: "Frankfurt", Attempt: 1, OK: true},
+			},
+		},
+		"account-status": actions.AccountStatus{
+			SchemaVersion: schema.Version,
+			Email:         "user@example.com",
+			Plan:          "premium",
+			ExpiryDate:    since.AddDate(1, 0, 0),
+			Expired:       false,
+			EmailVerified: true,
+			Devices:       2,
+		},
+	}
+}
+
+func decode(t *testing.T, v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+// TestGolden checks that the output still has every field of the golden file of the schema version with the same value,
+// so the fields are only added within a version.
+func TestGolden(t *testing.T) {
+	for name, output := range outputs() {
+		data, err := os.ReadFile(fmt.Sprintf("testdata/%s.v%d.json", name, schema.Version))
+		if err != nil {
+			t.Fatalf("%s: %s, a new schema version needs a golden file", name, err)
+		}
+
+		var golden map[string]interface{}
+		if err := json.Unmarshal(data, &golden); err != nil {
+			t.Fatal(err)
+		}
+
+		actual := decode(t, output)
+		for key, value := range golden {
+			if !reflect.DeepEqual(actual[key], value) {
+				t.Errorf("%s: %s is %v, expected %v", name, key, actual[key], value)
+			}
+		}
+	}
+}
+
+// TestSchema checks that the schema declares every field of the output with its type and requires no field the output leaves out.
+func TestSchema(t *testing.T) {
+	types := map[string]string{"string": "string", "float64": "number", "bool": "boolean", "slice": "array"}
+
+	for name, output := range outputs() {
+		data, err := schema.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var document struct {
+			Required   []string `json:"required"`
+			Properties map[string]struct {
+				Type  string      `json:"type"`
+				Const interface{} `json:"const"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		actual := decode(t, output)
+		for key, value := range actual {
+			property, ok := document.Properties[key]
+			if !ok {
+				t.Errorf("%s: %s is not in the schema", name, key)
+				continue
+			}
+
+			kind := types[reflect.TypeOf(value).Kind().String()]
+			if property.Type != kind && !(property.Type == "integer" && kind == "number") {
+				t.Errorf("%s: %s is %s, the schema has %s", name, key, kind, property.Type)
+			}
+			if property.Const != nil && !reflect.DeepEqual(property.Const, value) {
+				t.Errorf("%s: %s is %v, the schema has %v", name, key, value, property.Const)
+			}
+		}
+
+		for _, key := range document.Required {
+			if _, ok := actual[key]; !ok {
+				t.Errorf("%s: required %s is missing", name, key)
+			}
+		}
+	}
+
+	if _, err := schema.Get("device-status"); err == nil {
+		t.Error("expected an unknown schema to fail")
+	}
+}
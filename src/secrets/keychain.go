@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeychainService is a service name the secrets are stored under in the keychain.
+const KeychainService = "fvpn"
+
+// KeychainStore is a structure that stores the secrets in the macOS Keychain with 'security',
+// or in the Secret Service, e.g. GNOME Keyring, with 'secret-tool' on Linux.
+type KeychainStore struct{}
+
+// NewKeychainStore is a factory function that returns the KeychainStore if the keychain tool of the system is installed.
+func NewKeychainStore() (*KeychainStore, error) {
+	tool := "secret-tool"
+	switch runtime.GOOS {
+	case "darwin":
+		tool = "security"
+	case "linux":
+	default:
+		return nil, fmt.Errorf("keychain secret backend is not supported on %s", runtime.GOOS)
+	}
+
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%s not found, it's needed for the keychain secret backend", tool)
+	}
+
+	return &KeychainStore{}, nil
+}
+
+// Load is a method to read the secret stored under key.
+func (s *KeychainStore) Load(key string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", KeychainService, "-a", key, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", KeychainService, "key", key)
+	}
+
+	stdout, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// both tools exit with 1 if nothing matches
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// Save is a method to store value under key. The value is passed on the standard input to keep it out of the process list.
+func (s *KeychainStore) Save(key string, value string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %q -w %q\n", KeychainService, key, value))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", KeychainService+" "+key, "service", KeychainService, "key", key)
+		cmd.Stdin = strings.NewReader(value)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store %s in the keychain: %s", key, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Delete is a method to remove the secret stored under key, if any.
+func (s *KeychainStore) Delete(key string) error {
+	if runtime.GOOS == "darwin" {
+		// fails if there is nothing to delete
+		_ = exec.Command("security", "delete-generic-password", "-s", KeychainService, "-a", key).Run()
+		return nil
+	}
+
+	return exec.Command("secret-tool", "clear", "service", KeychainService, "key", key).Run()
+}
@@ -0,0 +1,92 @@
+// secrets is a package containing the storages of the refresh and machine tokens of the profiles, picked with 'fvpn config set secret-backend',
+// so fleet servers could keep them in Vault or AWS SSM Parameter Store rather than on the local disk.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by Store.Load when there is no secret stored under the key.
+var ErrNotFound = errors.New("secret not found")
+
+// Store is an interface of the storage of the secrets by key.
+type Store interface {
+	Load(key string) (string, error)
+	Save(key string, value string) error
+	Delete(key string) error
+}
+
+// Open is a factory function that returns the Store of the backend:
+// file, keychain, vault://MOUNT/PATH for the KV v2 secrets engine of Vault, or ssm:///PATH or ssm://REGION/PATH for AWS SSM Parameter Store.
+// The file backend keeps the secrets in dir.
+func Open(backend string, dir string) (Store, error) {
+	switch backend {
+	case "file":
+		return NewFileStore(dir), nil
+	case "keychain":
+		return NewKeychainStore()
+	}
+
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "vault":
+		return NewVaultStore(u.Host + u.Path)
+	case "ssm":
+		return NewSSMStore(u.Host, u.Path)
+	}
+
+	return nil, fmt.Errorf("unsupported secret backend: %s, must be file, keychain, vault://MOUNT/PATH or ssm:///PATH", backend)
+}
+
+// FileStore is a structure that stores every secret in its own file readable by the owner only.
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore is a factory function that returns the FileStore keeping the secrets in dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// Load is a method to read the secret stored under key.
+func (s *FileStore) Load(key string) (string, error) {
+	data, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Save is a method to store value under key.
+func (s *FileStore) Save(key string, value string) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path(key), []byte(value), 0600)
+}
+
+// Delete is a method to remove the secret stored under key, if any.
+func (s *FileStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.Dir, filepath.Base(key))
+}
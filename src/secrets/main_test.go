@@ -0,0 +1,112 @@
+package secrets_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/forestvpn/cli/secrets"
+)
+
+func testStore(t *testing.T, store secrets.Store) {
+	if _, err := store.Load("token"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("expected %v, got %v", secrets.ErrNotFound, err)
+	}
+
+	if err := store.Save("token", "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := store.Load("token"); err != nil || value != "s3cr3t" {
+		t.Errorf("expected s3cr3t, got %q, %v", value, err)
+	}
+
+	if err := store.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Load("token"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("expected %v after delete, got %v", secrets.ErrNotFound, err)
+	}
+
+	if err := store.Delete("token"); err != nil {
+		t.Errorf("expected deleting a missing secret to succeed, got %v", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	testStore(t, secrets.NewFileStore(t.TempDir()))
+}
+
+// fakeVault is a handler serving the subset of the KV v2 secrets engine used by VaultStore.
+func fakeVault(t *testing.T, token string) http.Handler {
+	var mu sync.Mutex
+	values := make(map[string]string)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		key := strings.NewReplacer("/metadata/", "/", "/data/", "/").Replace(r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			value, ok := values[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": map[string]string{"value": value}}})
+		case http.MethodPost:
+			var body struct {
+				Data map[string]string `json:"data"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			values[key] = body.Data["value"]
+			_, _ = w.Write([]byte(`{"data":{"version":1}}`))
+		case http.MethodDelete:
+			delete(values, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+func TestVaultStore(t *testing.T) {
+	ts := httptest.NewServer(fakeVault(t, "root"))
+	defer ts.Close()
+
+	t.Setenv("VAULT_ADDR", ts.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	store, err := secrets.Open("vault://secret/fvpn/fleet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	store, err = secrets.Open("vault://secret/fvpn", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("token"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	for _, backend := range []string{"plaintext", "vault://", "s3://bucket/fvpn"} {
+		if _, err := secrets.Open(backend, ""); err == nil {
+			t.Errorf("expected an error for %s", backend)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SSMStore is a structure that stores the secrets as SecureString parameters of AWS SSM Parameter Store under Path/key with the AWS CLI,
+// so the credentials are taken from its usual chain, e.g. the instance profile of an EC2 server.
+type SSMStore struct {
+	Region string
+	Path   string
+}
+
+// NewSSMStore is a factory function that returns the SSMStore for the parameters under path, in region or the default region of the AWS CLI if empty.
+func NewSSMStore(region string, path string) (*SSMStore, error) {
+	if _, err := exec.LookPath("aws"); err != nil {
+		return nil, errors.New("aws not found, the AWS CLI is needed for the ssm secret backend")
+	}
+
+	return &SSMStore{Region: region, Path: "/" + strings.Trim(path, "/")}, nil
+}
+
+// Load is a method to read and decrypt the secret stored under key.
+func (s *SSMStore) Load(key string) (string, error) {
+	stdout, err := s.aws("", "get-parameter", "--name", s.name(key), "--with-decryption", "--query", "Parameter.Value", "--output", "text")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+// Save is a method to store value under key encrypted with the default KMS key of the account.
+func (s *SSMStore) Save(key string, value string) error {
+	// the value is read from the standard input rather than passed in the process list
+	_, err := s.aws(value, "put-parameter", "--name", s.name(key), "--type", "SecureString", "--overwrite", "--value", "file:///dev/stdin")
+	return err
+}
+
+// Delete is a method to remove the secret stored under key, if any.
+func (s *SSMStore) Delete(key string) error {
+	if _, err := s.aws("", "delete-parameter", "--name", s.name(key)); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SSMStore) name(key string) string {
+	return strings.TrimRight(s.Path, "/") + "/" + key
+}
+
+func (s *SSMStore) aws(stdin string, args ...string) (string, error) {
+	args = append([]string{"ssm"}, args...)
+	if len(s.Region) > 0 {
+		args = append(args, "--region", s.Region)
+	}
+
+	cmd := exec.Command("aws", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "ParameterNotFound") {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("aws ssm %s: %s", args[1], strings.TrimSpace(stderr.String()))
+	}
+
+	return string(stdout), nil
+}
@@ -0,0 +1,164 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VaultStore is a structure that stores the secrets in the KV v2 secrets engine of HashiCorp Vault, each under the value field of Path/key.
+// The address and the token are taken from VAULT_ADDR and VAULT_TOKEN, or ~/.vault-token written by 'vault login'.
+type VaultStore struct {
+	Address   string
+	Token     string
+	Namespace string
+	Mount     string
+	Path      string
+	client    *http.Client
+}
+
+// vaultSecret is a structure of the request and response bodies of the KV v2 secrets engine.
+type vaultSecret struct {
+	Data struct {
+		Data map[string]string `json:"data"`
+	} `json:"data"`
+}
+
+// NewVaultStore is a factory function that returns the VaultStore for the path of the secrets prefixed with the mount of the secrets engine, e.g. secret/fvpn.
+func NewVaultStore(path string) (*VaultStore, error) {
+	mount, path, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	if len(mount) == 0 {
+		return nil, errors.New("vault secret backend needs the mount of the secrets engine, e.g. vault://secret/fvpn")
+	}
+
+	address := os.Getenv("VAULT_ADDR")
+	if len(address) == 0 {
+		address = "https://127.0.0.1:8200"
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if len(token) == 0 {
+		home, _ := os.UserHomeDir()
+		data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+		if err != nil {
+			return nil, errors.New("no Vault token, set VAULT_TOKEN or run 'vault login'")
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	return &VaultStore{
+		Address:   strings.TrimRight(address, "/"),
+		Token:     token,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Mount:     mount,
+		Path:      path,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Load is a method to read the secret stored under key.
+func (s *VaultStore) Load(key string) (string, error) {
+	resp, err := s.do(http.MethodGet, "data", key, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return "", vaultError(resp)
+	}
+
+	var secret vaultSecret
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data.Data["value"]
+	if !ok {
+		// the latest version is deleted
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+// Save is a method to store value under key as a new version of the secret.
+func (s *VaultStore) Save(key string, value string) error {
+	var secret vaultSecret
+	secret.Data.Data = map[string]string{"value": value}
+	body, err := json.Marshal(secret.Data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPost, "data", key, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return vaultError(resp)
+	}
+
+	return nil
+}
+
+// Delete is a method to remove every version of the secret stored under key, if any.
+func (s *VaultStore) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, "metadata", key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return vaultError(resp)
+	}
+
+	return nil
+}
+
+func (s *VaultStore) do(method string, kind string, key string, body []byte) (*http.Response, error) {
+	url := strings.Join([]string{s.Address, "v1", s.Mount, kind, s.Path, key}, "/")
+	if len(s.Path) == 0 {
+		url = strings.Join([]string{s.Address, "v1", s.Mount, kind, key}, "/")
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", s.Token)
+	if len(s.Namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return s.client.Do(req)
+}
+
+// vaultError is a function that returns the errors reported by Vault in resp as a single error.
+func vaultError(resp *http.Response) error {
+	var body struct {
+		Errors []string `json:"errors"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &body) == nil && len(body.Errors) > 0 {
+		return fmt.Errorf("vault: %s", strings.Join(body.Errors, ", "))
+	}
+
+	return fmt.Errorf("vault: %s", resp.Status)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/forestvpn/cli/pkg/forestvpn"
+)
+
+// knownErrors are the errors of the forestvpn package that are restored out of the ErrorResponse, so callers could check them with errors.Is.
+var knownErrors = []error{
+	forestvpn.ErrAlreadyConnected,
+	forestvpn.ErrNotConnected,
+	forestvpn.ErrSubscriptionExpired,
+	forestvpn.ErrPremiumRequired,
+	forestvpn.ErrLocationNotFound,
+}
+
+// Client is a structure that implements the Controller by sending requests to the Server running on another machine.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient is a factory function that returns the Client of the Server listening on address, e.g. tls://router.lan:9999,
+// unix:///run/fvpn.sock or npipe:////./pipe/fvpn.
+// The tls:// or https:// scheme enables TLS, which is required beyond the loopback interface;
+// caFile is an optional PEM file to verify self-signed certificates of the Server.
+func NewClient(address string, token string, caFile string) (*Client, error) {
+	// the host of the local socket is only used in the requests
+	if dial := localDialer(address); dial != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dial
+		return &Client{
+			baseURL:    "http://fvpn",
+			token:      token,
+			httpClient: &http.Client{Transport: transport, Timeout: 60 * time.Second},
+		}, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || len(u.Host) == 0 {
+		// address without scheme, e.g. router.lan:9999 or 127.0.0.1:9999
+		u, err = url.Parse("tcp://" + address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch u.Scheme {
+	case "tcp", "http":
+		// the daemon only listens beyond the loopback interface with TLS, the token isn't sent in plaintext either
+		if !Loopback(net.JoinHostPort(u.Hostname(), "0")) {
+			return nil, fmt.Errorf("%s would send the token in plaintext, use tls://%s or https://%s to reach the daemon beyond the loopback interface", address, u.Host, u.Host)
+		}
+		u.Scheme = "http"
+	case "tls", "https":
+		u.Scheme = "https"
+		transport.TLSClientConfig = &tls.Config{}
+		if len(caFile) > 0 {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", caFile)
+			}
+			transport.TLSClientConfig.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("unsupported daemon address: %s", address)
+	}
+
+	return &Client{
+		baseURL:    u.Scheme + "://" + u.Host,
+		token:      token,
+		httpClient: &http.Client{Transport: transport, Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Status is a method to get the state of the connection on the remote machine.
+func (c *Client) Status(ctx context.Context) (forestvpn.Status, error) {
+	var status forestvpn.Status
+	err := c.do(ctx, http.MethodGet, "/status", nil, &status)
+	return status, err
+}
+
+// Locations is a method to get the locations available on the remote machine.
+func (c *Client) Locations(ctx context.Context) ([]forestvpn.Location, error) {
+	var locations []forestvpn.Location
+	err := c.do(ctx, http.MethodGet, "/locations", nil, &locations)
+	return locations, err
+}
+
+// Connect is a method to establish the Wireguard connection on the remote machine.
+func (c *Client) Connect(ctx context.Context, persist bool) error {
+	return c.do(ctx, http.MethodPost, "/connect", ConnectRequest{Persist: persist}, nil)
+}
+
+// Disconnect is a method to terminate the Wireguard connection on the remote machine.
+func (c *Client) Disconnect(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/disconnect", nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(ctx, method, PathPrefix+path, data)
+	// the daemons of the releases before PathPrefix only serve the paths without it
+	if err == nil && resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		resp, err = c.send(ctx, method, path, data)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || len(e.Error) == 0 {
+			return fmt.Errorf("daemon responded with %s", resp.Status)
+		}
+
+		for _, known := range knownErrors {
+			if e.Error == known.Error() {
+				return known
+			}
+		}
+		return errors.New(e.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send is a method to send the request with the JSON body data to path of the daemon.
+func (c *Client) send(ctx context.Context, method string, path string, data []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Listen is a function that listens on the address of the daemon: HOST:PORT for TCP, unix:///PATH for a unix socket
+// only root and the user running the daemon could connect to, or npipe:////./pipe/NAME for a Windows named pipe restricted
+// to the administrators and the user running the daemon. The members of group, if set, are let in to the socket and the pipe as well.
+func Listen(address string, group string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return listenUnix(strings.TrimPrefix(address, "unix://"), group)
+	case strings.HasPrefix(address, "npipe://"):
+		return listenPipe(pipePath(address), group)
+	}
+
+	if len(group) > 0 {
+		return nil, errors.New("the group is only applicable to a unix:// socket or npipe:// pipe")
+	}
+	return net.Listen("tcp", address)
+}
+
+// Authenticates is a function to check whether the connections to the address are authenticated by the operating system,
+// with the credentials of the peer of a unix socket or the access control list of a named pipe, so the token could be left out.
+func Authenticates(address string) bool {
+	return strings.HasPrefix(address, "unix://") && peerCredentialsSupported || strings.HasPrefix(address, "npipe://") && runtime.GOOS == "windows"
+}
+
+// Loopback is a function to check whether the TCP address only listens on the loopback interface, e.g. 127.0.0.1:9999 or localhost:9999,
+// so the token isn't sent over the network in plaintext without TLS. The unix:// sockets and npipe:// pipes never leave the machine as well.
+func Loopback(address string) bool {
+	if strings.HasPrefix(address, "unix://") || strings.HasPrefix(address, "npipe://") {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// localDialer is a function that returns the dialer of the unix:// or npipe:// address to use instead of TCP, or nil for the other ones.
+func localDialer(address string) func(ctx context.Context, network string, addr string) (net.Conn, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		path := strings.TrimPrefix(address, "unix://")
+		return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	case strings.HasPrefix(address, "npipe://"):
+		path := pipePath(address)
+		return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return dialPipe(ctx, path)
+		}
+	}
+
+	return nil
+}
+
+func listenUnix(path string, group string) (net.Listener, error) {
+	// the socket is left behind if the daemon was killed, and nobody listens on it anymore
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+		} else {
+			_ = os.Remove(path)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !peerCredentialsSupported {
+		if len(group) > 0 {
+			listener.Close()
+			return nil, errors.New("the group of the unix socket is only supported on Linux and macOS")
+		}
+
+		if err := os.Chmod(path, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	peers, err := newPeerListener(listener, path, group)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return peers, nil
+}
+
+// pipePath is a function that converts the npipe:// address to the path of the named pipe, e.g. \\.\pipe\fvpn out of npipe:////./pipe/fvpn.
+func pipePath(address string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(address, "npipe://"), "/", `\`)
+}
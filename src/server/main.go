@@ -0,0 +1,285 @@
+// server is a package containing the REST API to manage ForestVPN remotely, e.g. on a router, with 'fvpn daemon'.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forestvpn/cli/pkg/forestvpn"
+)
+
+// ProtocolVersion is a version of the REST API served by the Server, incremented on breaking changes.
+// It's sent in the X-Fvpn-Protocol header of every response, and the API is served under PathPrefix.
+const ProtocolVersion = 1
+
+// PathPrefix is the prefix of the paths of the ProtocolVersion of the REST API.
+// The paths are served without it as well for the clients of the releases before it.
+const PathPrefix = "/v1"
+
+// Controller is an interface of the forestvpn.Client used by the Server.
+type Controller interface {
+	Status(ctx context.Context) (forestvpn.Status, error)
+	Locations(ctx context.Context) ([]forestvpn.Location, error)
+	Connect(ctx context.Context, persist bool) error
+	Disconnect(ctx context.Context) error
+}
+
+// ConnectRequest is a body of the POST /v1/connect request.
+type ConnectRequest struct {
+	Persist bool `json:"persist"`
+}
+
+// ErrorResponse is a body of the response of a failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server is a structure that serves the REST API, authenticating every request with the bearer token.
+// Without the token, the requests are left to the listener to authenticate, see Authenticates.
+//
+//	GET  /v1/status
+//	GET  /v1/locations
+//	POST /v1/connect
+//	POST /v1/disconnect
+type Server struct {
+	controller Controller
+	token      string
+	// mu serializes the requests, as the Wireguard connection can't be changed concurrently.
+	// It's a channel holding the lock rather than a sync.Mutex, so the Healthy probe can stop waiting for it.
+	mu  chan struct{}
+	mux *http.ServeMux
+}
+
+// New is a factory function that returns the Server controlling the connection with controller.
+func New(controller Controller, token string) *Server {
+	s := &Server{controller: controller, token: token, mu: make(chan struct{}, 1), mux: http.NewServeMux()}
+	for _, prefix := range []string{PathPrefix, ""} {
+		s.mux.HandleFunc(prefix+"/status", s.method(http.MethodGet, s.status))
+		s.mux.HandleFunc(prefix+"/locations", s.method(http.MethodGet, s.locations))
+		s.mux.HandleFunc(prefix+"/connect", s.method(http.MethodPost, s.connect))
+		s.mux.HandleFunc(prefix+"/disconnect", s.method(http.MethodPost, s.disconnect))
+	}
+	return s
+}
+
+// ServeHTTP is a method that implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Fvpn-Protocol", strconv.Itoa(ProtocolVersion))
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if !s.lock(r.Context()) {
+		return
+	}
+	defer s.unlock()
+	s.mux.ServeHTTP(w, r)
+}
+
+// lock is a method that serializes the caller with the requests and tasks, waiting for the lock until ctx is done.
+// Returns false if ctx was done first, the lock isn't taken then.
+func (s *Server) lock(ctx context.Context) bool {
+	select {
+	case s.mu <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// unlock is a method that releases the lock taken with lock.
+func (s *Server) unlock() {
+	<-s.mu
+}
+
+// Every is a method that calls task every interval until ctx is done, serialized with the requests.
+// Errors are passed to onError, if set.
+func (s *Server) Every(ctx context.Context, interval time.Duration, task func(ctx context.Context) error, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.lock(ctx) {
+				return
+			}
+			err := task(ctx)
+			s.unlock()
+
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Healthy is a method to check whether the Server gets to serve a request before ctx is done, e.g. with a timeout,
+// i.e. neither a request nor a task hangs, e.g. in wg-quick or an API call.
+// It stops waiting once ctx is done, so a hung Server doesn't leave the probes behind.
+func (s *Server) Healthy(ctx context.Context) bool {
+	if !s.lock(ctx) {
+		return false
+	}
+	s.unlock()
+	return true
+}
+
+// Watchdog is a method that checks the Server is Healthy every interval until ctx is done, calling alive while it is
+// and unhealthy once it isn't, e.g. to ping the service manager and to exit for a restart respectively.
+func (s *Server) Watchdog(ctx context.Context, interval time.Duration, alive func() error, unhealthy func(), onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probe, cancel := context.WithTimeout(ctx, interval)
+			healthy := s.Healthy(probe)
+			cancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if !healthy {
+				unhealthy()
+				continue
+			}
+
+			if err := alive(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// OnChange is a method that calls task once the changes stop arriving for debounce, serialized with the requests,
+// until ctx is done or changes is closed. Errors are passed to onError, if set.
+func (s *Server) OnChange(ctx context.Context, changes <-chan string, debounce time.Duration, task func(ctx context.Context) error, onError func(err error)) {
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			// a command usually writes a few files at once
+			timer.Reset(debounce)
+		case <-timer.C:
+			if !s.lock(ctx) {
+				return
+			}
+			err := task(ctx)
+			s.unlock()
+
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.token) == 0 {
+		return true
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) method(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	status, err := s.controller.Status(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) locations(w http.ResponseWriter, r *http.Request) {
+	locations, err := s.controller.Locations(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, locations)
+}
+
+func (s *Server) connect(w http.ResponseWriter, r *http.Request) {
+	var request ConnectRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	if err := s.controller.Connect(r.Context(), request.Persist); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.status(w, r)
+}
+
+func (s *Server) disconnect(w http.ResponseWriter, r *http.Request) {
+	if err := s.controller.Disconnect(r.Context()); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.status(w, r)
+}
+
+// writeError is a function that maps the errors of the forestvpn package to HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, forestvpn.ErrAlreadyConnected), errors.Is(err, forestvpn.ErrNotConnected):
+		code = http.StatusConflict
+	case errors.Is(err, forestvpn.ErrSubscriptionExpired), errors.Is(err, forestvpn.ErrPremiumRequired):
+		code = http.StatusPaymentRequired
+	case errors.Is(err, forestvpn.ErrLocationNotFound):
+		code = http.StatusNotFound
+	}
+	writeJSON(w, code, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,328 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/forestvpn/cli/pkg/forestvpn"
+	"github.com/forestvpn/cli/server"
+)
+
+type fakeController struct {
+	connected bool
+}
+
+func (f *fakeController) Status(ctx context.Context) (forestvpn.Status, error) {
+	return forestvpn.Status{Connected: f.connected}, nil
+}
+
+func (f *fakeController) Locations(ctx context.Context) ([]forestvpn.Location, error) {
+	return []forestvpn.Location{{Name: "Helsinki", Country: "Finland"}}, nil
+}
+
+func (f *fakeController) Connect(ctx context.Context, persist bool) error {
+	if f.connected {
+		return forestvpn.ErrAlreadyConnected
+	}
+	f.connected = true
+	return nil
+}
+
+func (f *fakeController) Disconnect(ctx context.Context) error {
+	if !f.connected {
+		return forestvpn.ErrNotConnected
+	}
+	f.connected = false
+	return nil
+}
+
+func request(s *server.Server, method string, path string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestServerUnauthorized(t *testing.T) {
+	s := server.New(&fakeController{}, "secret")
+
+	for _, token := range []string{"", "wrong"} {
+		if w := request(s, http.MethodGet, "/status", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected %d for token %q, got %d", http.StatusUnauthorized, token, w.Code)
+		}
+	}
+
+	// the token without the Bearer scheme is not accepted
+	r := httptest.NewRequest(http.MethodGet, "/status", nil)
+	r.Header.Set("Authorization", "secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d without the Bearer prefix, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestServerConnect(t *testing.T) {
+	s := server.New(&fakeController{}, "secret")
+
+	if w := request(s, http.MethodPost, "/connect", "secret"); w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if w := request(s, http.MethodPost, "/connect", "secret"); w.Code != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	if w := request(s, http.MethodGet, "/connect", "secret"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestServerPaths(t *testing.T) {
+	s := server.New(&fakeController{}, "secret")
+
+	for _, path := range []string{server.PathPrefix + "/status", "/status"} {
+		w := request(s, http.MethodGet, path, "secret")
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusOK, w.Code)
+		}
+		if protocol := w.Header().Get("X-Fvpn-Protocol"); protocol != strconv.Itoa(server.ProtocolVersion) {
+			t.Errorf("%s: expected protocol %d, got %q", path, server.ProtocolVersion, protocol)
+		}
+	}
+
+	// the daemons of the earlier releases only serve the paths without the prefix
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(forestvpn.Status{Connected: true})
+	})
+	ts := httptest.NewServer(legacy)
+	defer ts.Close()
+
+	client, err := server.NewClient(ts.URL, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := client.Status(context.Background()); err != nil || !status.Connected {
+		t.Errorf("expected the status of the earlier daemon, got %+v, %v", status, err)
+	}
+}
+
+func TestClientRestoresErrors(t *testing.T) {
+	ts := httptest.NewServer(server.New(&fakeController{}, "secret"))
+	defer ts.Close()
+
+	client, err := server.NewClient(ts.URL, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Disconnect(context.Background()); !errors.Is(err, forestvpn.ErrNotConnected) {
+		t.Errorf("expected %v, got %v", forestvpn.ErrNotConnected, err)
+	}
+
+	if err := client.Connect(context.Background(), false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	status, err := client.Status(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !status.Connected {
+		t.Error("expected status to be connected")
+	}
+}
+
+func TestClientUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are tested on POSIX")
+	}
+
+	path := filepath.Join(t.TempDir(), "fvpn.sock")
+	listener, err := server.Listen("unix://"+path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &http.Server{Handler: server.New(&fakeController{}, "secret")}
+	go ts.Serve(listener)
+	defer ts.Close()
+
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the socket to be accessible to its owner only, got %v", info.Mode().Perm())
+	}
+
+	client, err := server.NewClient("unix://"+path, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Connect(context.Background(), false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnixSocketGroupWithoutToken(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("peer credentials are checked on Linux and macOS")
+	}
+
+	path := filepath.Join(t.TempDir(), "fvpn.sock")
+	if !server.Authenticates("unix://" + path) {
+		t.Fatal("expected the unix socket to be authenticated by the system")
+	}
+
+	group := strconv.Itoa(os.Getgid())
+	listener, err := server.Listen("unix://"+path, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &http.Server{Handler: server.New(&fakeController{}, "")}
+	go ts.Serve(listener)
+	defer ts.Close()
+
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0660 {
+		t.Errorf("expected the socket to be accessible to the group, got %v", info.Mode().Perm())
+	}
+
+	client, err := server.NewClient("unix://"+path, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Connect(context.Background(), false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := server.Listen("127.0.0.1:0", group); err == nil {
+		t.Error("expected the group to be refused on TCP")
+	}
+	if server.Authenticates("127.0.0.1:9999") {
+		t.Error("expected TCP to require the token")
+	}
+}
+
+func TestOnChangeDebounces(t *testing.T) {
+	s := server.New(&fakeController{}, "secret")
+	changes := make(chan string)
+	calls := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.OnChange(ctx, changes, 50*time.Millisecond, func(ctx context.Context) error {
+		calls <- struct{}{}
+		return nil
+	}, nil)
+
+	for i := 0; i < 3; i++ {
+		changes <- "config.json"
+	}
+
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("task not called")
+	}
+
+	select {
+	case <-calls:
+		t.Error("expected a burst of changes to call the task once")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHealthyDetectsHungTask(t *testing.T) {
+	s := server.New(&fakeController{}, "secret")
+	if !healthy(s, 100*time.Millisecond) {
+		t.Error("expected the idle server to be healthy")
+	}
+
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Every(ctx, 10*time.Millisecond, func(ctx context.Context) error {
+		<-release
+		return nil
+	}, nil)
+
+	time.Sleep(50 * time.Millisecond)
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		if healthy(s, 20*time.Millisecond) {
+			t.Error("expected the server with a hung task to be unhealthy")
+		}
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("expected the probes to return, got %d goroutines instead of %d", n, goroutines)
+	}
+
+	close(release)
+	if !healthy(s, time.Second) {
+		t.Error("expected the server to recover once the task returns")
+	}
+}
+
+func healthy(s *server.Server, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.Healthy(ctx)
+}
+
+func TestLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:9999":        true,
+		"[::1]:9999":            true,
+		"localhost:9999":        true,
+		"unix:///run/fvpn.sock": true,
+		":9999":                 false,
+		"0.0.0.0:9999":          false,
+		"192.168.1.1:9999":      false,
+		"router.lan:9999":       false,
+	}
+
+	for address, expected := range tests {
+		if actual := server.Loopback(address); actual != expected {
+			t.Errorf("%s: expected %t, got %t", address, expected, actual)
+		}
+	}
+}
+
+func TestNewClientPlaintext(t *testing.T) {
+	tests := map[string]bool{
+		"tcp://127.0.0.1:9999":  true,
+		"http://localhost:9999": true,
+		"http://[::1]":          true,
+		"127.0.0.1:9999":        true,
+		"tls://router.lan:9999": true,
+		"https://10.0.0.1:9999": true,
+		"tcp://router.lan:9999": false,
+		"http://10.0.0.1:9999":  false,
+		"router.lan:9999":       false,
+		"tcp://0.0.0.0:9999":    false,
+	}
+
+	for address, allowed := range tests {
+		if _, err := server.NewClient(address, "secret", ""); (err == nil) != allowed {
+			t.Errorf("%s: expected allowed %t, got %v", address, allowed, err)
+		}
+	}
+}
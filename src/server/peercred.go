@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// peer is a structure of the credentials of the process connected to the unix socket.
+type peer struct {
+	uid    int
+	groups []int
+}
+
+// peerListener is a structure that implements net.Listener by closing the connections of the processes other than root,
+// the user running the daemon and the members of the group, checked with the credentials of the peer,
+// so the file permissions of the socket aren't the only thing keeping the other users out.
+type peerListener struct {
+	net.Listener
+	uid int
+	gid int
+}
+
+// Accept is a method that returns the next connection of an allowed peer.
+func (l *peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if p, err := peerCredentials(conn); err == nil && l.allowed(p) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+func (l *peerListener) allowed(p peer) bool {
+	if p.uid == 0 || p.uid == l.uid {
+		return true
+	}
+
+	for _, gid := range p.groups {
+		if l.gid >= 0 && gid == l.gid {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupGroup is a function that returns the id of the group given by name or id.
+func lookupGroup(name string) (int, error) {
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		if group, err = user.LookupGroupId(name); err != nil {
+			return 0, err
+		}
+	}
+
+	return strconv.Atoi(group.Gid)
+}
+
+// newPeerListener is a function that wraps the listener of the unix socket at path, letting the group in if set.
+func newPeerListener(listener net.Listener, path string, group string) (net.Listener, error) {
+	l := &peerListener{Listener: listener, uid: os.Getuid(), gid: -1}
+	if len(group) == 0 {
+		return l, os.Chmod(path, 0600)
+	}
+
+	gid, err := lookupGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	l.gid = gid
+
+	if err := os.Chown(path, -1, gid); err != nil {
+		return nil, err
+	}
+	return l, os.Chmod(path, 0660)
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentialsSupported is whether the unix sockets are restricted with the credentials of the peer, see peerListener.
+const peerCredentialsSupported = true
+
+// peerCredentials is a function that reads the credentials of the peer of the unix socket with LOCAL_PEERCRED, as getpeereid does.
+func peerCredentials(conn net.Conn) (peer, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return peer{}, errors.New("not a unix socket")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return peer{}, err
+	}
+
+	var cred *unix.Xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return peer{}, err
+	} else if credErr != nil {
+		return peer{}, credErr
+	}
+
+	p := peer{uid: int(cred.Uid)}
+	for _, gid := range cred.Groups[:cred.Ngroups] {
+		p.groups = append(p.groups, int(gid))
+	}
+	return p, nil
+}
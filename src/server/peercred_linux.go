@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentialsSupported is whether the unix sockets are restricted with the credentials of the peer, see peerListener.
+const peerCredentialsSupported = true
+
+// peerCredentials is a function that reads the credentials of the peer of the unix socket with SO_PEERCRED.
+// SO_PEERCRED only carries the primary group, so the supplementary ones are read from /proc.
+func peerCredentials(conn net.Conn) (peer, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return peer{}, errors.New("not a unix socket")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return peer{}, err
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return peer{}, err
+	} else if credErr != nil {
+		return peer{}, credErr
+	}
+
+	p := peer{uid: int(cred.Uid), groups: []int{int(cred.Gid)}}
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(int(cred.Pid)) + "/status")
+	if err != nil {
+		return p, nil
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Groups:") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(line, "Groups:")) {
+			if gid, err := strconv.Atoi(field); err == nil {
+				p.groups = append(p.groups, gid)
+			}
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentialsSupported is whether the unix sockets are restricted with the credentials of the peer, see peerListener.
+const peerCredentialsSupported = false
+
+// peerCredentials is a function that fails, as the credentials of the peer are only read on Linux and macOS.
+func peerCredentials(conn net.Conn) (peer, error) {
+	return peer{}, errors.New("peer credentials are only supported on Linux and macOS")
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var errPipeUnsupported = errors.New("named pipes are only supported on Windows, use a unix:// socket instead")
+
+func listenPipe(path string, group string) (net.Listener, error) {
+	return nil, errPipeUnsupported
+}
+
+func dialPipe(ctx context.Context, path string) (net.Conn, error) {
+	return nil, errPipeUnsupported
+}
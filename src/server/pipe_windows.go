@@ -0,0 +1,335 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeBufferSize is the size of the input and output buffers of the named pipe.
+const pipeBufferSize = 65536
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
+
+// pipeListener is a structure that implements net.Listener by creating a new instance of the named pipe for every client.
+type pipeListener struct {
+	path      string
+	sa        *windows.SecurityAttributes
+	mu        sync.Mutex
+	first     bool
+	pending   windows.Handle
+	accepting bool
+	closed    bool
+}
+
+// pipeSecurity is a function that returns the security attributes granting the named pipe to SYSTEM, the administrators
+// and the user running the daemon only, and to the members of group, if set.
+func pipeSecurity(group string) (*windows.SecurityAttributes, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+
+	dacl := fmt.Sprintf("D:P(A;;GA;;;SY)(A;;GA;;;BA)(A;;GA;;;%s)", user.User.Sid.String())
+	if len(group) > 0 {
+		sid, _, _, err := windows.LookupSID("", group)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %s", group, err)
+		}
+		dacl += fmt.Sprintf("(A;;GA;;;%s)", sid.String())
+	}
+
+	sd, err := windows.SecurityDescriptorFromString(dacl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), SecurityDescriptor: sd}, nil
+}
+
+func listenPipe(path string, group string) (net.Listener, error) {
+	sa, err := pipeSecurity(group)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &pipeListener{path: path, sa: sa, first: true}
+
+	// the first instance is created right away, so another process can't take over the name
+	h, err := l.createPipe()
+	if err != nil {
+		return nil, err
+	}
+	l.pending = h
+
+	return l, nil
+}
+
+func (l *pipeListener) createPipe() (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(l.path)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED)
+	if l.first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+		l.first = false
+	}
+
+	mode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	return windows.CreateNamedPipe(name, flags, mode, windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+
+	h := l.pending
+	if h == windows.InvalidHandle {
+		var err error
+		if h, err = l.createPipe(); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		l.pending = h
+	}
+	l.accepting = true
+	l.mu.Unlock()
+
+	err := overlapped(h, func(ov *windows.Overlapped) error {
+		return windows.ConnectNamedPipe(h, ov)
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending, l.accepting = windows.InvalidHandle, false
+
+	if l.closed {
+		windows.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+
+	// the client could connect between creating the instance and waiting for it
+	if err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+
+	return newPipeConn(h, l.path), nil
+}
+
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+	if l.pending == windows.InvalidHandle {
+		return nil
+	}
+
+	if l.accepting {
+		// aborts the pending ConnectNamedPipe, Accept closes the handle
+		return windows.CancelIoEx(l.pending, nil)
+	}
+	return windows.CloseHandle(l.pending)
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.path)
+}
+
+// pipeConn is a structure that implements net.Conn over an instance of the named pipe opened for the overlapped I/O,
+// so the pending reads and writes could be cancelled once the deadline passes.
+type pipeConn struct {
+	handle   windows.Handle
+	path     string
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	closed   bool
+}
+
+func newPipeConn(h windows.Handle, path string) *pipeConn {
+	return &pipeConn{handle: h, path: path}
+}
+
+func dialPipe(ctx context.Context, path string) (net.Conn, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		// the daemon can't impersonate the client with SECURITY_IDENTIFICATION
+		h, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING,
+			windows.FILE_FLAG_OVERLAPPED|windows.SECURITY_SQOS_PRESENT|windows.SECURITY_IDENTIFICATION, 0)
+		if err == nil {
+			return newPipeConn(h, path), nil
+		}
+
+		// all the instances are busy with other clients until the daemon creates a new one
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(path), Err: err}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// overlapped is a function that starts the overlapped operation on the handle and waits for its result.
+func overlapped(h windows.Handle, op func(ov *windows.Overlapped) error) error {
+	_, err := overlappedIO(h, func(done *uint32, ov *windows.Overlapped) error {
+		return op(ov)
+	})
+	return err
+}
+
+func overlappedIO(h windows.Handle, op func(done *uint32, ov *windows.Overlapped) error) (uint32, error) {
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(event)
+
+	ov := windows.Overlapped{HEvent: event}
+	var done uint32
+	err = op(&done, &ov)
+	if errors.Is(err, windows.ERROR_IO_PENDING) {
+		err = windows.GetOverlappedResult(h, &ov, &done, true)
+	}
+	return done, err
+}
+
+// expired is a method to check whether the deadline has passed before the read or write is started.
+func (c *pipeConn) expired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.deadline.IsZero() && !time.Now().Before(c.deadline)
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	if c.expired() {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	n, err := overlappedIO(c.handle, func(done *uint32, ov *windows.Overlapped) error {
+		return windows.ReadFile(c.handle, b, done, ov)
+	})
+
+	switch {
+	case errors.Is(err, windows.ERROR_BROKEN_PIPE), errors.Is(err, windows.ERROR_PIPE_NOT_CONNECTED):
+		return int(n), io.EOF
+	case errors.Is(err, windows.ERROR_OPERATION_ABORTED):
+		return int(n), os.ErrDeadlineExceeded
+	case err == nil && n == 0 && len(b) > 0:
+		return 0, io.EOF
+	}
+	return int(n), err
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	if c.expired() {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	n, err := overlappedIO(c.handle, func(done *uint32, ov *windows.Overlapped) error {
+		return windows.WriteFile(c.handle, b, done, ov)
+	})
+
+	if errors.Is(err, windows.ERROR_OPERATION_ABORTED) {
+		return int(n), os.ErrDeadlineExceeded
+	}
+	return int(n), err
+}
+
+func (c *pipeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	_ = windows.CancelIoEx(c.handle, nil)
+	return windows.CloseHandle(c.handle)
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return pipeAddr(c.path)
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return pipeAddr(c.path)
+}
+
+// SetDeadline is a method that cancels the pending reads and writes once t passes. The zero t cancels the deadline.
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	c.deadline = t
+	if t.IsZero() {
+		return nil
+	}
+
+	cancel := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.closed {
+			_ = windows.CancelIoEx(c.handle, nil)
+		}
+	}
+
+	if d := time.Until(t); d > 0 {
+		c.timer = time.AfterFunc(d, cancel)
+	} else {
+		go cancel()
+	}
+	return nil
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
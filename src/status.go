@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	forestvpn_api "github.com/forestvpn/api-client-go"
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/auth"
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+// statusFlags is a function that returns the flags of 'fvpn status' and 'fvpn state status'.
+func statusFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "prompt",
+			Usage: "print a short colored segment for PS1, formatted with the prompt-format setting; prints nothing when disconnected",
+		},
+		&cli.BoolFlag{
+			Name:  "starship",
+			Usage: "print symbol, style and text of the connection as JSON for a starship custom module",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the state of the connection as JSON, see 'fvpn dev schema state-status'",
+		},
+		&cli.BoolFlag{
+			Name:  "all-profiles",
+			Usage: "print the state of the connection of every logged-in profile, e.g. on a gateway running several accounts",
+		},
+	}
+}
+
+// StarshipSegment is a structure printed by 'fvpn status --starship'.
+type StarshipSegment struct {
+	Symbol string `json:"symbol"`
+	Style  string `json:"style"`
+	Text   string `json:"text"`
+}
+
+// stateStatus is an action of 'fvpn status' and 'fvpn state status'.
+func stateStatus(ctx *cli.Context) error {
+	if ctx.Bool("prompt") {
+		fmt.Print(promptSegment())
+		return nil
+	}
+
+	if ctx.Bool("starship") {
+		data, err := json.Marshal(starshipSegment())
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	remote, err := remoteController(ctx)
+	if err != nil {
+		return err
+	} else if remote != nil {
+		if ctx.Bool("all-profiles") {
+			return errors.New("--all-profiles is not supported with --host")
+		}
+		return remoteStatus(ctx, remote)
+	}
+
+	if ctx.Bool("all-profiles") {
+		return profileStatuses(ctx)
+	}
+
+	profile := auth.OpenUserDB().CurrentUser()
+	if err = profile.SignIn(utils.ApiHost); err != nil {
+		return err
+	}
+
+	state := actions.State{WiregaurdInterface: "fvpn0"}
+
+	if jsonOutput(ctx) {
+		status, err := state.GetConnectionStatus(profile.ID)
+		if err != nil {
+			return err
+		}
+		return printJSON(status)
+	}
+
+	if state.GetStatus() {
+		device, err := actions.ConnectedDevice(profile.ID)
+
+		if err != nil {
+			return err
+		}
+
+		location := device.GetLocation()
+		country := location.GetCountry()
+
+		fmt.Printf("Connected to %s, %s\n", location.GetName(), country.GetName())
+		if label := actions.LoadLabel(profile.ID); len(label) > 0 {
+			fmt.Printf("Label: %s\n", label)
+		}
+		if event, ok := actions.LoadFailoverEvent(profile.ID); ok {
+			fmt.Printf("Failed over from %s at %s\n", event.From, utils.FormatTime(event.At))
+		}
+		if deadline, ok := actions.LoadExpiry(profile.ID); ok {
+			fmt.Printf("Disconnects at %s\n", utils.FormatTime(deadline))
+		}
+	} else {
+		fmt.Println("Disconnected")
+	}
+
+	if actions.KillSwitchEnabled(profile.ID) {
+		fmt.Println("Kill switch is on, the traffic outside of the tunnel is blocked until 'fvpn state down'")
+	}
+	if supervision, ok := actions.LoadSupervision(profile.ID); ok {
+		fmt.Println("Auto-reconnect is on until 'fvpn state down'")
+		for _, event := range supervision.Events {
+			result := "succeeded"
+			if len(event.Error) > 0 {
+				result = "failed, " + event.Error
+			} else if !event.OK {
+				result = "failed, no handshake"
+			}
+			fmt.Printf("%s %s to %s after %s: %s\n", utils.FormatTime(event.At), event.Action, event.Location, event.Cause, result)
+		}
+	}
+
+	return nil
+}
+
+// profileStatuses is a function that prints the state of the connection of every logged-in profile for 'fvpn state status --all-profiles'.
+func profileStatuses(ctx *cli.Context) error {
+	state := actions.State{WiregaurdInterface: "fvpn0"}
+	statuses, err := state.GetProfileStatuses()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput(ctx) {
+		return printJSON(statuses)
+	}
+
+	var data [][]string
+	for _, status := range statuses {
+		mark, connection, since := "", "Disconnected", ""
+		if status.Current {
+			mark = "*"
+		}
+		if status.Connected {
+			connection = fmt.Sprintf("Connected to %s, %s", status.Location, status.Country)
+		}
+		if status.Since != nil {
+			since = utils.FormatTime(*status.Since)
+		}
+		data = append(data, []string{mark, status.Email, status.Interface, connection, since})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Current", "Email", "Interface", "State", "Since"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	table.AppendBulk(data)
+	table.Render()
+	return nil
+}
+
+// jsonOutput is a function to check whether the command prints JSON, either with its --json flag or with 'fvpn --output json'.
+func jsonOutput(c *cli.Context) bool {
+	return c.Bool("json") || utils.JSONOutput
+}
+
+// printJSON is a function that prints v as indented JSON.
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+// connectedLocation is a function that returns the location of the connection out of the local files only,
+// without signing in or calling wg, so it's fast enough to run on every prompt.
+func connectedLocation() (forestvpn_api.Location, bool) {
+	if utils.Fake {
+		if record, err := actions.LoadStateRecord(); err != nil || record.State != "up" {
+			return forestvpn_api.Location{}, false
+		}
+	} else if !utils.WireguardInterfaceUp("fvpn0") {
+		return forestvpn_api.Location{}, false
+	}
+
+	profile := auth.OpenUserDB().CurrentUser()
+	device, err := actions.ConnectedDevice(profile.ID)
+	if err != nil {
+		return forestvpn_api.Location{}, false
+	}
+
+	return device.GetLocation(), true
+}
+
+// formatLocation is a function that renders the location of the connection with the prompt-format setting.
+func formatLocation(location forestvpn_api.Location) string {
+	conf, err := config.Load()
+	if err != nil {
+		return ""
+	}
+
+	country := location.GetCountry()
+	return strings.NewReplacer(
+		"{flag}", country.GetEmoji(),
+		"{country}", strings.ToUpper(country.GetId()),
+		"{city}", location.GetName(),
+	).Replace(conf.Get(config.PromptFormat))
+}
+
+// promptSegment is a function that renders the location of the connection with the prompt-format setting for PS1.
+// The color escapes are marked as non-printing, so the shell doesn't count them to the width of the prompt:
+// with %{ %} in zsh, and with the \001 and \002 bytes readline reads \[ \] as in bash, as the output of $(...) isn't decoded again.
+func promptSegment() string {
+	location, connected := connectedLocation()
+	if !connected {
+		return ""
+	}
+
+	segment := formatLocation(location)
+	if len(segment) == 0 || len(os.Getenv("NO_COLOR")) > 0 {
+		return segment
+	}
+
+	start, end := "\001", "\002"
+	if filepath.Base(os.Getenv("SHELL")) == "zsh" {
+		start, end = "%{", "%}"
+	}
+
+	return start + "\033[32m" + end + segment + start + "\033[0m" + end
+}
+
+// starshipSegment is a function that describes the connection for a starship custom module, with the text formatted with the prompt-format setting.
+func starshipSegment() StarshipSegment {
+	location, connected := connectedLocation()
+	if !connected {
+		return StarshipSegment{Symbol: "🌲 ", Style: "dimmed", Text: "off"}
+	}
+
+	return StarshipSegment{Symbol: "🌲 ", Style: "bold green", Text: formatLocation(location)}
+}
+
+// superviseState is a function that records the running command as the supervisor of the connection to the state file,
+// so the supervisors and status bars could tell whether 'fvpn daemon' or 'fvpn monitor start' is alive.
+func superviseState() {
+	actions.SupervisorPid = os.Getpid()
+	profile := auth.OpenUserDB().CurrentUser()
+	state := actions.State{WiregaurdInterface: "fvpn0"}
+	if err := state.RecordState(profile.ID, state.GetStatus()); err != nil && utils.Verbose {
+		utils.InfoLogger.Println(err)
+	}
+}
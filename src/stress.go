@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/forestvpn/cli/actions"
+	"github.com/forestvpn/cli/pkg/forestvpn"
+	"github.com/forestvpn/cli/server"
+	"github.com/forestvpn/cli/utils"
+	"github.com/google/uuid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+// stressOperations are the operations 'fvpn dev stress' picks from at random.
+var stressOperations = []string{"up", "down", "status", "location-set", "cli-status"}
+
+// stressRun is a structure of the outcome of an operation run by 'fvpn dev stress'.
+type stressRun struct {
+	Operation string
+	Duration  time.Duration
+	Err       error
+}
+
+// stressTest is a structure of the clients 'fvpn dev stress' runs the operations with: up, down and status go through the daemon queue
+// like the requests of the remote clients, location-set goes around it like 'fvpn location set', and cli-status runs another process
+// reading the same files.
+type stressTest struct {
+	daemon    *server.Client
+	client    *forestvpn.Client
+	locations []string
+	exe       string
+}
+
+// stressAction is an action of 'fvpn dev stress'.
+func stressAction(c *cli.Context) error {
+	if !utils.Fake {
+		return errors.New("stress test only runs against the fake backend, pass --fake-backend or set FVPN_FAKE=1")
+	}
+
+	iterations, parallel := c.Int("iterations"), c.Int("parallel")
+	if iterations < 1 || parallel < 1 {
+		return errors.New("--iterations and --parallel must be positive")
+	}
+
+	test, stop, err := newStressTest(c.Context)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	seed := c.Int64("seed")
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Printf("Running %d operations in %d workers, seed %d\n", iterations, parallel, seed)
+
+	runs := make(chan stressRun, iterations)
+	jobs := make(chan string, iterations)
+	random := rand.New(rand.NewSource(seed))
+	for i := 0; i < iterations; i++ {
+		jobs <- stressOperations[random.Intn(len(stressOperations))]
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed + int64(worker)))
+			for operation := range jobs {
+				started := time.Now()
+				err := test.run(c.Context, operation, random)
+				if err == nil {
+					err = checkStateFiles()
+				}
+				runs <- stressRun{Operation: operation, Duration: time.Since(started), Err: err}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(runs)
+
+	var results []stressRun
+	for run := range runs {
+		results = append(results, run)
+	}
+
+	// once quiet, the connection must end up down
+	started := time.Now()
+	err = test.finalDown(c.Context)
+	results = append(results, stressRun{Operation: "final-down", Duration: time.Since(started), Err: err})
+
+	failed := printStressResults(results)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d operations failed, rerun with --seed %d to repeat the order", failed, len(results), seed)
+	}
+
+	fmt.Println("No races found")
+	return nil
+}
+
+// newStressTest is a function that starts the daemon of the logged-in user on a random local port and returns the clients,
+// along with the function stopping the daemon.
+func newStressTest(ctx context.Context) (*stressTest, func(), error) {
+	client, err := forestvpn.NewClient(ctx, utils.ApiHost)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locations, err := client.Locations(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	test := &stressTest{client: client}
+	for _, location := range locations {
+		if location.Usable {
+			test.locations = append(test.locations, location.ID)
+		}
+	}
+
+	if test.exe, err = os.Executable(); err != nil {
+		return nil, nil, err
+	}
+
+	// the daemon has a client of its own, as 'fvpn daemon' runs in another process
+	daemonClient, err := forestvpn.NewClient(ctx, utils.ApiHost)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := server.Listen("127.0.0.1:0", "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := uuid.New().String()
+	srv := &http.Server{Handler: server.New(daemonClient, token)}
+	go func() { _ = srv.Serve(listener) }()
+
+	if test.daemon, err = server.NewClient("tcp://"+listener.Addr().String(), token, ""); err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+
+	return test, func() { srv.Close() }, nil
+}
+
+// run is a method to run the operation, leaving out the errors expected from the order of the operations, e.g. down when already down.
+func (t *stressTest) run(ctx context.Context, operation string, random *rand.Rand) error {
+	var err error
+	switch operation {
+	case "up":
+		err = t.daemon.Connect(ctx, false)
+	case "down":
+		err = t.daemon.Disconnect(ctx)
+	case "status":
+		_, err = t.daemon.Status(ctx)
+	case "location-set":
+		if len(t.locations) == 0 {
+			return nil
+		}
+		_, err = t.client.SetLocation(ctx, t.locations[random.Intn(len(t.locations))])
+	case "cli-status":
+		err = t.cliStatus(ctx)
+	}
+
+	if errors.Is(err, forestvpn.ErrAlreadyConnected) || errors.Is(err, forestvpn.ErrNotConnected) {
+		return nil
+	}
+	return err
+}
+
+// cliStatus is a method to run 'fvpn state status --json' in another process and check its output.
+func (t *stressTest) cliStatus(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, t.exe, "state", "status", "--json")
+	cmd.Env = append(os.Environ(), "FVPN_FAKE=1", "FVPN_API_URL="+utils.ApiScheme+"://"+utils.ApiHost)
+	stdout, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("fvpn state status: %s", err)
+	}
+
+	var status actions.ConnectionStatus
+	if err := json.Unmarshal(stdout, &status); err != nil || status.SchemaVersion == 0 {
+		return fmt.Errorf("fvpn state status printed %q", stdout)
+	}
+	return nil
+}
+
+// finalDown is a method to set the connection down once the operations are over and to check it's down everywhere.
+func (t *stressTest) finalDown(ctx context.Context) error {
+	if err := t.daemon.Disconnect(ctx); err != nil && !errors.Is(err, forestvpn.ErrNotConnected) {
+		return err
+	}
+
+	status, err := t.daemon.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	record, err := actions.LoadStateRecord()
+	if err != nil {
+		return err
+	}
+
+	if status.Connected || record.State != "down" {
+		return fmt.Errorf("state is up after down: daemon connected=%t, state file %s", status.Connected, record.State)
+	}
+	return nil
+}
+
+// checkStateFiles is a function that checks the state file is readable, as it's rewritten by every transition.
+func checkStateFiles() error {
+	record, err := actions.LoadStateRecord()
+	if err != nil {
+		return fmt.Errorf("state file: %s", err)
+	}
+
+	if record.State != "up" && record.State != "down" {
+		return fmt.Errorf("state file has the state %q", record.State)
+	}
+	return nil
+}
+
+// printStressResults is a function that prints the runs and the failures per operation and returns the number of the failed runs.
+func printStressResults(runs []stressRun) int {
+	durations := make(map[string][]time.Duration)
+	failures := make(map[string]int)
+	errs := make(map[string]int)
+	for _, run := range runs {
+		durations[run.Operation] = append(durations[run.Operation], run.Duration)
+		if run.Err != nil {
+			failures[run.Operation]++
+			errs[run.Operation+": "+run.Err.Error()]++
+		}
+	}
+
+	operations := make([]string, 0, len(durations))
+	for operation := range durations {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Operation", "Runs", "Failed", "Median", "Slowest"})
+	table.SetBorder(false)
+	failed := 0
+	for _, operation := range operations {
+		d := durations[operation]
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		failed += failures[operation]
+		table.Append([]string{operation, strconv.Itoa(len(d)), strconv.Itoa(failures[operation]), d[len(d)/2].Round(time.Millisecond).String(), d[len(d)-1].Round(time.Millisecond).String()})
+	}
+	table.Render()
+
+	messages := make([]string, 0, len(errs))
+	for message := range errs {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+	for _, message := range messages {
+		fmt.Printf("%dx %s\n", errs[message], message)
+	}
+
+	return failed
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/crash"
+	"github.com/forestvpn/cli/utils"
+)
+
+// diagnosticsBundle is a function that writes the versions, settings and the state of the Wireguard interface to a temporary file
+// to attach to a support ticket. The contents are scrubbed of URL credentials, emails, tokens, IP addresses and home directories.
+// The caller removes the file.
+func diagnosticsBundle(conf config.Config) (*os.File, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "fvpn %s\n\n", appVersion)
+
+	for _, detail := range buildDetails(utils.ApiHost, conf.Get(config.DaemonAddress)) {
+		fmt.Fprintf(&b, "%s: %s\n", detail[0], detail[1])
+	}
+
+	for _, feature := range buildFeatures(conf) {
+		fmt.Fprintf(&b, "%s: %s\n", feature[0], feature[1])
+	}
+
+	b.WriteString("\nconfig:\n")
+	for _, key := range config.Keys() {
+		if key.Name == config.DaemonToken {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", key.Name, conf.Get(key.Name))
+	}
+
+	fmt.Fprintf(&b, "\ninterface up: %t\n", utils.WireguardInterfaceUp("fvpn0"))
+	if wg, err := utils.Output("wg", "show", "fvpn0"); err == nil {
+		b.Write(wg)
+	}
+
+	file, err := os.CreateTemp("", "fvpn-diagnostics-*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.WriteString(crash.Scrub(b.String())); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"os/exec"
+)
+
+// OpenBrowser is a function to open the url in the default browser, e.g. to sign in.
+// Fails on machines without a desktop, so the url should be printed for the user to open elsewhere as well.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch {
+	case Os == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case Os == "darwin":
+		cmd = exec.Command("open", url)
+	case IsTermux():
+		cmd = exec.Command("termux-open-url", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// PrivateNetworks are the networks that don't route to the internet: private, link-local, loopback and multicast ones,
+// excluded from the tunnel with the internet-only tunnel scope.
+var PrivateNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"127.0.0.0/8",
+	"224.0.0.0/4",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// ParseCIDRs is a function that parses the comma-separated networks, e.g. "10.0.0.0/8, 2001:db8::/32".
+// Addresses without the prefix length are taken as single hosts.
+func ParseCIDRs(value string) ([]string, error) {
+	var networks []string
+	for _, cidr := range strings.Split(value, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if len(cidr) == 0 {
+			continue
+		}
+
+		if ip := net.ParseIP(cidr); ip != nil {
+			networks = append(networks, hostNetwork(ip))
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network: %s", cidr)
+		}
+		networks = append(networks, network.String())
+	}
+
+	return networks, nil
+}
+
+// ExcludeNetworks is a function that returns the smallest set of networks covering allowed without excluded, for both IPv4 and IPv6.
+// Unlike ExcludeDisallowedIps it takes any number of networks to exclude.
+func ExcludeNetworks(allowed []string, excluded []string) ([]string, error) {
+	var networks []*net.IPNet
+	for _, cidr := range allowed {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	for _, cidr := range excluded {
+		_, exclude, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		var remaining []*net.IPNet
+		for _, network := range networks {
+			remaining = append(remaining, subtractNetwork(network, exclude)...)
+		}
+		networks = remaining
+	}
+
+	result := make([]string, 0, len(networks))
+	for _, network := range networks {
+		result = append(result, network.String())
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// HostNetworks is a function that converts the addresses, e.g. DNS servers, into single host networks, e.g. 10.0.0.1/32.
+func HostNetworks(addresses []string) []string {
+	var networks []string
+	for _, address := range addresses {
+		if ip := net.ParseIP(strings.TrimSpace(address)); ip != nil {
+			networks = append(networks, hostNetwork(ip))
+		}
+	}
+
+	return networks
+}
+
+// subtractNetwork is a function that splits network in halves until the parts overlapping exclude are dropped.
+func subtractNetwork(network *net.IPNet, exclude *net.IPNet) []*net.IPNet {
+	ones, bits := network.Mask.Size()
+	excludeOnes, excludeBits := exclude.Mask.Size()
+
+	if bits != excludeBits || (!network.Contains(exclude.IP) && !exclude.Contains(network.IP)) {
+		return []*net.IPNet{network}
+	}
+
+	if excludeOnes <= ones {
+		return nil
+	}
+
+	mask := net.CIDRMask(ones+1, bits)
+	lower := &net.IPNet{IP: network.IP.Mask(mask), Mask: mask}
+	upper := &net.IPNet{IP: make(net.IP, len(lower.IP)), Mask: mask}
+	copy(upper.IP, lower.IP)
+	upper.IP[ones/8] |= 0x80 >> (ones % 8)
+
+	return append(subtractNetwork(lower, exclude), subtractNetwork(upper, exclude)...)
+}
+
+func hostNetwork(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
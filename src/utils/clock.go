@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// MaxClockSkew is a difference between the local and back-end clocks after which the tokens are rejected as issued in the future or expired.
+const MaxClockSkew = 5 * time.Minute
+
+// observedSkew is the clock skew measured by ObserveClock out of the latest response of the back-end, in nanoseconds.
+var observedSkew int64
+
+// observed is set to 1 once ObserveClock measured the clock skew.
+var observed int32
+
+// ObserveClock is a function to measure how far the local clock is off using the Date header of resp to the request sent at sent,
+// so the errors of the request are explained by ExplainClockSkew without asking the back-end again.
+// The result is positive if the local clock is ahead. Returns false if resp has no Date header.
+func ObserveClock(resp *http.Response, sent time.Time) (time.Duration, bool) {
+	received := time.Now()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+
+	// the Date header has a one second precision, so the round-trip time only matters for slow connections
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(date).Round(time.Second)
+	atomic.StoreInt64(&observedSkew, int64(skew))
+	atomic.StoreInt32(&observed, 1)
+	return skew, true
+}
+
+// ExplainClockSkew is a function that replaces err with a hint to fix the system clock if it's off by more than MaxClockSkew
+// according to the latest response of the back-end seen by ObserveClock, as the back-end responds to requests with expired
+// or not yet valid tokens with confusing errors.
+func ExplainClockSkew(err error) error {
+	if err == nil || atomic.LoadInt32(&observed) == 0 {
+		return err
+	}
+
+	skew := time.Duration(atomic.LoadInt64(&observedSkew))
+	direction := "ahead"
+	if skew < 0 {
+		skew, direction = -skew, "behind"
+	}
+
+	if skew <= MaxClockSkew {
+		return err
+	}
+
+	return fmt.Errorf("system clock is %s %s, fix it with ntp, e.g. 'sudo timedatectl set-ntp true': %s", HumanizeDuration(skew), direction, err)
+}
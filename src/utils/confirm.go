@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NonInteractive is a flag that is true when the commands must not prompt, set with --non-interactive, e.g. in scripts run from a terminal.
+var NonInteractive bool
+
+// ErrCancelled is returned by Confirm when the user declines.
+var ErrCancelled = errors.New("cancelled")
+
+// IsInteractive is a function to check whether the commands could prompt the user, i.e. the standard input is a terminal
+// and --non-interactive isn't set.
+func IsInteractive() bool {
+	if NonInteractive {
+		return false
+	}
+
+	return isTerminal(os.Stdin.Fd())
+}
+
+// Confirm is a function that asks the yes/no question before a destructive operation and returns nil once the user answers yes.
+// yes is the --yes flag of the command, which confirms without asking. Without a terminal to ask on, the operation is refused,
+// so scripts have to pass --yes rather than hang on the prompt or proceed unnoticed.
+func Confirm(question string, yes bool) error {
+	if yes {
+		return nil
+	}
+
+	if !IsInteractive() {
+		return errors.New("confirmation required, run again with --yes")
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "y", "yes":
+		return nil
+	}
+
+	return ErrCancelled
+}
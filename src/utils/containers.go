@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// ContainerInterfacePrefixes are the prefixes of the names of the bridges and overlays created by Docker, Podman, libvirt and Kubernetes network plugins.
+var ContainerInterfacePrefixes = []string{"docker", "br-", "cni", "flannel", "cali", "vxlan.calico", "weave", "cilium", "kube-bridge", "podman", "virbr", "lxdbr"}
+
+// ContainerNetwork is a structure representing the network of the container bridge or overlay interface, e.g. 172.17.0.0/16 of docker0.
+type ContainerNetwork struct {
+	Interface string
+	Network   string
+}
+
+// ContainerNetworks is a function that lists the networks of the container interfaces of the host.
+func ContainerNetworks() ([]ContainerNetwork, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []ContainerNetwork
+	for _, iface := range interfaces {
+		if !isContainerInterface(iface.Name) {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+
+			network := &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}
+			networks = append(networks, ContainerNetwork{Interface: iface.Name, Network: network.String()})
+		}
+	}
+
+	return networks, nil
+}
+
+// Overlaps is a function to check whether the networks a and b share any address.
+func Overlaps(a string, b string) bool {
+	_, aNet, err := net.ParseCIDR(a)
+	if err != nil {
+		return false
+	}
+
+	_, bNet, err := net.ParseCIDR(b)
+	if err != nil {
+		return false
+	}
+
+	return aNet.Contains(bNet.IP) || bNet.Contains(aNet.IP)
+}
+
+func isContainerInterface(name string) bool {
+	for _, prefix := range ContainerInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+)
+
+// CountryCode is a structure of a country of ISO 3166-1 with its codes, English name and the other names it's known by,
+// e.g. the official, common and local ones.
+type CountryCode struct {
+	Alpha2  string
+	Alpha3  string
+	Name    string
+	Aliases []string
+}
+
+var countryIndex map[string]CountryCode
+var countryIndexOnce sync.Once
+
+// LookupCountry is a function that resolves the ISO 3166-1 alpha-2 or alpha-3 code or any name of a country, case-insensitively,
+// e.g. USA, US, America and Estados Unidos all to the United States.
+func LookupCountry(query string) (CountryCode, bool) {
+	countryIndexOnce.Do(func() {
+		countryIndex = make(map[string]CountryCode, len(Countries)*4)
+		for _, country := range Countries {
+			for _, key := range append([]string{country.Alpha2, country.Alpha3, country.Name}, country.Aliases...) {
+				// the codes and names of the countries listed first win, e.g. US over the alias of another one
+				if _, ok := countryIndex[countryKey(key)]; !ok {
+					countryIndex[countryKey(key)] = country
+				}
+			}
+		}
+	})
+
+	country, ok := countryIndex[countryKey(query)]
+	return country, ok
+}
+
+// MatchCountry is a function to check whether query names the country with the code and name given, as the locations name it,
+// by the code or any name of the country. The code may be empty where it isn't known.
+func MatchCountry(query string, code string, name string) bool {
+	if strings.EqualFold(query, name) || (len(code) > 0 && strings.EqualFold(query, code)) {
+		return true
+	}
+
+	country, ok := LookupCountry(query)
+	if !ok {
+		return false
+	}
+
+	if len(code) > 0 {
+		return strings.EqualFold(country.Alpha2, code)
+	}
+
+	other, ok := LookupCountry(name)
+	return ok && other.Alpha2 == country.Alpha2
+}
+
+// countryKey is a function that normalizes the code or name of a country for the lookup, e.g. U.S.A. to usa.
+func countryKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(name, ".", "")), " "))
+}
+
+// Countries are the countries of ISO 3166-1.
+var Countries = []CountryCode{
+	{"AD", "AND", "Andorra", []string{"Principality of Andorra"}},
+	{"AE", "ARE", "United Arab Emirates", []string{"UAE", "Emirates"}},
+	{"AF", "AFG", "Afghanistan", []string{"Islamic Republic of Afghanistan"}},
+	{"AG", "ATG", "Antigua and Barbuda", nil},
+	{"AI", "AIA", "Anguilla", nil},
+	{"AL", "ALB", "Albania", []string{"Republic of Albania"}},
+	{"AM", "ARM", "Armenia", []string{"Republic of Armenia", "Հայաստան", "Armenien", "Arménie"}},
+	{"AO", "AGO", "Angola", []string{"Republic of Angola"}},
+	{"AQ", "ATA", "Antarctica", nil},
+	{"AR", "ARG", "Argentina", []string{"Argentine Republic", "Argentinien", "Argentine"}},
+	{"AS", "ASM", "American Samoa", nil},
+	{"AT", "AUT", "Austria", []string{"Republic of Austria", "Österreich", "Autriche", "Австрия"}},
+	{"AU", "AUS", "Australia", []string{"Australien", "Australie", "Австралия"}},
+	{"AW", "ABW", "Aruba", nil},
+	{"AX", "ALA", "Åland Islands", nil},
+	{"AZ", "AZE", "Azerbaijan", []string{"Republic of Azerbaijan"}},
+	{"BA", "BIH", "Bosnia and Herzegovina", []string{"Republic of Bosnia and Herzegovina"}},
+	{"BB", "BRB", "Barbados", nil},
+	{"BD", "BGD", "Bangladesh", []string{"People's Republic of Bangladesh"}},
+	{"BE", "BEL", "Belgium", []string{"Kingdom of Belgium", "België", "Belgique", "Belgien", "Bélgica"}},
+	{"BF", "BFA", "Burkina Faso", nil},
+	{"BG", "BGR", "Bulgaria", []string{"Republic of Bulgaria", "България", "Bulgarien", "Bulgarie"}},
+	{"BH", "BHR", "Bahrain", []string{"Kingdom of Bahrain"}},
+	{"BI", "BDI", "Burundi", []string{"Republic of Burundi"}},
+	{"BJ", "BEN", "Benin", []string{"Republic of Benin"}},
+	{"BL", "BLM", "Saint Barthélemy", nil},
+	{"BM", "BMU", "Bermuda", nil},
+	{"BN", "BRN", "Brunei Darussalam", nil},
+	{"BO", "BOL", "Bolivia, Plurinational State of", []string{"Bolivia", "Plurinational State of Bolivia"}},
+	{"BQ", "BES", "Bonaire, Sint Eustatius and Saba", nil},
+	{"BR", "BRA", "Brazil", []string{"Federative Republic of Brazil", "Brasil", "Brasilien", "Brésil", "Бразилия"}},
+	{"BS", "BHS", "Bahamas", []string{"Commonwealth of the Bahamas"}},
+	{"BT", "BTN", "Bhutan", []string{"Kingdom of Bhutan"}},
+	{"BV", "BVT", "Bouvet Island", nil},
+	{"BW", "BWA", "Botswana", []string{"Republic of Botswana"}},
+	{"BY", "BLR", "Belarus", []string{"Republic of Belarus"}},
+	{"BZ", "BLZ", "Belize", nil},
+	{"CA", "CAN", "Canada", []string{"Kanada", "Канада"}},
+	{"CC", "CCK", "Cocos (Keeling) Islands", nil},
+	{"CD", "COD", "Congo, The Democratic Republic of the", []string{"DR Congo", "DRC"}},
+	{"CF", "CAF", "Central African Republic", nil},
+	{"CG", "COG", "Congo", []string{"Republic of the Congo"}},
+	{"CH", "CHE", "Switzerland", []string{"Swiss Confederation", "Schweiz", "Suisse", "Svizzera", "Suiza", "Швейцария"}},
+	{"CI", "CIV", "Côte d'Ivoire", []string{"Republic of Côte d'Ivoire", "Ivory Coast"}},
+	{"CK", "COK", "Cook Islands", nil},
+	{"CL", "CHL", "Chile", []string{"Republic of Chile", "Chili"}},
+	{"CM", "CMR", "Cameroon", []string{"Republic of Cameroon"}},
+	{"CN", "CHN", "China", []string{"People's Republic of China", "中国", "Chine", "Китай"}},
+	{"CO", "COL", "Colombia", []string{"Republic of Colombia", "Kolumbien", "Colombie"}},
+	{"CR", "CRI", "Costa Rica", []string{"Republic of Costa Rica"}},
+	{"CU", "CUB", "Cuba", []string{"Republic of Cuba"}},
+	{"CV", "CPV", "Cabo Verde", []string{"Republic of Cabo Verde"}},
+	{"CW", "CUW", "Curaçao", nil},
+	{"CX", "CXR", "Christmas Island", nil},
+	{"CY", "CYP", "Cyprus", []string{"Republic of Cyprus", "Κύπρος", "Zypern", "Chypre"}},
+	{"CZ", "CZE", "Czechia", []string{"Czech Republic", "Česko", "Tschechien", "Tchéquie", "Чехия"}},
+	{"DE", "DEU", "Germany", []string{"Federal Republic of Germany", "Deutschland", "Allemagne", "Alemania", "Germania", "Duitsland", "Niemcy", "Германия"}},
+	{"DJ", "DJI", "Djibouti", []string{"Republic of Djibouti"}},
+	{"DK", "DNK", "Denmark", []string{"Kingdom of Denmark", "Danmark", "Dänemark", "Danemark", "Dinamarca", "Дания"}},
+	{"DM", "DMA", "Dominica", []string{"Commonwealth of Dominica"}},
+	{"DO", "DOM", "Dominican Republic", nil},
+	{"DZ", "DZA", "Algeria", []string{"People's Democratic Republic of Algeria"}},
+	{"EC", "ECU", "Ecuador", []string{"Republic of Ecuador"}},
+	{"EE", "EST", "Estonia", []string{"Republic of Estonia", "Eesti", "Estland", "Estonie"}},
+	{"EG", "EGY", "Egypt", []string{"Arab Republic of Egypt", "مصر", "Ägypten", "Égypte"}},
+	{"EH", "ESH", "Western Sahara", nil},
+	{"ER", "ERI", "Eritrea", []string{"the State of Eritrea"}},
+	{"ES", "ESP", "Spain", []string{"Kingdom of Spain", "España", "Spanien", "Espagne", "Spagna", "Hiszpania", "Испания"}},
+	{"ET", "ETH", "Ethiopia", []string{"Federal Democratic Republic of Ethiopia"}},
+	{"FI", "FIN", "Finland", []string{"Republic of Finland", "Suomi", "Finnland", "Finlande", "Finlandia", "Финляндия"}},
+	{"FJ", "FJI", "Fiji", []string{"Republic of Fiji"}},
+	{"FK", "FLK", "Falkland Islands (Malvinas)", nil},
+	{"FM", "FSM", "Micronesia, Federated States of", []string{"Federated States of Micronesia"}},
+	{"FO", "FRO", "Faroe Islands", nil},
+	{"FR", "FRA", "France", []string{"French Republic", "Frankreich", "Francia", "Frankrijk", "Francja", "Франция"}},
+	{"GA", "GAB", "Gabon", []string{"Gabonese Republic"}},
+	{"GB", "GBR", "United Kingdom", []string{"United Kingdom of Great Britain and Northern Ireland", "UK", "Great Britain", "Britain", "England", "Royaume-Uni", "Reino Unido", "Vereinigtes Königreich", "Regno Unito", "Великобритания"}},
+	{"GD", "GRD", "Grenada", nil},
+	{"GE", "GEO", "Georgia", []string{"საქართველო", "Georgien", "Géorgie"}},
+	{"GF", "GUF", "French Guiana", nil},
+	{"GG", "GGY", "Guernsey", nil},
+	{"GH", "GHA", "Ghana", []string{"Republic of Ghana"}},
+	{"GI", "GIB", "Gibraltar", nil},
+	{"GL", "GRL", "Greenland", nil},
+	{"GM", "GMB", "Gambia", []string{"Republic of the Gambia"}},
+	{"GN", "GIN", "Guinea", []string{"Republic of Guinea"}},
+	{"GP", "GLP", "Guadeloupe", nil},
+	{"GQ", "GNQ", "Equatorial Guinea", []string{"Republic of Equatorial Guinea"}},
+	{"GR", "GRC", "Greece", []string{"Hellenic Republic", "Ελλάδα", "Griechenland", "Grèce", "Grecia"}},
+	{"GS", "SGS", "South Georgia and the South Sandwich Islands", nil},
+	{"GT", "GTM", "Guatemala", []string{"Republic of Guatemala"}},
+	{"GU", "GUM", "Guam", nil},
+	{"GW", "GNB", "Guinea-Bissau", []string{"Republic of Guinea-Bissau"}},
+	{"GY", "GUY", "Guyana", []string{"Republic of Guyana"}},
+	{"HK", "HKG", "Hong Kong", []string{"Hong Kong Special Administrative Region of China", "香港"}},
+	{"HM", "HMD", "Heard Island and McDonald Islands", nil},
+	{"HN", "HND", "Honduras", []string{"Republic of Honduras"}},
+	{"HR", "HRV", "Croatia", []string{"Republic of Croatia", "Hrvatska", "Kroatien", "Croatie"}},
+	{"HT", "HTI", "Haiti", []string{"Republic of Haiti"}},
+	{"HU", "HUN", "Hungary", []string{"Magyarország", "Ungarn", "Hongrie", "Hungría"}},
+	{"ID", "IDN", "Indonesia", []string{"Republic of Indonesia", "Indonesien", "Indonésie"}},
+	{"IE", "IRL", "Ireland", []string{"Éire", "Irland", "Irlande", "Irlanda"}},
+	{"IL", "ISR", "Israel", []string{"State of Israel", "ישראל"}},
+	{"IM", "IMN", "Isle of Man", nil},
+	{"IN", "IND", "India", []string{"Republic of India", "Bharat", "भारत", "Indien", "Inde", "Индия"}},
+	{"IO", "IOT", "British Indian Ocean Territory", nil},
+	{"IQ", "IRQ", "Iraq", []string{"Republic of Iraq"}},
+	{"IR", "IRN", "Iran, Islamic Republic of", []string{"Iran", "Islamic Republic of Iran"}},
+	{"IS", "ISL", "Iceland", []string{"Republic of Iceland", "Ísland", "Island", "Islande"}},
+	{"IT", "ITA", "Italy", []string{"Italian Republic", "Italia", "Italien", "Italie", "Włochy", "Италия"}},
+	{"JE", "JEY", "Jersey", nil},
+	{"JM", "JAM", "Jamaica", nil},
+	{"JO", "JOR", "Jordan", []string{"Hashemite Kingdom of Jordan"}},
+	{"JP", "JPN", "Japan", []string{"Nippon", "Nihon", "日本", "Japon", "Japón", "Япония"}},
+	{"KE", "KEN", "Kenya", []string{"Republic of Kenya"}},
+	{"KG", "KGZ", "Kyrgyzstan", []string{"Kyrgyz Republic"}},
+	{"KH", "KHM", "Cambodia", []string{"Kingdom of Cambodia"}},
+	{"KI", "KIR", "Kiribati", []string{"Republic of Kiribati"}},
+	{"KM", "COM", "Comoros", []string{"Union of the Comoros"}},
+	{"KN", "KNA", "Saint Kitts and Nevis", nil},
+	{"KP", "PRK", "Korea, Democratic People's Republic of", []string{"North Korea", "Democratic People's Republic of Korea"}},
+	{"KR", "KOR", "Korea, Republic of", []string{"South Korea", "Korea", "대한민국", "한국", "Südkorea", "Corée du Sud", "Южная Корея"}},
+	{"KW", "KWT", "Kuwait", []string{"State of Kuwait"}},
+	{"KY", "CYM", "Cayman Islands", nil},
+	{"KZ", "KAZ", "Kazakhstan", []string{"Republic of Kazakhstan", "Қазақстан", "Казахстан", "Kasachstan"}},
+	{"LA", "LAO", "Lao People's Democratic Republic", []string{"Laos"}},
+	{"LB", "LBN", "Lebanon", []string{"Lebanese Republic"}},
+	{"LC", "LCA", "Saint Lucia", nil},
+	{"LI", "LIE", "Liechtenstein", []string{"Principality of Liechtenstein"}},
+	{"LK", "LKA", "Sri Lanka", []string{"Democratic Socialist Republic of Sri Lanka"}},
+	{"LR", "LBR", "Liberia", []string{"Republic of Liberia"}},
+	{"LS", "LSO", "Lesotho", []string{"Kingdom of Lesotho"}},
+	{"LT", "LTU", "Lithuania", []string{"Republic of Lithuania", "Lietuva", "Litauen", "Lituanie"}},
+	{"LU", "LUX", "Luxembourg", []string{"Grand Duchy of Luxembourg", "Lëtzebuerg", "Luxemburg"}},
+	{"LV", "LVA", "Latvia", []string{"Republic of Latvia", "Latvija", "Lettland", "Lettonie"}},
+	{"LY", "LBY", "Libya", nil},
+	{"MA", "MAR", "Morocco", []string{"Kingdom of Morocco"}},
+	{"MC", "MCO", "Monaco", []string{"Principality of Monaco"}},
+	{"MD", "MDA", "Moldova, Republic of", []string{"Moldova", "Republic of Moldova"}},
+	{"ME", "MNE", "Montenegro", nil},
+	{"MF", "MAF", "Saint Martin (French part)", nil},
+	{"MG", "MDG", "Madagascar", []string{"Republic of Madagascar"}},
+	{"MH", "MHL", "Marshall Islands", []string{"Republic of the Marshall Islands"}},
+	{"MK", "MKD", "North Macedonia", []string{"Republic of North Macedonia", "Macedonia"}},
+	{"ML", "MLI", "Mali", []string{"Republic of Mali"}},
+	{"MM", "MMR", "Myanmar", []string{"Republic of Myanmar"}},
+	{"MN", "MNG", "Mongolia", nil},
+	{"MO", "MAC", "Macao", []string{"Macao Special Administrative Region of China"}},
+	{"MP", "MNP", "Northern Mariana Islands", []string{"Commonwealth of the Northern Mariana Islands"}},
+	{"MQ", "MTQ", "Martinique", nil},
+	{"MR", "MRT", "Mauritania", []string{"Islamic Republic of Mauritania"}},
+	{"MS", "MSR", "Montserrat", nil},
+	{"MT", "MLT", "Malta", []string{"Republic of Malta", "Malte"}},
+	{"MU", "MUS", "Mauritius", []string{"Republic of Mauritius"}},
+	{"MV", "MDV", "Maldives", []string{"Republic of Maldives"}},
+	{"MW", "MWI", "Malawi", []string{"Republic of Malawi"}},
+	{"MX", "MEX", "Mexico", []string{"United Mexican States", "México", "Mexiko", "Mexique"}},
+	{"MY", "MYS", "Malaysia", nil},
+	{"MZ", "MOZ", "Mozambique", []string{"Republic of Mozambique"}},
+	{"NA", "NAM", "Namibia", []string{"Republic of Namibia"}},
+	{"NC", "NCL", "New Caledonia", nil},
+	{"NE", "NER", "Niger", []string{"Republic of the Niger"}},
+	{"NF", "NFK", "Norfolk Island", nil},
+	{"NG", "NGA", "Nigeria", []string{"Federal Republic of Nigeria"}},
+	{"NI", "NIC", "Nicaragua", []string{"Republic of Nicaragua"}},
+	{"NL", "NLD", "Netherlands", []string{"Kingdom of the Netherlands", "Holland", "Nederland", "Niederlande", "Pays-Bas", "Países Bajos", "Paesi Bassi", "Нидерланды"}},
+	{"NO", "NOR", "Norway", []string{"Kingdom of Norway", "Norge", "Norwegen", "Norvège", "Noruega", "Норвегия"}},
+	{"NP", "NPL", "Nepal", []string{"Federal Democratic Republic of Nepal"}},
+	{"NR", "NRU", "Nauru", []string{"Republic of Nauru"}},
+	{"NU", "NIU", "Niue", nil},
+	{"NZ", "NZL", "New Zealand", []string{"Aotearoa", "Neuseeland", "Nouvelle-Zélande"}},
+	{"OM", "OMN", "Oman", []string{"Sultanate of Oman"}},
+	{"PA", "PAN", "Panama", []string{"Republic of Panama"}},
+	{"PE", "PER", "Peru", []string{"Republic of Peru", "Perú", "Pérou"}},
+	{"PF", "PYF", "French Polynesia", nil},
+	{"PG", "PNG", "Papua New Guinea", []string{"Independent State of Papua New Guinea"}},
+	{"PH", "PHL", "Philippines", []string{"Republic of the Philippines", "Pilipinas", "Philippinen"}},
+	{"PK", "PAK", "Pakistan", []string{"Islamic Republic of Pakistan"}},
+	{"PL", "POL", "Poland", []string{"Republic of Poland", "Polska", "Polen", "Pologne", "Polonia", "Польша"}},
+	{"PM", "SPM", "Saint Pierre and Miquelon", nil},
+	{"PN", "PCN", "Pitcairn", nil},
+	{"PR", "PRI", "Puerto Rico", nil},
+	{"PS", "PSE", "Palestine, State of", []string{"the State of Palestine", "Palestine"}},
+	{"PT", "PRT", "Portugal", []string{"Portuguese Republic", "Portogallo"}},
+	{"PW", "PLW", "Palau", []string{"Republic of Palau"}},
+	{"PY", "PRY", "Paraguay", []string{"Republic of Paraguay"}},
+	{"QA", "QAT", "Qatar", []string{"State of Qatar"}},
+	{"RE", "REU", "Réunion", nil},
+	{"RO", "ROU", "Romania", []string{"România", "Rumänien", "Roumanie", "Rumania"}},
+	{"RS", "SRB", "Serbia", []string{"Republic of Serbia", "Србија", "Srbija", "Serbien", "Serbie"}},
+	{"RU", "RUS", "Russian Federation", []string{"Russia", "Россия", "Russland", "Russie", "Rusia"}},
+	{"RW", "RWA", "Rwanda", []string{"Rwandese Republic"}},
+	{"SA", "SAU", "Saudi Arabia", []string{"Kingdom of Saudi Arabia", "Saudi-Arabien", "Arabie saoudite"}},
+	{"SB", "SLB", "Solomon Islands", nil},
+	{"SC", "SYC", "Seychelles", []string{"Republic of Seychelles"}},
+	{"SD", "SDN", "Sudan", []string{"Republic of the Sudan"}},
+	{"SE", "SWE", "Sweden", []string{"Kingdom of Sweden", "Sverige", "Schweden", "Suède", "Suecia", "Швеция"}},
+	{"SG", "SGP", "Singapore", []string{"Republic of Singapore", "Singapur", "Singapour"}},
+	{"SH", "SHN", "Saint Helena, Ascension and Tristan da Cunha", nil},
+	{"SI", "SVN", "Slovenia", []string{"Republic of Slovenia", "Slovenija", "Slowenien", "Slovénie"}},
+	{"SJ", "SJM", "Svalbard and Jan Mayen", nil},
+	{"SK", "SVK", "Slovakia", []string{"Slovak Republic", "Slovensko", "Slowakei", "Slovaquie"}},
+	{"SL", "SLE", "Sierra Leone", []string{"Republic of Sierra Leone"}},
+	{"SM", "SMR", "San Marino", []string{"Republic of San Marino"}},
+	{"SN", "SEN", "Senegal", []string{"Republic of Senegal"}},
+	{"SO", "SOM", "Somalia", []string{"Federal Republic of Somalia"}},
+	{"SR", "SUR", "Suriname", []string{"Republic of Suriname"}},
+	{"SS", "SSD", "South Sudan", []string{"Republic of South Sudan"}},
+	{"ST", "STP", "Sao Tome and Principe", []string{"Democratic Republic of Sao Tome and Principe"}},
+	{"SV", "SLV", "El Salvador", []string{"Republic of El Salvador"}},
+	{"SX", "SXM", "Sint Maarten (Dutch part)", nil},
+	{"SY", "SYR", "Syrian Arab Republic", []string{"Syria"}},
+	{"SZ", "SWZ", "Eswatini", []string{"Kingdom of Eswatini"}},
+	{"TC", "TCA", "Turks and Caicos Islands", nil},
+	{"TD", "TCD", "Chad", []string{"Republic of Chad"}},
+	{"TF", "ATF", "French Southern Territories", nil},
+	{"TG", "TGO", "Togo", []string{"Togolese Republic"}},
+	{"TH", "THA", "Thailand", []string{"Kingdom of Thailand", "ประเทศไทย", "Thaïlande"}},
+	{"TJ", "TJK", "Tajikistan", []string{"Republic of Tajikistan"}},
+	{"TK", "TKL", "Tokelau", nil},
+	{"TL", "TLS", "Timor-Leste", []string{"Democratic Republic of Timor-Leste"}},
+	{"TM", "TKM", "Turkmenistan", nil},
+	{"TN", "TUN", "Tunisia", []string{"Republic of Tunisia"}},
+	{"TO", "TON", "Tonga", []string{"Kingdom of Tonga"}},
+	{"TR", "TUR", "Türkiye", []string{"Republic of Türkiye", "Turkey", "Türkei", "Turquie", "Turquía", "Турция"}},
+	{"TT", "TTO", "Trinidad and Tobago", []string{"Republic of Trinidad and Tobago"}},
+	{"TV", "TUV", "Tuvalu", nil},
+	{"TW", "TWN", "Taiwan, Province of China", []string{"Taiwan", "臺灣", "台灣"}},
+	{"TZ", "TZA", "Tanzania, United Republic of", []string{"Tanzania", "United Republic of Tanzania"}},
+	{"UA", "UKR", "Ukraine", []string{"Україна", "Украина"}},
+	{"UG", "UGA", "Uganda", []string{"Republic of Uganda"}},
+	{"UM", "UMI", "United States Minor Outlying Islands", nil},
+	{"US", "USA", "United States", []string{"United States of America", "USA", "US", "America", "Estados Unidos", "États-Unis", "Vereinigte Staaten", "Stati Uniti", "Соединённые Штаты", "США"}},
+	{"UY", "URY", "Uruguay", []string{"Eastern Republic of Uruguay"}},
+	{"UZ", "UZB", "Uzbekistan", []string{"Republic of Uzbekistan"}},
+	{"VA", "VAT", "Holy See (Vatican City State)", []string{"Vatican"}},
+	{"VC", "VCT", "Saint Vincent and the Grenadines", nil},
+	{"VE", "VEN", "Venezuela, Bolivarian Republic of", []string{"Venezuela", "Bolivarian Republic of Venezuela"}},
+	{"VG", "VGB", "Virgin Islands, British", []string{"British Virgin Islands"}},
+	{"VI", "VIR", "Virgin Islands, U.S.", []string{"Virgin Islands of the United States"}},
+	{"VN", "VNM", "Viet Nam", []string{"Vietnam", "Socialist Republic of Viet Nam", "Việt Nam"}},
+	{"VU", "VUT", "Vanuatu", []string{"Republic of Vanuatu"}},
+	{"WF", "WLF", "Wallis and Futuna", nil},
+	{"WS", "WSM", "Samoa", []string{"Independent State of Samoa"}},
+	{"YE", "YEM", "Yemen", []string{"Republic of Yemen"}},
+	{"YT", "MYT", "Mayotte", nil},
+	{"ZA", "ZAF", "South Africa", []string{"Republic of South Africa", "Südafrika", "Afrique du Sud", "Sudáfrica"}},
+	{"ZM", "ZMB", "Zambia", []string{"Republic of Zambia"}},
+	{"ZW", "ZWE", "Zimbabwe", []string{"Republic of Zimbabwe"}},
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandTimeout is a time given to wg-quick and similar shell commands to complete.
+const CommandTimeout = 30 * time.Second
+
+// Run is a function that executes the shell command with CommandTimeout.
+// On failure it returns an error with the command output, e.g. "wg-quick up: resolvconf: command not found",
+// instead of a bare exit status.
+func Run(name string, args ...string) error {
+	return RunInput("", name, args...)
+}
+
+// RunInput is a function that executes the shell command like Run, feeding input to its standard input, e.g. to resolvconf.
+func RunInput(input string, name string, args ...string) error {
+	defer RecordTiming("exec", commandName(name, args), time.Now())
+	command, commandArgs, input, timeout, err := elevate(input, name, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, commandArgs...)
+	if len(input) > 0 {
+		cmd.Stdin = strings.NewReader(input)
+	}
+
+	output, err := cmd.CombinedOutput()
+	output = []byte(strings.TrimSpace(string(output)))
+
+	if Verbose && len(output) > 0 {
+		InfoLogger.Printf("%s %s\n%s\n", name, strings.Join(args, " "), string(output))
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s did not complete within %s", commandName(name, args), timeout)
+	}
+
+	if err != nil {
+		if len(output) > 0 {
+			return fmt.Errorf("%s: %s", commandName(name, args), lastLine(string(output)))
+		}
+		return fmt.Errorf("%s: %s", commandName(name, args), err)
+	}
+
+	return nil
+}
+
+// Output is a function that executes the shell command with CommandTimeout and returns its combined output.
+func Output(name string, args ...string) ([]byte, error) {
+	defer RecordTiming("exec", commandName(name, args), time.Now())
+	command, commandArgs, input, timeout, err := elevate("", name, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, commandArgs...)
+	if len(input) > 0 {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	return cmd.CombinedOutput()
+}
+
+// commandName is a function that returns the command name with its subcommand, e.g. "wg-quick up", leaving out paths and keys.
+func commandName(name string, args []string) string {
+	if len(args) > 0 && !strings.ContainsAny(args[0], "/\\=") && !strings.HasPrefix(args[0], "-") {
+		return name + " " + args[0]
+	}
+	return name
+}
+
+// lastLine is a function that returns the last line of the output, which is where the shell commands print the cause of failure.
+func lastLine(output string) string {
+	lines := strings.Split(output, "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// IsAdmin is a function to check whether fvpn runs as root, or as the administrator on Windows.
+func IsAdmin() bool {
+	if Os == "windows" {
+		// only the administrators can list the sessions of the server service
+		return exec.Command("net", "session").Run() == nil
+	}
+
+	return os.Geteuid() == 0
+}
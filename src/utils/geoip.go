@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GeoIPURL is a trace endpoint responding with the country of the client as loc=XX line.
+var GeoIPURL = "https://www.cloudflare.com/cdn-cgi/trace"
+
+// EgressCountry is a function to get the ISO 3166-1 alpha-2 code of the country the requests to the internet come from.
+func EgressCountry() (string, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(GeoIPURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("GeoIP lookup failed: " + resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "loc=") {
+			return strings.ToUpper(strings.TrimPrefix(line, "loc=")), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("GeoIP lookup responded without country")
+}
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedactedKeys are the JSON fields, form fields, query parameters and HTTP headers replaced with [redacted] in the recorded API interactions.
+var RedactedKeys = []string{"authorization", "cookie", "set-cookie", "priv_key", "ps_key", "token", "access_token", "refresh_token", "password", "email"}
+
+// HAR is a structure representing the subset of the HTTP Archive 1.2 format written by HARRecorder.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is a structure representing the log of the HAR.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator is a structure representing the application that wrote the HAR.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a structure representing a single request along with its response.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+}
+
+// HARRequest is a structure representing the request of the HAREntry.
+type HARRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []HARHeader `json:"headers"`
+	PostData    *HARContent `json:"postData,omitempty"`
+}
+
+// HARResponse is a structure representing the response of the HAREntry.
+type HARResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []HARHeader `json:"headers"`
+	Content     HARContent  `json:"content"`
+}
+
+// HARHeader is a structure representing an HTTP header.
+type HARHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARContent is a structure representing the body of the request or response.
+type HARContent struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARRecorder is a structure that records the API interactions into the HAR file at Path with the secrets redacted.
+type HARRecorder struct {
+	Path string
+	har  HAR
+	mu   sync.Mutex
+}
+
+// NewHARRecorder is a factory function that returns the HARRecorder writing to path.
+func NewHARRecorder(path string) *HARRecorder {
+	return &HARRecorder{
+		Path: path,
+		har:  HAR{Log: HARLog{Version: "1.2", Creator: HARCreator{Name: "fvpn", Version: AppVersion}, Entries: []HAREntry{}}},
+	}
+}
+
+// Record is a method to add the interaction started at the time given to the HAR and write it out,
+// so the file is complete even if the command is interrupted.
+func (r *HARRecorder) Record(started time.Time, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) error {
+	entry := HAREntry{
+		StartedDateTime: started,
+		Time:            float64(time.Since(started)) / float64(time.Millisecond),
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         RedactURL(req.URL),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     harHeaders(req.Header),
+		},
+		Response: HARResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Headers:     harHeaders(resp.Header),
+			Content:     HARContent{MimeType: resp.Header.Get("Content-Type"), Text: RedactBody(respBody, resp.Header.Get("Content-Type"))},
+		},
+	}
+
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &HARContent{MimeType: req.Header.Get("Content-Type"), Text: RedactBody(reqBody, req.Header.Get("Content-Type"))}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.har.Log.Entries = append(r.har.Log.Entries, entry)
+	data, err := json.MarshalIndent(r.har, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.Path, data, 0600)
+}
+
+// RedactJSON is a function that replaces the values of RedactedKeys in the JSON body with [redacted].
+// Bodies that are not JSON are returned as they are.
+func RedactJSON(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+
+	data, err := json.Marshal(redact(v))
+	if err != nil {
+		return string(body)
+	}
+
+	return string(data)
+}
+
+// RedactBody is a function that redacts the body of the request or response with contentType: the values of RedactedKeys
+// are replaced in JSON and form bodies, and any other body is replaced as a whole with a placeholder, as it can't be told what it holds,
+// e.g. a multipart upload of the diagnostics.
+func RedactBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if json.Valid(body) {
+		return RedactJSON(body)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(contentType)
+	if mimeType == "application/x-www-form-urlencoded" {
+		return redactQuery(string(body))
+	}
+
+	if len(mimeType) == 0 {
+		mimeType = "unknown type"
+	}
+	return fmt.Sprintf("[redacted %d bytes of %s]", len(body), mimeType)
+}
+
+// RedactURL is a function that returns u with the values of RedactedKeys in the query replaced with [redacted].
+// The other parameters are kept as they are and in their order, so the requests are still matched by HARReplayer.
+func RedactURL(u *url.URL) string {
+	redacted := *u
+	redacted.RawQuery = redactQuery(u.RawQuery)
+	return redacted.String()
+}
+
+// redactQuery is a function that replaces the values of RedactedKeys in the URL-encoded query or form with [redacted].
+func redactQuery(query string) string {
+	if len(query) == 0 {
+		return query
+	}
+
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && isRedacted(name) {
+			pairs[i] = key + "=" + url.QueryEscape("[redacted]")
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// ReadHAR is a function that reads the HAR file at path.
+func ReadHAR(path string) (HAR, error) {
+	var har HAR
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return har, err
+	}
+
+	if err := json.Unmarshal(data, &har); err != nil {
+		return har, fmt.Errorf("invalid HAR file %s: %s", path, err)
+	}
+
+	return har, nil
+}
+
+// HARReplayer is a structure that serves the responses of the HAR to the requests with the same method, path and query.
+// The responses to the repeated requests are served in the recorded order, the last one repeating once they run out.
+type HARReplayer struct {
+	entries map[string][]HAREntry
+	served  map[string]int
+	mu      sync.Mutex
+}
+
+// NewHARReplayer is a factory function that returns the HARReplayer of har.
+func NewHARReplayer(har HAR) *HARReplayer {
+	r := &HARReplayer{entries: make(map[string][]HAREntry), served: make(map[string]int)}
+	for _, entry := range har.Log.Entries {
+		req, err := http.NewRequest(entry.Request.Method, entry.Request.URL, nil)
+		if err != nil {
+			continue
+		}
+		key := replayKey(req)
+		r.entries[key] = append(r.entries[key], entry)
+	}
+
+	return r
+}
+
+// ServeHTTP is a method that implements http.Handler.
+func (r *HARReplayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	key := replayKey(req)
+	entries := r.entries[key]
+	i := r.served[key]
+	r.served[key]++
+	r.mu.Unlock()
+
+	if len(entries) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"code": "not_recorded", "message": key + " is not in the capture"})
+		return
+	}
+
+	if i >= len(entries) {
+		i = len(entries) - 1
+	}
+
+	response := entries[i].Response
+	for _, header := range response.Headers {
+		// the body is written out as recorded, so the original framing headers don't apply
+		switch strings.ToLower(header.Name) {
+		case "content-length", "content-encoding", "transfer-encoding", "connection":
+			continue
+		}
+		w.Header().Add(header.Name, header.Value)
+	}
+
+	w.WriteHeader(response.Status)
+	_, _ = w.Write([]byte(response.Content.Text))
+}
+
+func replayKey(req *http.Request) string {
+	key := req.Method + " " + req.URL.Path
+	if len(req.URL.RawQuery) > 0 {
+		key += "?" + req.URL.RawQuery
+	}
+	return key
+}
+
+func harHeaders(header http.Header) []HARHeader {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []HARHeader{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			if isRedacted(name) {
+				value = "[redacted]"
+			}
+			headers = append(headers, HARHeader{Name: name, Value: value})
+		}
+	}
+
+	return headers
+}
+
+func redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isRedacted(key) {
+				value[key] = "[redacted]"
+			} else {
+				value[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redact(item)
+		}
+	}
+
+	return v
+}
+
+func isRedacted(key string) bool {
+	for _, redacted := range RedactedKeys {
+		if strings.EqualFold(key, redacted) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HelperName is the name of the fvpn helper, the running fvpn acts as the helper when it's run under it.
+const HelperName = "fvpn-helper"
+
+// HelperPath is where 'fvpn system install' installs the helper run with pkexec to change the fvpn0 interface and the hosts file without sudo.
+var HelperPath = "/usr/libexec/" + HelperName
+
+// HelperInterface is the only Wireguard interface the helper changes.
+const HelperInterface = "fvpn0"
+
+// HelperDir is a directory writable by root only, where the helper keeps the configurations it was given, as wg-quick names the interface after the file.
+var HelperDir = "/run/" + HelperName
+
+// helperInputLimit is the size of the largest configuration or blocklist the helper reads.
+const helperInputLimit = 1 << 20
+
+// hookPattern matches the PostUp and PostDown commands of wg-quick adding and removing the policy routing rules, see actions.Routing.Hooks.
+var hookPattern = regexp.MustCompile(`^ip -[46] rule (add|del) (fwmark 0/0xffffffff table [0-9]+|table main suppress_prefixlength 0) priority [0-9]+( \|\| true)?$`)
+
+// helperCommand is a function that maps the command changing the fvpn0 interface to the arguments of the helper and the configuration file it's given as input.
+// Returns no arguments for the commands the helper doesn't run, they aren't elevated.
+func helperCommand(name string, args []string) ([]string, string) {
+	switch {
+	case name == HelperName:
+		return args, ""
+	case name == "wg-quick" && len(args) == 2 && (args[0] == "up" || args[0] == "down" || args[0] == "strip"):
+		if filepath.Base(args[1]) == HelperInterface+".conf" {
+			return args[:1], args[1]
+		}
+	case name == "wg" && len(args) == 3 && (args[0] == "setconf" || args[0] == "syncconf") && args[1] == HelperInterface:
+		return args[:1], args[2]
+	case name == "wg" && len(args) > 2 && args[0] == "set" && args[1] == HelperInterface:
+		return append([]string{"set"}, args[2:]...), ""
+	}
+
+	return nil, ""
+}
+
+// RunHelper is a function that runs the helper with pkexec, feeding input to it, e.g. the blocked domains.
+// Returns false if the helper isn't available, i.e. fvpn runs as root or the polkit policy isn't installed.
+func RunHelper(input string, args ...string) (bool, error) {
+	if _, ok := pkexecPath(); !ok {
+		return false, nil
+	}
+
+	return true, RunInput(input, HelperName, args...)
+}
+
+// Helper is a function that runs as root under pkexec and makes the change given with args:
+//   - up, down or strip: wg-quick with the fvpn0 configuration read from input.
+//   - setconf or syncconf: wg with the fvpn0 configuration read from input.
+//   - set peer KEY persistent-keepalive SECONDS or endpoint HOST:PORT: wg set on fvpn0.
+//   - hosts: WriteHostsBlocklist with the domains read from input, one per line.
+//
+// The configurations are checked with ValidateWireguardConfig first, so the helper can't be used to run other commands as root.
+func Helper(args []string, input io.Reader, output io.Writer) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%s is run by fvpn with pkexec", HelperName)
+	}
+
+	if len(args) == 0 {
+		return errors.New("missing command")
+	}
+
+	switch args[0] {
+	case "up", "down", "strip":
+		if len(args) != 1 {
+			return fmt.Errorf("unexpected arguments of %s", args[0])
+		}
+
+		path, err := writeHelperConfig(input, HelperInterface+".conf")
+		if err != nil {
+			return err
+		}
+		return runHelperCommand(output, "wg-quick", args[0], path)
+	case "setconf", "syncconf":
+		if len(args) != 1 {
+			return fmt.Errorf("unexpected arguments of %s", args[0])
+		}
+
+		path, err := writeHelperConfig(input, HelperInterface+"-wg.conf")
+		if err != nil {
+			return err
+		}
+		return runHelperCommand(output, "wg", args[0], HelperInterface, path)
+	case "set":
+		if err := validatePeerSettings(args[1:]); err != nil {
+			return err
+		}
+		return runHelperCommand(output, "wg", append([]string{"set", HelperInterface}, args[1:]...)...)
+	case "hosts":
+		if len(args) != 1 {
+			return errors.New("unexpected arguments of hosts")
+		}
+
+		var domains []string
+		scanner := bufio.NewScanner(io.LimitReader(input, helperInputLimit))
+		for scanner.Scan() {
+			domain := strings.TrimSpace(scanner.Text())
+			if len(domain) == 0 {
+				continue
+			}
+			if !IsDomain(domain) {
+				return fmt.Errorf("invalid domain %q", domain)
+			}
+			domains = append(domains, domain)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return WriteHostsBlocklist(domains)
+	}
+
+	return fmt.Errorf("unknown command %s", args[0])
+}
+
+// writeHelperConfig is a function that validates the Wireguard configuration read from input and writes it to name in HelperDir.
+func writeHelperConfig(input io.Reader, name string) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(input, helperInputLimit))
+	if err != nil {
+		return "", err
+	}
+
+	if err := ValidateWireguardConfig(string(data)); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(HelperDir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(HelperDir, name)
+	return path, WriteFileAtomic(path, data, 0600)
+}
+
+// runHelperCommand is a function that runs the command for the helper, passing its output on to fvpn.
+func runHelperCommand(output io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
+
+// ValidateWireguardConfig is a function to check that the Wireguard configuration only has the settings written by fvpn, see actions.AuthClientWrapper.SetLocation,
+// with their values in the expected format. The PostUp and PostDown commands may only add and remove the policy routing rules of the tunnel.
+func ValidateWireguardConfig(config string) error {
+	section := ""
+	for i, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if line == "[Interface]" || line == "[Peer]" {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found || len(section) == 0 {
+			return fmt.Errorf("line %d: unexpected %q", i+1, line)
+		}
+
+		key = strings.TrimSpace(key)
+		if err := validateWireguardSetting(section, key, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("line %d: %s: %s", i+1, key, err)
+		}
+	}
+
+	return nil
+}
+
+// validateWireguardSetting is a function to check the value of the setting with key in section of the Wireguard configuration.
+func validateWireguardSetting(section string, key string, value string) error {
+	switch section + "." + key {
+	case "Interface.PrivateKey", "Peer.PublicKey", "Peer.PresharedKey":
+		return validateWireguardKey(value)
+	case "Interface.Address", "Peer.AllowedIPs":
+		return eachValue(value, func(v string) bool {
+			_, _, err := net.ParseCIDR(v)
+			return err == nil || net.ParseIP(v) != nil
+		})
+	case "Interface.DNS":
+		return eachValue(value, func(v string) bool { return net.ParseIP(v) != nil || IsDomain(v) })
+	case "Interface.Table":
+		if value == "off" || value == "auto" || isNumber(value) {
+			return nil
+		}
+	case "Interface.FwMark":
+		if _, err := strconv.ParseUint(value, 0, 32); err == nil || value == "off" {
+			return nil
+		}
+	case "Interface.ListenPort", "Interface.MTU":
+		if isNumber(value) {
+			return nil
+		}
+	case "Interface.PostUp", "Interface.PostDown":
+		for _, command := range strings.Split(value, ";") {
+			if !hookPattern.MatchString(strings.TrimSpace(command)) {
+				return fmt.Errorf("unexpected command %q", strings.TrimSpace(command))
+			}
+		}
+		return nil
+	case "Peer.Endpoint":
+		return validateEndpoint(value)
+	case "Peer.PersistentKeepalive":
+		if value == "off" || isNumber(value) {
+			return nil
+		}
+	default:
+		return errors.New("not supported")
+	}
+
+	return fmt.Errorf("invalid value %q", value)
+}
+
+// validatePeerSettings is a function to check the arguments of 'wg set' given to the helper: the peer and its persistent keepalive or endpoint.
+func validatePeerSettings(args []string) error {
+	if len(args) < 4 || len(args)%2 != 0 || args[0] != "peer" {
+		return errors.New("expected peer KEY followed by its settings")
+	}
+
+	if err := validateWireguardKey(args[1]); err != nil {
+		return err
+	}
+
+	for i := 2; i < len(args); i += 2 {
+		var err error
+		switch value := args[i+1]; args[i] {
+		case "persistent-keepalive":
+			if value != "off" && !isNumber(value) {
+				err = fmt.Errorf("invalid persistent keepalive %q", value)
+			}
+		case "endpoint":
+			err = validateEndpoint(value)
+		default:
+			err = fmt.Errorf("unexpected setting %s", args[i])
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateWireguardKey is a function to check that value is a base64 encoded Wireguard key.
+func validateWireguardKey(value string) error {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return errors.New("invalid key")
+	}
+	return nil
+}
+
+// validateEndpoint is a function to check that value is the HOST:PORT of a Wireguard endpoint.
+func validateEndpoint(value string) error {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil || !isNumber(port) || (net.ParseIP(host) == nil && !IsDomain(host)) {
+		return fmt.Errorf("invalid endpoint %q", value)
+	}
+	return nil
+}
+
+// eachValue is a function to check every value of the comma-separated list with valid.
+func eachValue(list string, valid func(string) bool) error {
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); !valid(value) {
+			return fmt.Errorf("invalid value %q", value)
+		}
+	}
+	return nil
+}
+
+// isNumber is a function to check whether s is a non-negative decimal number.
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
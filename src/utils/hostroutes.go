@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strings"
+)
+
+// HostRoute is a structure of a route of the main routing table of the host, e.g. the default one through the router.
+type HostRoute struct {
+	Destination string
+	Gateway     string
+	Device      string
+}
+
+// String is a method that formats the route the way 'ip route' prints it, e.g. "default via 192.168.1.1 dev eth0".
+func (r HostRoute) String() string {
+	route := r.Destination
+	if route == "0.0.0.0/0" || route == "::/0" {
+		route = "default"
+	}
+	if len(r.Gateway) > 0 {
+		route += " via " + r.Gateway
+	}
+	if len(r.Device) > 0 {
+		route += " dev " + r.Device
+	}
+	return route
+}
+
+// HostRoutes is a function that lists the IPv4 and IPv6 routes of the main routing table of the host. Linux only.
+func HostRoutes() ([]HostRoute, error) {
+	var routes []HostRoute
+	for _, family := range []string{"-4", "-6"} {
+		stdout, err := Output("ip", family, "route", "show", "table", "main")
+		if err != nil {
+			// IPv6 could be disabled
+			if family == "-4" {
+				return nil, err
+			}
+			continue
+		}
+
+		routes = append(routes, ParseHostRoutes(string(stdout), family == "-6")...)
+	}
+
+	return routes, nil
+}
+
+// ParseHostRoutes is a function that parses the output of 'ip route show'. The destinations are turned into networks,
+// e.g. default into 0.0.0.0/0, or ::/0 if ipv6.
+func ParseHostRoutes(output string, ipv6 bool) []HostRoute {
+	var routes []HostRoute
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// the unreachable, blackhole and other typed routes carry no traffic anywhere
+		switch fields[0] {
+		case "unreachable", "blackhole", "prohibit", "throw", "local", "broadcast", "multicast":
+			continue
+		}
+
+		route := HostRoute{Destination: fields[0]}
+		switch {
+		case route.Destination == "default" && ipv6:
+			route.Destination = "::/0"
+		case route.Destination == "default":
+			route.Destination = "0.0.0.0/0"
+		case !strings.Contains(route.Destination, "/") && ipv6:
+			route.Destination += "/128"
+		case !strings.Contains(route.Destination, "/"):
+			route.Destination += "/32"
+		}
+
+		for i := 1; i < len(fields)-1; i++ {
+			switch fields[i] {
+			case "via":
+				route.Gateway = fields[i+1]
+			case "dev":
+				route.Device = fields[i+1]
+			}
+		}
+		routes = append(routes, route)
+	}
+
+	return routes
+}
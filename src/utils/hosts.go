@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// HostsFile is a path to the hosts file of the system.
+var HostsFile = "/etc/hosts"
+
+const hostsBegin = "# BEGIN fvpn blocklist"
+const hostsEnd = "# END fvpn blocklist"
+
+var domainPattern = regexp.MustCompile(`^(?i)[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?(\.[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?)+$`)
+
+func init() {
+	if Os == "windows" {
+		HostsFile = os.Getenv("SystemRoot") + `\System32\drivers\etc\hosts`
+	} else if IsTermux() {
+		// /etc is read-only without root, the programs of Termux read its own prefix
+		HostsFile = os.Getenv("PREFIX") + "/etc/hosts"
+	}
+}
+
+// IsDomain is a function to check whether s is a valid domain name, e.g. ads.example.com.
+func IsDomain(s string) bool {
+	return len(s) <= 253 && domainPattern.MatchString(s)
+}
+
+// ParseHosts is a function that reads the domains out of a hosts-file formatted list, e.g. "0.0.0.0 ads.example.com".
+// Lines with a single domain are accepted too. Localhost entries are skipped.
+func ParseHosts(r io.Reader) ([]string, error) {
+	var domains []string
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			domain := strings.ToLower(strings.TrimSuffix(field, "."))
+			if IsDomain(domain) && !strings.HasPrefix(domain, "localhost") && !strings.HasPrefix(domain, "ip6-") {
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	return domains, scanner.Err()
+}
+
+// WriteHostsBlocklist is a function that replaces the fvpn section of HostsFile with the entries resolving domains to 0.0.0.0 and ::.
+// The section is removed if domains is empty. Other entries of the hosts file are kept as is.
+func WriteHostsBlocklist(domains []string) error {
+	data, err := os.ReadFile(HostsFile)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	inside := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		switch strings.TrimSpace(line) {
+		case hostsBegin:
+			inside = true
+			continue
+		case hostsEnd:
+			inside = false
+			continue
+		}
+
+		if !inside {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(domains) > 0 {
+		lines = append(lines, hostsBegin)
+		for _, domain := range domains {
+			lines = append(lines, "0.0.0.0 "+domain, ":: "+domain)
+		}
+		lines = append(lines, hostsEnd)
+	}
+
+	// the hosts file usually needs root to write, so it's left alone unless the managed section changes
+	content := strings.Join(lines, "\n") + "\n"
+	if content == string(data) {
+		return nil
+	}
+
+	fStat, err := os.Stat(HostsFile)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(HostsFile, []byte(content), fStat.Mode())
+	if errors.Is(err, fs.ErrPermission) && HostsFile == "/etc/hosts" {
+		// without root, the section is written by the helper elevated by polkit, like the interface
+		if elevated, helperErr := RunHelper(strings.Join(domains, "\n"), "hosts"); elevated {
+			return helperErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// NAT64WellKnownPrefix is a prefix of the NAT64 gateways that don't announce one with DNS64, see RFC 6052.
+var NAT64WellKnownPrefix = net.ParseIP("64:ff9b::")
+
+// IPv6Only is a function to check whether the host has an IPv6 route to the internet, but no IPv4 one, e.g. on a mobile carrier with NAT64.
+// Connecting UDP sockets only looks up the routes, so nothing is sent.
+func IPv6Only() bool {
+	return !hasRoute("udp4", "8.8.8.8:53") && hasRoute("udp6", "[2001:4860:4860::8888]:53")
+}
+
+// NAT64Prefix is a function to discover the /96 prefix of the NAT64 gateway by resolving ipv4only.arpa with DNS64, see RFC 7050.
+// Falls back to NAT64WellKnownPrefix if the resolver doesn't synthesize the address.
+func NAT64Prefix() net.IP {
+	ips, err := net.LookupIP("ipv4only.arpa")
+	if err != nil {
+		return NAT64WellKnownPrefix
+	}
+
+	for _, ip := range ips {
+		if ip.To4() != nil || len(ip) != net.IPv6len {
+			continue
+		}
+
+		// the well-known addresses of ipv4only.arpa are 192.0.0.170 and 192.0.0.171
+		if suffix := ip[12:]; suffix[0] == 192 && suffix[1] == 0 && suffix[2] == 0 && (suffix[3] == 170 || suffix[3] == 171) {
+			prefix := make(net.IP, net.IPv6len)
+			copy(prefix, ip[:12])
+			return prefix
+		}
+	}
+
+	return NAT64WellKnownPrefix
+}
+
+// SynthesizeIPv6 is a function that embeds ipv4 into the /96 NAT64 prefix, e.g. 64:ff9b::c000:aa for 192.0.0.170.
+func SynthesizeIPv6(prefix net.IP, ipv4 net.IP) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.To16())
+	copy(ip[12:], ipv4.To4())
+	return ip
+}
+
+// IPv6Endpoint is a function that rewrites the Wireguard endpoint, e.g. 1.2.3.4:51820, to be reachable over IPv6.
+// IPv6 addresses are kept as they are, IPv4 ones are embedded into the NAT64 prefix, and host names are resolved to IPv6 addresses,
+// which DNS64 synthesizes for IPv4-only hosts.
+func IPv6Endpoint(endpoint string, prefix net.IP) (string, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			return endpoint, nil
+		}
+		return net.JoinHostPort(SynthesizeIPv6(prefix, ip).String(), port), nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			return net.JoinHostPort(ip.String(), port), nil
+		}
+	}
+
+	for _, ip := range ips {
+		return net.JoinHostPort(SynthesizeIPv6(prefix, ip).String(), port), nil
+	}
+
+	return "", fmt.Errorf("no addresses found for %s", host)
+}
+
+// NetworkFingerprint is a function that lists the addresses of the network interfaces which are up, except the loopback and exclude,
+// e.g. the Wireguard interface, to tell when the host moves to another network, e.g. from Wi-Fi to a mobile carrier.
+func NetworkFingerprint(exclude string) string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	var addresses []string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Name == exclude {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			addresses = append(addresses, iface.Name+" "+addr.String())
+		}
+	}
+
+	sort.Strings(addresses)
+	return strings.Join(addresses, ", ")
+}
+
+func hasRoute(network string, address string) bool {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"net"
+	"time"
+)
+
+// MeasureLatency is a function that opens count TCP connections to address one after another and times the handshakes,
+// as ICMP needs root. Returns the round trip times of the successful connections and the number of the failed ones.
+func MeasureLatency(address string, count int, timeout time.Duration) ([]time.Duration, int) {
+	var rtts []time.Duration
+	lost := 0
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			lost++
+			continue
+		}
+
+		rtts = append(rtts, time.Since(start))
+		conn.Close()
+	}
+
+	return rtts, lost
+}
+
+// Jitter is a function that calculates the mean difference between the consecutive round trip times.
+func Jitter(rtts []time.Duration) time.Duration {
+	if len(rtts) < 2 {
+		return 0
+	}
+
+	var sum time.Duration
+	for i := 1; i < len(rtts); i++ {
+		diff := rtts[i] - rtts[i-1]
+		if diff < 0 {
+			diff = -diff
+		}
+		sum += diff
+	}
+
+	return sum / time.Duration(len(rtts)-1)
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Locale is a function that returns the locale used to format dates and times, e.g. en_US,
+// taken from the LC_ALL, LC_TIME or LANG environment variables.
+func Locale() string {
+	for _, name := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if value := os.Getenv(name); len(value) > 0 {
+			locale, _, _ := strings.Cut(value, ".")
+			locale, _, _ = strings.Cut(locale, "@")
+			return locale
+		}
+	}
+
+	return ""
+}
+
+// DateTimeLayout is a function that returns the time layout for locale with its day and month order and 12 or 24-hour clock.
+// The ISO 8601 layout is used for the C and POSIX locales and when the locale is not set.
+func DateTimeLayout(locale string) string {
+	language, _, _ := strings.Cut(locale, "_")
+
+	switch {
+	case locale == "en_US" || locale == "en_PH":
+		return "01/02/2006 3:04:05 PM"
+	case locale == "en_CA":
+		return "2006-01-02 3:04:05 PM"
+	case locale == "en_AU" || locale == "en_NZ" || locale == "en_IN":
+		return "02/01/2006 3:04:05 PM"
+	case len(language) == 0 || language == "C" || language == "POSIX":
+		return "2006-01-02 15:04:05"
+	}
+
+	switch language {
+	case "ja", "zh", "ko", "hu", "lt", "sv":
+		return "2006-01-02 15:04:05"
+	case "de", "ru", "uk", "be", "pl", "cs", "sk", "fi", "nb", "no", "da", "tr", "ro", "kk":
+		return "02.01.2006 15:04:05"
+	case "nl":
+		return "02-01-2006 15:04:05"
+	}
+
+	return "02/01/2006 15:04:05"
+}
+
+// FormatTime is a function that formats t in the local timezone with the time layout of the user's locale.
+func FormatTime(t time.Time) string {
+	return t.Local().Format(DateTimeLayout(Locale()))
+}
@@ -8,8 +8,12 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,10 +23,40 @@ import (
 
 var Verbose bool
 
+// Fake is whether the connection is only recorded to the local state files without any networking,
+// set with 'fvpn --fake-backend' or FVPN_FAKE=1, e.g. to test the scripts driving fvpn in CI containers without privileges.
+var Fake bool
+
+// JSONOutput is whether the commands print machine-readable JSON instead of tables and colored text, set with 'fvpn --output json'.
+var JSONOutput bool
+
+// AppVersion is a version of Forest CLI reported to the back-end along with the device info. It is assigned by main on start.
+var AppVersion string
+
 const Os = runtime.GOOS
 
 // ApiHost is a hostname of Forest VPN back-end API that is stored in an environment variable and assigned during the build with ldflags.
-const ApiHost = "api.forestvpn.com"
+// It could be pointed elsewhere with --api-url, e.g. to 'fvpn dev mock-server'.
+var ApiHost = "api.forestvpn.com"
+
+// ApiScheme is a scheme of the requests to ApiHost.
+var ApiScheme = "https"
+
+// SetApiURL is a function that points ApiHost and ApiScheme to the API at rawURL, e.g. http://127.0.0.1:8080.
+// A bare host name keeps the https scheme.
+func SetApiURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(u.Host) == 0 {
+		u, err = url.Parse("https://" + rawURL)
+	}
+
+	if err != nil || len(u.Host) == 0 || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid API URL: %s", rawURL)
+	}
+
+	ApiHost, ApiScheme = u.Host, u.Scheme
+	return nil
+}
 
 var InfoLogger = log.New(os.Stdout, "[DEBUG] ", log.Ldate|log.Ltime|log.Lmsgprefix)
 
@@ -147,6 +181,20 @@ func HumanizeDuration(duration time.Duration) string {
 		int64(remainingMinutes), int64(remainingSeconds))
 }
 
+// ParseDays is a function that parses the duration like time.ParseDuration, but also in days or weeks, e.g. 90d or 2w.
+func ParseDays(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); err == nil && strings.HasSuffix(value, suffix) {
+			if n < 0 {
+				return 0, fmt.Errorf("negative duration: %s", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	return time.ParseDuration(value)
+}
+
 func GetLocalTimezone() (string, error) {
 	b, err := ioutil.ReadFile("/etc/timezone")
 
@@ -164,3 +212,92 @@ func GetHttpClient(retries int) *http.Client {
 	retryClient.Logger = nil
 	return retryClient.StandardClient()
 }
+
+// SplitPatterns is a function that splits comma-separated patterns given as command arguments, e.g. ["de-*,nl-*", "fi-*"].
+func SplitPatterns(args []string) []string {
+	var patterns []string
+	for _, arg := range args {
+		for _, p := range strings.Split(arg, ",") {
+			if p = strings.TrimSpace(p); len(p) > 0 {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+	return patterns
+}
+
+// GlobMatch is a function to check whether any of candidates matches any of shell patterns, e.g. "de-*", case-insensitively.
+// Returns true if there are no patterns.
+func GlobMatch(patterns []string, candidates ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		for _, c := range candidates {
+			if matched, _ := path.Match(strings.ToLower(p), strings.ToLower(c)); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// slugFolder folds the accented letters common in city names to their ASCII counterparts.
+var slugFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n", "ß", "s",
+)
+
+// Slug is a function that returns a short identifier of the city in the country with the ISO code given, e.g. de-fra for Frankfurt.
+// The city part is the first three letters of a single-word name, or the initials of a longer one
+// followed by the letters of its last word, e.g. us-sfr for San Francisco.
+func Slug(countryCode string, city string) string {
+	words := strings.FieldsFunc(slugFolder.Replace(strings.ToLower(city)), func(r rune) bool { return r < 'a' || r > 'z' })
+
+	var abbr string
+	if len(words) > 1 {
+		for _, word := range words {
+			abbr += word[:1]
+		}
+		abbr += words[len(words)-1][1:]
+	} else if len(words) == 1 {
+		abbr = words[0]
+	}
+
+	if len(abbr) > 3 {
+		abbr = abbr[:3]
+	}
+	return strings.ToLower(countryCode) + "-" + abbr
+}
+
+// WriteFileAtomic is a function that writes data to a temporary file next to path and renames it over path,
+// so the readers never see the file partially written.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
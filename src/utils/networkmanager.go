@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// IsNetworkManager is a function to determine whether NetworkManager is available and running.
+func IsNetworkManager() bool {
+	stdout, err := exec.Command("nmcli", "-t", "-f", "RUNNING", "general").Output()
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(string(stdout)) == "running"
+}
+
+// NMConnectionExists is a function to check whether NetworkManager has a connection with the given name.
+func NMConnectionExists(name string) bool {
+	stdout, err := exec.Command("nmcli", "-t", "-f", "NAME", "connection", "show").Output()
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if strings.TrimSpace(line) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NMImport is a function that imports the Wireguard configuration file as a NetworkManager connection.
+// The connection is named after the file, e.g. fvpn0 for fvpn0.conf, and replaces the existing one if any.
+// Autoconnect is disabled, so the connection is only toggled by the user.
+func NMImport(name string, configPath string) error {
+	if NMConnectionExists(name) {
+		if err := NMDelete(name); err != nil {
+			return err
+		}
+	}
+
+	if err := exec.Command("nmcli", "connection", "import", "type", "wireguard", "file", configPath).Run(); err != nil {
+		return err
+	}
+
+	return exec.Command("nmcli", "connection", "modify", name, "connection.autoconnect", "no").Run()
+}
+
+// NMDelete is a function that removes the NetworkManager connection with the given name.
+func NMDelete(name string) error {
+	return exec.Command("nmcli", "connection", "delete", name).Run()
+}
@@ -6,7 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
-	"github.com/getsentry/sentry-go"
+	"github.com/forestvpn/cli/crash"
 )
 
 func Commit() error {
@@ -44,7 +44,7 @@ func Firewall(wiregaurdInterface string) error {
 		return err
 	}
 	if err := exec.Command("uci", "del_list", fmt.Sprintf("firewall.wan.network=%s", wiregaurdInterface)).Run(); err != nil {
-		sentry.CaptureException(err)
+		crash.CaptureException(err)
 		return err
 	}
 	if err := exec.Command("uci", "add_list", fmt.Sprintf("firewall.wan.network=%s", wiregaurdInterface)).Run(); err != nil {
@@ -67,7 +67,7 @@ func Network(
 	wireguardAllowedIps []string) error {
 	err := exec.Command("uci", "delete", fmt.Sprintf("network.%s", wiregaurdInterface)).Run()
 	if err != nil {
-		sentry.CaptureException(err)
+		crash.CaptureException(err)
 		if Verbose {
 			InfoLogger.Println(err)
 		}
@@ -95,7 +95,7 @@ func Network(
 
 	err = exec.Command("uci", "delete", "network.wgserver").Run()
 	if err != nil {
-		sentry.CaptureException(err)
+		crash.CaptureException(err)
 		if Verbose {
 			InfoLogger.Println(err)
 		}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// PolkitPolicyFile is a file with the polkit action of fvpn, installed with 'fvpn system install'.
+var PolkitPolicyFile = "/usr/share/polkit-1/actions/org.forestvpn.policy"
+
+// PolkitAction is the polkit action authorizing HelperPath, the only program fvpn runs with pkexec.
+const PolkitAction = "org.forestvpn.helper"
+
+// PolkitTimeout is a time given to the elevated commands, as it includes entering the password into the authentication prompt.
+const PolkitTimeout = 2 * time.Minute
+
+// PolkitPolicy is a function that renders the polkit policy authorizing the active users to run the helper at path after authenticating as the administrator.
+// The authorization isn't kept, as the helper changes the network and the hosts file of the system: every change prompts for the password.
+func PolkitPolicy(path string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE policyconfig PUBLIC "-//freedesktop//DTD PolicyKit Policy Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/PolicyKit/1/policyconfig.dtd">
+<policyconfig>
+  <vendor>ForestVPN</vendor>
+  <vendor_url>https://forestvpn.com/</vendor_url>
+  <action id="%s">
+    <description>Change the ForestVPN connection and blocklist</description>
+    <message>Authentication is required to change the ForestVPN connection or the blocked domains</message>
+    <defaults>
+      <allow_any>auth_admin</allow_any>
+      <allow_inactive>auth_admin</allow_inactive>
+      <allow_active>auth_admin</allow_active>
+    </defaults>
+    <annotate key="org.freedesktop.policykit.exec.path">%s</annotate>
+  </action>
+</policyconfig>
+`, PolkitAction, path)
+}
+
+// InstallPolkitPolicy is a function that installs the running fvpn as HelperPath and writes the PolkitPolicy for it to PolkitPolicyFile.
+func InstallPolkitPolicy() error {
+	if Os != "linux" {
+		return fmt.Errorf("polkit is not supported on %s", Os)
+	}
+
+	for _, command := range []string{"wg-quick", "wg"} {
+		if _, err := exec.LookPath(command); err != nil {
+			return fmt.Errorf("%s not found, install wireguard-tools with 'fvpn setup deps' first", command)
+		}
+	}
+
+	if err := installHelper(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(PolkitPolicyFile, []byte(PolkitPolicy(HelperPath)), 0644)
+}
+
+// installHelper is a function that copies the running executable to HelperPath, owned by root and writable by root only.
+func installHelper() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(executable)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	if err := os.MkdirAll(filepath.Dir(HelperPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(HelperPath), ".fvpn-helper-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, source); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Chmod(0755); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), HelperPath)
+}
+
+// elevate is a function that replaces the commands changing the fvpn0 interface with HelperPath run by pkexec when fvpn runs as a regular user on Linux
+// and the polkit policy is installed, so they trigger the authentication prompt instead of requiring the whole CLI under sudo.
+// The configuration files are passed to the helper as input, as it doesn't read the files of the user.
+// Returns the command as is otherwise, e.g. for reading commands like 'wg show', which would prompt on every status check.
+func elevate(input string, name string, args []string) (string, []string, string, time.Duration, error) {
+	helperArgs, file := helperCommand(name, args)
+	if len(helperArgs) == 0 {
+		return name, args, input, CommandTimeout, nil
+	}
+
+	pkexec, ok := pkexecPath()
+	if !ok {
+		return name, args, input, CommandTimeout, nil
+	}
+
+	if len(file) > 0 {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return name, args, input, CommandTimeout, err
+		}
+		input = string(data)
+	}
+
+	return pkexec, append([]string{HelperPath}, helperArgs...), input, PolkitTimeout, nil
+}
+
+// pkexecPath is a function to get the path of pkexec if the changes to the system are elevated with it, i.e. fvpn runs as a regular user on Linux
+// with the polkit policy installed.
+func pkexecPath() (string, bool) {
+	if Os != "linux" || os.Geteuid() == 0 {
+		return "", false
+	}
+
+	if _, err := os.Stat(PolkitPolicyFile); err != nil {
+		return "", false
+	}
+
+	pkexec, err := exec.LookPath("pkexec")
+	return pkexec, err == nil
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PowerCheckTTL is a time the results of OnBattery and IsMetered are reused for,
+// as the daemon checks them every few seconds and they spawn pmset, busctl or powershell.
+const PowerCheckTTL = time.Minute
+
+// cachedCheck is a structure to reuse the result of check for PowerCheckTTL.
+type cachedCheck struct {
+	check     func() bool
+	mu        sync.Mutex
+	value     bool
+	checkedAt time.Time
+}
+
+func (c *cachedCheck) get() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checkedAt.IsZero() || time.Since(c.checkedAt) >= PowerCheckTTL {
+		c.value, c.checkedAt = c.check(), time.Now()
+	}
+
+	return c.value
+}
+
+var (
+	onBatteryCheck = &cachedCheck{check: onBattery}
+	isMeteredCheck = &cachedCheck{check: isMetered}
+)
+
+// OnBattery is a function to check whether the system runs on battery: discharging batteries in sysfs on Linux, 'pmset -g batt' on macOS
+// and Win32_Battery on Windows. Returns false if it can't be detected, e.g. on desktops. The result is reused for PowerCheckTTL.
+func OnBattery() bool {
+	return onBatteryCheck.get()
+}
+
+func onBattery() bool {
+	switch Os {
+	case "linux":
+		supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+		for _, supply := range supplies {
+			kind, _ := os.ReadFile(filepath.Join(supply, "type"))
+			status, _ := os.ReadFile(filepath.Join(supply, "status"))
+			if strings.TrimSpace(string(kind)) == "Battery" && strings.TrimSpace(string(status)) == "Discharging" {
+				return true
+			}
+		}
+	case "darwin":
+		stdout, err := Output("pmset", "-g", "batt")
+		return err == nil && strings.Contains(string(stdout), "'Battery Power'")
+	case "windows":
+		// BatteryStatus 1 is discharging
+		stdout, err := Output("powershell", "-NoProfile", "-Command", "(Get-CimInstance Win32_Battery).BatteryStatus")
+		return err == nil && strings.TrimSpace(string(stdout)) == "1"
+	}
+
+	return false
+}
+
+// IsMetered is a function to check whether the internet connection is metered, e.g. LTE: the Metered property of NetworkManager on Linux
+// and the cost of the connection profile on Windows. Returns false if it can't be detected, e.g. on macOS.
+// The result is reused for PowerCheckTTL.
+func IsMetered() bool {
+	return isMeteredCheck.get()
+}
+
+func isMetered() bool {
+	switch Os {
+	case "linux":
+		// NMMetered: 1 is yes, 3 is guess-yes
+		stdout, err := Output("busctl", "get-property", "org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager", "org.freedesktop.NetworkManager", "Metered")
+		value := strings.TrimSpace(string(stdout))
+		return err == nil && (value == "u 1" || value == "u 3")
+	case "windows":
+		stdout, err := Output("powershell", "-NoProfile", "-Command",
+			"[Windows.Networking.Connectivity.NetworkInformation,Windows.Networking.Connectivity,ContentType=WindowsRuntime]::GetInternetConnectionProfile().GetConnectionCost().NetworkCostType")
+		cost := strings.TrimSpace(string(stdout))
+		return err == nil && (cost == "Fixed" || cost == "Variable")
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+)
+
+// ProbeHTTPS is a function that sends HEAD requests to url until it responds, but no longer than timeout.
+// Any response counts, as it proves the traffic gets through.
+func ProbeHTTPS(url string, timeout time.Duration) error {
+	client := http.Client{Timeout: 5 * time.Second}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		resp, err := client.Head(url)
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+
+		if time.Now().Add(time.Second).After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
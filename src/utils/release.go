@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxReleaseAsset is a limit of the size of the release assets downloaded by FetchReleaseAsset.
+const maxReleaseAsset = 64 << 20
+
+// SHA256File is a function to get the hex encoded SHA-256 checksum of the file at path.
+func SHA256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SHA256 is a function to get the hex encoded SHA-256 checksum of data.
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseChecksums is a function that reads the checksums.txt manifest published with the releases into a map of file names to checksums.
+func ParseChecksums(r io.Reader) (map[string]string, error) {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+
+	return checksums, scanner.Err()
+}
+
+// FetchReleaseAsset is a function to download the release asset at url.
+func FetchReleaseAsset(url string) ([]byte, error) {
+	client := http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxReleaseAsset))
+}
+
+// ExtractFile is a function that reads the file named name out of the tar.gz or zip archive.
+func ExtractFile(archive []byte, name string) ([]byte, error) {
+	if bytes.HasPrefix(archive, []byte("PK")) {
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range reader.File {
+			if path.Base(file.Name) == name {
+				f, err := file.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer f.Close()
+				return io.ReadAll(io.LimitReader(f, maxReleaseAsset))
+			}
+		}
+
+		return nil, fmt.Errorf("no %s in the archive", name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no %s in the archive", name)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == name {
+			return io.ReadAll(io.LimitReader(reader, maxReleaseAsset))
+		}
+	}
+}
+
+// ReleaseAsset is a structure describing the archive of a release for a platform.
+type ReleaseAsset struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// ReleaseManifest is a structure describing a release for every platform, produced with 'fvpn release manifest'.
+// Signature is an ed25519 signature of the manifest encoded as JSON without the signature.
+type ReleaseManifest struct {
+	Version   string         `json:"version"`
+	Assets    []ReleaseAsset `json:"assets"`
+	Signature []byte         `json:"signature,omitempty"`
+}
+
+// NewReleaseManifest is a factory function that builds the ReleaseManifest of version out of the checksums of the archives
+// named by goreleaser, e.g. fvpn_linux_amd64.tar.gz. The other files, e.g. packages, are left out.
+func NewReleaseManifest(version string, checksums map[string]string, baseURL string) ReleaseManifest {
+	manifest := ReleaseManifest{Version: version}
+	for name, checksum := range checksums {
+		platform := strings.TrimSuffix(strings.TrimSuffix(name, ".tar.gz"), ".zip")
+		parts := strings.Split(platform, "_")
+		if platform == name || len(parts) != 3 || parts[0] != "fvpn" {
+			continue
+		}
+
+		manifest.Assets = append(manifest.Assets, ReleaseAsset{
+			OS:     parts[1],
+			Arch:   parts[2],
+			URL:    baseURL + version + "/" + name,
+			SHA256: checksum,
+		})
+	}
+
+	sort.Slice(manifest.Assets, func(i, j int) bool {
+		return manifest.Assets[i].URL < manifest.Assets[j].URL
+	})
+
+	return manifest
+}
+
+// Asset is a method to find the asset for os and arch.
+func (m ReleaseManifest) Asset(os string, arch string) (ReleaseAsset, bool) {
+	for _, asset := range m.Assets {
+		if asset.OS == os && asset.Arch == arch {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// Sign is a method to sign the manifest with key.
+func (m *ReleaseManifest) Sign(key ed25519.PrivateKey) error {
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+
+	m.Signature = ed25519.Sign(key, payload)
+	return nil
+}
+
+// Verify is a method to check the manifest is signed with the private key of publicKey.
+func (m ReleaseManifest) Verify(publicKey ed25519.PublicKey) error {
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+
+	if len(m.Signature) == 0 || !ed25519.Verify(publicKey, payload, m.Signature) {
+		return errors.New("invalid signature of the release manifest")
+	}
+
+	return nil
+}
+
+// FetchReleaseManifest is a function to download the release manifest of version at url
+// and check it's signed with the private key of publicKey.
+func FetchReleaseManifest(url string, version string, publicKey ed25519.PublicKey) (ReleaseManifest, error) {
+	var manifest ReleaseManifest
+	data, err := FetchReleaseAsset(url)
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid release manifest: %s", err)
+	}
+
+	if err := manifest.Verify(publicKey); err != nil {
+		return manifest, err
+	}
+
+	if manifest.Version != version {
+		return manifest, fmt.Errorf("release manifest is of %s instead of %s", manifest.Version, version)
+	}
+
+	return manifest, nil
+}
+
+func (m ReleaseManifest) payload() ([]byte, error) {
+	m.Signature = nil
+	return json.Marshal(m)
+}
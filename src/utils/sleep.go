@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// WakeDetector is a structure to detect that the system has been suspended between the calls of Woke.
+// The monotonic clock of Go stops while the system sleeps, but the wall clock doesn't, so a sleep shows up as
+// a wall clock gap way longer than the interval between the calls.
+// The wall clock is a fallback for the platforms without the power events of WatchWake, i.e. macOS and Windows,
+// and for Linux without logind, as it's also fooled by the clock set forward.
+type WakeDetector struct {
+	// Threshold is a wall clock gap between the calls after which the system is considered woken up.
+	Threshold time.Duration
+	last      time.Time
+	wakes     <-chan struct{}
+}
+
+// NewWakeDetector is a factory function that returns the WakeDetector expecting to be called more often than threshold.
+func NewWakeDetector(threshold time.Duration) *WakeDetector {
+	return &WakeDetector{Threshold: threshold, last: time.Now().Round(0)}
+}
+
+// Watch is a method to detect the wakes with the power events of WatchWake instead of the wall clock until ctx is done.
+// Returns the error if the power events aren't available, the wall clock is compared then.
+func (d *WakeDetector) Watch(ctx context.Context) error {
+	wakes, err := WatchWake(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.wakes = wakes
+	return nil
+}
+
+// Woke is a method to check whether the system has woken up since the last call.
+func (d *WakeDetector) Woke() bool {
+	return d.WokeAt(time.Now())
+}
+
+// WokeAt is a method to check whether the system has woken up between the last call and now.
+func (d *WakeDetector) WokeAt(now time.Time) bool {
+	// Round(0) strips the monotonic reading, so the wall clock is compared
+	now = now.Round(0)
+	woke := !d.last.IsZero() && now.Sub(d.last) > d.Threshold
+	d.last = now
+	if d.wakes == nil {
+		return woke
+	}
+
+	select {
+	case _, ok := <-d.wakes:
+		if !ok {
+			// the power events stopped, e.g. logind restarted
+			d.wakes = nil
+			return woke
+		}
+		return true
+	default:
+		return false
+	}
+}
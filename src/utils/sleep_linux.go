@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// WatchWake is a function that sends on the returned channel as the system wakes up from sleep, until ctx is done.
+// The PrepareForSleep signal of logind is followed with 'gdbus monitor', the channel is closed once the monitor exits.
+func WatchWake(ctx context.Context) (<-chan struct{}, error) {
+	cmd := exec.CommandContext(ctx, "gdbus", "monitor", "--system", "--dest", "org.freedesktop.login1", "--object-path", "/org/freedesktop/login1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	wakes := make(chan struct{}, 1)
+	go func() {
+		defer close(wakes)
+		defer cmd.Wait()
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			// PrepareForSleep (true,) is sent before the sleep, PrepareForSleep (false,) after the wake
+			if !strings.Contains(scanner.Text(), ".PrepareForSleep (false,)") {
+				continue
+			}
+
+			select {
+			case wakes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return wakes, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package utils
+
+import (
+	"context"
+	"errors"
+)
+
+// WatchWake is a function that sends on the returned channel as the system wakes up from sleep, until ctx is done.
+// IOKit and Windows power events aren't subscribed to, so WakeDetector compares the wall clock on macOS and Windows.
+func WatchWake(ctx context.Context) (<-chan struct{}, error) {
+	return nil, errors.New("power events are only supported with logind on Linux")
+}
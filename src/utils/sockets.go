@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Socket is a structure describing a connected socket read from /proc/net.
+type Socket struct {
+	Proto  string
+	Remote net.IP
+	Port   int
+	Inode  string
+}
+
+// tcpConnecting are the states of /proc/net/tcp sockets that send packets to the remote address: ESTABLISHED, SYN_SENT and CLOSE_WAIT.
+var tcpConnecting = map[string]bool{"01": true, "02": true, "08": true}
+
+// ConnectedSockets is a function that reads TCP and UDP sockets with a remote address from /proc/net on Linux.
+func ConnectedSockets() ([]Socket, error) {
+	if Os != "linux" {
+		return nil, errors.New("sockets could only be listed on Linux")
+	}
+
+	var sockets []Socket
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		file, err := os.Open("/proc/net/" + proto)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		s, err := ParseProcNet(file, proto)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		sockets = append(sockets, s...)
+	}
+
+	return sockets, nil
+}
+
+// ParseProcNet is a function that parses /proc/net/tcp, tcp6, udp or udp6 formatted table, skipping sockets without a remote address.
+func ParseProcNet(r io.Reader, proto string) ([]Socket, error) {
+	var sockets []Socket
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		if strings.HasPrefix(proto, "tcp") && !tcpConnecting[fields[3]] {
+			continue
+		}
+
+		remote, port, err := parseProcNetAddress(fields[2])
+		if err != nil {
+			return nil, err
+		}
+
+		if remote.IsUnspecified() {
+			continue
+		}
+
+		sockets = append(sockets, Socket{Proto: strings.TrimSuffix(proto, "6"), Remote: remote, Port: port, Inode: fields[9]})
+	}
+
+	return sockets, scanner.Err()
+}
+
+// parseProcNetAddress is a function that decodes the address of /proc/net table, e.g. 0100007F:0035.
+// The address is stored as 32-bit words in the host byte order, little-endian on all supported architectures.
+func parseProcNetAddress(s string) (net.IP, int, error) {
+	host, port, found := strings.Cut(s, ":")
+	if !found {
+		return nil, 0, fmt.Errorf("invalid address: %s", s)
+	}
+
+	b, err := hex.DecodeString(host)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address: %s", s)
+	}
+
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+
+	p, err := strconv.ParseInt(port, 16, 32)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return net.IP(b), int(p), nil
+}
+
+// SocketProcesses is a function that maps the inodes of sockets to the processes owning them, e.g. "curl (1234)".
+// Processes of other users are only visible to root.
+func SocketProcesses() map[string]string {
+	processes := make(map[string]string)
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+
+		pid := strings.Split(fd, "/")[2]
+		comm, _ := os.ReadFile("/proc/" + pid + "/comm")
+		processes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = fmt.Sprintf("%s (%s)", strings.TrimSpace(string(comm)), pid)
+	}
+
+	return processes
+}
+
+// RouteInterface is a function that calls the 'ip route get' shell command to get the interface the packets to ip leave through.
+func RouteInterface(ip net.IP) (string, error) {
+	stdout, err := Output("ip", "route", "get", ip.String())
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(stdout))
+	for i, field := range fields {
+		if field == "dev" && i+1 < len(fields) {
+			return fields[i+1], nil
+		}
+	}
+
+	return "", fmt.Errorf("no route to %s", ip)
+}
+
+// IsLAN is a function to check whether ip is a loopback, private, link-local or multicast address that never leaves the local network.
+func IsLAN(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast()
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StatusPage is a structure of the summary served by Statuspage-compatible status pages at /api/v2/summary.json.
+type StatusPage struct {
+	Status struct {
+		Indicator   string `json:"indicator"`
+		Description string `json:"description"`
+	} `json:"status"`
+	Incidents             []Incident `json:"incidents"`
+	ScheduledMaintenances []Incident `json:"scheduled_maintenances"`
+}
+
+// Incident is a structure describing an unresolved incident or a scheduled maintenance of the StatusPage.
+type Incident struct {
+	Name           string     `json:"name"`
+	Status         string     `json:"status"`
+	Impact         string     `json:"impact"`
+	ScheduledFor   *time.Time `json:"scheduled_for"`
+	ScheduledUntil *time.Time `json:"scheduled_until"`
+	Components     []struct {
+		Name string `json:"name"`
+	} `json:"components"`
+}
+
+// Affects is a method to check whether the incident names any of the terms, e.g. the city or the country of the location, in its title or components.
+// Incidents without components are considered to affect everything.
+func (i Incident) Affects(terms ...string) bool {
+	if len(i.Components) == 0 {
+		return true
+	}
+
+	names := []string{i.Name}
+	for _, component := range i.Components {
+		names = append(names, component.Name)
+	}
+
+	for _, name := range names {
+		for _, term := range terms {
+			if len(term) > 0 && strings.Contains(strings.ToLower(name), strings.ToLower(term)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// FetchStatusPage is a function to get the summary of the Statuspage-compatible status page at pageURL.
+func FetchStatusPage(pageURL string) (StatusPage, error) {
+	var page StatusPage
+	client := http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get(strings.TrimSuffix(pageURL, "/") + "/api/v2/summary.json")
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return page, fmt.Errorf("status page responded with %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// sparkBlocks are the characters of Sparkline from the lowest to the highest value.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// TerminalSize is a function to get the number of columns and rows of the terminal attached to the standard input.
+// It falls back to the COLUMNS and LINES variables, and then to 80x24.
+func TerminalSize() (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if stdout, err := cmd.Output(); err == nil {
+		fields := strings.Fields(string(stdout))
+		if len(fields) == 2 {
+			rows, errRows := strconv.Atoi(fields[0])
+			columns, errColumns := strconv.Atoi(fields[1])
+			if errRows == nil && errColumns == nil && rows > 0 && columns > 0 {
+				return columns, rows
+			}
+		}
+	}
+
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || columns <= 0 {
+		columns = 80
+	}
+
+	rows, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil || rows <= 0 {
+		rows = 24
+	}
+
+	return columns, rows
+}
+
+// Sparkline is a function that draws the last width of values as a line of block characters scaled from 0 to the highest value.
+// Negative values, e.g. the samples with every probe lost, are drawn as blanks.
+func Sparkline(values []float64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	var highest float64
+	for _, value := range values {
+		if value > highest {
+			highest = value
+		}
+	}
+
+	var line strings.Builder
+	for _, value := range values {
+		switch {
+		case value < 0:
+			line.WriteRune(' ')
+		case highest == 0:
+			line.WriteRune(sparkBlocks[0])
+		default:
+			line.WriteRune(sparkBlocks[int(value/highest*float64(len(sparkBlocks)-1)+0.5)])
+		}
+	}
+
+	return line.String()
+}
+
+// SVGPoints is a function that scales the values from 0 to the highest one into the points of an SVG polyline of width and height,
+// spread evenly from left to right. Negative values, e.g. the samples with every probe lost, are left out.
+func SVGPoints(values []float64, width int, height int) string {
+	var highest float64
+	for _, value := range values {
+		if value > highest {
+			highest = value
+		}
+	}
+
+	step := float64(width)
+	if len(values) > 1 {
+		step = float64(width) / float64(len(values)-1)
+	}
+
+	points := make([]string, 0, len(values))
+	for i, value := range values {
+		if value < 0 {
+			continue
+		}
+
+		y := float64(height)
+		if highest > 0 {
+			y -= value / highest * float64(height)
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", float64(i)*step, y))
+	}
+
+	return strings.Join(points, " ")
+}
+
+// HeatColor is a function that colors the text by percent, e.g. of the connection quality: green from 80, yellow from 50 and red below.
+// The text is left as is with NO_COLOR set.
+func HeatColor(percent int, text string) string {
+	if len(os.Getenv("NO_COLOR")) > 0 {
+		return text
+	}
+
+	color := "31"
+	if percent >= 80 {
+		color = "32"
+	} else if percent >= 50 {
+		color = "33"
+	}
+	return "\033[" + color + "m" + text + "\033[0m"
+}
+
+// ProgressBar is a function that draws percent from 0 to 100 as a bar of width characters.
+func ProgressBar(percent int, width int) string {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+
+	filled := percent * width / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// IsTermux is a function to determine whether cli is running in Termux on Android, where the Wireguard interface can't be created without root.
+func IsTermux() bool {
+	return len(os.Getenv("TERMUX_VERSION")) > 0 || strings.Contains(os.Getenv("PREFIX"), "com.termux")
+}
+
+// ProcessAlive is a function to check whether the process with pid is running.
+func ProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	return process.Signal(syscall.Signal(0)) == nil
+}
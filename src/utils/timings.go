@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Timings is whether the time spent in the auth refresh, the API calls and the system commands is summarized after the command,
+// set with 'fvpn --timings'.
+var Timings bool
+
+// Timing is a structure of a step of the command timed with --timings: its kind (auth, api or exec), e.g. "GET /v1/locations/".
+type Timing struct {
+	Kind     string
+	Name     string
+	Duration time.Duration
+}
+
+var timings struct {
+	sync.Mutex
+	steps []Timing
+}
+
+// RecordTiming is a function that records the step of the kind started at started, if Timings is set.
+// It's meant to be deferred, e.g. defer RecordTiming("exec", "wg-quick up", time.Now()).
+func RecordTiming(kind string, name string, started time.Time) {
+	if !Timings {
+		return
+	}
+
+	timings.Lock()
+	defer timings.Unlock()
+	timings.steps = append(timings.steps, Timing{Kind: kind, Name: name, Duration: time.Since(started)})
+}
+
+// RecordedTimings is a function that returns the steps recorded so far in order.
+func RecordedTimings() []Timing {
+	timings.Lock()
+	defer timings.Unlock()
+	return append([]Timing(nil), timings.steps...)
+}
+
+// PrintTimings is a function that prints the recorded steps as a table to w, followed by the totals per kind and the total of the command.
+func PrintTimings(w io.Writer, total time.Duration) {
+	steps := RecordedTimings()
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Kind", "Step", "Time"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+
+	totals := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	for _, step := range steps {
+		table.Append([]string{step.Kind, step.Name, formatTiming(step.Duration)})
+		totals[step.Kind] += step.Duration
+		counts[step.Kind]++
+	}
+
+	if len(steps) > 0 {
+		table.Render()
+	}
+
+	for _, kind := range []string{"auth", "api", "exec"} {
+		if counts[kind] > 0 {
+			fmt.Fprintf(w, "%s: %s in %d calls\n", kind, formatTiming(totals[kind]), counts[kind])
+		}
+	}
+	fmt.Fprintf(w, "total: %s\n", formatTiming(total))
+}
+
+// formatTiming is a function that rounds the duration to milliseconds, e.g. 1.234s or 56ms, or to microseconds below a millisecond.
+func formatTiming(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
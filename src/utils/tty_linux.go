@@ -0,0 +1,9 @@
+package utils
+
+import "golang.org/x/sys/unix"
+
+// isTerminal is a function to check whether the file descriptor is a terminal rather than a pipe, a file or /dev/null.
+func isTerminal(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	return err == nil
+}
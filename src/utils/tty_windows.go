@@ -0,0 +1,9 @@
+package utils
+
+import "golang.org/x/sys/windows"
+
+// isTerminal is a function to check whether the handle is a console rather than a pipe, a file or NUL.
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(fd), &mode) == nil
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// TailscaleInterface is the name of the interface created by tailscaled on Linux.
+const TailscaleInterface = "tailscale0"
+
+// TailscaleNetworks are the networks of the tailnet routed through the Tailscale interface.
+var TailscaleNetworks = []string{"100.64.0.0/10", "fd7a:115c:a1e0::/48"}
+
+// Tunnel is a structure representing another VPN interface of the host, e.g. a corporate WireGuard one or Tailscale,
+// with the networks routed through it.
+type Tunnel struct {
+	Interface string
+	Kind      string
+	Networks  []string
+}
+
+// Default is a method to check whether the tunnel takes all the traffic, i.e. it's routed 0.0.0.0/0 or ::/0.
+func (t Tunnel) Default() bool {
+	for _, network := range t.Networks {
+		if network == "0.0.0.0/0" || network == "::/0" {
+			return true
+		}
+	}
+	return false
+}
+
+// Tunnels is a function that lists the WireGuard and Tailscale interfaces of the host except the own one.
+// The WireGuard interfaces are listed by 'wg show interfaces', which requires root, so none are found otherwise.
+func Tunnels(own string) ([]Tunnel, error) {
+	var tunnels []Tunnel
+	if _, err := net.InterfaceByName(TailscaleInterface); err == nil {
+		tunnels = append(tunnels, Tunnel{Interface: TailscaleInterface, Kind: "Tailscale", Networks: TailscaleNetworks})
+	}
+
+	stdout, err := exec.Command("wg", "show", "interfaces").Output()
+	if err != nil {
+		return tunnels, nil
+	}
+
+	for _, name := range strings.Fields(string(stdout)) {
+		if name == own || name == TailscaleInterface || name == DarwinInterface(own) {
+			continue
+		}
+
+		allowedIPs, err := exec.Command("wg", "show", name, "allowed-ips").Output()
+		if err != nil {
+			return nil, err
+		}
+
+		tunnels = append(tunnels, Tunnel{Interface: name, Kind: "WireGuard", Networks: ParseAllowedIPs(string(allowedIPs))})
+	}
+
+	return tunnels, nil
+}
+
+// ParseAllowedIPs is a function that collects the networks of all the peers from the output of 'wg show <interface> allowed-ips'.
+func ParseAllowedIPs(output string) []string {
+	var networks []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		// the first field is the public key of the peer
+		for _, network := range fields[1:] {
+			if _, _, err := net.ParseCIDR(network); err != nil || seen[network] {
+				continue
+			}
+			seen[network] = true
+			networks = append(networks, network)
+		}
+	}
+
+	return networks
+}
+
+// DarwinInterface is a function that returns the utunN name wg-quick has given to the interface on macOS, if any.
+func DarwinInterface(wiregaurdInterface string) string {
+	if Os != "darwin" {
+		return ""
+	}
+
+	name, err := os.ReadFile("/var/run/wireguard/" + wiregaurdInterface + ".name")
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(name))
+}
+
+// FreeRoutingTable is a function that returns the first routing table from start that has no routes and no rules, e.g. for a tunnel
+// to keep its routes apart from the main table. wg-quick uses 51820 for both its table and firewall mark.
+func FreeRoutingTable(start int) (int, error) {
+	used := make(map[string]bool)
+	for _, args := range [][]string{{"-4", "route", "show", "table", "all"}, {"-6", "route", "show", "table", "all"}, {"-4", "rule", "show"}, {"-6", "rule", "show"}} {
+		stdout, err := exec.Command("ip", args...).Output()
+		if err != nil {
+			if args[0] == "-4" {
+				return 0, err
+			}
+			continue
+		}
+
+		for _, line := range strings.Split(string(stdout), "\n") {
+			fields := strings.Fields(line)
+			for i := 0; i < len(fields)-1; i++ {
+				if fields[i] == "table" || fields[i] == "lookup" || fields[i] == "fwmark" {
+					used[fields[i+1]] = true
+				}
+			}
+		}
+	}
+
+	for table := start; ; table++ {
+		if !used[strconv.Itoa(table)] && !used[fmt.Sprintf("0x%x", table)] {
+			return table, nil
+		}
+	}
+}
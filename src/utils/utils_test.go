@@ -1,6 +1,21 @@
 package utils_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -58,3 +73,932 @@ func TestHumanizeDuration(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, actual)
 	}
 }
+
+func TestMQTTPublishPacket(t *testing.T) {
+	expected := []byte{0x31, 0x0d, 0x00, 0x09, 'f', 'v', 'p', 'n', '/', 's', 't', 'a', 't', 'u', 'p'}
+	actual := utils.MQTTPublishPacket("fvpn/stat", []byte("up"), true)
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestMQTTPublishPacketRemainingLength(t *testing.T) {
+	payload := bytes.Repeat([]byte{'x'}, 200)
+	actual := utils.MQTTPublishPacket("t", payload, false)
+
+	// 3 bytes of topic plus 200 bytes of payload are encoded as 0xcb 0x01
+	expected := []byte{0x30, 0xcb, 0x01}
+	if !bytes.Equal(expected, actual[:3]) {
+		t.Errorf("expected %v, got %v", expected, actual[:3])
+	}
+}
+
+func TestMQTTConnectPacketCredentials(t *testing.T) {
+	packet := utils.MQTTConnectPacket("fvpn", "user", "secret")
+
+	// the connect flags follow the fixed header (2 bytes), protocol name (6 bytes) and protocol level (1 byte)
+	if flags := packet[9]; flags != 0xc2 {
+		t.Errorf("expected connect flags %#x, got %#x", 0xc2, flags)
+	}
+}
+
+func TestRunSurfacesOutput(t *testing.T) {
+	err := utils.Run("sh", "-c", "echo 'Warning: config is world accessible' >&2; echo 'resolvconf: command not found' >&2; exit 127")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	expected := "sh: resolvconf: command not found"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	patterns := utils.SplitPatterns([]string{"de-*,nl-*", "Hel*"})
+
+	for _, candidate := range []string{"DE-Frankfurt", "nl-amsterdam", "helsinki"} {
+		if !utils.GlobMatch(patterns, candidate) {
+			t.Errorf("expected %q to match %v", candidate, patterns)
+		}
+	}
+
+	if utils.GlobMatch(patterns, "fi-helsinki-2", "us-new-york") {
+		t.Errorf("expected no match for %v", patterns)
+	}
+
+	if !utils.GlobMatch(nil, "anything") {
+		t.Error("expected empty patterns to match anything")
+	}
+}
+
+func TestDateTimeLayout(t *testing.T) {
+	date := time.Date(2023, time.March, 4, 17, 5, 6, 0, time.UTC)
+	cases := map[string]string{
+		"":      "2023-03-04 17:05:06",
+		"C":     "2023-03-04 17:05:06",
+		"en_US": "03/04/2023 5:05:06 PM",
+		"en_GB": "04/03/2023 17:05:06",
+		"de_DE": "04.03.2023 17:05:06",
+		"ja_JP": "2023-03-04 17:05:06",
+	}
+
+	for locale, expected := range cases {
+		if actual := date.Format(utils.DateTimeLayout(locale)); actual != expected {
+			t.Errorf("%s: expected %q, got %q", locale, expected, actual)
+		}
+	}
+}
+
+func TestObserveClock(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	if _, ok := utils.ObserveClock(resp, time.Now()); ok {
+		t.Error("expected no skew without the Date header")
+	}
+
+	resp.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	skew, ok := utils.ObserveClock(resp, time.Now())
+	if !ok {
+		t.Fatal("expected the skew out of the Date header")
+	}
+
+	if skew < 59*time.Minute || skew > 61*time.Minute {
+		t.Errorf("expected local clock to be an hour ahead, got %s", skew)
+	}
+
+	err := utils.ExplainClockSkew(errors.New("token used before issued"))
+	if !strings.HasPrefix(err.Error(), "system clock is 1 hours") || !strings.Contains(err.Error(), "ahead") {
+		t.Errorf("expected the error to explain the skew, got %q", err)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	list := "# ads\n0.0.0.0 ads.example.com tracker.example.com\n127.0.0.1 localhost\nmalware.example.org. # inline\n"
+	domains, err := utils.ParseHosts(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"ads.example.com", "tracker.example.com", "malware.example.org"}
+	if !reflect.DeepEqual(domains, expected) {
+		t.Errorf("expected %v, got %v", expected, domains)
+	}
+}
+
+func TestWriteHostsBlocklist(t *testing.T) {
+	hostsFile := utils.HostsFile
+	t.Cleanup(func() { utils.HostsFile = hostsFile })
+
+	utils.HostsFile = filepath.Join(t.TempDir(), "hosts")
+	original := "127.0.0.1 localhost\n"
+	if err := os.WriteFile(utils.HostsFile, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := utils.WriteHostsBlocklist([]string{"ads.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(utils.HostsFile)
+	if !strings.Contains(string(data), "0.0.0.0 ads.example.com") {
+		t.Errorf("expected blocked domain in hosts file, got %q", data)
+	}
+
+	// the unchanged section isn't written again
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(utils.HostsFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.WriteHostsBlocklist([]string{"ads.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(utils.HostsFile); err != nil {
+		t.Fatal(err)
+	} else if !info.ModTime().Equal(past) {
+		t.Errorf("expected the hosts file to be left alone, modified at %s", info.ModTime())
+	}
+
+	if err := utils.WriteHostsBlocklist(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ = os.ReadFile(utils.HostsFile)
+	if string(data) != original {
+		t.Errorf("expected hosts file to be restored to %q, got %q", original, data)
+	}
+}
+
+func TestValidateWireguardConfig(t *testing.T) {
+	key := "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	config := `[Interface]
+Address = 10.8.0.2/32,fd00::2/128
+PrivateKey = ` + key + `
+DNS = 1.1.1.1,1.0.0.1
+Table = 51821
+FwMark = 51821
+PostUp = ip -4 rule add fwmark 0/0xffffffff table 51821 priority 32000; ip -4 rule add table main suppress_prefixlength 0 priority 31999
+PostDown = ip -4 rule del fwmark 0/0xffffffff table 51821 priority 32000 || true
+
+[Peer]
+AllowedIPs = 0.0.0.0/0, ::/0
+Endpoint = de-fra.example.com:51820
+PublicKey = ` + key + `
+PersistentKeepalive = 25
+`
+	if err := utils.ValidateWireguardConfig(config); err != nil {
+		t.Errorf("expected the configuration written by fvpn to be valid, got %s", err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		config string
+	}{
+		{"command in PostUp", "[Interface]\nPostUp = ip -4 rule add table 1 priority 1; chmod u+s /bin/sh\n"},
+		{"PreUp", "[Interface]\nPreUp = ip -4 rule add fwmark 0/0xffffffff table 1 priority 1\n"},
+		{"SaveConfig", "[Interface]\nSaveConfig = true\n"},
+		{"substitution in DNS", "[Interface]\nDNS = $(id)\n"},
+		{"invalid key", "[Interface]\nPrivateKey = /etc/shadow\n"},
+		{"invalid endpoint", "[Peer]\nEndpoint = example.com\n"},
+		{"setting outside a section", "Address = 10.8.0.2/32\n"},
+		{"unknown section", "[Interface]\n[Hooks]\nAddress = 10.8.0.2/32\n"},
+	} {
+		if err := utils.ValidateWireguardConfig(c.config); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestPolkitPolicy(t *testing.T) {
+	policy := utils.PolkitPolicy("/usr/libexec/fvpn-helper")
+	if strings.Count(policy, "<action ") != 1 || !strings.Contains(policy, ">/usr/libexec/fvpn-helper<") {
+		t.Errorf("expected a single action for the helper, got %s", policy)
+	}
+
+	if strings.Contains(policy, "auth_admin_keep") {
+		t.Errorf("expected the authorization not to be kept, got %s", policy)
+	}
+}
+
+func TestEgressCountry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "fl=1f1\nip=192.0.2.1\nloc=de\ntls=TLSv1.3\n")
+	}))
+	defer server.Close()
+
+	utils.GeoIPURL = server.URL
+	country, err := utils.EgressCountry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if country != "DE" {
+		t.Errorf("expected DE, got %s", country)
+	}
+}
+
+func TestParseProcNet(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0035 00000000:0000 0A 00000000:00000000 00:00000000 00000000   101        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0F02000A:C350 D8B43A8E:01BB 01 00000000:00000000 02:00000D6C 00000000  1000        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 0F02000A:C351 D8B43A8E:01BB 06 00000000:00000000 03:00000D6C 00000000  1000        0 1003 3 0000000000000000 20 4 30 10 -1
+`
+	sockets, err := utils.ParseProcNet(strings.NewReader(table), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) != 1 {
+		t.Fatalf("expected 1 established socket, got %d", len(sockets))
+	}
+
+	if sockets[0].Remote.String() != "142.58.180.216" || sockets[0].Port != 443 || sockets[0].Inode != "1002" {
+		t.Errorf("unexpected socket %+v", sockets[0])
+	}
+}
+
+func TestFetchStatusPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/summary.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"status":{"indicator":"minor","description":"Partially Degraded Service"},
+			"incidents":[{"name":"Packet loss","status":"investigating","impact":"minor","components":[{"name":"Frankfurt"}]}],
+			"scheduled_maintenances":[{"name":"Network upgrade","status":"scheduled","components":[]}]}`)
+	}))
+	defer server.Close()
+
+	page, err := utils.FetchStatusPage(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Incidents) != 1 || !page.Incidents[0].Affects("Frankfurt", "Germany") || page.Incidents[0].Affects("Helsinki", "Finland") {
+		t.Errorf("expected the incident to affect Frankfurt only, got %+v", page.Incidents)
+	}
+
+	if len(page.ScheduledMaintenances) != 1 || !page.ScheduledMaintenances[0].Affects("Helsinki") {
+		t.Errorf("expected the maintenance without components to affect every location, got %+v", page.ScheduledMaintenances)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	manifest := "0a1b  fvpn_linux_amd64.tar.gz\n2C3D  fvpn_windows_amd64.zip\n"
+	checksums, err := utils.ParseChecksums(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checksums["fvpn_linux_amd64.tar.gz"] != "0a1b" || checksums["fvpn_windows_amd64.zip"] != "2c3d" {
+		t.Errorf("unexpected checksums %v", checksums)
+	}
+}
+
+func TestExtractFile(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{"README.md": "readme", "fvpn": "binary"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+
+	data, err := utils.ExtractFile(archive.Bytes(), "fvpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "binary" {
+		t.Errorf("expected binary, got %q", data)
+	}
+}
+
+func TestReleaseManifest(t *testing.T) {
+	checksums := map[string]string{
+		"fvpn_linux_amd64.tar.gz": "0a1b",
+		"fvpn_windows_386.zip":    "2c3d",
+		"fvpn_linux_amd64.deb":    "4e5f",
+	}
+
+	manifest := utils.NewReleaseManifest("v1.2.3", checksums, "https://example.com/download/")
+	if len(manifest.Assets) != 2 {
+		t.Fatalf("expected archives only, got %+v", manifest.Assets)
+	}
+
+	asset, ok := manifest.Asset("linux", "amd64")
+	if !ok || asset.SHA256 != "0a1b" || asset.URL != "https://example.com/download/v1.2.3/fvpn_linux_amd64.tar.gz" {
+		t.Errorf("unexpected asset %+v", asset)
+	}
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.Sign(private); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.Verify(public); err != nil {
+		t.Error(err)
+	}
+
+	manifest.Assets[0].SHA256 = "tampered"
+	if err := manifest.Verify(public); err == nil {
+		t.Error("expected tampered manifest to fail verification")
+	}
+}
+
+func TestFetchReleaseManifest(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := utils.NewReleaseManifest("v1.2.3", map[string]string{"fvpn_linux_amd64.tar.gz": "0a1b"}, "https://example.com/download/")
+	if err := manifest.Sign(private); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(manifest)
+	}))
+	defer server.Close()
+
+	fetched, err := utils.FetchReleaseManifest(server.URL, "v1.2.3", public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if asset, ok := fetched.Asset("linux", "amd64"); !ok || asset.SHA256 != "0a1b" {
+		t.Errorf("unexpected asset %+v", asset)
+	}
+
+	if _, err := utils.FetchReleaseManifest(server.URL, "v1.2.4", public); err == nil {
+		t.Error("expected the manifest of another version to be rejected")
+	}
+
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := utils.FetchReleaseManifest(server.URL, "v1.2.3", other); err == nil {
+		t.Error("expected the manifest signed with another key to be rejected")
+	}
+}
+
+func TestWakeDetector(t *testing.T) {
+	detector := utils.NewWakeDetector(30 * time.Second)
+	now := time.Now()
+
+	if detector.WokeAt(now.Add(5 * time.Second)) {
+		t.Error("expected no wake after 5 seconds")
+	}
+
+	if !detector.WokeAt(now.Add(time.Hour)) {
+		t.Error("expected wake after an hour")
+	}
+
+	if detector.WokeAt(now.Add(time.Hour + 5*time.Second)) {
+		t.Error("expected no wake right after the previous one")
+	}
+}
+
+func TestProbeHTTPS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	if err := utils.ProbeHTTPS(server.URL, time.Second); err != nil {
+		t.Errorf("expected any response to pass the probe, got %s", err)
+	}
+
+	server.Close()
+	if err := utils.ProbeHTTPS(server.URL, time.Second); err == nil {
+		t.Error("expected the probe of a closed server to fail")
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"100GB":   100 * 1000 * 1000 * 1000,
+		"1.5 tb":  1500 * 1000 * 1000 * 1000,
+		"512MiB":  512 << 20,
+		"2048":    2048,
+		" 10 KB ": 10000,
+	} {
+		n, err := utils.ParseBytes(input)
+		if err != nil || n != expected {
+			t.Errorf("ParseBytes(%q) = %d, %v; want %d", input, n, err, expected)
+		}
+	}
+
+	for _, input := range []string{"", "GB", "-1GB", "10XB", "0"} {
+		if _, err := utils.ParseBytes(input); err == nil {
+			t.Errorf("ParseBytes(%q) should fail", input)
+		}
+	}
+
+	if s := utils.FormatBytes(1500 * 1000 * 1000); s != "1.5 GB" {
+		t.Errorf("FormatBytes = %s", s)
+	}
+	if s := utils.FormatBytes(999); s != "999 B" {
+		t.Errorf("FormatBytes = %s", s)
+	}
+}
+
+func TestMeasureLatency(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	rtts, lost := utils.MeasureLatency(listener.Addr().String(), 3, time.Second)
+	if len(rtts) != 3 || lost != 0 {
+		t.Errorf("expected 3 round trips, got %d with %d lost", len(rtts), lost)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+	if rtts, lost := utils.MeasureLatency(address, 2, time.Second); len(rtts) != 0 || lost != 2 {
+		t.Errorf("expected 2 lost, got %d round trips with %d lost", len(rtts), lost)
+	}
+
+	if jitter := utils.Jitter([]time.Duration{10 * time.Millisecond, 14 * time.Millisecond, 12 * time.Millisecond}); jitter != 3*time.Millisecond {
+		t.Errorf("Jitter = %s", jitter)
+	}
+}
+
+func TestIPv6Endpoint(t *testing.T) {
+	prefix := utils.NAT64WellKnownPrefix
+	if ip := utils.SynthesizeIPv6(prefix, net.ParseIP("192.0.0.170")); ip.String() != "64:ff9b::c000:aa" {
+		t.Errorf("SynthesizeIPv6 = %s", ip)
+	}
+
+	for endpoint, expected := range map[string]string{
+		"192.0.2.1:51820":       "[64:ff9b::c000:201]:51820",
+		"[2001:db8::1]:51820":   "[2001:db8::1]:51820",
+		"[::ffff:192.0.2.1]:53": "[64:ff9b::c000:201]:53",
+	} {
+		rewritten, err := utils.IPv6Endpoint(endpoint, prefix)
+		if err != nil || rewritten != expected {
+			t.Errorf("IPv6Endpoint(%s) = %s, %v; want %s", endpoint, rewritten, err, expected)
+		}
+	}
+
+	if _, err := utils.IPv6Endpoint("192.0.2.1", prefix); err == nil {
+		t.Error("expected an error for the endpoint without port")
+	}
+}
+
+func TestHARRecordReplay(t *testing.T) {
+	redacted := utils.RedactJSON([]byte(`{"email":"user@example.com","wireguard":{"priv_key":"secret","peers":[{"ps_key":"secret","endpoint":"192.0.2.1:51820"}]}}`))
+	if strings.Contains(redacted, "secret") || strings.Contains(redacted, "user@example.com") || !strings.Contains(redacted, "192.0.2.1:51820") {
+		t.Errorf("RedactJSON = %s", redacted)
+	}
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"call":%d,"token":"secret"}`, calls)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "session.har")
+	recorder := utils.NewHARRecorder(path)
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v2/locations/", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		started := time.Now()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if err := recorder.Record(started, req, nil, resp, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("the capture leaks the secrets: %s", data)
+	}
+
+	har, err := utils.ReadHAR(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay := httptest.NewServer(utils.NewHARReplayer(har))
+	defer replay.Close()
+
+	for _, expected := range []string{`"call":1`, `"call":2`, `"call":2`} {
+		resp, err := http.Get(replay.URL + "/v2/locations/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected %s in the replayed response, got %s", expected, body)
+		}
+	}
+
+	resp, err := http.Get(replay.URL + "/v2/devices/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for the request not in the capture, got %d", resp.StatusCode)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	u, _ := url.Parse("https://api.example.com/v2/auth/?next=%2Fhome&access_token=secret&Email=user%40example.com&page=2")
+	expected := "https://api.example.com/v2/auth/?next=%2Fhome&access_token=%5Bredacted%5D&Email=%5Bredacted%5D&page=2"
+	if redacted := utils.RedactURL(u); redacted != expected {
+		t.Errorf("RedactURL = %s, want %s", redacted, expected)
+	}
+
+	u, _ = url.Parse("https://api.example.com/v2/locations/")
+	if redacted := utils.RedactURL(u); redacted != u.String() {
+		t.Errorf("expected the URL without query to be kept, got %s", redacted)
+	}
+}
+
+func TestRedactBodyForm(t *testing.T) {
+	redacted := utils.RedactBody([]byte("grant_type=password&email=user%40example.com&password=secret"), "application/x-www-form-urlencoded; charset=utf-8")
+	if expected := "grant_type=password&email=%5Bredacted%5D&password=%5Bredacted%5D"; redacted != expected {
+		t.Errorf("RedactBody = %s, want %s", redacted, expected)
+	}
+}
+
+func TestRedactBodyOther(t *testing.T) {
+	body := "--boundary\r\nContent-Disposition: form-data; name=\"token\"\r\n\r\nsecret\r\n--boundary--\r\n"
+	redacted := utils.RedactBody([]byte(body), "multipart/form-data; boundary=boundary")
+	if strings.Contains(redacted, "secret") || redacted != fmt.Sprintf("[redacted %d bytes of multipart/form-data]", len(body)) {
+		t.Errorf("RedactBody = %s", redacted)
+	}
+
+	if redacted := utils.RedactBody([]byte(`{"token":"secret","id":1}`), "text/plain"); redacted != `{"id":1,"token":"[redacted]"}` {
+		t.Errorf("expected the JSON body to be redacted by field whatever its type, got %s", redacted)
+	}
+
+	if redacted := utils.RedactBody(nil, "text/plain"); len(redacted) > 0 {
+		t.Errorf("expected the empty body to stay empty, got %s", redacted)
+	}
+}
+
+func TestExcludeNetworks(t *testing.T) {
+	networks, err := utils.ExcludeNetworks([]string{"10.0.0.0/8"}, []string{"10.0.0.0/9", "10.192.0.0/10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(networks, []string{"10.128.0.0/10"}) {
+		t.Errorf("ExcludeNetworks = %v", networks)
+	}
+
+	networks, err = utils.ExcludeNetworks([]string{"0.0.0.0/0", "::/0"}, utils.PrivateNetworks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ip := range []string{"1.1.1.1", "172.32.0.1", "192.169.0.1", "2001:4860::8888"} {
+		if !containsIP(networks, ip) {
+			t.Errorf("expected %s to be routed through the tunnel", ip)
+		}
+	}
+	for _, ip := range []string{"10.1.2.3", "172.16.5.4", "192.168.1.1", "169.254.0.1", "fd00::1", "fe80::1"} {
+		if containsIP(networks, ip) {
+			t.Errorf("expected %s to be excluded from the tunnel", ip)
+		}
+	}
+
+	cidrs, err := utils.ParseCIDRs("203.0.113.7/24, 2001:db8::1,")
+	if err != nil || !reflect.DeepEqual(cidrs, []string{"203.0.113.0/24", "2001:db8::1/128"}) {
+		t.Errorf("ParseCIDRs = %v, %v", cidrs, err)
+	}
+	if _, err := utils.ParseCIDRs("203.0.113.0/33"); err == nil {
+		t.Error("expected an error for the invalid network")
+	}
+}
+
+func containsIP(networks []string, address string) bool {
+	ip := net.ParseIP(address)
+	for _, cidr := range networks {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOverlaps(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{"0.0.0.0/0", "172.17.0.0/16", true},
+		{"172.17.0.0/16", "172.17.3.0/24", true},
+		{"128.0.0.0/1", "172.17.0.0/16", true},
+		{"0.0.0.0/1", "172.17.0.0/16", false},
+		{"::/0", "172.17.0.0/16", false},
+		{"::/0", "fd00:dead::/64", true},
+	} {
+		if actual := utils.Overlaps(c.a, c.b); actual != c.expected {
+			t.Errorf("Overlaps(%s, %s) = %t", c.a, c.b, actual)
+		}
+	}
+}
+
+func TestParseDefaultGateway(t *testing.T) {
+	route := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0010A8C0	00000000	0001	0	0	0	00F0FFFF	0	0	0
+eth0	00000000	0110A8C0	0003	0	0	0	00000000	0	0	0
+`
+	gateway, err := utils.ParseDefaultGateway(strings.NewReader(route))
+	if err != nil {
+		t.Error(err)
+	} else if gateway.String() != "192.168.16.1" {
+		t.Errorf("expected 192.168.16.1, got %s", gateway)
+	}
+
+	if _, err := utils.ParseDefaultGateway(strings.NewReader(route[:strings.LastIndex(route[:len(route)-1], "\n")+1])); err == nil {
+		t.Error("expected an error without the default route")
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	if !utils.ProcessAlive(os.Getpid()) {
+		t.Error("expected the test process to be alive")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	for _, c := range []struct {
+		values   []float64
+		width    int
+		expected string
+	}{
+		{[]float64{0, 50, 100}, 10, "▁▅█"},
+		{[]float64{10, 20, -1, 40}, 3, "▅ █"},
+		{[]float64{0, 0}, 5, "▁▁"},
+		{nil, 5, ""},
+	} {
+		if actual := utils.Sparkline(c.values, c.width); actual != c.expected {
+			t.Errorf("Sparkline(%v, %d) = %q, expected %q", c.values, c.width, actual, c.expected)
+		}
+	}
+}
+
+func TestSVGPoints(t *testing.T) {
+	for _, c := range []struct {
+		values   []float64
+		expected string
+	}{
+		{[]float64{0, 50, 100}, "0.0,20.0 50.0,10.0 100.0,0.0"},
+		{[]float64{10, -1, 20}, "0.0,10.0 100.0,0.0"},
+		{[]float64{0, 0}, "0.0,20.0 100.0,20.0"},
+		{nil, ""},
+	} {
+		if actual := utils.SVGPoints(c.values, 100, 20); actual != c.expected {
+			t.Errorf("SVGPoints(%v, 100, 20) = %q, expected %q", c.values, actual, c.expected)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, data := range []string{`{"state":"up"}`, `{"state":"down"}`} {
+		if err := utils.WriteFileAtomic(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		actual, _ := os.ReadFile(path)
+		if string(actual) != data {
+			t.Errorf("expected %s, got %s", data, actual)
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files left, got %d files", len(entries))
+	}
+}
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := utils.WatchFiles(ctx, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.WriteFileAtomic(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case changed := <-changes:
+		if changed != path {
+			t.Errorf("expected %s, got %s", path, changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no change reported")
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestSlug(t *testing.T) {
+	for _, c := range []struct {
+		country  string
+		city     string
+		expected string
+	}{
+		{"DE", "Frankfurt", "de-fra"},
+		{"FI", "Helsinki", "fi-hel"},
+		{"US", "San Francisco", "us-sfr"},
+		{"US", "New York", "us-nyo"},
+		{"CH", "Zürich", "ch-zur"},
+		{"BR", "São Paulo", "br-spa"},
+	} {
+		if actual := utils.Slug(c.country, c.city); actual != c.expected {
+			t.Errorf("Slug(%q, %q) = %q, expected %q", c.country, c.city, actual, c.expected)
+		}
+	}
+}
+
+func TestParseAllowedIPs(t *testing.T) {
+	output := "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\t10.0.0.0/8 172.16.0.0/12\n" +
+		"HIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw=\t(none)\n" +
+		"gN65BkIKy1eCE9pP1wdc8ROUtkHLF2PfAqYdyYBz6EA=\t10.0.0.0/8 0.0.0.0/0 ::/0\n"
+	expected := []string{"10.0.0.0/8", "172.16.0.0/12", "0.0.0.0/0", "::/0"}
+
+	networks := utils.ParseAllowedIPs(output)
+	if strings.Join(networks, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, networks)
+	}
+
+	if !(utils.Tunnel{Networks: networks}).Default() {
+		t.Error("expected the tunnel to take all the traffic")
+	}
+	if (utils.Tunnel{Networks: utils.TailscaleNetworks}).Default() {
+		t.Error("expected the tailnet not to take all the traffic")
+	}
+}
+
+func TestPrintTimings(t *testing.T) {
+	utils.Timings = true
+	defer func() { utils.Timings = false }()
+
+	utils.RecordTiming("api", "GET /v2/locations/", time.Now().Add(-120*time.Millisecond))
+	utils.RecordTiming("api", "GET /v2/geo/countries/", time.Now().Add(-80*time.Millisecond))
+	utils.RecordTiming("exec", "wg-quick up", time.Now().Add(-time.Second))
+
+	var out bytes.Buffer
+	utils.PrintTimings(&out, 2*time.Second)
+	for _, expected := range []string{"GET /v2/locations/", "wg-quick up", "in 2 calls", "total: 2s"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"90d": 90 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+		"0d":  0,
+	} {
+		actual, err := utils.ParseDays(value)
+		if err != nil {
+			t.Errorf("ParseDays(%q): %s", value, err)
+		} else if actual != expected {
+			t.Errorf("ParseDays(%q) = %s, expected %s", value, actual, expected)
+		}
+	}
+
+	for _, value := range []string{"", "d", "-1d", "1x"} {
+		if _, err := utils.ParseDays(value); err == nil {
+			t.Errorf("ParseDays(%q): expected an error", value)
+		}
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	for percent, color := range map[int]string{95: "\033[32m", 80: "\033[32m", 60: "\033[33m", 20: "\033[31m"} {
+		if actual := utils.HeatColor(percent, "bar"); actual != color+"bar\033[0m" {
+			t.Errorf("HeatColor(%d) = %q", percent, actual)
+		}
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if actual := utils.HeatColor(95, "bar"); actual != "bar" {
+		t.Errorf("expected no color with NO_COLOR, got %q", actual)
+	}
+}
+
+func TestMatchCountry(t *testing.T) {
+	for _, query := range []string{"United States", "usa", "US", "U.S.A.", "Estados Unidos", "united states of america"} {
+		if !utils.MatchCountry(query, "US", "United States") {
+			t.Errorf("expected %q to match the United States", query)
+		}
+		if !utils.MatchCountry(query, "", "United States") {
+			t.Errorf("expected %q to match the United States without the code", query)
+		}
+	}
+
+	for _, query := range []string{"DEU", "de", "Deutschland"} {
+		if !utils.MatchCountry(query, "DE", "Germany") {
+			t.Errorf("expected %q to match Germany", query)
+		}
+	}
+
+	for _, query := range []string{"Germany", "Nowhere", "GB"} {
+		if utils.MatchCountry(query, "US", "United States") {
+			t.Errorf("expected %q not to match the United States", query)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	defer func(previous bool) { utils.NonInteractive = previous }(utils.NonInteractive)
+	utils.NonInteractive = true
+
+	if err := utils.Confirm("Remove?", true); err != nil {
+		t.Errorf("expected --yes to confirm, got %s", err)
+	}
+
+	if utils.IsInteractive() {
+		t.Error("expected --non-interactive to disable the prompts")
+	}
+
+	if err := utils.Confirm("Remove?", false); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("expected the confirmation to be refused without a terminal, got %v", err)
+	}
+}
+
+func TestParseHostRoutes(t *testing.T) {
+	output := `default via 192.0.2.1 dev eth0 proto dhcp metric 100
+192.0.2.0/24 dev eth0 proto kernel scope link src 192.0.2.2
+198.51.100.7 via 192.0.2.1 dev eth0
+unreachable 203.0.113.0/24
+`
+	expected := []utils.HostRoute{
+		{Destination: "0.0.0.0/0", Gateway: "192.0.2.1", Device: "eth0"},
+		{Destination: "192.0.2.0/24", Device: "eth0"},
+		{Destination: "198.51.100.7/32", Gateway: "192.0.2.1", Device: "eth0"},
+	}
+	if routes := utils.ParseHostRoutes(output, false); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("expected %v, got %v", expected, routes)
+	}
+
+	routes := utils.ParseHostRoutes("default via fe80::1 dev wlan0 metric 600\n", true)
+	if len(routes) != 1 || routes[0].Destination != "::/0" || routes[0].String() != "default via fe80::1 dev wlan0" {
+		t.Errorf("expected the IPv6 default route, got %v", routes)
+	}
+}
+
+func TestNetworkFingerprint(t *testing.T) {
+	if fingerprint := utils.NetworkFingerprint(""); strings.Contains(fingerprint, "127.0.0.1") {
+		t.Errorf("expected the loopback to be left out, got %q", fingerprint)
+	}
+
+	if utils.NetworkFingerprint("") != utils.NetworkFingerprint("") {
+		t.Error("expected the same fingerprint on the same network")
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// WatchFiles is a function that sends the paths on the returned channel as they are written, created, replaced or removed, until ctx is done.
+// The directories of the paths are watched with inotify, so the files replaced with a rename, e.g. by WriteFileAtomic, are followed.
+func WatchFiles(ctx context.Context, paths []string) (<-chan string, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	// the non-blocking descriptor is handled by the runtime poller, so closing the file interrupts the read
+	file := os.NewFile(uintptr(fd), "inotify")
+	watched := make(map[string]bool)
+	dirs := make(map[int32]string)
+	for _, path := range paths {
+		watched[filepath.Clean(path)] = true
+		dir := filepath.Dir(path)
+		wd, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO|syscall.IN_CREATE|syscall.IN_DELETE)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		dirs[int32(wd)] = dir
+	}
+
+	changes := make(chan string)
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+
+	go func() {
+		defer close(changes)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				name := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+				path := filepath.Join(dirs[event.Wd], string(bytes.TrimRight(name, "\x00")))
+				if !watched[path] {
+					continue
+				}
+
+				select {
+				case changes <- path:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,57 @@
+//go:build !linux
+
+package utils
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchInterval is a time between the checks of WatchFiles where inotify is not available.
+const WatchInterval = 2 * time.Second
+
+// WatchFiles is a function that sends the paths on the returned channel as they are written, created, replaced or removed, until ctx is done.
+// The modification times of the paths are checked every WatchInterval.
+func WatchFiles(ctx context.Context, paths []string) (<-chan string, error) {
+	modified := func(path string) time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+
+	last := make(map[string]time.Time)
+	for _, path := range paths {
+		last[path] = modified(path)
+	}
+
+	changes := make(chan string)
+	go func() {
+		defer close(changes)
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, path := range paths {
+				if t := modified(path); !t.Equal(last[path]) {
+					last[path] = t
+					select {
+					case changes <- path:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
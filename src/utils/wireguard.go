@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WireguardTransfer is a function that calls the 'wg show <interface> transfer' shell command
+// and sums up received and sent bytes of all the peers of the Wireguard interface.
+func WireguardTransfer(wiregaurdInterface string) (int64, int64, error) {
+	var rx, tx int64
+	stdout, err := exec.Command("wg", "show", wiregaurdInterface, "transfer").Output()
+	if err != nil {
+		return rx, tx, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(stdout)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		r, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return rx, tx, err
+		}
+
+		t, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return rx, tx, err
+		}
+
+		rx += r
+		tx += t
+	}
+
+	return rx, tx, nil
+}
+
+// WireguardLatestHandshake is a function that calls the 'wg show <interface> latest-handshakes' shell command
+// and returns the most recent handshake among the peers of the Wireguard interface, or zero time if there was none.
+func WireguardLatestHandshake(wiregaurdInterface string) (time.Time, error) {
+	var latest int64
+	stdout, err := exec.Command("wg", "show", wiregaurdInterface, "latest-handshakes").Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(stdout)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		t, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if t > latest {
+			latest = t
+		}
+	}
+
+	if latest == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(latest, 0), nil
+}
+
+// WireguardBackend is a function that detects the Wireguard implementation used by wg-quick and returns its name and version:
+// the kernel module on Linux, if loaded, or wireguard-go otherwise.
+func WireguardBackend() (string, string) {
+	if Os == "windows" {
+		return "wireguard-windows", "unknown"
+	}
+
+	if _, err := os.Stat("/sys/module/wireguard"); err == nil {
+		version, err := os.ReadFile("/sys/module/wireguard/version")
+		if err != nil {
+			// built into the kernel
+			return "kernel", "unknown"
+		}
+		return "kernel", strings.TrimSpace(string(version))
+	}
+
+	if stdout, err := exec.Command("wireguard-go", "--version").Output(); err == nil {
+		return "wireguard-go", strings.TrimSpace(string(stdout))
+	}
+
+	return "none", "unknown"
+}
+
+// WireguardToolsVersion is a function that returns the version of wireguard-tools reported by 'wg --version'.
+func WireguardToolsVersion() (string, error) {
+	stdout, err := exec.Command("wg", "--version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(stdout))
+	if len(fields) < 2 {
+		return strings.TrimSpace(string(stdout)), nil
+	}
+
+	return fields[1], nil
+}
+
+// WireguardInterfaceUp is a function to check whether the Wireguard interface exists without calling wg, which requires root.
+// On macOS wg-quick names the interface utunN and keeps the mapping in /var/run/wireguard.
+func WireguardInterfaceUp(wiregaurdInterface string) bool {
+	if Os == "darwin" {
+		_, err := os.Stat("/var/run/wireguard/" + wiregaurdInterface + ".name")
+		return err == nil
+	}
+
+	_, err := net.InterfaceByName(wiregaurdInterface)
+	return err == nil
+}
+
+// WireguardEndpoints is a function that calls the 'wg show <interface> endpoints' shell command to get the IP addresses of the peers.
+func WireguardEndpoints(wiregaurdInterface string) ([]net.IP, error) {
+	var endpoints []net.IP
+	stdout, err := exec.Command("wg", "show", wiregaurdInterface, "endpoints").Output()
+	if err != nil {
+		return endpoints, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(stdout)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		host, _, err := net.SplitHostPort(fields[1])
+		if err != nil {
+			continue
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			endpoints = append(endpoints, ip)
+		}
+	}
+
+	return endpoints, nil
+}
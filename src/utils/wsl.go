@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// IsWSL2 is a function to check whether fvpn runs in the Windows Subsystem for Linux 2, whose kernel usually lacks the Wireguard module.
+func IsWSL2() bool {
+	if Os != "linux" {
+		return false
+	}
+
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+
+	r := strings.ToLower(string(release))
+	return strings.Contains(r, "microsoft") && (strings.Contains(r, "wsl2") || strings.Contains(r, "microsoft-standard"))
+}
+
+// WSLHostAddresses is a function that lists the addresses the Windows host could be reached at from WSL2:
+// the loopback with the mirrored networking, and the default gateway with the NAT one.
+func WSLHostAddresses() []string {
+	addresses := []string{"127.0.0.1"}
+
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return addresses
+	}
+	defer file.Close()
+
+	if gateway, err := ParseDefaultGateway(file); err == nil {
+		addresses = append(addresses, gateway.String())
+	}
+
+	return addresses
+}
+
+// ParseDefaultGateway is a function that parses the gateway of the default route out of /proc/net/route.
+func ParseDefaultGateway(r io.Reader) (net.IP, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+
+		gateway, err := hex.DecodeString(fields[2])
+		if err != nil || len(gateway) != 4 {
+			continue
+		}
+
+		// the addresses are in the host byte order
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(gateway))
+		return ip, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errors.New("no default route")
+}
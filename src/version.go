@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/forestvpn/cli/config"
+	"github.com/forestvpn/cli/crash"
+	"github.com/forestvpn/cli/server"
+	"github.com/forestvpn/cli/utils"
+)
+
+// commit is a hash of the commit fvpn is built from. It is assigned during the build with ldflags.
+// Falls back to the VCS information embedded by the Go toolchain.
+var commit string
+
+// buildDate is a date fvpn is built at. It is assigned during the build with ldflags.
+// Falls back to the commit time embedded by the Go toolchain.
+var buildDate string
+
+// buildFeatures is a function that lists the optional features of this build and whether they are available at runtime.
+func buildFeatures(conf config.Config) [][]string {
+	telemetry := "compiled out (notelemetry)"
+	if crash.Telemetry {
+		telemetry = fmt.Sprintf("enabled (crash-reports: %s)", conf.Get(config.CrashReports))
+	}
+
+	userspace := "not found"
+	if path, err := exec.LookPath("wireguard-go"); err == nil {
+		userspace = path
+	}
+
+	return [][]string{
+		{"telemetry", telemetry},
+		{"userspace-wg", userspace},
+		{"obfuscation", "not supported"},
+	}
+}
+
+// buildDetails is a function that lists the versions of fvpn components to be attached to bug reports.
+func buildDetails(apiHost string, daemonAddress string) [][]string {
+	hash, date := commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && len(hash) == 0:
+				hash = setting.Value
+			case setting.Key == "vcs.time" && len(date) == 0:
+				date = setting.Value
+			}
+		}
+	}
+
+	backend, backendVersion := utils.WireguardBackend()
+	tools, err := utils.WireguardToolsVersion()
+	if err != nil {
+		tools = "not found"
+	}
+
+	details := [][]string{
+		{"go", fmt.Sprintf("%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)},
+		{"commit", orUnknown(hash)},
+		{"built", orUnknown(date)},
+		{"wireguard backend", fmt.Sprintf("%s %s", backend, backendVersion)},
+		{"wireguard-tools", tools},
+		{"daemon protocol", strconv.Itoa(server.ProtocolVersion)},
+		{"api host", apiHost},
+	}
+
+	if len(daemonAddress) > 0 {
+		details = append(details, []string{"daemon", daemonAddress})
+	}
+
+	return details
+}
+
+func orUnknown(s string) string {
+	if len(s) == 0 {
+		return "unknown"
+	}
+	return s
+}